@@ -0,0 +1,252 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync/atomic"
+)
+
+/*
+AtomicBloomFilter is a bloomfilter backed by an array of unsigned 64 bit integers (with bits encoded in each one) that uses no locks at all: inserts set bits with atomic ORs and lookups use atomic loads.
+Unlike the *Async methods of the other variants it is safe for any mix of concurrent inserts and lookups, and every method is race free under the Go memory model.
+*/
+type AtomicBloomFilter struct {
+	bv     []uint64 //bitvector, only accessed atomically
+	size   uint64   //Size of bitvector. MUST BE A POWER OF 2.
+	hf     int      //Number of hash functions
+	hasher Hasher   //Produces the base hashes for each entry
+}
+
+/*
+NewAtomicBloomFilter allocates an AtomicBloomFilter with a given size (in bits) and using a certain number of hashes.
+Size must be a power of 2 and larger than 64
+*/
+func NewAtomicBloomFilter(size uint64, hf int, opts ...Option) (*AtomicBloomFilter, error) {
+	var bf AtomicBloomFilter
+	bf.size = size
+	if bf.size < 64 {
+		return nil, errors.New("Filter size must be at least 64")
+	} else if (bf.size & (bf.size - 1)) != 0 {
+		return nil, errors.New("Size must be a power of 2")
+	} else if err := checkHashes(hf); err != nil {
+		return nil, err
+	}
+	bf.bv = make([]uint64, size/64)
+	bf.hf = int(hf)
+	bf.hasher = buildOptions(opts).hasher
+	return &bf, nil
+}
+
+/*
+NewAtomicBloomFilterWithEstimates allocates an AtomicBloomFilter sized for n entries at a target false positive rate p (see EstimateParameters).
+It also returns the false positive rate expected once n entries have been inserted.
+*/
+func NewAtomicBloomFilterWithEstimates(n uint64, p float64, opts ...Option) (*AtomicBloomFilter, float64, error) {
+	size, hf, fpRate, err := EstimateParameters(n, p)
+	if err != nil {
+		return nil, 0, err
+	}
+	bf, err := NewAtomicBloomFilter(size, hf, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bf, fpRate, nil
+}
+
+/*Sets a bit and returns whether it was already set.*/
+func (bf AtomicBloomFilter) setBit(idx uint64) bool {
+	mask := uint64(1) << (idx & 63)
+	return atomic.OrUint64(&bf.bv[idx/64], mask)&mask != 0
+}
+
+func (bf AtomicBloomFilter) getBit(idx uint64) bool {
+	return atomic.LoadUint64(&bf.bv[idx/64])&(1<<(idx&63)) != 0
+}
+
+/*Looks up an entry in the AtomicBloomFilter. Returns true if a match is found, false otherwise.*/
+func (bf AtomicBloomFilter) Lookup(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2), nil
+}
+
+/*Same as Lookup, which never locks. Provided so that AtomicBloomFilter implements Filter.*/
+func (bf AtomicBloomFilter) LookupAsync(entry string) (bool, error) {
+	return bf.Lookup(entry)
+}
+
+/*LookupBytes is Lookup for a byte slice entry. It gives the same answer as Lookup(string(entry)) without converting or allocating.*/
+func (bf AtomicBloomFilter) LookupBytes(entry []byte) (bool, error) {
+	h1, h2 := baseHashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2), nil
+}
+
+/*LookupUint64 is Lookup for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (bf AtomicBloomFilter) LookupUint64(entry uint64) (bool, error) {
+	h1, h2 := uint64Hashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2), nil
+}
+
+func (bf AtomicBloomFilter) lookupHashes(h1, h2 uint64) bool {
+	for i := 0; i < bf.hf; i++ {
+		if !bf.getBit(nthHash(h1, h2, i) & (bf.size - 1)) {
+			return false
+		}
+	}
+	return true
+}
+
+/*Inserts an entry into the AtomicBloomFilter. Concurrent lookups may see the entry's bits being set one at a time.*/
+func (bf AtomicBloomFilter) Insert(entry string) error {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	bf.insertHashes(h1, h2)
+	return nil
+}
+
+/*Same as Insert, which never locks. Provided so that AtomicBloomFilter implements Filter.*/
+func (bf AtomicBloomFilter) InsertAsync(entry string) error {
+	return bf.Insert(entry)
+}
+
+/*InsertBytes is Insert for a byte slice entry. It is equivalent to Insert(string(entry)) without converting or allocating.*/
+func (bf AtomicBloomFilter) InsertBytes(entry []byte) error {
+	h1, h2 := baseHashes(bf.hasher, entry)
+	bf.insertHashes(h1, h2)
+	return nil
+}
+
+/*InsertUint64 is Insert for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (bf AtomicBloomFilter) InsertUint64(entry uint64) error {
+	h1, h2 := uint64Hashes(bf.hasher, entry)
+	bf.insertHashes(h1, h2)
+	return nil
+}
+
+/*Sets every bit of an entry and reports whether all of them were already set.*/
+func (bf AtomicBloomFilter) insertHashes(h1, h2 uint64) bool {
+	present := true
+	for i := 0; i < bf.hf; i++ {
+		if !bf.setBit(nthHash(h1, h2, i) & (bf.size - 1)) {
+			present = false
+		}
+	}
+	return present
+}
+
+/*
+TestAndInsert inserts entry and reports whether it was (probably) already present. Each bit is tested and set by a single atomic OR, so the entry is hashed once and nothing is locked.
+The guarantee is weaker than for the locking variants: of several concurrent TestAndInsert calls for the same new entry, at least one reports it absent, but more than one may (each call that sets any of its bits does).
+*/
+func (bf AtomicBloomFilter) TestAndInsert(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.insertHashes(h1, h2), nil
+}
+
+/*InsertBatch inserts every entry in entries. As the filter has no locks this is only a convenience: it is the same as inserting each entry in turn.*/
+func (bf AtomicBloomFilter) InsertBatch(entries []string) error {
+	for _, entry := range entries {
+		h1, h2 := stringHashes(bf.hasher, entry)
+		bf.insertHashes(h1, h2)
+	}
+	return nil
+}
+
+/*LookupBatch looks up every entry in entries and returns whether each one was found, in the same order.*/
+func (bf AtomicBloomFilter) LookupBatch(entries []string) ([]bool, error) {
+	results := make([]bool, len(entries))
+	for i, entry := range entries {
+		h1, h2 := stringHashes(bf.hasher, entry)
+		results[i] = bf.lookupHashes(h1, h2)
+	}
+	return results, nil
+}
+
+/*Hasher returns the hasher the filter was built with.*/
+func (bf AtomicBloomFilter) Hasher() Hasher {
+	return bf.hasher
+}
+
+/*
+WriteTo writes the filter to w in the package's binary format (see serialization.go) and returns the number of bytes written. It implements io.WriterTo.
+Words are loaded atomically a buffer at a time, so inserts running concurrently may or may not be captured.
+*/
+func (bf AtomicBloomFilter) WriteTo(w io.Writer) (int64, error) {
+	h := newFileHeader(KindAtomic, bf.size, bf.hf, 0, bf.hasher)
+	return writeFilter(w, h, func(w io.Writer) error {
+		chunk := make([]uint64, 4096)
+		for start := 0; start < len(bf.bv); start += len(chunk) {
+			n := min(len(chunk), len(bf.bv)-start)
+			for i := 0; i < n; i++ {
+				chunk[i] = atomic.LoadUint64(&bf.bv[start+i])
+			}
+			if err := writeWords(w, chunk[:n]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+/*
+ReadFrom merges the filter with a serialized one read from r and returns the number of bytes read. It implements io.ReaderFrom.
+Exactly one serialized filter is consumed from r. It may have been written by any variant with the same size, hash count and hasher.
+*/
+func (bf AtomicBloomFilter) ReadFrom(r io.Reader) (int64, error) {
+	h, payload, n, err := readFilter(r, func(h fileHeader) error {
+		return h.checkCompatible(KindAtomic, bf.size, bf.hf, bf.hasher)
+	})
+	if err != nil {
+		return n, err
+	}
+	bf.merge(h, payload)
+	return n, nil
+}
+
+func (bf AtomicBloomFilter) merge(h fileHeader, payload []byte) {
+	for i, word := range payloadWords(h, payload) {
+		if word != 0 {
+			atomic.OrUint64(&bf.bv[i], word)
+		}
+	}
+}
+
+/*MarshalBinary returns the filter in the package's binary format. It implements encoding.BinaryMarshaler (and so also works with encoding/gob).*/
+func (bf AtomicBloomFilter) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := bf.WriteTo(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+/*
+UnmarshalBinary replaces the filter with a serialized one, taking its size, hash count and hasher from data. It implements encoding.BinaryUnmarshaler.
+The filter's current hasher is kept if it matches the serialized one (which is the only way to unmarshal a filter using a keyed hasher). It must not be called concurrently with any other method.
+*/
+func (bf *AtomicBloomFilter) UnmarshalBinary(data []byte) error {
+	h, payload, err := unmarshalFilter(data, KindAtomic)
+	if err != nil {
+		return err
+	}
+	hasher, err := h.resolveHasher(bf.hasher)
+	if err != nil {
+		return err
+	}
+	fresh, err := NewAtomicBloomFilter(h.size, h.hf, WithHasher(hasher))
+	if err != nil {
+		return err
+	}
+	fresh.merge(h, payload)
+	*bf = *fresh
+	return nil
+}
+
+/*Writes the filter to a file. See WriteTo.*/
+func (bf AtomicBloomFilter) Write(filename string) error {
+	return writeFile(filename, bf.WriteTo)
+}
+
+/*Merges the filter with one loaded from a file. See ReadFrom.*/
+func (bf AtomicBloomFilter) Load(filename string) error {
+	return readFile(filename, bf.ReadFrom)
+}
@@ -0,0 +1,114 @@
+package hyperbloom
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestABFSetBit(t *testing.T) {
+	bf, err := NewAtomicBloomFilter(1048576, 4)
+	assert.Nil(t, err)
+	assert.False(t, bf.setBit(100))
+	assert.True(t, bf.setBit(100))
+	assert.True(t, bf.getBit(100))
+	assert.False(t, bf.getBit(1048575))
+}
+
+func TestNewAtomicBloomFilter(t *testing.T) {
+	bf, err := NewAtomicBloomFilter(100000, 4)
+	assert.NotNil(t, err)
+	assert.Nil(t, bf)
+
+	bf, err = NewAtomicBloomFilter(1048576, 4)
+	assert.Nil(t, err)
+	assert.NotNil(t, bf)
+
+	bf, fpRate, err := NewAtomicBloomFilterWithEstimates(1000000, 0.01)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1<<24), bf.size)
+	assert.True(t, fpRate <= 0.01)
+}
+
+/*Run with -race: concurrent inserts, lookups and writes must not race.*/
+func TestAtomicBloomFilterConcurrent(t *testing.T) {
+	bf, err := NewAtomicBloomFilter(1<<16, 4)
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				bf.Insert(fmt.Sprintf("entry-%d-%d", w, i))
+			}
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				bf.Lookup(fmt.Sprintf("entry-%d-%d", w, i))
+			}
+		}(w)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := bf.MarshalBinary()
+		assert.Nil(t, err)
+	}()
+	wg.Wait()
+	for w := 0; w < 4; w++ {
+		for i := 0; i < 1000; i++ {
+			exists, err := bf.Lookup(fmt.Sprintf("entry-%d-%d", w, i))
+			assert.Nil(t, err)
+			assert.True(t, exists)
+		}
+	}
+}
+
+/*Compares every variant with all CPUs inserting, using only the race free (locking) methods of the others.*/
+func BenchmarkParallelInsert(b *testing.B) {
+	benchmarkKinds(b, func(b *testing.B, f Filter) {
+		b.RunParallel(func(pb *testing.PB) {
+			var i uint64
+			for pb.Next() {
+				f.InsertUint64(i)
+				i++
+			}
+		})
+	})
+}
+
+func BenchmarkParallelLookup(b *testing.B) {
+	benchmarkKinds(b, func(b *testing.B, f Filter) {
+		for i := uint64(0); i < 10000; i++ {
+			f.InsertUint64(i)
+		}
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			var i uint64
+			for pb.Next() {
+				f.LookupUint64(i % 20000)
+				i++
+			}
+		})
+	})
+}
+
+/*One insert for every nine lookups.*/
+func BenchmarkParallelMixed(b *testing.B) {
+	benchmarkKinds(b, func(b *testing.B, f Filter) {
+		b.RunParallel(func(pb *testing.PB) {
+			var i uint64
+			for pb.Next() {
+				if i%10 == 0 {
+					f.InsertUint64(i)
+				} else {
+					f.LookupUint64(i)
+				}
+				i++
+			}
+		})
+	})
+}
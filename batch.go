@@ -0,0 +1,82 @@
+package hyperbloom
+
+import (
+	"slices"
+	"sync"
+)
+
+/*Hashes every entry and returns its two base hashes, interleaved.*/
+func batchHashes(h Hasher, entries []string) []uint64 {
+	hashes := make([]uint64, 0, 2*len(entries))
+	for _, entry := range entries {
+		h1, h2 := stringHashes(h, entry)
+		hashes = append(hashes, h1, h2)
+	}
+	return hashes
+}
+
+/*Hashes every entry and returns their indices into a filter of the given size, hf consecutive indices per entry.*/
+func batchIndices(h Hasher, entries []string, hf int, size uint64) []uint64 {
+	indices := make([]uint64, 0, len(entries)*hf)
+	for _, entry := range entries {
+		h1, h2 := stringHashes(h, entry)
+		for i := 0; i < hf; i++ {
+			indices = append(indices, nthHash(h1, h2, i)&(size-1))
+		}
+	}
+	return indices
+}
+
+/*
+Groups indices by the shard they fall in (a counting sort). The positions of the indices in shard s are order[offsets[s]:offsets[s+1]].
+Positions rather than indices are returned so that lookups can map each index back to its entry (position / hf).
+*/
+func groupByShard(indices []uint64, shardLen uint64, shards uint64) (order []int, offsets []int) {
+	offsets = make([]int, shards+1)
+	for _, idx := range indices {
+		offsets[idx/shardLen+1]++
+	}
+	for s := uint64(1); s <= shards; s++ {
+		offsets[s] += offsets[s-1]
+	}
+	next := make([]int, shards)
+	copy(next, offsets)
+	order = make([]int, len(indices))
+	for pos, idx := range indices {
+		shardID := idx / shardLen
+		order[next[shardID]] = pos
+		next[shardID]++
+	}
+	return order, offsets
+}
+
+/*Returns a result slice for a batch lookup with every entry initially present.*/
+func allPresent(n int) []bool {
+	results := make([]bool, n)
+	for i := range results {
+		results[i] = true
+	}
+	return results
+}
+
+/*
+Locks every shard that holds one of indices, in ascending order so that concurrent callers can't deadlock, and returns the locked shards for unlockShards.
+*/
+func lockShards(mutArr []*sync.Mutex, indices []uint64, shardLen uint64) []uint64 {
+	shards := make([]uint64, 0, len(indices))
+	for _, idx := range indices {
+		shards = append(shards, idx/shardLen)
+	}
+	slices.Sort(shards)
+	shards = slices.Compact(shards)
+	for _, s := range shards {
+		mutArr[s].Lock()
+	}
+	return shards
+}
+
+func unlockShards(mutArr []*sync.Mutex, shards []uint64) {
+	for _, s := range shards {
+		mutArr[s].Unlock()
+	}
+}
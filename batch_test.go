@@ -0,0 +1,135 @@
+package hyperbloom
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGroupByShard(t *testing.T) {
+	indices := []uint64{130, 5, 64, 70, 200, 1}
+	order, offsets := groupByShard(indices, 64, 4)
+	assert.Equal(t, []int{0, 2, 4, 5, 6}, offsets)
+	assert.Equal(t, []int{1, 5, 2, 3, 0, 4}, order)
+}
+
+func TestBatch(t *testing.T) {
+	members := make([]string, 1000)
+	for i := range members {
+		members[i] = fmt.Sprintf("member-%d", i)
+	}
+	probes := []string{"member-0", "hahaidontexist", "member-999", "foobar", "member-500", "turnips"}
+
+	for _, spec := range conformanceSpecs {
+		batched, err := NewFilter(spec)
+		assert.Nil(t, err)
+		single, err := NewFilter(spec)
+		assert.Nil(t, err)
+
+		assert.Nil(t, batched.InsertBatch(members))
+		for _, m := range members {
+			assert.Nil(t, single.Insert(m))
+		}
+
+		//Batches must leave the filter exactly as the per-item calls would
+		a, err := batched.MarshalBinary()
+		assert.Nil(t, err)
+		b, err := single.MarshalBinary()
+		assert.Nil(t, err)
+		assert.Equal(t, b, a, spec.Kind.String())
+
+		results, err := batched.LookupBatch(probes)
+		assert.Nil(t, err)
+		assert.Equal(t, []bool{true, false, true, false, true, false}, results, spec.Kind.String())
+		for i, p := range probes {
+			exists, err := single.Lookup(p)
+			assert.Nil(t, err)
+			assert.Equal(t, exists, results[i])
+		}
+
+		//Empty batches are no-ops
+		assert.Nil(t, batched.InsertBatch(nil))
+		results, err = batched.LookupBatch(nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(results))
+	}
+}
+
+func benchmarkEntries(n int) []string {
+	entries := make([]string, n)
+	for i := range entries {
+		entries[i] = fmt.Sprintf("entry-%d", i)
+	}
+	return entries
+}
+
+/*Inserts (or looks up) 1000 entries per op, either one at a time or as one batch. Lookups are of entries present in the filter.*/
+func BenchmarkInsertLoop(b *testing.B) {
+	entries := benchmarkEntries(1000)
+	benchmarkKinds(b, func(b *testing.B, f Filter) {
+		for i := 0; i < b.N; i++ {
+			for _, e := range entries {
+				f.Insert(e)
+			}
+		}
+	})
+}
+
+func BenchmarkInsertBatch(b *testing.B) {
+	entries := benchmarkEntries(1000)
+	benchmarkKinds(b, func(b *testing.B, f Filter) {
+		for i := 0; i < b.N; i++ {
+			f.InsertBatch(entries)
+		}
+	})
+}
+
+func BenchmarkLookupLoop(b *testing.B) {
+	entries := benchmarkEntries(1000)
+	benchmarkKinds(b, func(b *testing.B, f Filter) {
+		results := make([]bool, len(entries))
+		f.InsertBatch(entries)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for j, e := range entries {
+				results[j], _ = f.Lookup(e)
+			}
+		}
+	})
+}
+
+func BenchmarkLookupBatch(b *testing.B) {
+	entries := benchmarkEntries(1000)
+	benchmarkKinds(b, func(b *testing.B, f Filter) {
+		f.InsertBatch(entries)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			f.LookupBatch(entries)
+		}
+	})
+}
+
+/*The batch APIs pay off most under contention, so also compare them with every CPU inserting.*/
+func BenchmarkInsertLoopParallel(b *testing.B) {
+	entries := benchmarkEntries(1000)
+	benchmarkKinds(b, func(b *testing.B, f Filter) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				for _, e := range entries {
+					f.Insert(e)
+				}
+			}
+		})
+	})
+}
+
+func BenchmarkInsertBatchParallel(b *testing.B) {
+	entries := benchmarkEntries(1000)
+	benchmarkKinds(b, func(b *testing.B, f Filter) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				f.InsertBatch(entries)
+			}
+		})
+	})
+}
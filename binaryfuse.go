@@ -0,0 +1,185 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+const binaryFuseMaxSegmentLength = 1 << 18
+
+/*
+BinaryFuseFilter is a static filter (Graf & Lemire, "Binary Fuse Filters: Fast and Smaller Than Xor Filters") built from a complete key set with BuildBinaryFuseFilter. Like XorFilter its false positive rate is 0.4%, but it takes about 9 bits per key for large key sets (up to about 10 for small ones) and builds faster.
+It can't be inserted into, so it implements Querier (and the serialization interfaces) rather than Filter. Being immutable, it needs no locking: every method is safe for concurrent use, and the *Async methods are the same as the others.
+*/
+type BinaryFuseFilter struct {
+	fingerprints  []byte //segmentCount+2 segments of segmentLength fingerprints
+	segmentLength uint64 //Slots per segment. MUST BE A POWER OF 2.
+	segmentCount  uint64 //Segments a key's first slot can be in
+	seed          uint64 //Mixed into the key hashes
+	count         uint64 //Number of distinct keys
+	hasher        Hasher //Produces the base hashes for each key
+}
+
+/*
+BuildBinaryFuseFilter builds a BinaryFuseFilter holding keys. Duplicate keys are ignored.
+*/
+func BuildBinaryFuseFilter(keys []string, opts ...Option) (*BinaryFuseFilter, error) {
+	var bff BinaryFuseFilter
+	bff.hasher = buildOptions(opts).hasher
+	hashes, err := staticKeyHashes(bff.hasher, keys)
+	if err != nil {
+		return nil, err
+	}
+	bff.count = uint64(len(hashes))
+	bff.segmentLength, bff.segmentCount = binaryFuseLayout(bff.count)
+	bff.fingerprints = make([]byte, (bff.segmentCount+2)*bff.segmentLength)
+	if bff.seed, err = buildStatic(bff.fingerprints, hashes, bff.positions); err != nil {
+		return nil, err
+	}
+	return &bff, nil
+}
+
+/*
+Returns the segment length and count for n keys, as picked by the reference implementation for 3-wise binary fuse filters. These are sensitive: they trade the size of the filter against the odds of it failing to build.
+*/
+func binaryFuseLayout(n uint64) (uint64, uint64) {
+	segmentLength := uint64(4)
+	if n > 0 {
+		segmentLength = min(binaryFuseMaxSegmentLength, uint64(1)<<int(math.Floor(math.Log(float64(n))/math.Log(3.33)+2.25)))
+	}
+	capacity := uint64(0)
+	if n > 1 {
+		sizeFactor := math.Max(1.125, 0.875+0.25*math.Log(1000000)/math.Log(float64(n)))
+		capacity = uint64(math.Round(float64(n) * sizeFactor))
+	}
+	segments := (capacity + segmentLength - 1) / segmentLength
+	return segmentLength, max(1, segments, 3) - 2
+}
+
+/*
+Returns a mixed hash's slots: one in each of three consecutive segments. The first segment is picked from the high bits of the hash, and the offsets within the other two are perturbed by other bits of it.
+*/
+func (bff BinaryFuseFilter) positions(hash uint64) [3]uint64 {
+	h0, _ := bits.Mul64(hash, bff.segmentCount*bff.segmentLength)
+	mask := bff.segmentLength - 1
+	return [3]uint64{
+		h0,
+		(h0 + bff.segmentLength) ^ (hash >> 18 & mask),
+		(h0 + 2*bff.segmentLength) ^ (hash & mask),
+	}
+}
+
+func (bff BinaryFuseFilter) lookupHash(h1 uint64) bool {
+	hash := fmix64(h1 + bff.seed)
+	p := bff.positions(hash)
+	return xorFingerprint(hash) == bff.fingerprints[p[0]]^bff.fingerprints[p[1]]^bff.fingerprints[p[2]]
+}
+
+/*Looks up an entry in the BinaryFuseFilter. Returns true if a match is found, false otherwise.*/
+func (bff BinaryFuseFilter) Lookup(entry string) (bool, error) {
+	h1, _ := stringHashes(bff.hasher, entry)
+	return bff.lookupHash(h1), nil
+}
+
+/*The same as Lookup: a BinaryFuseFilter never needs locking.*/
+func (bff BinaryFuseFilter) LookupAsync(entry string) (bool, error) {
+	return bff.Lookup(entry)
+}
+
+/*LookupBytes is Lookup for a byte slice entry. It gives the same answer as Lookup(string(entry)) without converting or allocating.*/
+func (bff BinaryFuseFilter) LookupBytes(entry []byte) (bool, error) {
+	h1, _ := baseHashes(bff.hasher, entry)
+	return bff.lookupHash(h1), nil
+}
+
+/*LookupUint64 is Lookup for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (bff BinaryFuseFilter) LookupUint64(entry uint64) (bool, error) {
+	h1, _ := uint64Hashes(bff.hasher, entry)
+	return bff.lookupHash(h1), nil
+}
+
+/*LookupBatch looks up every entry in entries and returns whether each one was found, in the same order.*/
+func (bff BinaryFuseFilter) LookupBatch(entries []string) ([]bool, error) {
+	results := make([]bool, len(entries))
+	for i, entry := range entries {
+		results[i], _ = bff.Lookup(entry)
+	}
+	return results, nil
+}
+
+/*Count returns the number of distinct keys the filter was built from.*/
+func (bff BinaryFuseFilter) Count() uint64 {
+	return bff.count
+}
+
+/*Hasher returns the hasher the filter was built with.*/
+func (bff BinaryFuseFilter) Hasher() Hasher {
+	return bff.hasher
+}
+
+/*WriteTo writes the filter to w in the package's binary format (see serialization.go) and returns the number of bytes written. It implements io.WriterTo.*/
+func (bff BinaryFuseFilter) WriteTo(w io.Writer) (int64, error) {
+	return writeStatic(w, KindBinaryFuse, bff.hasher, [4]uint64{bff.seed, bff.count, bff.segmentLength, bff.segmentCount}, bff.fingerprints)
+}
+
+/*
+ReadFrom replaces the filter with a serialized BinaryFuseFilter read from r and returns the number of bytes read. It implements io.ReaderFrom.
+Unlike the other filters' ReadFrom it doesn't merge (static filters can't be combined) and must not be called concurrently with any other method. The filter's current hasher is kept if it matches the serialized one.
+*/
+func (bff *BinaryFuseFilter) ReadFrom(r io.Reader) (int64, error) {
+	h, payload, n, err := readFilter(r, func(h fileHeader) error {
+		return h.checkStatic(KindBinaryFuse)
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, bff.replace(h, payload)
+}
+
+func (bff *BinaryFuseFilter) replace(h fileHeader, payload []byte) error {
+	params, fingerprints := parseStatic(payload)
+	segmentLength, segmentCount := params[2], params[3]
+	if segmentLength == 0 || segmentLength > binaryFuseMaxSegmentLength || segmentLength&(segmentLength-1) != 0 ||
+		segmentCount == 0 || segmentCount > staticMaxKeys || uint64(len(fingerprints)) != (segmentCount+2)*segmentLength {
+		return fmt.Errorf("Invalid binary fuse filter: %d segments of %d for %d fingerprints", segmentCount, segmentLength, len(fingerprints))
+	}
+	hasher, err := h.resolveHasher(bff.hasher)
+	if err != nil {
+		return err
+	}
+	*bff = BinaryFuseFilter{fingerprints: fingerprints, segmentLength: segmentLength, segmentCount: segmentCount, seed: params[0], count: params[1], hasher: hasher}
+	return nil
+}
+
+/*MarshalBinary returns the filter in the package's binary format. It implements encoding.BinaryMarshaler (and so also works with encoding/gob).*/
+func (bff BinaryFuseFilter) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := bff.WriteTo(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+/*UnmarshalBinary replaces the filter with a serialized BinaryFuseFilter. It implements encoding.BinaryUnmarshaler. See ReadFrom.*/
+func (bff *BinaryFuseFilter) UnmarshalBinary(data []byte) error {
+	h, payload, err := unmarshalFilter(data, KindBinaryFuse)
+	if err != nil {
+		return err
+	} else if err := h.checkStatic(KindBinaryFuse); err != nil {
+		return err
+	}
+	return bff.replace(h, payload)
+}
+
+/*Writes the filter to a file. See WriteTo.*/
+func (bff BinaryFuseFilter) Write(filename string) error {
+	return writeFile(filename, bff.WriteTo)
+}
+
+/*Replaces the filter with one loaded from a file. See ReadFrom.*/
+func (bff *BinaryFuseFilter) Load(filename string) error {
+	return readFile(filename, bff.ReadFrom)
+}
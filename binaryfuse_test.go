@@ -0,0 +1,49 @@
+package hyperbloom
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBinaryFuseFilter(t *testing.T) {
+	bff, err := BuildBinaryFuseFilter(staticKeys("member", 100000))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(100000), bff.Count())
+	checkStaticFilter(t, bff, len(bff.fingerprints), 9.6)
+}
+
+/*Layouts for a few sizes, as computed by the reference implementation.*/
+func TestBinaryFuseLayout(t *testing.T) {
+	for _, c := range []struct {
+		n, segmentLength, segmentCount uint64
+	}{
+		{0, 4, 1},
+		{1, 4, 1},
+		{2, 4, 1},
+		{10, 16, 1},
+		{1000, 128, 9},
+		{100000, 2048, 56},
+		{1000000, 8192, 136},
+	} {
+		segmentLength, segmentCount := binaryFuseLayout(c.n)
+		assert.Equal(t, c.segmentLength, segmentLength, "n=%d", c.n)
+		assert.Equal(t, c.segmentCount, segmentCount, "n=%d", c.n)
+	}
+
+	//About 9 bits per key for large key sets
+	segmentLength, segmentCount := binaryFuseLayout(1000000)
+	assert.InDelta(t, 9.0, float64((segmentCount+2)*segmentLength*8)/1000000, 0.1)
+}
+
+func TestBinaryFuseFilterSmall(t *testing.T) {
+	for n := 0; n < 50; n++ {
+		keys := staticKeys("member", n)
+		bff, err := BuildBinaryFuseFilter(keys)
+		assert.Nil(t, err)
+		for _, k := range keys {
+			exists, err := bff.Lookup(k)
+			assert.Nil(t, err)
+			assert.True(t, exists, "n=%d, %s", n, k)
+		}
+	}
+}
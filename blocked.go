@@ -0,0 +1,337 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"sync"
+)
+
+/*
+Blocked filters split their bit vector into blocks of one 64 byte cache line each. An entry's block is picked by the low bits of its first base hash, and all of its bits are set within that block by double hashing from the high bits of the first hash.
+*/
+const (
+	blockBits  = 512
+	blockWords = blockBits / 64
+)
+
+/*Returns the first word of the block holding an entry's bits, in a filter with the given number of blocks.*/
+func blockStart(h1, blocks uint64) uint64 {
+	return (h1 & (blocks - 1)) * blockWords
+}
+
+/*Returns the i-th bit of an entry within its block.*/
+func blockBit(h1, h2 uint64, i int) uint64 {
+	return nthHash(h1>>32, h2, i) & (blockBits - 1)
+}
+
+func blockLookup(block []uint64, h1, h2 uint64, hf int) bool {
+	for i := 0; i < hf; i++ {
+		bit := blockBit(h1, h2, i)
+		if block[bit/64]&(1<<(bit&63)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+/*Sets an entry's bits in its block and reports whether all of them were already set.*/
+func blockInsert(block []uint64, h1, h2 uint64, hf int) bool {
+	present := true
+	for i := 0; i < hf; i++ {
+		bit := blockBit(h1, h2, i)
+		if block[bit/64]&(1<<(bit&63)) == 0 {
+			present = false
+			block[bit/64] |= 1 << (bit & 63)
+		}
+	}
+	return present
+}
+
+/*
+Returns the expected false positive rate of a blocked filter of the given size and hash count holding n entries (Putze, Sanders & Singler, "Cache-, Hash- and Space-Efficient Bloom Filters").
+The number of entries per block is Poisson distributed, so this averages the false positive rate of a 512 bit Bloom filter over the block loads.
+*/
+func blockedFalsePositiveRate(size uint64, hf int, n uint64) float64 {
+	return averageOverLoads(float64(n)/float64(size/blockBits), func(load uint64) float64 {
+		return FalsePositiveRate(blockBits, hf, load)
+	})
+}
+
+/*Averages the false positive rate of a single block holding load entries over Poisson distributed loads with the given mean.*/
+func averageOverLoads(mean float64, blockRate func(load uint64) float64) float64 {
+	upper := mean + 10*math.Sqrt(mean) + 10
+	rate := 0.0
+	for load := 0.0; load <= upper; load++ {
+		lg, _ := math.Lgamma(load + 1)
+		weight := math.Exp(load*math.Log(mean) - mean - lg)
+		rate += weight * blockRate(uint64(load))
+	}
+	return rate
+}
+
+/*
+Returns the size and hash count of a blocked filter holding n entries with a false positive rate of at most p, and the rate expected at n entries.
+Blocks fill unevenly, so a blocked filter needs somewhat more space than EstimateParameters gives for the same rate: starting from that size, the size is doubled until the rate is met.
+*/
+func estimateBlockedParameters(n uint64, p float64) (size uint64, hf int, fpRate float64, err error) {
+	size, hf, _, err = EstimateParameters(n, p)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if size < blockBits {
+		size = blockBits
+	}
+	for {
+		hf = min(max(1, int(math.Round(float64(size)/float64(n)*math.Ln2))), maxHashFunctions)
+		fpRate = blockedFalsePositiveRate(size, hf, n)
+		if fpRate <= p {
+			return size, hf, fpRate, nil
+		} else if size >= 1<<62 {
+			return 0, 0, 0, errors.New("Filter for these estimates would exceed 2^63 buckets")
+		}
+		size *= 2
+	}
+}
+
+/*
+BlockedBloomFilter is a bloomfilter that keeps all the bits of an entry in a single 512 bit block (one cache line), so inserts and lookups touch one cache line however many hash functions are used. It uses central locking via a RWMutex.
+For the same size it has a somewhat higher false positive rate than BloomFilter, since blocks fill unevenly; NewBlockedBloomFilterWithEstimates accounts for that.
+*/
+type BlockedBloomFilter struct {
+	bv     []uint64      //bitvector, blockWords words per block
+	size   uint64        //Size of bitvector. MUST BE A POWER OF 2.
+	blocks uint64        //Number of blocks
+	hf     int           //Number of hash functions
+	hasher Hasher        //Produces the base hashes for each entry
+	mut    *sync.RWMutex //Centralized mutex
+}
+
+/*
+NewBlockedBloomFilter allocates a BlockedBloomFilter with a given size (in bits) and using a certain number of hashes.
+Size must be a power of 2 and at least 512 (one block).
+*/
+func NewBlockedBloomFilter(size uint64, hf int, opts ...Option) (*BlockedBloomFilter, error) {
+	var bf BlockedBloomFilter
+	bf.size = size
+	if bf.size < blockBits {
+		return nil, errors.New("Filter size must be at least 512")
+	} else if (bf.size & (bf.size - 1)) != 0 {
+		return nil, errors.New("Size must be a power of 2")
+	} else if err := checkHashes(hf); err != nil {
+		return nil, err
+	}
+	bf.bv = make([]uint64, size/64)
+	bf.blocks = size / blockBits
+	bf.hf = int(hf)
+	bf.hasher = buildOptions(opts).hasher
+	bf.mut = &sync.RWMutex{}
+	return &bf, nil
+}
+
+/*
+NewBlockedBloomFilterWithEstimates allocates a BlockedBloomFilter sized for n entries at a target false positive rate p.
+It also returns the false positive rate expected once n entries have been inserted.
+*/
+func NewBlockedBloomFilterWithEstimates(n uint64, p float64, opts ...Option) (*BlockedBloomFilter, float64, error) {
+	size, hf, fpRate, err := estimateBlockedParameters(n, p)
+	if err != nil {
+		return nil, 0, err
+	}
+	bf, err := NewBlockedBloomFilter(size, hf, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bf, fpRate, nil
+}
+
+/*Returns the block holding an entry's bits.*/
+func (bf BlockedBloomFilter) block(h1 uint64) []uint64 {
+	start := blockStart(h1, bf.blocks)
+	return bf.bv[start : start+blockWords : start+blockWords]
+}
+
+/*Looks up an entry in the BlockedBloomFilter. Returns true if a match is found, false otherwise. Takes a reader lock on the filter.*/
+func (bf BlockedBloomFilter) Lookup(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2), nil
+}
+
+/*Looks up an entry in the BlockedBloomFilter. Returns true if a match is found, false otherwise. This won't lock the filter.*/
+func (bf BlockedBloomFilter) LookupAsync(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return blockLookup(bf.block(h1), h1, h2, bf.hf), nil
+}
+
+/*LookupBytes is Lookup for a byte slice entry. It gives the same answer as Lookup(string(entry)) without converting or allocating.*/
+func (bf BlockedBloomFilter) LookupBytes(entry []byte) (bool, error) {
+	h1, h2 := baseHashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2), nil
+}
+
+/*LookupUint64 is Lookup for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (bf BlockedBloomFilter) LookupUint64(entry uint64) (bool, error) {
+	h1, h2 := uint64Hashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2), nil
+}
+
+func (bf BlockedBloomFilter) lookupHashes(h1, h2 uint64) bool {
+	bf.mut.RLock()
+	defer bf.mut.RUnlock()
+	return blockLookup(bf.block(h1), h1, h2, bf.hf)
+}
+
+/*LookupBatch looks up every entry in entries and returns whether each one was found, in the same order. Takes the reader lock once for the whole batch.*/
+func (bf BlockedBloomFilter) LookupBatch(entries []string) ([]bool, error) {
+	hashes := batchHashes(bf.hasher, entries)
+	results := make([]bool, len(entries))
+	bf.mut.RLock()
+	for e := 0; e < len(hashes); e += 2 {
+		results[e/2] = blockLookup(bf.block(hashes[e]), hashes[e], hashes[e+1], bf.hf)
+	}
+	bf.mut.RUnlock()
+	return results, nil
+}
+
+/*Inserts an entry into the BlockedBloomFilter. Locks the filter.*/
+func (bf BlockedBloomFilter) Insert(entry string) error {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	bf.insertHashes(h1, h2)
+	return nil
+}
+
+/*Inserts an entry into the BlockedBloomFilter. Doesn't lock the filter.*/
+func (bf BlockedBloomFilter) InsertAsync(entry string) error {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	blockInsert(bf.block(h1), h1, h2, bf.hf)
+	return nil
+}
+
+/*InsertBytes is Insert for a byte slice entry. It is equivalent to Insert(string(entry)) without converting or allocating.*/
+func (bf BlockedBloomFilter) InsertBytes(entry []byte) error {
+	h1, h2 := baseHashes(bf.hasher, entry)
+	bf.insertHashes(h1, h2)
+	return nil
+}
+
+/*InsertUint64 is Insert for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (bf BlockedBloomFilter) InsertUint64(entry uint64) error {
+	h1, h2 := uint64Hashes(bf.hasher, entry)
+	bf.insertHashes(h1, h2)
+	return nil
+}
+
+/*Sets an entry's bits under the write lock and reports whether all of them were already set.*/
+func (bf BlockedBloomFilter) insertHashes(h1, h2 uint64) bool {
+	bf.mut.Lock()
+	defer bf.mut.Unlock()
+	return blockInsert(bf.block(h1), h1, h2, bf.hf)
+}
+
+/*
+InsertBatch inserts every entry in entries. Takes the write lock once for the whole batch.
+Inserts are not atomic as a whole: concurrent lookups may see some entries of the batch before others.
+*/
+func (bf BlockedBloomFilter) InsertBatch(entries []string) error {
+	hashes := batchHashes(bf.hasher, entries)
+	bf.mut.Lock()
+	for e := 0; e < len(hashes); e += 2 {
+		blockInsert(bf.block(hashes[e]), hashes[e], hashes[e+1], bf.hf)
+	}
+	bf.mut.Unlock()
+	return nil
+}
+
+/*
+TestAndInsert inserts entry and reports whether it was (probably) already present, as a single operation.
+Holds the write lock for the whole operation: of any number of concurrent TestAndInsert calls for the same entry, exactly one reports it absent.
+*/
+func (bf BlockedBloomFilter) TestAndInsert(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.insertHashes(h1, h2), nil
+}
+
+/*Hasher returns the hasher the filter was built with.*/
+func (bf BlockedBloomFilter) Hasher() Hasher {
+	return bf.hasher
+}
+
+/*
+WriteTo writes the filter to w in the package's binary format (see serialization.go) and returns the number of bytes written. It implements io.WriterTo.
+Takes a reader lock on the filter while it is written.
+*/
+func (bf BlockedBloomFilter) WriteTo(w io.Writer) (int64, error) {
+	h := newFileHeader(KindBlocked, bf.size, bf.hf, 0, bf.hasher)
+	return writeFilter(w, h, func(w io.Writer) error {
+		bf.mut.RLock()
+		defer bf.mut.RUnlock()
+		return writeWords(w, bf.bv)
+	})
+}
+
+/*
+ReadFrom merges the filter with a serialized one read from r and returns the number of bytes read. It implements io.ReaderFrom.
+Exactly one serialized filter is consumed from r. It must be a blocked filter (other kinds place bits differently) with the same size, hash count and hasher.
+The filter is read and verified in full before the filter is locked, once, for the merge.
+*/
+func (bf BlockedBloomFilter) ReadFrom(r io.Reader) (int64, error) {
+	h, payload, n, err := readFilter(r, func(h fileHeader) error {
+		return h.checkCompatible(KindBlocked, bf.size, bf.hf, bf.hasher)
+	})
+	if err != nil {
+		return n, err
+	}
+	bf.merge(h, payload)
+	return n, nil
+}
+
+func (bf BlockedBloomFilter) merge(h fileHeader, payload []byte) {
+	words := payloadWords(h, payload)
+	bf.mut.Lock()
+	for i, word := range words {
+		bf.bv[i] |= word
+	}
+	bf.mut.Unlock()
+}
+
+/*MarshalBinary returns the filter in the package's binary format. It implements encoding.BinaryMarshaler (and so also works with encoding/gob).*/
+func (bf BlockedBloomFilter) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := bf.WriteTo(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+/*
+UnmarshalBinary replaces the filter with a serialized blocked filter, taking its size, hash count and hasher from data. It implements encoding.BinaryUnmarshaler.
+The filter's current hasher is kept if it matches the serialized one (which is the only way to unmarshal a filter using a keyed hasher). It must not be called concurrently with any other method.
+*/
+func (bf *BlockedBloomFilter) UnmarshalBinary(data []byte) error {
+	h, payload, err := unmarshalFilter(data, KindBlocked)
+	if err != nil {
+		return err
+	}
+	hasher, err := h.resolveHasher(bf.hasher)
+	if err != nil {
+		return err
+	}
+	fresh, err := NewBlockedBloomFilter(h.size, h.hf, WithHasher(hasher))
+	if err != nil {
+		return err
+	}
+	fresh.merge(h, payload)
+	*bf = *fresh
+	return nil
+}
+
+/*Writes the filter to a file. See WriteTo.*/
+func (bf BlockedBloomFilter) Write(filename string) error {
+	return writeFile(filename, bf.WriteTo)
+}
+
+/*Merges the filter with one loaded from a file. See ReadFrom.*/
+func (bf BlockedBloomFilter) Load(filename string) error {
+	return readFile(filename, bf.ReadFrom)
+}
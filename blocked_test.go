@@ -0,0 +1,96 @@
+package hyperbloom
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewBlockedBloomFilter(t *testing.T) {
+	bf, err := NewBlockedBloomFilter(256, 4)
+	assert.NotNil(t, err)
+	assert.Nil(t, bf)
+
+	bf, err = NewBlockedBloomFilter(100000, 4)
+	assert.NotNil(t, err)
+	assert.Nil(t, bf)
+
+	bf, err = NewBlockedBloomFilter(1048576, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2048), bf.blocks)
+}
+
+/*Every bit of an entry must land in the same block, at distinct positions.*/
+func TestBlockBits(t *testing.T) {
+	bf, err := NewBlockedBloomFilter(1048576, 16)
+	assert.Nil(t, err)
+	assert.Nil(t, bf.Insert("foo"))
+	h1, _ := stringHashes(bf.hasher, "foo")
+	start := blockStart(h1, bf.blocks)
+	set := 0
+	for i, word := range bf.bv {
+		for ; word != 0; word &= word - 1 {
+			assert.True(t, uint64(i) >= start && uint64(i) < start+blockWords)
+			set++
+		}
+	}
+	assert.Equal(t, 16, set)
+}
+
+func TestEstimateBlockedParameters(t *testing.T) {
+	size, hf, fpRate, err := estimateBlockedParameters(1000000, 0.01)
+	assert.Nil(t, err)
+	assert.True(t, fpRate <= 0.01)
+	assert.InDelta(t, blockedFalsePositiveRate(size, hf, 1000000), fpRate, 1e-12)
+	classic, _, _, _ := EstimateParameters(1000000, 0.01)
+	assert.True(t, size >= classic)
+
+	//A blocked filter is never better than a classic one of the same size
+	assert.True(t, blockedFalsePositiveRate(size, hf, 1000000) >= FalsePositiveRate(size, hf, 1000000))
+
+	size, _, _, err = estimateBlockedParameters(1, 0.5)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(blockBits), size)
+	_, _, _, err = estimateBlockedParameters(0, 0.01)
+	assert.NotNil(t, err)
+
+	bf, fpRate, err := NewBlockedBloomFilterWithEstimates(1000000, 0.01)
+	assert.Nil(t, err)
+	assert.NotNil(t, bf)
+	assert.True(t, fpRate <= 0.01)
+}
+
+/*
+Compares the blocked variants with StripedBloomFilter on a 2^30 bit (128MB) filter, much larger than the CPU caches, with all CPUs inserting or looking up.
+*/
+func BenchmarkLargeFilter(b *testing.B) {
+	const size = 1 << 30
+	specs := []Spec{
+		{Kind: KindStriped, Size: size, Hashes: 7, Shards: 256},
+		{Kind: KindBlocked, Size: size, Hashes: 7},
+		{Kind: KindStripedBlocked, Size: size, Hashes: 7, Shards: 256},
+	}
+	for _, spec := range specs {
+		f, err := NewFilter(spec)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(spec.Kind.String()+"/Insert", func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				var i uint64
+				for pb.Next() {
+					f.InsertUint64(i)
+					i++
+				}
+			})
+		})
+		b.Run(spec.Kind.String()+"/Lookup", func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				var i uint64
+				for pb.Next() {
+					f.LookupUint64(i)
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+)
+
+/*
+StripedBlockedBloomFilter is a BlockedBloomFilter that uses distributed locking via striping. Blocks never cross shards, so every insert and lookup locks exactly one shard.
+*/
+type StripedBlockedBloomFilter struct {
+	bv          []uint64      //bitvector, blockWords words per block
+	size        uint64        //Size of bitvector. MUST BE A POWER OF 2.
+	blocks      uint64        //Number of blocks
+	shards      uint64        //Number of shards. blocks must be multiple of shards.
+	hf          int           //Number of hash functions
+	hasher      Hasher        //Produces the base hashes for each entry
+	mutArr      []*sync.Mutex //Mutex for each shard
+	shardBlocks uint64        //Precomputed number of blocks per shard
+}
+
+/*
+NewStripedBlockedBloomFilter allocates a StripedBlockedBloomFilter with a given size (in bits) and using a certain number of hashes.
+Size must be a power of 2 and at least 512 (one block).
+Shards must be a power of 2 and cannot exceed size/512 (one block per shard).
+*/
+func NewStripedBlockedBloomFilter(size uint64, hf int, shards uint64, opts ...Option) (*StripedBlockedBloomFilter, error) {
+	var bf StripedBlockedBloomFilter
+	bf.size = size
+	bf.shards = shards
+	if bf.size < blockBits {
+		return nil, errors.New("Filter size must be at least 512")
+	} else if bf.shards == 0 {
+		return nil, errors.New("Shards must be nonzero")
+	} else if (bf.size & (bf.size - 1)) != 0 {
+		return nil, errors.New("Size must be a power of 2")
+	} else if (bf.shards & (bf.shards - 1)) != 0 {
+		return nil, errors.New("Shards must be a power of 2")
+	} else if bf.shards > bf.size/blockBits {
+		return nil, errors.New("Shards cannot exceed size/512")
+	} else if err := checkHashes(hf); err != nil {
+		return nil, err
+	}
+	bf.bv = make([]uint64, size/64)
+	bf.blocks = size / blockBits
+	bf.hf = int(hf)
+	bf.hasher = buildOptions(opts).hasher
+	bf.mutArr = make([]*sync.Mutex, shards)
+	for i := 0; i < int(shards); i++ {
+		bf.mutArr[i] = &sync.Mutex{}
+	}
+	bf.shardBlocks = bf.blocks / bf.shards
+	return &bf, nil
+}
+
+/*
+NewStripedBlockedBloomFilterWithEstimates allocates a StripedBlockedBloomFilter sized for n entries at a target false positive rate p. The shard count is picked from GOMAXPROCS.
+It also returns the false positive rate expected once n entries have been inserted.
+*/
+func NewStripedBlockedBloomFilterWithEstimates(n uint64, p float64, opts ...Option) (*StripedBlockedBloomFilter, float64, error) {
+	size, hf, fpRate, err := estimateBlockedParameters(n, p)
+	if err != nil {
+		return nil, 0, err
+	}
+	bf, err := NewStripedBlockedBloomFilter(size, hf, estimateBlockedShards(size), opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bf, fpRate, nil
+}
+
+/*estimateShards for a blocked filter, which needs at least one block per shard.*/
+func estimateBlockedShards(size uint64) uint64 {
+	return min(estimateShards(size), size/blockBits)
+}
+
+/*Returns the block holding an entry's bits and the shard it belongs to.*/
+func (bf StripedBlockedBloomFilter) block(h1 uint64) ([]uint64, uint64) {
+	start := blockStart(h1, bf.blocks)
+	return bf.bv[start : start+blockWords : start+blockWords], start / blockWords / bf.shardBlocks
+}
+
+/*Looks up an entry in the StripedBlockedBloomFilter. Returns true if a match is found, false otherwise. Locks the entry's shard.*/
+func (bf StripedBlockedBloomFilter) Lookup(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2), nil
+}
+
+/*Looks up an entry in the StripedBlockedBloomFilter. Returns true if a match is found, false otherwise. This won't lock the filter.*/
+func (bf StripedBlockedBloomFilter) LookupAsync(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	block, _ := bf.block(h1)
+	return blockLookup(block, h1, h2, bf.hf), nil
+}
+
+/*LookupBytes is Lookup for a byte slice entry. It gives the same answer as Lookup(string(entry)) without converting or allocating.*/
+func (bf StripedBlockedBloomFilter) LookupBytes(entry []byte) (bool, error) {
+	h1, h2 := baseHashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2), nil
+}
+
+/*LookupUint64 is Lookup for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (bf StripedBlockedBloomFilter) LookupUint64(entry uint64) (bool, error) {
+	h1, h2 := uint64Hashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2), nil
+}
+
+func (bf StripedBlockedBloomFilter) lookupHashes(h1, h2 uint64) bool {
+	block, shardID := bf.block(h1)
+	bf.mutArr[shardID].Lock()
+	defer bf.mutArr[shardID].Unlock()
+	return blockLookup(block, h1, h2, bf.hf)
+}
+
+/*Inserts an entry into the StripedBlockedBloomFilter. Locks the entry's shard.*/
+func (bf StripedBlockedBloomFilter) Insert(entry string) error {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	bf.insertHashes(h1, h2)
+	return nil
+}
+
+/*Inserts an entry into the StripedBlockedBloomFilter. Doesn't lock the filter.*/
+func (bf StripedBlockedBloomFilter) InsertAsync(entry string) error {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	block, _ := bf.block(h1)
+	blockInsert(block, h1, h2, bf.hf)
+	return nil
+}
+
+/*InsertBytes is Insert for a byte slice entry. It is equivalent to Insert(string(entry)) without converting or allocating.*/
+func (bf StripedBlockedBloomFilter) InsertBytes(entry []byte) error {
+	h1, h2 := baseHashes(bf.hasher, entry)
+	bf.insertHashes(h1, h2)
+	return nil
+}
+
+/*InsertUint64 is Insert for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (bf StripedBlockedBloomFilter) InsertUint64(entry uint64) error {
+	h1, h2 := uint64Hashes(bf.hasher, entry)
+	bf.insertHashes(h1, h2)
+	return nil
+}
+
+/*Sets an entry's bits under its shard's lock and reports whether all of them were already set.*/
+func (bf StripedBlockedBloomFilter) insertHashes(h1, h2 uint64) bool {
+	block, shardID := bf.block(h1)
+	bf.mutArr[shardID].Lock()
+	defer bf.mutArr[shardID].Unlock()
+	return blockInsert(block, h1, h2, bf.hf)
+}
+
+/*
+TestAndInsert inserts entry and reports whether it was (probably) already present, as a single operation.
+All of an entry's bits are in one shard, whose lock is held for the whole operation. Unlike the other striped variants this makes it atomic with respect to every other locking method, Insert included.
+*/
+func (bf StripedBlockedBloomFilter) TestAndInsert(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.insertHashes(h1, h2), nil
+}
+
+/*Returns the block of every entry, given their interleaved base hashes.*/
+func (bf StripedBlockedBloomFilter) batchBlocks(hashes []uint64) []uint64 {
+	blocks := make([]uint64, len(hashes)/2)
+	for e := range blocks {
+		blocks[e] = hashes[2*e] & (bf.blocks - 1)
+	}
+	return blocks
+}
+
+/*
+InsertBatch inserts every entry in entries. Every entry is hashed first, then each shard touched by the batch is locked once.
+Inserts are not atomic as a whole: concurrent lookups may see some entries of the batch before others.
+*/
+func (bf StripedBlockedBloomFilter) InsertBatch(entries []string) error {
+	hashes := batchHashes(bf.hasher, entries)
+	order, offsets := groupByShard(bf.batchBlocks(hashes), bf.shardBlocks, bf.shards)
+	for s := uint64(0); s < bf.shards; s++ {
+		if offsets[s] == offsets[s+1] {
+			continue
+		}
+		bf.mutArr[s].Lock()
+		for _, e := range order[offsets[s]:offsets[s+1]] {
+			block, _ := bf.block(hashes[2*e])
+			blockInsert(block, hashes[2*e], hashes[2*e+1], bf.hf)
+		}
+		bf.mutArr[s].Unlock()
+	}
+	return nil
+}
+
+/*
+LookupBatch looks up every entry in entries and returns whether each one was found, in the same order. Every entry is hashed first, then each shard touched by the batch is locked once.
+*/
+func (bf StripedBlockedBloomFilter) LookupBatch(entries []string) ([]bool, error) {
+	hashes := batchHashes(bf.hasher, entries)
+	order, offsets := groupByShard(bf.batchBlocks(hashes), bf.shardBlocks, bf.shards)
+	results := make([]bool, len(entries))
+	for s := uint64(0); s < bf.shards; s++ {
+		if offsets[s] == offsets[s+1] {
+			continue
+		}
+		bf.mutArr[s].Lock()
+		for _, e := range order[offsets[s]:offsets[s+1]] {
+			block, _ := bf.block(hashes[2*e])
+			results[e] = blockLookup(block, hashes[2*e], hashes[2*e+1], bf.hf)
+		}
+		bf.mutArr[s].Unlock()
+	}
+	return results, nil
+}
+
+/*Hasher returns the hasher the filter was built with.*/
+func (bf StripedBlockedBloomFilter) Hasher() Hasher {
+	return bf.hasher
+}
+
+/*
+WriteTo writes the filter to w in the package's binary format (see serialization.go) and returns the number of bytes written. It implements io.WriterTo.
+Locks one shard at a time while it is written, so concurrent inserts may be captured in some shards but not others.
+*/
+func (bf StripedBlockedBloomFilter) WriteTo(w io.Writer) (int64, error) {
+	h := newFileHeader(KindStripedBlocked, bf.size, bf.hf, bf.shards, bf.hasher)
+	shardWords := bf.shardBlocks * blockWords
+	return writeFilter(w, h, func(w io.Writer) error {
+		for s := uint64(0); s < bf.shards; s++ {
+			bf.mutArr[s].Lock()
+			err := writeWords(w, bf.bv[s*shardWords:(s+1)*shardWords])
+			bf.mutArr[s].Unlock()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+/*
+ReadFrom merges the filter with a serialized one read from r and returns the number of bytes read. It implements io.ReaderFrom.
+Exactly one serialized filter is consumed from r. It must be a blocked filter (other kinds place bits differently) with the same size, hash count and hasher.
+The filter is read and verified in full before the merge, which locks each shard once.
+*/
+func (bf StripedBlockedBloomFilter) ReadFrom(r io.Reader) (int64, error) {
+	h, payload, n, err := readFilter(r, func(h fileHeader) error {
+		return h.checkCompatible(KindStripedBlocked, bf.size, bf.hf, bf.hasher)
+	})
+	if err != nil {
+		return n, err
+	}
+	bf.merge(h, payload)
+	return n, nil
+}
+
+func (bf StripedBlockedBloomFilter) merge(h fileHeader, payload []byte) {
+	words := payloadWords(h, payload)
+	shardWords := bf.shardBlocks * blockWords
+	for s := uint64(0); s < bf.shards; s++ {
+		bf.mutArr[s].Lock()
+		for i := s * shardWords; i < (s+1)*shardWords; i++ {
+			bf.bv[i] |= words[i]
+		}
+		bf.mutArr[s].Unlock()
+	}
+}
+
+/*MarshalBinary returns the filter in the package's binary format. It implements encoding.BinaryMarshaler (and so also works with encoding/gob).*/
+func (bf StripedBlockedBloomFilter) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := bf.WriteTo(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+/*
+UnmarshalBinary replaces the filter with a serialized blocked filter, taking its size, hash count and hasher from data. It implements encoding.BinaryUnmarshaler.
+The filter's current hasher is kept if it matches the serialized one (which is the only way to unmarshal a filter using a keyed hasher). It must not be called concurrently with any other method.
+*/
+func (bf *StripedBlockedBloomFilter) UnmarshalBinary(data []byte) error {
+	h, payload, err := unmarshalFilter(data, KindStripedBlocked)
+	if err != nil {
+		return err
+	}
+	hasher, err := h.resolveHasher(bf.hasher)
+	if err != nil {
+		return err
+	}
+	shards := h.shards
+	if !h.kind.striped() {
+		shards = estimateBlockedShards(h.size)
+	}
+	fresh, err := NewStripedBlockedBloomFilter(h.size, h.hf, shards, WithHasher(hasher))
+	if err != nil {
+		return err
+	}
+	fresh.merge(h, payload)
+	*bf = *fresh
+	return nil
+}
+
+/*Writes the filter to a file. See WriteTo.*/
+func (bf StripedBlockedBloomFilter) Write(filename string) error {
+	return writeFile(filename, bf.WriteTo)
+}
+
+/*Merges the filter with one loaded from a file. See ReadFrom.*/
+func (bf StripedBlockedBloomFilter) Load(filename string) error {
+	return readFile(filename, bf.ReadFrom)
+}
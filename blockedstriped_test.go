@@ -0,0 +1,87 @@
+package hyperbloom
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestNewStripedBlockedBloomFilter(t *testing.T) {
+	bf, err := NewStripedBlockedBloomFilter(1048576, 4, 0)
+	assert.NotNil(t, err)
+	assert.Nil(t, bf)
+
+	//More shards than blocks
+	bf, err = NewStripedBlockedBloomFilter(1024, 4, 4)
+	assert.NotNil(t, err)
+	assert.Nil(t, bf)
+
+	bf, err = NewStripedBlockedBloomFilter(1048576, 4, 64)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(32), bf.shardBlocks)
+
+	bf, fpRate, err := NewStripedBlockedBloomFilterWithEstimates(1000000, 0.01)
+	assert.Nil(t, err)
+	assert.True(t, bf.shards <= bf.blocks)
+	assert.True(t, fpRate <= 0.01)
+}
+
+/*An entry's block, and so all of its bits, must lie within the shard it locks.*/
+func TestStripedBlockedShard(t *testing.T) {
+	bf, err := NewStripedBlockedBloomFilter(1<<16, 4, 16)
+	assert.Nil(t, err)
+	shardWords := bf.shardBlocks * blockWords
+	for i := 0; i < 1000; i++ {
+		h1, _ := stringHashes(bf.hasher, fmt.Sprintf("entry-%d", i))
+		start := blockStart(h1, bf.blocks)
+		_, shardID := bf.block(h1)
+		assert.True(t, start >= shardID*shardWords && start+blockWords <= (shardID+1)*shardWords)
+	}
+}
+
+/*Run with -race: concurrent inserts, lookups, batches and writes must not race.*/
+func TestStripedBlockedConcurrent(t *testing.T) {
+	bf, err := NewStripedBlockedBloomFilter(1<<16, 4, 16)
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			batch := make([]string, 0, 100)
+			for i := 0; i < 1000; i++ {
+				if i%2 == 0 {
+					bf.Insert(fmt.Sprintf("entry-%d-%d", w, i))
+				} else {
+					batch = append(batch, fmt.Sprintf("entry-%d-%d", w, i))
+				}
+			}
+			assert.Nil(t, bf.InsertBatch(batch))
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				bf.Lookup(fmt.Sprintf("entry-%d-%d", w, i))
+			}
+		}(w)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := bf.MarshalBinary()
+		assert.Nil(t, err)
+	}()
+	wg.Wait()
+	entries := make([]string, 0, 4000)
+	for w := 0; w < 4; w++ {
+		for i := 0; i < 1000; i++ {
+			entries = append(entries, fmt.Sprintf("entry-%d-%d", w, i))
+		}
+	}
+	results, err := bf.LookupBatch(entries)
+	assert.Nil(t, err)
+	for i, exists := range results {
+		assert.True(t, exists, entries[i])
+	}
+}
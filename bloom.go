@@ -4,13 +4,10 @@ A high performance concurrent bloom filter library with striped and naive implem
 package hyperbloom
 
 import (
-	"bufio"
 	"bytes"
 	"errors"
-	"fmt"
 	"io"
-	"log"
-	"os"
+	"slices"
 	"sync"
 )
 
@@ -18,25 +15,31 @@ import (
 BloomFilter is a bloomfilter backed by an array of unsigned 64 bit integers (with bits encoded in each one). It uses central locking via a RWMutex and supports both synchronous and asynchronous inserts and lookups
 */
 type BloomFilter struct {
-	bv   []uint64      //bitvector
-	size uint64        //Size of bitvector. MUST BE A POWER OF 2.
-	hf   int           //Number of hash functions
-	mut  *sync.RWMutex //Centralized mutex
+	bv      []uint64      //bitvector
+	size    uint64        //Size of bitvector. MUST BE A POWER OF 2.
+	hf      int           //Number of hash functions
+	hasher  Hasher        //Produces the base hashes for each entry
+	mut     *sync.RWMutex //Centralized mutex
+	mapping *mappedFile   //File the bitvector is mapped from, if any
 }
 
-/*NewBloomfilter allocates a BloomFilter with a given size (in bits) and using a certain number of hashes.
+/*
+NewBloomfilter allocates a BloomFilter with a given size (in bits) and using a certain number of hashes.
 Size must be a power of 2 and larger than 64
 */
-func NewBloomFilter(size uint64, hf int) (*BloomFilter, error) {
+func NewBloomFilter(size uint64, hf int, opts ...Option) (*BloomFilter, error) {
 	var bf BloomFilter
 	bf.size = size
 	if bf.size < 64 {
 		return nil, errors.New("Filter size must be at least 64")
 	} else if (bf.size & (bf.size - 1)) != 0 {
 		return nil, errors.New("Size must be a power of 2")
+	} else if err := checkHashes(hf); err != nil {
+		return nil, err
 	}
 	bf.bv = make([]uint64, size/64)
 	bf.hf = int(hf)
+	bf.hasher = buildOptions(opts).hasher
 	bf.mut = &sync.RWMutex{}
 
 	for i := 0; i < len(bf.bv); i++ {
@@ -46,6 +49,22 @@ func NewBloomFilter(size uint64, hf int) (*BloomFilter, error) {
 	return &bf, nil
 }
 
+/*
+NewBloomFilterWithEstimates allocates a BloomFilter sized for n entries at a target false positive rate p (see EstimateParameters).
+It also returns the false positive rate expected once n entries have been inserted.
+*/
+func NewBloomFilterWithEstimates(n uint64, p float64, opts ...Option) (*BloomFilter, float64, error) {
+	size, hf, fpRate, err := EstimateParameters(n, p)
+	if err != nil {
+		return nil, 0, err
+	}
+	bf, err := NewBloomFilter(size, hf, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bf, fpRate, nil
+}
+
 func (bf BloomFilter) setBit(idx uint64) error {
 	if idx > (bf.size - 1) {
 		return errors.New("Index can't be larger than filter size")
@@ -91,13 +110,39 @@ func (bf BloomFilter) getBitAsync(idx uint64) (bool, error) {
 	return exists, nil
 }
 
-/*Looks up an entry into the BloomFilter. Returns true if a match is found, false otherwise. If an error occurs, will also return false.
+/*
+Looks up an entry into the BloomFilter. Returns true if a match is found, false otherwise. If an error occurs, will also return false.
 This perform a reader lock on the filter (writers must wait until all active readers finish).
 */
 func (bf BloomFilter) Lookup(entry string) (bool, error) {
-	hashes := hashEntry([]byte(entry), bf.hf)
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2)
+}
+
+/*
+Looks up an entry into the BloomFilter. Returns true if a match is found, false otherwise. If an error occurs, will also return false.
+This won't lock the filter.
+*/
+func (bf BloomFilter) LookupAsync(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.lookupHashesAsync(h1, h2)
+}
+
+/*LookupBytes is Lookup for a byte slice entry. It gives the same answer as Lookup(string(entry)) without converting or allocating.*/
+func (bf BloomFilter) LookupBytes(entry []byte) (bool, error) {
+	h1, h2 := baseHashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2)
+}
+
+/*LookupUint64 is Lookup for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (bf BloomFilter) LookupUint64(entry uint64) (bool, error) {
+	h1, h2 := uint64Hashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2)
+}
+
+func (bf BloomFilter) lookupHashes(h1, h2 uint64) (bool, error) {
 	for i := 0; i < bf.hf; i++ {
-		lookup_idx := hashes[i] & (bf.size - 1)
+		lookup_idx := nthHash(h1, h2, i) & (bf.size - 1)
 		if exists, err := bf.getBit(lookup_idx); !exists {
 			if err != nil {
 				return false, err
@@ -108,13 +153,9 @@ func (bf BloomFilter) Lookup(entry string) (bool, error) {
 	return true, nil
 }
 
-/*Looks up an entry into the BloomFilter. Returns true if a match is found, false otherwise. If an error occurs, will also return false.
-This won't lock the filter.
-*/
-func (bf BloomFilter) LookupAsync(entry string) (bool, error) {
-	hashes := hashEntry([]byte(entry), bf.hf)
+func (bf BloomFilter) lookupHashesAsync(h1, h2 uint64) (bool, error) {
 	for i := 0; i < bf.hf; i++ {
-		lookup_idx := hashes[i] & (bf.size - 1)
+		lookup_idx := nthHash(h1, h2, i) & (bf.size - 1)
 		if exists, err := bf.getBitAsync(lookup_idx); !exists {
 			if err != nil {
 				return false, err
@@ -127,9 +168,31 @@ func (bf BloomFilter) LookupAsync(entry string) (bool, error) {
 
 /*Inserts an entry into the NaiveBloomFilter. Locks the filter.*/
 func (bf BloomFilter) Insert(entry string) error {
-	hashes := hashEntry([]byte(entry), bf.hf)
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.insertHashes(h1, h2)
+}
+
+/*Inserts an entry into the NaiveBloomFilter. Doesn't lock the filter.*/
+func (bf BloomFilter) InsertAsync(entry string) error {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.insertHashesAsync(h1, h2)
+}
+
+/*InsertBytes is Insert for a byte slice entry. It is equivalent to Insert(string(entry)) without converting or allocating.*/
+func (bf BloomFilter) InsertBytes(entry []byte) error {
+	h1, h2 := baseHashes(bf.hasher, entry)
+	return bf.insertHashes(h1, h2)
+}
+
+/*InsertUint64 is Insert for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (bf BloomFilter) InsertUint64(entry uint64) error {
+	h1, h2 := uint64Hashes(bf.hasher, entry)
+	return bf.insertHashes(h1, h2)
+}
+
+func (bf BloomFilter) insertHashes(h1, h2 uint64) error {
 	for i := 0; i < bf.hf; i++ {
-		insert_idx := hashes[i] & (bf.size - 1)
+		insert_idx := nthHash(h1, h2, i) & (bf.size - 1)
 		err := bf.setBit(insert_idx)
 		if err != nil {
 			return err
@@ -138,11 +201,9 @@ func (bf BloomFilter) Insert(entry string) error {
 	return nil
 }
 
-/*Inserts an entry into the NaiveBloomFilter. Doesn't lock the filter.*/
-func (bf BloomFilter) InsertAsync(entry string) error {
-	hashes := hashEntry([]byte(entry), bf.hf)
+func (bf BloomFilter) insertHashesAsync(h1, h2 uint64) error {
 	for i := 0; i < bf.hf; i++ {
-		insert_idx := hashes[i] & (bf.size - 1)
+		insert_idx := nthHash(h1, h2, i) & (bf.size - 1)
 		err := bf.setBitAsync(insert_idx)
 		if err != nil {
 			return err
@@ -151,51 +212,262 @@ func (bf BloomFilter) InsertAsync(entry string) error {
 	return nil
 }
 
-/*Writes underlying bit vector to a file.*/
-func (bf BloomFilter) Write(filename string) error {
-	var err error
-	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
-	defer f.Close()
-	if err != nil {
+/*
+TestAndInsert inserts entry and reports whether it was (probably) already present, as a single operation. This replaces a racy Lookup followed by Insert and hashes the entry once.
+Holds the write lock for the whole operation, so it is atomic with respect to every other locking method: of any number of concurrent TestAndInsert calls for the same entry, exactly one reports it absent.
+*/
+func (bf BloomFilter) TestAndInsert(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	bf.mut.Lock()
+	defer bf.mut.Unlock()
+	present := true
+	for i := 0; i < bf.hf; i++ {
+		idx := nthHash(h1, h2, i) & (bf.size - 1)
+		if bf.bv[idx/64]&(1<<(idx&63)) == 0 {
+			present = false
+			bf.bv[idx/64] |= 1 << (idx & 63)
+		}
+	}
+	return present, nil
+}
+
+/*
+InsertBatch inserts every entry in entries. Takes the write lock once for the whole batch.
+Inserts are not atomic as a whole: concurrent lookups may see some entries of the batch before others.
+*/
+func (bf BloomFilter) InsertBatch(entries []string) error {
+	hashes := batchHashes(bf.hasher, entries)
+	bf.mut.Lock()
+	for e := 0; e < len(hashes); e += 2 {
+		for i := 0; i < bf.hf; i++ {
+			idx := nthHash(hashes[e], hashes[e+1], i) & (bf.size - 1)
+			bf.bv[idx/64] |= 1 << (idx & 63)
+		}
+	}
+	bf.mut.Unlock()
+	return nil
+}
+
+/*
+LookupBatch looks up every entry in entries and returns whether each one was found, in the same order. Takes the reader lock once for the whole batch.
+*/
+func (bf BloomFilter) LookupBatch(entries []string) ([]bool, error) {
+	hashes := batchHashes(bf.hasher, entries)
+	results := allPresent(len(entries))
+	bf.mut.RLock()
+	for e := 0; e < len(hashes); e += 2 {
+		for i := 0; i < bf.hf; i++ {
+			idx := nthHash(hashes[e], hashes[e+1], i) & (bf.size - 1)
+			if bf.bv[idx/64]&(1<<(idx&63)) == 0 {
+				results[e/2] = false
+				break
+			}
+		}
+	}
+	bf.mut.RUnlock()
+	return results, nil
+}
+
+/*Hasher returns the hasher the filter was built with.*/
+func (bf BloomFilter) Hasher() Hasher {
+	return bf.hasher
+}
+
+/*Spec returns the Spec describing the filter, from which NewFilter builds an empty filter like it.*/
+func (bf BloomFilter) Spec() Spec {
+	return Spec{Kind: KindBloom, Size: bf.size, Hashes: bf.hf, Hasher: bf.hasher}
+}
+
+/*PopCount returns the number of bits set in the filter. Read locks the filter.*/
+func (bf BloomFilter) PopCount() uint64 {
+	bf.mut.RLock()
+	defer bf.mut.RUnlock()
+	return popCountWords(bf.bv)
+}
+
+/*FillRatio returns the fraction of the filter's bits that are set.*/
+func (bf BloomFilter) FillRatio() float64 {
+	return float64(bf.PopCount()) / float64(bf.size)
+}
+
+/*
+EstimatedCount returns an estimate of the number of distinct entries inserted into the filter, from the number of bits set (Swamidass & Baldi). It returns math.MaxUint64 once every bit is set, as the filter is then saturated.
+*/
+func (bf BloomFilter) EstimatedCount() uint64 {
+	return estimateCount(bf.size, bf.hf, bf.PopCount())
+}
+
+/*EstimatedFalsePositiveRate returns the filter's current false positive rate, estimated from its fill ratio (FillRatio()^hf).*/
+func (bf BloomFilter) EstimatedFalsePositiveRate() float64 {
+	return fillFalsePositiveRate(bf.size, bf.hf, bf.PopCount())
+}
+
+/*
+UnionWith sets every bit that is set in other, so the filter holds every entry of either. other must have the same size, hash count and hasher.
+Both filters are locked, in an order that keeps concurrent calls in opposite directions from deadlocking.
+*/
+func (bf BloomFilter) UnionWith(other *BloomFilter) error {
+	if err := checkCombinable(bf.size, bf.hf, bf.hasher, other.size, other.hf, other.hasher); err != nil {
 		return err
 	}
-	var b bytes.Buffer
-	for i := 0; i < int(bf.size); i++ {
-		bin := fmt.Sprintf("%b", bf.bv[i])
-		b.Write([]byte(bin))
-	}
-	bv := b.Bytes()
-	log.Println("Writing bit vector to file...")
-	f.Write(bv)
-	f.Close()
-	log.Printf("Successfully wrote bitvector to file: %s\n", filename)
+	unlock := lockPair(bf.mut, true, other.mut)
+	orWords(bf.bv, other.bv)
+	unlock()
 	return nil
 }
 
-/*Merges current bit vector with one loaded from a file.
-This locks the filter for each nonzero byte being written.
+/*
+IntersectWith clears every bit that isn't set in other, so the filter only matches entries that both filters match. other must have the same size, hash count and hasher.
+The result has at least the false positive rate of a filter built from the common entries alone, as it keeps bits that other entries set in both filters.
 */
-func (bf BloomFilter) Load(filename string) error {
-	f, err := os.Open(filename)
-	defer f.Close()
-	if err != nil {
+func (bf BloomFilter) IntersectWith(other *BloomFilter) error {
+	if err := checkCombinable(bf.size, bf.hf, bf.hasher, other.size, other.hf, other.hasher); err != nil {
 		return err
 	}
+	unlock := lockPair(bf.mut, true, other.mut)
+	andWords(bf.bv, other.bv)
+	unlock()
+	return nil
+}
+
+/*Union returns a new filter holding every entry of either filter, leaving both unchanged. See UnionWith.*/
+func (bf BloomFilter) Union(other *BloomFilter) (*BloomFilter, error) {
+	if err := checkCombinable(bf.size, bf.hf, bf.hasher, other.size, other.hf, other.hasher); err != nil {
+		return nil, err
+	}
+	fresh := bf.Clone()
+	return fresh, fresh.UnionWith(other)
+}
+
+/*Intersect returns a new filter matching the entries both filters match, leaving both unchanged. See IntersectWith.*/
+func (bf BloomFilter) Intersect(other *BloomFilter) (*BloomFilter, error) {
+	if err := checkCombinable(bf.size, bf.hf, bf.hasher, other.size, other.hf, other.hasher); err != nil {
+		return nil, err
+	}
+	fresh := bf.Clone()
+	return fresh, fresh.IntersectWith(other)
+}
+
+/*
+EstimatedIntersectionCount estimates the number of entries inserted into both filters, from the bits set in each and in their union. other must have the same size, hash count and hasher.
+It returns math.MaxUint64 if the union of the filters is saturated.
+*/
+func (bf BloomFilter) EstimatedIntersectionCount(other *BloomFilter) (uint64, error) {
+	if err := checkCombinable(bf.size, bf.hf, bf.hasher, other.size, other.hf, other.hasher); err != nil {
+		return 0, err
+	}
+	unlock := lockPair(bf.mut, false, other.mut)
+	popA, popB, popU := unionPopCountWords(bf.bv, other.bv)
+	unlock()
+	return estimateIntersection(bf.size, bf.hf, popA, popB, popU), nil
+}
+
+/*Reset clears every bit in the filter, leaving it empty with the same parameters. Locks the filter.*/
+func (bf BloomFilter) Reset() {
+	bf.mut.Lock()
+	clear(bf.bv)
+	bf.mut.Unlock()
+}
+
+/*Clone returns a deep copy of the filter, with its own bit vector and mutex. Read locks the filter while it is copied.*/
+func (bf BloomFilter) Clone() *BloomFilter {
+	clone := bf
+	clone.mapping = nil
+	bf.mut.RLock()
+	clone.bv = slices.Clone(bf.bv)
+	bf.mut.RUnlock()
+	clone.mut = &sync.RWMutex{}
+	return &clone
+}
+
+/*Equal reports whether other has the same size, hash count, hasher and bits as the filter. Both filters are read locked.*/
+func (bf BloomFilter) Equal(other *BloomFilter) bool {
+	if checkCombinable(bf.size, bf.hf, bf.hasher, other.size, other.hf, other.hasher) != nil {
+		return false
+	}
+	unlock := lockPair(bf.mut, false, other.mut)
+	defer unlock()
+	return slices.Equal(bf.bv, other.bv)
+}
+
+/*
+WriteTo writes the filter to w in the package's binary format (see serialization.go) and returns the number of bytes written. It implements io.WriterTo.
+Takes a reader lock on the filter while it is written.
+*/
+func (bf BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	h := newFileHeader(KindBloom, bf.size, bf.hf, 0, bf.hasher)
+	return writeFilter(w, h, func(w io.Writer) error {
+		bf.mut.RLock()
+		defer bf.mut.RUnlock()
+		return writeWords(w, bf.bv)
+	})
+}
+
+/*
+ReadFrom merges the filter with a serialized one read from r and returns the number of bytes read. It implements io.ReaderFrom.
+Exactly one serialized filter is consumed from r. It may have been written by any variant but must have the same size, hash count and hasher.
+The filter is read and verified in full before the filter is locked, once, for the merge.
+*/
+func (bf BloomFilter) ReadFrom(r io.Reader) (int64, error) {
+	h, payload, n, err := readFilter(r, func(h fileHeader) error {
+		return h.checkCompatible(KindBloom, bf.size, bf.hf, bf.hasher)
+	})
+	if err != nil {
+		return n, err
+	}
+	bf.merge(h, payload)
+	return n, nil
+}
+
+func (bf BloomFilter) merge(h fileHeader, payload []byte) {
+	words := payloadWords(h, payload)
+	bf.mut.Lock()
+	for i, word := range words {
+		bf.bv[i] |= word
+	}
+	bf.mut.Unlock()
+}
+
+/*MarshalBinary returns the filter in the package's binary format. It implements encoding.BinaryMarshaler (and so also works with encoding/gob).*/
+func (bf BloomFilter) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := bf.WriteTo(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
 
-	buf_rdr := bufio.NewReader(f)
-	bv, err := buf_rdr.ReadBytes('\n')
-	if err != nil && err != io.EOF {
+/*
+UnmarshalBinary replaces the filter with a serialized one, taking its size, hash count and hasher from data. It implements encoding.BinaryUnmarshaler.
+The filter's current hasher is kept if it matches the serialized one (which is the only way to unmarshal a filter using a keyed hasher). It must not be called concurrently with any other method. Memory mapped filters can't be unmarshalled into (merge into them with ReadFrom).
+*/
+func (bf *BloomFilter) UnmarshalBinary(data []byte) error {
+	if bf.mapping != nil {
+		return errUnmarshalMapped
+	}
+	h, payload, err := unmarshalFilter(data, KindBloom)
+	if err != nil {
 		return err
 	}
-	log.Println("Bytes read successfully")
-	if bf.size != uint64(len(bv)-1) {
-		return errors.New(fmt.Sprintf("File bitvector size: %d. Specified size: %d. Mismatch.", len(bv)-1, bf.size))
+	hasher, err := h.resolveHasher(bf.hasher)
+	if err != nil {
+		return err
 	}
-	for i := 0; i < len(bv); i++ {
-		if bv[i] > 0 {
-			bf.setBit(uint64(i))
-		}
+	fresh, err := NewBloomFilter(h.size, h.hf, WithHasher(hasher))
+	if err != nil {
+		return err
 	}
-	fmt.Printf("Loaded bitvector from file: %s\n", filename)
+	fresh.merge(h, payload)
+	*bf = *fresh
 	return nil
 }
+
+/*Writes the filter to a file. See WriteTo.*/
+func (bf BloomFilter) Write(filename string) error {
+	return writeFile(filename, bf.WriteTo)
+}
+
+/*Merges the filter with one loaded from a file. See ReadFrom.*/
+func (bf BloomFilter) Load(filename string) error {
+	return readFile(filename, bf.ReadFrom)
+}
@@ -25,6 +25,12 @@ func TestNewBloomFilter(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Nil(t, bf)
 
+	for _, hf := range []int{-1, 0, 65} {
+		bf, err = NewBloomFilter(1048576, hf)
+		assert.NotNil(t, err, "%d", hf)
+		assert.Nil(t, bf)
+	}
+
 	bf, err = NewBloomFilter(1048576, 4)
 	assert.Nil(t, err)
 	assert.NotNil(t, bf)
@@ -80,3 +86,15 @@ func TestBloomFilter(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, false, fake4Exists)
 }
+
+func TestNewBloomFilterWithEstimates(t *testing.T) {
+	bf, fpRate, err := NewBloomFilterWithEstimates(1000000, 0.01)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1<<24), bf.size)
+	assert.Equal(t, 12, bf.hf)
+	assert.True(t, fpRate <= 0.01)
+
+	bf, _, err = NewBloomFilterWithEstimates(1000000, 2)
+	assert.NotNil(t, err)
+	assert.Nil(t, bf)
+}
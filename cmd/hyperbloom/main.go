@@ -0,0 +1,516 @@
+/*
+Command hyperbloom builds, fills and inspects filter files in the hyperbloom binary format, as written by Write and read by Load.
+
+	hyperbloom create [flags] FILE          create an empty filter, sized from -n/-p or -size/-hashes
+	hyperbloom add [flags] FILE [KEYFILE...] insert keys, one per line, from the key files or stdin
+	hyperbloom query FILE [KEY...]          look keys up, from the arguments or stdin
+	hyperbloom info FILE...                 describe filters: parameters, fill and estimated count
+	hyperbloom merge [flags] OUT IN...      write the union of compatible filters to OUT
+	hyperbloom convert [flags] IN OUT       copy a filter into another variant
+
+Keys are read a line at a time; blank lines are skipped. query prints each key with true or false and exits with status 1 if any key is missing, 2 on errors.
+Files are replaced atomically, by writing a temporary file next to them and renaming it.
+*/
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/iamthebot/hyperbloom"
+)
+
+const usage = `Usage:
+  hyperbloom create [flags] FILE
+  hyperbloom add [flags] FILE [KEYFILE...]
+  hyperbloom query FILE [KEY...]
+  hyperbloom info FILE...
+  hyperbloom merge [flags] OUT IN...
+  hyperbloom convert [flags] IN OUT
+Run "hyperbloom COMMAND -h" for the flags of a command.
+`
+
+/*errMissing makes query exit with status 1 once it has printed every result.*/
+var errMissing = errors.New("Some keys are missing")
+
+/*The standard streams, replaced by tests.*/
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+var commands = map[string]func(e env, args []string) error{
+	"create":  create,
+	"add":     add,
+	"query":   query,
+	"info":    info,
+	"merge":   merge,
+	"convert": convert,
+}
+
+func main() {
+	os.Exit(run(env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}, os.Args[1:]))
+}
+
+/*Runs the command line args and returns the exit status.*/
+func run(e env, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(e.stderr, usage)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(e.stderr, "hyperbloom: unknown command %q\n%s", args[0], usage)
+		return 2
+	}
+	err := cmd(e, args[1:])
+	if err == errMissing {
+		return 1
+	} else if err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		fmt.Fprintf(e.stderr, "hyperbloom %s: %v\n", args[0], err)
+		return 2
+	}
+	return 0
+}
+
+/*Returns a flag set for a command that reports errors and usage on the environment's stderr.*/
+func newFlagSet(e env, name, operands string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: hyperbloom %s %s\n", name, operands)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+/*Parses args and checks the number of operands is between min and max (no limit if max < 0).*/
+func parseArgs(fs *flag.FlagSet, args []string, min, max int) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < min || (max >= 0 && fs.NArg() > max) {
+		fs.Usage()
+		return errors.New("Wrong number of arguments")
+	}
+	return nil
+}
+
+func newHasher(name string, seed uint64) (hyperbloom.Hasher, error) {
+	switch strings.ToLower(name) {
+	case hyperbloom.HasherXXHash64.String():
+		return hyperbloom.NewXXHasher(seed), nil
+	case hyperbloom.HasherFNV1a.String():
+		return hyperbloom.NewFNV1aHasher(seed), nil
+	case hyperbloom.HasherMurmur3.String():
+		if seed > 1<<32-1 {
+			return nil, errors.New("Murmur3 seeds must fit in 32 bits")
+		}
+		return hyperbloom.NewMurmur3Hasher(uint32(seed)), nil
+	}
+	return nil, fmt.Errorf("Unknown hasher %q (keyed hashers aren't supported)", name)
+}
+
+func create(e env, args []string) error {
+	fs := newFlagSet(e, "create", "[flags] FILE")
+	kindName := fs.String("kind", "bloom", "filter variant (see hyperbloom.ParseKind)")
+	n := fs.Uint64("n", 0, "number of entries to size the filter for")
+	p := fs.Float64("p", 0.01, "target false positive rate, with -n")
+	size := fs.Uint64("size", 0, "size in buckets (a power of 2), instead of -n")
+	hashes := fs.Int("hashes", 0, "number of hash functions, with -size")
+	shards := fs.Uint64("shards", 0, "number of shards for striped kinds (picked from GOMAXPROCS with -n)")
+	bucketSize := fs.Int("bucket", 4, "entries per bucket, for cuckoo filters built with -size")
+	fingerprint := fs.Int("fingerprint", 16, "fingerprint size in bits, for cuckoo filters built with -size")
+	precision := fs.Uint("precision", 14, "precision of hyperloglog sketches")
+	hasherName := fs.String("hasher", "xxhash64", "hasher: xxhash64, fnv1a or murmur3")
+	seed := fs.Uint64("seed", 0, "hasher seed")
+	force := fs.Bool("force", false, "overwrite an existing file")
+	if err := parseArgs(fs, args, 1, 1); err != nil {
+		return err
+	}
+	path := fs.Arg(0)
+	kind, err := hyperbloom.ParseKind(*kindName)
+	if err != nil {
+		return err
+	}
+	hasher, err := newHasher(*hasherName, *seed)
+	if err != nil {
+		return err
+	}
+	if !*force {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists (use -force to overwrite it)", path)
+		}
+	}
+
+	var f io.WriterTo
+	switch {
+	case kind == hyperbloom.KindHyperLogLog:
+		f, err = hyperbloom.NewHyperLogLog(uint8(*precision), hyperbloom.WithHasher(hasher))
+	case kind == hyperbloom.KindStripedHyperLogLog:
+		f, err = hyperbloom.NewStripedHyperLogLog(uint8(*precision), *shards, hyperbloom.WithHasher(hasher))
+	case *n != 0 && *size != 0:
+		err = errors.New("Set either -n or -size, not both")
+	case *n != 0:
+		var fpRate float64
+		f, fpRate, err = hyperbloom.NewFilterWithEstimates(kind, *n, *p, hyperbloom.WithHasher(hasher))
+		if err == nil {
+			fmt.Fprintf(e.stdout, "Expected false positive rate at %d entries: %.4g\n", *n, fpRate)
+		}
+	case *size != 0:
+		spec := hyperbloom.Spec{Kind: kind, Size: *size, Hashes: *hashes, Shards: *shards, Hasher: hasher}
+		if kind == hyperbloom.KindCuckoo {
+			spec.BucketSize, spec.FingerprintBits = *bucketSize, *fingerprint
+		}
+		f, err = hyperbloom.NewFilter(spec)
+	default:
+		err = errors.New("Set -n (and -p) or -size and -hashes")
+	}
+	if err != nil {
+		return err
+	}
+	return save(path, f)
+}
+
+func add(e env, args []string) error {
+	fs := newFlagSet(e, "add", "[flags] FILE [KEYFILE...]")
+	batch := fs.Int("batch", 4096, "number of keys inserted at a time")
+	if err := parseArgs(fs, args, 1, -1); err != nil {
+		return err
+	}
+	if *batch < 1 {
+		return errors.New("-batch must be positive")
+	}
+	path := fs.Arg(0)
+	_, f, err := load(path)
+	if err != nil {
+		return err
+	}
+	inserter, ok := f.(hyperbloom.Inserter)
+	if !ok {
+		return fmt.Errorf("Can't add keys to %s: static filters are built from their keys", path)
+	}
+	keys := make([]string, 0, *batch)
+	added := 0
+	err = forEachKey(e, fs.Args()[1:], func(key string) error {
+		keys = append(keys, key)
+		if len(keys) < *batch {
+			return nil
+		}
+		added += len(keys)
+		err := inserter.InsertBatch(keys)
+		keys = keys[:0]
+		return err
+	})
+	if err == nil && len(keys) > 0 {
+		added += len(keys)
+		err = inserter.InsertBatch(keys)
+	}
+	if err != nil {
+		return err
+	}
+	if err := save(path, f.(io.WriterTo)); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "Added %d keys to %s\n", added, path)
+	return nil
+}
+
+func query(e env, args []string) error {
+	fs := newFlagSet(e, "query", "FILE [KEY...]")
+	if err := parseArgs(fs, args, 1, -1); err != nil {
+		return err
+	}
+	_, f, err := load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	querier, ok := f.(hyperbloom.Querier)
+	if !ok {
+		return fmt.Errorf("Can't query %s: sketches don't record their keys", fs.Arg(0))
+	}
+	missing := false
+	check := func(key string) error {
+		exists, err := querier.Lookup(key)
+		if err != nil {
+			return err
+		}
+		missing = missing || !exists
+		_, err = fmt.Fprintf(e.stdout, "%s\t%t\n", key, exists)
+		return err
+	}
+	if fs.NArg() > 1 {
+		for _, key := range fs.Args()[1:] {
+			if err := check(key); err != nil {
+				return err
+			}
+		}
+	} else if err := forEachKey(e, nil, check); err != nil {
+		return err
+	}
+	if missing {
+		return errMissing
+	}
+	return nil
+}
+
+func info(e env, args []string) error {
+	fs := newFlagSet(e, "info", "FILE...")
+	if err := parseArgs(fs, args, 1, -1); err != nil {
+		return err
+	}
+	for i, path := range fs.Args() {
+		if i > 0 {
+			fmt.Fprintln(e.stdout)
+		}
+		if err := describe(e.stdout, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*The methods reported by info for the filters that have them.*/
+type filler interface {
+	FillRatio() float64
+	EstimatedCount() uint64
+	EstimatedFalsePositiveRate() float64
+}
+
+type counter interface {
+	Count() uint64
+}
+
+func describe(w io.Writer, path string) error {
+	spec, f, err := load(path)
+	if err != nil {
+		return err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	line := func(name string, format string, a ...any) {
+		fmt.Fprintf(w, "%-32s"+format+"\n", append([]any{name + ":"}, a...)...)
+	}
+	line("file", "%s", path)
+	line("kind", "%s", spec.Kind)
+	line("bytes", "%d", stat.Size())
+	line("size", "%d", spec.Size)
+	switch spec.Kind {
+	case hyperbloom.KindCuckoo:
+		line("bucket size", "%d", spec.BucketSize)
+		line("fingerprint bits", "%d", spec.FingerprintBits)
+	case hyperbloom.KindHyperLogLog, hyperbloom.KindStripedHyperLogLog:
+		line("precision", "%d", bits.Len64(spec.Size)-1)
+	default:
+		line("hashes", "%d", spec.Hashes)
+	}
+	if spec.Shards != 0 {
+		line("shards", "%d", spec.Shards)
+	}
+	line("hasher", "%s (seed %d)", spec.Hasher.ID(), spec.Hasher.Seed())
+
+	switch f := f.(type) {
+	case filler:
+		line("fill ratio", "%.4f", f.FillRatio())
+		line("estimated count", "%d", f.EstimatedCount())
+		line("estimated false positive rate", "%.4g", f.EstimatedFalsePositiveRate())
+	case *hyperbloom.ScalableBloomFilter:
+		for i, layer := range f.Layers() {
+			line(fmt.Sprintf("layer %d", i), "size %d, %d hashes, %d/%d entries, false positive rate %.4g", layer.Size, layer.Hashes, layer.Count, layer.Capacity, layer.FalsePositiveRate)
+		}
+	case *hyperbloom.HyperLogLog, *hyperbloom.StripedHyperLogLog:
+		line("estimated count", "%d", f.(counter).Count())
+	case counter:
+		line("count", "%d", f.Count())
+	}
+	return nil
+}
+
+func merge(e env, args []string) error {
+	fs := newFlagSet(e, "merge", "[flags] OUT IN...")
+	force := fs.Bool("force", false, "overwrite an existing OUT that isn't one of the inputs")
+	if err := parseArgs(fs, args, 2, -1); err != nil {
+		return err
+	}
+	out, inputs := fs.Arg(0), fs.Args()[1:]
+	if !*force && !slices.Contains(inputs, out) {
+		if _, err := os.Stat(out); err == nil {
+			return fmt.Errorf("%s already exists (use -force to overwrite it)", out)
+		}
+	}
+	spec, f, err := load(inputs[0])
+	if err != nil {
+		return err
+	}
+	merger, ok := f.(io.ReaderFrom)
+	if !ok || spec.Kind == hyperbloom.KindXor || spec.Kind == hyperbloom.KindBinaryFuse {
+		return fmt.Errorf("Can't merge %s filters", spec.Kind)
+	}
+	for _, path := range inputs[1:] {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if _, err := merger.ReadFrom(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return save(out, f.(io.WriterTo))
+}
+
+func convert(e env, args []string) error {
+	fs := newFlagSet(e, "convert", "[flags] IN OUT")
+	kindName := fs.String("kind", "", "filter variant to convert to (required)")
+	shards := fs.Uint64("shards", 0, "number of shards for striped kinds (default: the input's, or picked from GOMAXPROCS)")
+	force := fs.Bool("force", false, "overwrite an existing OUT")
+	if err := parseArgs(fs, args, 2, 2); err != nil {
+		return err
+	}
+	in, out := fs.Arg(0), fs.Arg(1)
+	if *kindName == "" {
+		fs.Usage()
+		return errors.New("-kind is required")
+	}
+	kind, err := hyperbloom.ParseKind(*kindName)
+	if err != nil {
+		return err
+	}
+	if !*force {
+		if _, err := os.Stat(out); err == nil {
+			return fmt.Errorf("%s already exists (use -force to overwrite it)", out)
+		}
+	}
+	data, err := os.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	spec, err := hyperbloom.ParseSpec(data)
+	if err != nil {
+		return err
+	}
+	var f hyperbloom.Filter
+	if *shards != 0 {
+		f, err = hyperbloom.NewFilter(hyperbloom.Spec{Kind: kind, Size: spec.Size, Hashes: spec.Hashes, Shards: *shards, Hasher: spec.Hasher})
+		if err == nil {
+			_, err = f.ReadFrom(bytes.NewReader(data))
+		}
+	} else {
+		f, err = hyperbloom.UnmarshalFilterAs(kind, data, hyperbloom.WithHasher(spec.Hasher))
+	}
+	if err != nil {
+		return err
+	}
+	return save(out, f)
+}
+
+/*
+Reads the filter, static filter or sketch saved at path. Filters are returned as a hyperbloom.Filter, the others as a pointer to their type.
+*/
+func load(path string) (hyperbloom.Spec, any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return hyperbloom.Spec{}, nil, err
+	}
+	spec, err := hyperbloom.ParseSpec(data)
+	if err != nil {
+		return spec, nil, fmt.Errorf("%s: %v", path, err)
+	}
+	var f interface{ UnmarshalBinary([]byte) error }
+	switch spec.Kind {
+	case hyperbloom.KindXor:
+		f = &hyperbloom.XorFilter{}
+	case hyperbloom.KindBinaryFuse:
+		f = &hyperbloom.BinaryFuseFilter{}
+	case hyperbloom.KindHyperLogLog:
+		f = &hyperbloom.HyperLogLog{}
+	case hyperbloom.KindStripedHyperLogLog:
+		f = &hyperbloom.StripedHyperLogLog{}
+	default:
+		filter, err := hyperbloom.UnmarshalFilter(data)
+		if err != nil {
+			return spec, nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return spec, filter, nil
+	}
+	if err := f.UnmarshalBinary(data); err != nil {
+		return spec, nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return spec, f, nil
+}
+
+/*Writes f to a temporary file next to path, then renames it over path. An existing file's permissions are kept.*/
+func save(path string, f io.WriterTo) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(tmp)
+	_, err = f.WriteTo(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		mode := os.FileMode(0644)
+		if stat, err := os.Stat(path); err == nil {
+			mode = stat.Mode().Perm()
+		}
+		err = tmp.Chmod(mode)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+/*Calls fn with every key in the named files, or on stdin if there are none. Blank lines are skipped and a trailing \r is dropped.*/
+func forEachKey(e env, files []string, fn func(key string) error) error {
+	scan := func(r io.Reader) error {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1<<20)
+		for scanner.Scan() {
+			key := strings.TrimSuffix(scanner.Text(), "\r")
+			if key == "" {
+				continue
+			}
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	}
+	if len(files) == 0 {
+		return scan(e.stdin)
+	}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = scan(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
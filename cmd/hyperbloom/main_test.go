@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/iamthebot/hyperbloom"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/*Runs the command line with stdin as its input and returns its exit status and output.*/
+func runCmd(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	status := run(env{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &stderr}, args)
+	return status, stdout.String(), stderr.String()
+}
+
+func keyLines(from, to int) string {
+	var b strings.Builder
+	for i := from; i < to; i++ {
+		fmt.Fprintf(&b, "key-%d\n", i)
+	}
+	return b.String()
+}
+
+func TestCreateAddQuery(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "keys.bloom")
+	status, _, stderr := runCmd("", "create", "-n", "10000", "-p", "0.001", path)
+	assert.Equal(t, 0, status, stderr)
+	status, _, _ = runCmd("", "create", "-n", "10000", path)
+	assert.Equal(t, 2, status)
+
+	keyFile := filepath.Join(dir, "keys.txt")
+	assert.Nil(t, os.WriteFile(keyFile, []byte(keyLines(0, 500)), 0666))
+	status, stdout, stderr := runCmd(keyLines(500, 1000)+"\r\n\n", "add", "-batch", "64", path)
+	assert.Equal(t, 0, status, stderr)
+	assert.Contains(t, stdout, "Added 500 keys")
+	status, _, stderr = runCmd("", "add", path, keyFile)
+	assert.Equal(t, 0, status, stderr)
+
+	status, stdout, _ = runCmd("", "query", path, "key-0", "key-999")
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "key-0\ttrue\nkey-999\ttrue\n", stdout)
+	status, stdout, _ = runCmd("key-1\nabsent\n", "query", path)
+	assert.Equal(t, 1, status)
+	assert.Equal(t, "key-1\ttrue\nabsent\tfalse\n", stdout)
+
+	//The file is an ordinary filter
+	bf, _, err := hyperbloom.NewBloomFilterWithEstimates(10000, 0.001)
+	assert.Nil(t, err)
+	assert.Nil(t, bf.Load(path))
+	assert.InDelta(t, 1000, float64(bf.EstimatedCount()), 30)
+}
+
+func TestCreateFlags(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"-kind", "striped", "-size", "65536", "-hashes", "4", "-shards", "16", "-hasher", "murmur3", "-seed", "7"},
+		{"-kind", "cuckoo", "-size", "1024", "-bucket", "4", "-fingerprint", "8"},
+		{"-kind", "scalable", "-n", "1000"},
+		{"-kind", "hyperloglog", "-precision", "10"},
+	} {
+		path := filepath.Join(dir, args[1])
+		status, _, stderr := runCmd("", append(append([]string{"create"}, args...), path)...)
+		assert.Equal(t, 0, status, stderr)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "striped"))
+	assert.Nil(t, err)
+	spec, err := hyperbloom.ParseSpec(data)
+	assert.Nil(t, err)
+	assert.Equal(t, hyperbloom.Spec{Kind: hyperbloom.KindStriped, Size: 65536, Hashes: 4, Shards: 16, Hasher: hyperbloom.NewMurmur3Hasher(7)}, spec)
+
+	for _, args := range [][]string{
+		{},
+		{"-n", "1000", "-size", "1024", "-hashes", "3"},
+		{"-kind", "xor", "-n", "1000"},
+		{"-kind", "quotient", "-n", "1000"},
+		{"-hasher", "siphash", "-n", "1000"},
+		{"-size", "1000", "-hashes", "3"},
+	} {
+		status, _, _ := runCmd("", append(append([]string{"create"}, args...), filepath.Join(dir, "bad"))...)
+		assert.Equal(t, 2, status, "%v", args)
+	}
+	_, err = os.Stat(filepath.Join(dir, "bad"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestInfo(t *testing.T) {
+	dir := t.TempDir()
+	bloom, sketch := filepath.Join(dir, "bloom"), filepath.Join(dir, "sketch")
+	runCmd("", "create", "-kind", "naivestriped", "-size", "65536", "-hashes", "4", "-shards", "8", bloom)
+	runCmd(keyLines(0, 1000), "add", bloom)
+	runCmd("", "create", "-kind", "stripedhyperloglog", "-precision", "12", "-shards", "4", sketch)
+	runCmd(keyLines(0, 1000), "add", sketch)
+
+	status, stdout, stderr := runCmd("", "info", bloom, sketch)
+	assert.Equal(t, 0, status, stderr)
+	for _, want := range []string{"naivestriped", "shards:", "fill ratio:", "estimated false positive rate:", "stripedhyperloglog", "precision:                      12"} {
+		assert.Contains(t, stdout, want)
+	}
+	status, _, stderr = runCmd("", "info", filepath.Join(dir, "missing"))
+	assert.Equal(t, 2, status)
+	assert.Contains(t, stderr, "missing")
+}
+
+func TestMergeConvert(t *testing.T) {
+	dir := t.TempDir()
+	a, b, merged := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "merged")
+	runCmd("", "create", "-size", "65536", "-hashes", "4", a)
+	runCmd(keyLines(0, 500), "add", a)
+	status, _, stderr := runCmd("", "convert", "-kind", "striped", "-shards", "4", a, b)
+	assert.Equal(t, 0, status, stderr)
+	runCmd(keyLines(500, 1000), "add", b)
+
+	status, _, stderr = runCmd("", "merge", merged, a, b)
+	assert.Equal(t, 0, status, stderr)
+	status, _, _ = runCmd(keyLines(0, 1000), "query", merged)
+	assert.Equal(t, 0, status)
+	status, _, _ = runCmd(keyLines(500, 1000), "query", a)
+	assert.Equal(t, 1, status)
+	status, _, _ = runCmd("", "merge", merged, a, b)
+	assert.Equal(t, 2, status)
+	status, _, stderr = runCmd("", "merge", a, a, b)
+	assert.Equal(t, 0, status, stderr)
+	status, _, _ = runCmd("key-999\n", "query", a)
+	assert.Equal(t, 0, status)
+
+	//Converting keeps the entries, whatever the variant
+	naive := filepath.Join(dir, "naive")
+	status, _, stderr = runCmd("", "convert", "-kind", "naive", merged, naive)
+	assert.Equal(t, 0, status, stderr)
+	status, _, _ = runCmd(keyLines(0, 1000), "query", naive)
+	assert.Equal(t, 0, status)
+
+	//Incompatible filters are rejected and leave no file behind
+	other := filepath.Join(dir, "other")
+	runCmd("", "create", "-size", "32768", "-hashes", "4", other)
+	status, _, _ = runCmd("", "merge", filepath.Join(dir, "out"), a, other)
+	assert.Equal(t, 2, status)
+	status, _, _ = runCmd("", "convert", "-kind", "blocked", a, filepath.Join(dir, "out"))
+	assert.Equal(t, 2, status)
+	status, _, _ = runCmd("", "convert", a, filepath.Join(dir, "out"))
+	assert.Equal(t, 2, status)
+	_, err := os.Stat(filepath.Join(dir, "out"))
+	assert.True(t, os.IsNotExist(err))
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	for _, entry := range entries {
+		assert.False(t, strings.HasPrefix(entry.Name(), "."), entry.Name())
+	}
+}
+
+func TestStaticAndSketchFiles(t *testing.T) {
+	dir := t.TempDir()
+	xf, err := hyperbloom.BuildXorFilter([]string{"foo", "bar"})
+	assert.Nil(t, err)
+	path := filepath.Join(dir, "xor")
+	assert.Nil(t, xf.Write(path))
+	status, stdout, _ := runCmd("", "query", path, "foo")
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "foo\ttrue\n", stdout)
+	status, _, _ = runCmd("baz\n", "add", path)
+	assert.Equal(t, 2, status)
+	status, _, _ = runCmd("", "merge", filepath.Join(dir, "out"), path, path)
+	assert.Equal(t, 2, status)
+	status, stdout, _ = runCmd("", "info", path)
+	assert.Equal(t, 0, status)
+	assert.Contains(t, stdout, "count:                          2")
+
+	a, b := filepath.Join(dir, "a.hll"), filepath.Join(dir, "b.hll")
+	runCmd("", "create", "-kind", "hyperloglog", a)
+	runCmd("", "create", "-kind", "hyperloglog", b)
+	runCmd(keyLines(0, 600), "add", a)
+	runCmd(keyLines(400, 1000), "add", b)
+	status, _, stderr := runCmd("", "merge", a, a, b)
+	assert.Equal(t, 0, status, stderr)
+	hll, err := hyperbloom.NewHyperLogLog(14)
+	assert.Nil(t, err)
+	assert.Nil(t, hll.Load(a))
+	assert.InDelta(t, 1000, float64(hll.Count()), 20)
+	status, _, _ = runCmd("", "query", a, "key-1")
+	assert.Equal(t, 2, status)
+}
+
+func TestUsage(t *testing.T) {
+	status, _, stderr := runCmd("")
+	assert.Equal(t, 2, status)
+	assert.Contains(t, stderr, "Usage:")
+	status, _, _ = runCmd("", "frobnicate")
+	assert.Equal(t, 2, status)
+	status, _, stderr = runCmd("", "add", "-h")
+	assert.Equal(t, 0, status)
+	assert.Contains(t, stderr, "-batch")
+	status, _, _ = runCmd("", "query")
+	assert.Equal(t, 2, status)
+}
@@ -0,0 +1,200 @@
+/*
+Command hyperbloomd serves named filters over HTTP (see server.NewHandler for the API), and optionally to Redis clients with the RedisBloom BF.* commands (see server.RESPServer) and over gRPC (see package rpc).
+
+	hyperbloomd [-addr :8080] [-resp-addr :6379] [-grpc-addr :9090] [-dir DIR] [-load] [-snapshot-on-exit] [-snapshot-every DURATION] [-max-filter-bytes N]
+
+With -dir set, filters can be snapshotted to and loaded from DIR. -load loads every snapshot in DIR at startup, -snapshot-on-exit snapshots every filter when the daemon shuts down and -snapshot-every snapshots every filter periodically.
+Filters created or loaded by clients, and snapshots loaded with -load, are limited to -max-filter-bytes (1GB by default, no limit if 0).
+On SIGINT or SIGTERM the daemon stops accepting connections and waits up to -shutdown-timeout for requests in flight to finish before exiting.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/iamthebot/hyperbloom/rpc"
+	"github.com/iamthebot/hyperbloom/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*Largest gRPC message accepted, which bounds the size of a batch or a merged filter like the HTTP server's body limit.*/
+const maxMessageBytes = 64 << 20
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stderr, nil); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintf(os.Stderr, "hyperbloomd: %v\n", err)
+		}
+		os.Exit(2)
+	}
+}
+
+/*Runs the daemon until ctx is done. If ready isn't nil, the addresses listened on are sent to it once the daemon is serving: HTTP, RESP and gRPC, nil for those disabled.*/
+func run(ctx context.Context, args []string, stderr io.Writer, ready chan<- []net.Addr) error {
+	fs := flag.NewFlagSet("hyperbloomd", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	respAddr := fs.String("resp-addr", "", "address to serve the Redis protocol on (disabled if empty)")
+	grpcAddr := fs.String("grpc-addr", "", "address to serve gRPC on (disabled if empty)")
+	dir := fs.String("dir", "", "snapshot directory (snapshots are disabled if empty)")
+	load := fs.Bool("load", false, "load every snapshot in -dir at startup")
+	snapshotOnExit := fs.Bool("snapshot-on-exit", false, "snapshot every filter to -dir on shutdown")
+	snapshotEvery := fs.Duration("snapshot-every", 0, "snapshot every filter to -dir this often (never if 0)")
+	maxFilterBytes := fs.Uint64("max-filter-bytes", server.DefaultMaxFilterBytes, "largest filter clients can create or load, in bytes (no limit if 0)")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 30*time.Second, "how long to wait for requests in flight on shutdown")
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if fs.NArg() != 0 {
+		fs.Usage()
+		return errors.New("Unexpected arguments")
+	} else if *dir == "" && (*load || *snapshotOnExit || *snapshotEvery != 0) {
+		return errors.New("-load, -snapshot-on-exit and -snapshot-every need -dir")
+	}
+	logger := log.New(stderr, "hyperbloomd: ", log.LstdFlags)
+
+	store := server.NewStore(*dir)
+	store.MaxFilterBytes = *maxFilterBytes
+	if *load {
+		names, err := store.LoadAll()
+		if err != nil {
+			return err
+		}
+		logger.Printf("loaded %d filters from %s", len(names), *dir)
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	addrs := []net.Addr{ln.Addr(), nil, nil}
+	var respLn, grpcLn net.Listener
+	if *respAddr != "" {
+		if respLn, err = net.Listen("tcp", *respAddr); err != nil {
+			ln.Close()
+			return err
+		}
+		addrs[1] = respLn.Addr()
+	}
+	if *grpcAddr != "" {
+		if grpcLn, err = net.Listen("tcp", *grpcAddr); err != nil {
+			ln.Close()
+			if respLn != nil {
+				respLn.Close()
+			}
+			return err
+		}
+		addrs[2] = grpcLn.Addr()
+	}
+	srv := &http.Server{Handler: server.NewHandler(store), ErrorLog: logger, ReadHeaderTimeout: 10 * time.Second}
+	respSrv := &server.RESPServer{Store: store, ErrorLog: logger}
+	grpcSrv := grpc.NewServer(grpcServerOptions(logger)...)
+	rpc.RegisterFilterServiceServer(grpcSrv, rpc.NewService(store))
+	served := make(chan error, 3)
+	go func() {
+		served <- srv.Serve(ln)
+	}()
+	logger.Printf("serving on %s", ln.Addr())
+	if respLn != nil {
+		go func() {
+			served <- respSrv.Serve(respLn)
+		}()
+		logger.Printf("serving the Redis protocol on %s", respLn.Addr())
+	}
+	if grpcLn != nil {
+		go func() {
+			served <- grpcSrv.Serve(grpcLn)
+		}()
+		logger.Printf("serving gRPC on %s", grpcLn.Addr())
+	}
+	if ready != nil {
+		ready <- addrs
+	}
+
+	var ticks <-chan time.Time
+	if *snapshotEvery != 0 {
+		ticker := time.NewTicker(*snapshotEvery)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	for done := false; !done; {
+		select {
+		case err := <-served:
+			return err
+		case <-ticks:
+			if err := store.SnapshotAll(); err != nil {
+				logger.Printf("snapshot failed: %v", err)
+			}
+		case <-ctx.Done():
+			done = true
+		}
+	}
+
+	logger.Printf("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	err = srv.Shutdown(shutdownCtx)
+	if respErr := respSrv.Shutdown(shutdownCtx); err == nil {
+		err = respErr
+	}
+	stopGRPC(shutdownCtx, grpcSrv)
+	if *snapshotOnExit {
+		if snapErr := store.SnapshotAll(); snapErr != nil {
+			return snapErr
+		}
+		logger.Printf("snapshotted %d filters to %s", len(store.Names()), *dir)
+	}
+	return err
+}
+
+/*Options for the daemon's gRPC server: the message size limit, and interceptors that recover from panicking calls so that one bad request can't take the daemon down.*/
+func grpcServerOptions(logger *log.Logger) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(maxMessageBytes),
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+			defer recoverCall(logger, info.FullMethod, &err)
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+			defer recoverCall(logger, info.FullMethod, &err)
+			return handler(srv, ss)
+		}),
+	}
+}
+
+/*Deferred by the gRPC interceptors: logs a panic in the call and fails it with codes.Internal instead.*/
+func recoverCall(logger *log.Logger, method string, err *error) {
+	if r := recover(); r != nil {
+		logger.Printf("gRPC call %s panicked: %v", method, r)
+		*err = status.Errorf(codes.Internal, "internal error running %s", method)
+	}
+}
+
+/*Stops the gRPC server gracefully, letting calls in flight finish, or at once if ctx is done first.*/
+func stopGRPC(ctx context.Context, srv *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		srv.Stop()
+		<-done
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"github.com/iamthebot/hyperbloom/rpc"
+	"github.com/iamthebot/hyperbloom/server"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*A running daemon: its base URL, the addresses of the other protocols ("" if disabled) and a function that shuts it down and returns its error.*/
+type daemon struct {
+	url, respAddr, grpcAddr string
+	stop                    func() error
+}
+
+func startDaemon(t *testing.T, args ...string) daemon {
+	ctx, cancel := context.WithCancel(context.Background())
+	ready := make(chan []net.Addr, 1)
+	done := make(chan error, 1)
+	var log bytes.Buffer
+	go func() {
+		done <- run(ctx, append([]string{"-addr", "127.0.0.1:0"}, args...), &log, ready)
+	}()
+	select {
+	case addrs := <-ready:
+		d := daemon{url: "http://" + addrs[0].String(), stop: func() error {
+			cancel()
+			return <-done
+		}}
+		if addrs[1] != nil {
+			d.respAddr = addrs[1].String()
+		}
+		if addrs[2] != nil {
+			d.grpcAddr = addrs[2].String()
+		}
+		return d
+	case err := <-done:
+		cancel()
+		t.Fatalf("daemon exited: %v\n%s", err, log.String())
+	case <-time.After(10 * time.Second):
+		t.Fatal("daemon didn't start")
+	}
+	return daemon{}
+}
+
+func request(t *testing.T, method, url, body string) int {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	assert.Nil(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestDaemonSnapshotsOnExit(t *testing.T) {
+	dir := t.TempDir()
+	d := startDaemon(t, "-dir", dir, "-snapshot-on-exit")
+	assert.Equal(t, http.StatusCreated, request(t, "PUT", d.url+"/filters/users", `{"kind": "striped", "n": 1000}`))
+	assert.Equal(t, http.StatusNoContent, request(t, "PUT", d.url+"/filters/users/keys/alice", ""))
+	assert.Nil(t, d.stop())
+	_, err := os.Stat(filepath.Join(dir, "users.bloom"))
+	assert.Nil(t, err)
+
+	d = startDaemon(t, "-dir", dir, "-load")
+	assert.Equal(t, http.StatusOK, request(t, "GET", d.url+"/filters/users", ""))
+	assert.Equal(t, http.StatusOK, request(t, "GET", d.url+"/filters/users/keys/alice", ""))
+	assert.Nil(t, d.stop())
+}
+
+func TestDaemonSnapshotsPeriodically(t *testing.T) {
+	dir := t.TempDir()
+	d := startDaemon(t, "-dir", dir, "-snapshot-every", "10ms")
+	defer d.stop()
+	assert.Equal(t, http.StatusCreated, request(t, "PUT", d.url+"/filters/users", `{"kind": "bloom", "n": 1000}`))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, "users.bloom"))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDaemonRESP(t *testing.T) {
+	d := startDaemon(t, "-resp-addr", "127.0.0.1:0")
+	assert.Empty(t, d.grpcAddr)
+	conn, err := net.Dial("tcp", d.respAddr)
+	assert.Nil(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	_, err = conn.Write([]byte("*3\r\n$6\r\nBF.ADD\r\n$5\r\nusers\r\n$5\r\nalice\r\n"))
+	assert.Nil(t, err)
+	reply, err := r.ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, ":1\r\n", reply)
+
+	//Both protocols share the daemon's filters
+	assert.Equal(t, http.StatusOK, request(t, "GET", d.url+"/filters/users/keys/alice", ""))
+	assert.Nil(t, d.stop())
+	_, err = r.ReadByte()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestDaemonGRPC(t *testing.T) {
+	d := startDaemon(t, "-grpc-addr", "127.0.0.1:0", "-resp-addr", "127.0.0.1:0")
+	conn, err := grpc.NewClient(d.grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	defer conn.Close()
+	c := rpc.NewClient(conn, "users")
+	assert.Nil(t, c.Create(server.CreateRequest{Kind: "striped", N: 1000}))
+	assert.Nil(t, c.Insert("alice"))
+
+	//gRPC shares the daemon's filters too
+	assert.Equal(t, http.StatusOK, request(t, "GET", d.url+"/filters/users/keys/alice", ""))
+	found, err := c.Lookup("alice")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Nil(t, d.stop())
+	assert.NotNil(t, c.Insert("bob"))
+}
+
+/*A FilterService whose calls panic.*/
+type panickingService struct {
+	rpc.UnimplementedFilterServiceServer
+}
+
+func (panickingService) GetFilter(context.Context, *rpc.GetFilterRequest) (*rpc.FilterInfo, error) {
+	panic("boom")
+}
+
+func (panickingService) BatchInsert(rpc.FilterService_BatchInsertServer) error {
+	panic("boom")
+}
+
+func TestDaemonGRPCRecovers(t *testing.T) {
+	var out bytes.Buffer
+	gs := grpc.NewServer(grpcServerOptions(log.New(&out, "", 0))...)
+	rpc.RegisterFilterServiceServer(gs, panickingService{})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go gs.Serve(ln)
+	conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	defer conn.Close()
+	c := rpc.NewFilterServiceClient(conn)
+
+	//Panicking calls fail with Internal and the server keeps serving
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, err = c.GetFilter(ctx, &rpc.GetFilterRequest{Name: "users"})
+		assert.Equal(t, codes.Internal, status.Code(err))
+	}
+	stream, err := c.BatchInsert(ctx)
+	assert.Nil(t, err)
+	_, err = stream.CloseAndRecv()
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	gs.GracefulStop()
+	assert.Contains(t, out.String(), "gRPC call "+rpc.FilterService_GetFilter_FullMethodName+" panicked: boom")
+	assert.Contains(t, out.String(), "gRPC call "+rpc.FilterService_BatchInsert_FullMethodName+" panicked: boom")
+}
+
+func TestDaemonMaxFilterBytes(t *testing.T) {
+	d := startDaemon(t)
+	assert.Equal(t, http.StatusBadRequest, request(t, "PUT", d.url+"/filters/huge", `{"kind": "bloom", "size": 35184372088832, "hashes": 3}`))
+	assert.Nil(t, d.stop())
+
+	d = startDaemon(t, "-max-filter-bytes", "1024")
+	defer d.stop()
+	assert.Equal(t, http.StatusBadRequest, request(t, "PUT", d.url+"/filters/users", `{"kind": "bloom", "n": 1000}`))
+	assert.Equal(t, http.StatusCreated, request(t, "PUT", d.url+"/filters/users", `{"kind": "bloom", "n": 100}`))
+}
+
+func TestDaemonFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"-load"},
+		{"-snapshot-on-exit"},
+		{"extra"},
+		{"-addr", "not an address"},
+		{"-resp-addr", "not an address"},
+		{"-grpc-addr", "not an address"},
+		{"-max-filter-bytes", "-1"},
+		{"-load", "-dir", filepath.Join(t.TempDir(), "missing")},
+	} {
+		var log bytes.Buffer
+		assert.NotNil(t, run(context.Background(), args, &log, nil), "%v", args)
+	}
+}
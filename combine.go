@@ -0,0 +1,114 @@
+package hyperbloom
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sync"
+	"unsafe"
+)
+
+/*
+Returns an error unless two filters can be combined bucket by bucket: the same size, hash count and hasher.
+*/
+func checkCombinable(size uint64, hf int, hasher Hasher, otherSize uint64, otherHf int, otherHasher Hasher) error {
+	if size != otherSize {
+		return fmt.Errorf("Can't combine filters of size %d and %d", size, otherSize)
+	} else if hf != otherHf {
+		return fmt.Errorf("Can't combine filters using %d and %d hash functions", hf, otherHf)
+	} else if hasher.ID() != otherHasher.ID() || hasher.Seed() != otherHasher.Seed() {
+		return fmt.Errorf("Can't combine filters using %s and %s", hasherString(hasher), hasherString(otherHasher))
+	}
+	return nil
+}
+
+/*
+Locks a (for writing if write is set, otherwise for reading) and b (for reading) in address order, so that goroutines locking the same two filters in opposite roles can't deadlock. a and b may be the same mutex. Returns the function that unlocks both.
+*/
+func lockPair(a *sync.RWMutex, write bool, b *sync.RWMutex) func() {
+	lockA, unlockA := a.RLock, a.RUnlock
+	if write {
+		lockA, unlockA = a.Lock, a.Unlock
+	}
+	if a == b {
+		lockA()
+		return unlockA
+	}
+	if uintptr(unsafe.Pointer(a)) < uintptr(unsafe.Pointer(b)) {
+		lockA()
+		b.RLock()
+	} else {
+		b.RLock()
+		lockA()
+	}
+	return func() {
+		unlockA()
+		b.RUnlock()
+	}
+}
+
+func orWords(dst, src []uint64) {
+	for i := range dst {
+		dst[i] |= src[i]
+	}
+}
+
+func andWords(dst, src []uint64) {
+	for i := range dst {
+		dst[i] &= src[i]
+	}
+}
+
+func orBytes(dst, src []byte) {
+	for i := range dst {
+		dst[i] |= src[i]
+	}
+}
+
+func andBytes(dst, src []byte) {
+	for i := range dst {
+		dst[i] &= src[i]
+	}
+}
+
+/*Returns the number of bits set in a, in b and in their union.*/
+func unionPopCountWords(a, b []uint64) (uint64, uint64, uint64) {
+	popA, popB, popU := 0, 0, 0
+	for i := range a {
+		popA += bits.OnesCount64(a[i])
+		popB += bits.OnesCount64(b[i])
+		popU += bits.OnesCount64(a[i] | b[i])
+	}
+	return uint64(popA), uint64(popB), uint64(popU)
+}
+
+/*Returns the number of nonzero bytes in a, in b and in their union.*/
+func unionPopCountBytes(a, b []byte) (uint64, uint64, uint64) {
+	popA, popB, popU := uint64(0), uint64(0), uint64(0)
+	for i := range a {
+		if a[i] != 0 {
+			popA++
+		}
+		if b[i] != 0 {
+			popB++
+		}
+		if a[i]|b[i] != 0 {
+			popU++
+		}
+	}
+	return popA, popB, popU
+}
+
+/*
+Estimates the number of entries two filters of the given size and hash count have in common from the buckets set in each and in their union, by inclusion-exclusion over their estimated counts (see estimateCount).
+If the union is saturated no estimate is possible, so math.MaxUint64 is returned.
+*/
+func estimateIntersection(size uint64, hf int, popA, popB, popUnion uint64) uint64 {
+	if popUnion >= size {
+		return math.MaxUint64
+	}
+	count := func(pop uint64) float64 {
+		return -float64(size) / float64(hf) * math.Log1p(-float64(pop)/float64(size))
+	}
+	return uint64(math.Round(math.Max(0, count(popA)+count(popB)-count(popUnion))))
+}
@@ -0,0 +1,258 @@
+package hyperbloom
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+type combinable[T any] interface {
+	Filter
+	PopCount() uint64
+	UnionWith(other T) error
+	IntersectWith(other T) error
+	Union(other T) (T, error)
+	Intersect(other T) (T, error)
+	EstimatedIntersectionCount(other T) (uint64, error)
+}
+
+/*Combines a filter of entries 0-5999 with one of entries 4000-9999, built by newFilter.*/
+func checkCombine[T combinable[T]](t *testing.T, newFilter func(size uint64, hf int, opts ...Option) (T, error)) {
+	entries := benchmarkEntries(10000)
+	a, err := newFilter(1<<18, 4)
+	assert.Nil(t, err)
+	assert.Nil(t, a.InsertBatch(entries[:6000]))
+	b, err := newFilter(1<<18, 4)
+	assert.Nil(t, err)
+	assert.Nil(t, b.InsertBatch(entries[4000:]))
+	popA, popB := a.PopCount(), b.PopCount()
+
+	union, err := a.Union(b)
+	assert.Nil(t, err)
+	results, err := union.LookupBatch(entries)
+	assert.Nil(t, err)
+	assert.NotContains(t, results, false)
+	intersection, err := a.Intersect(b)
+	assert.Nil(t, err)
+	results, err = intersection.LookupBatch(entries[4000:6000])
+	assert.Nil(t, err)
+	assert.NotContains(t, results, false)
+	assert.True(t, intersection.PopCount() <= min(popA, popB))
+	assert.Equal(t, popA, a.PopCount())
+	assert.Equal(t, popB, b.PopCount())
+
+	estimate, err := a.EstimatedIntersectionCount(b)
+	assert.Nil(t, err)
+	assert.InDelta(t, 2000, float64(estimate), 200)
+
+	//In place gives the same filters
+	wantUnion, err := union.MarshalBinary()
+	assert.Nil(t, err)
+	wantIntersection, err := intersection.MarshalBinary()
+	assert.Nil(t, err)
+	c, err := newFilter(1<<18, 4)
+	assert.Nil(t, err)
+	assert.Nil(t, c.InsertBatch(entries[:6000]))
+	assert.Nil(t, a.UnionWith(b))
+	got, err := a.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, wantUnion, got)
+	assert.Nil(t, c.IntersectWith(b))
+	got, err = c.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, wantIntersection, got)
+
+	//Combining a filter with itself changes nothing
+	assert.Nil(t, a.UnionWith(a))
+	assert.Nil(t, a.IntersectWith(a))
+	got, err = a.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, wantUnion, got)
+
+	//Incompatible filters are rejected
+	for _, other := range []func() (T, error){
+		func() (T, error) { return newFilter(1<<17, 4) },
+		func() (T, error) { return newFilter(1<<18, 3) },
+		func() (T, error) { return newFilter(1<<18, 4, WithHasher(NewMurmur3Hasher(0))) },
+	} {
+		o, err := other()
+		assert.Nil(t, err)
+		assert.NotNil(t, a.UnionWith(o))
+		assert.NotNil(t, a.IntersectWith(o))
+		_, err = a.Union(o)
+		assert.NotNil(t, err)
+		_, err = a.Intersect(o)
+		assert.NotNil(t, err)
+		_, err = a.EstimatedIntersectionCount(o)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestCombine(t *testing.T) {
+	t.Run("bloom", func(t *testing.T) { checkCombine(t, NewBloomFilter) })
+	t.Run("naive", func(t *testing.T) { checkCombine(t, NewNaiveBloomFilter) })
+	t.Run("striped", func(t *testing.T) {
+		checkCombine(t, func(size uint64, hf int, opts ...Option) (*StripedBloomFilter, error) {
+			return NewStripedBloomFilter(size, hf, 64, opts...)
+		})
+	})
+	t.Run("naivestriped", func(t *testing.T) {
+		checkCombine(t, func(size uint64, hf int, opts ...Option) (*NaiveStripedBloomFilter, error) {
+			return NewNaiveStripedBloomFilter(size, hf, 64, opts...)
+		})
+	})
+}
+
+/*Striped filters combine across shard counts, stripes of one spanning several shards of the other.*/
+func TestCombineShardCounts(t *testing.T) {
+	entries := benchmarkEntries(2000)
+	a, err := NewStripedBloomFilter(1<<16, 4, 4)
+	assert.Nil(t, err)
+	assert.Nil(t, a.InsertBatch(entries[:1000]))
+	b, err := NewStripedBloomFilter(1<<16, 4, 256)
+	assert.Nil(t, err)
+	assert.Nil(t, b.InsertBatch(entries[1000:]))
+	union, err := b.Union(a)
+	assert.Nil(t, err)
+	assert.Nil(t, a.UnionWith(b))
+	for _, f := range []*StripedBloomFilter{a, union} {
+		results, err := f.LookupBatch(entries)
+		assert.Nil(t, err)
+		assert.NotContains(t, results, false)
+	}
+	assert.Equal(t, a.PopCount(), union.PopCount())
+
+	na, err := NewNaiveStripedBloomFilter(1<<16, 4, 256)
+	assert.Nil(t, err)
+	assert.Nil(t, na.InsertBatch(entries[:1000]))
+	nb, err := NewNaiveStripedBloomFilter(1<<16, 4, 2)
+	assert.Nil(t, err)
+	assert.Nil(t, nb.InsertBatch(entries[1000:]))
+	assert.Nil(t, na.UnionWith(nb))
+	results, err := na.LookupBatch(entries)
+	assert.Nil(t, err)
+	assert.NotContains(t, results, false)
+}
+
+/*Filters combined with each other in both directions at once must not deadlock.*/
+func TestCombineConcurrent(t *testing.T) {
+	a, err := NewBloomFilter(1<<16, 4)
+	assert.Nil(t, err)
+	b, err := NewBloomFilter(1<<16, 4)
+	assert.Nil(t, err)
+	sa, err := NewStripedBloomFilter(1<<16, 4, 16)
+	assert.Nil(t, err)
+	sb, err := NewStripedBloomFilter(1<<16, 4, 16)
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if w%2 == 0 {
+					a.UnionWith(b)
+					sa.UnionWith(sb)
+					b.InsertUint64(uint64(i))
+				} else {
+					b.UnionWith(a)
+					sb.IntersectWith(sa)
+					a.EstimatedIntersectionCount(b)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+type cloneable[T any] interface {
+	Filter
+	PopCount() uint64
+	Reset()
+	Clone() T
+	Equal(other T) bool
+}
+
+func checkCloneResetEqual[T cloneable[T]](t *testing.T, newFilter func(size uint64, hf int, opts ...Option) (T, error)) {
+	entries := benchmarkEntries(1000)
+	bf, err := newFilter(1<<16, 4)
+	assert.Nil(t, err)
+	assert.Nil(t, bf.InsertBatch(entries))
+
+	clone := bf.Clone()
+	assert.True(t, clone.Equal(bf))
+	assert.True(t, bf.Equal(clone))
+	assert.True(t, bf.Equal(bf))
+	results, err := clone.LookupBatch(entries)
+	assert.Nil(t, err)
+	assert.NotContains(t, results, false)
+
+	//The clone shares nothing with the original
+	assert.Nil(t, clone.Insert("only in the clone"))
+	assert.False(t, bf.Equal(clone))
+	exists, _ := bf.Lookup("only in the clone")
+	assert.False(t, exists)
+
+	bf.Reset()
+	assert.Equal(t, uint64(0), bf.PopCount())
+	exists, _ = bf.Lookup(entries[0])
+	assert.False(t, exists)
+	empty, err := newFilter(1<<16, 4)
+	assert.Nil(t, err)
+	assert.True(t, bf.Equal(empty))
+	exists, _ = clone.Lookup(entries[0])
+	assert.True(t, exists)
+
+	//Different parameters are never equal
+	for _, other := range []func() (T, error){
+		func() (T, error) { return newFilter(1<<17, 4) },
+		func() (T, error) { return newFilter(1<<16, 3) },
+		func() (T, error) { return newFilter(1<<16, 4, WithHasher(NewMurmur3Hasher(0))) },
+	} {
+		o, err := other()
+		assert.Nil(t, err)
+		assert.False(t, bf.Equal(o))
+	}
+
+	//Clones lock independently
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if w%2 == 0 {
+					clone.Clone().InsertUint64(uint64(i))
+				} else {
+					bf.Equal(clone)
+					clone.InsertUint64(uint64(i))
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func TestCloneResetEqual(t *testing.T) {
+	t.Run("bloom", func(t *testing.T) { checkCloneResetEqual(t, NewBloomFilter) })
+	t.Run("naive", func(t *testing.T) { checkCloneResetEqual(t, NewNaiveBloomFilter) })
+	t.Run("striped", func(t *testing.T) {
+		checkCloneResetEqual(t, func(size uint64, hf int, opts ...Option) (*StripedBloomFilter, error) {
+			return NewStripedBloomFilter(size, hf, 16, opts...)
+		})
+	})
+	t.Run("naivestriped", func(t *testing.T) {
+		checkCloneResetEqual(t, func(size uint64, hf int, opts ...Option) (*NaiveStripedBloomFilter, error) {
+			return NewNaiveStripedBloomFilter(size, hf, 16, opts...)
+		})
+	})
+
+	//Striped filters with different shard counts can still be equal
+	a, err := NewStripedBloomFilter(1<<16, 4, 4)
+	assert.Nil(t, err)
+	b, err := NewStripedBloomFilter(1<<16, 4, 64)
+	assert.Nil(t, err)
+	assert.Nil(t, a.Insert("foo"))
+	assert.Nil(t, b.Insert("foo"))
+	assert.True(t, a.Equal(b))
+}
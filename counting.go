@@ -0,0 +1,328 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+/*ErrNotPresent is returned when deleting an entry that isn't in a counting filter.*/
+var ErrNotPresent = errors.New("Entry is not in the filter")
+
+/*
+Counters saturate at counterMax instead of wrapping. A saturated counter no longer knows how many entries it counts, so it is never decremented again.
+*/
+const counterMax = 255
+
+func incCounter(c *byte) {
+	if *c != counterMax {
+		*c++
+	}
+}
+
+func decCounter(c *byte) {
+	if *c != 0 && *c != counterMax {
+		*c--
+	}
+}
+
+/*Adds two counters, saturating at counterMax.*/
+func addCounters(a, b byte) byte {
+	if sum := uint(a) + uint(b); sum < counterMax {
+		return byte(sum)
+	}
+	return counterMax
+}
+
+/*
+CountingBloomFilter is a bloomfilter that supports deletion. Like NaiveBloomFilter it spends a byte per bucket, but uses each byte as a saturating counter of the entries hashed to it rather than a flag. It uses central locking via a RWMutex.
+A counter that reaches 255 sticks there (deleting entries never decrements it again), so heavily loaded buckets can't cause false negatives, they just stop being freed.
+*/
+type CountingBloomFilter struct {
+	bv     []byte        //Counter vector, a saturating counter per bucket
+	size   uint64        //Size of counter vector. MUST BE A POWER OF 2.
+	hf     int           //Number of hash functions
+	hasher Hasher        //Produces the base hashes for each entry
+	mut    *sync.RWMutex //Centralized mutex
+}
+
+/*
+NewCountingBloomFilter allocates a CountingBloomFilter with a given size (in counters, i.e. bytes) and using a certain number of hashes.
+Size must be a power of 2 and larger than 64
+*/
+func NewCountingBloomFilter(size uint64, hf int, opts ...Option) (*CountingBloomFilter, error) {
+	var bf CountingBloomFilter
+	bf.size = size
+	if bf.size < 64 {
+		return nil, errors.New("Filter size must be at least 64")
+	} else if (bf.size & (bf.size - 1)) != 0 {
+		return nil, errors.New("Size must be a power of 2")
+	} else if err := checkHashes(hf); err != nil {
+		return nil, err
+	}
+	bf.bv = make([]byte, size)
+	bf.hf = int(hf)
+	bf.hasher = buildOptions(opts).hasher
+	bf.mut = &sync.RWMutex{}
+	return &bf, nil
+}
+
+/*
+NewCountingBloomFilterWithEstimates allocates a CountingBloomFilter sized for n entries at a target false positive rate p (see EstimateParameters).
+It also returns the false positive rate expected once n entries have been inserted.
+*/
+func NewCountingBloomFilterWithEstimates(n uint64, p float64, opts ...Option) (*CountingBloomFilter, float64, error) {
+	size, hf, fpRate, err := EstimateParameters(n, p)
+	if err != nil {
+		return nil, 0, err
+	}
+	bf, err := NewCountingBloomFilter(size, hf, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bf, fpRate, nil
+}
+
+/*Looks up an entry in the CountingBloomFilter. Returns true if a match is found, false otherwise. Takes a reader lock on the filter.*/
+func (bf CountingBloomFilter) Lookup(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	bf.mut.RLock()
+	defer bf.mut.RUnlock()
+	return bf.lookupHashes(h1, h2), nil
+}
+
+/*Looks up an entry in the CountingBloomFilter. Returns true if a match is found, false otherwise. This won't lock the filter.*/
+func (bf CountingBloomFilter) LookupAsync(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2), nil
+}
+
+/*LookupBytes is Lookup for a byte slice entry. It gives the same answer as Lookup(string(entry)) without converting or allocating.*/
+func (bf CountingBloomFilter) LookupBytes(entry []byte) (bool, error) {
+	h1, h2 := baseHashes(bf.hasher, entry)
+	bf.mut.RLock()
+	defer bf.mut.RUnlock()
+	return bf.lookupHashes(h1, h2), nil
+}
+
+/*LookupUint64 is Lookup for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (bf CountingBloomFilter) LookupUint64(entry uint64) (bool, error) {
+	h1, h2 := uint64Hashes(bf.hasher, entry)
+	bf.mut.RLock()
+	defer bf.mut.RUnlock()
+	return bf.lookupHashes(h1, h2), nil
+}
+
+/*LookupBatch looks up every entry in entries and returns whether each one was found, in the same order. Takes the reader lock once for the whole batch.*/
+func (bf CountingBloomFilter) LookupBatch(entries []string) ([]bool, error) {
+	hashes := batchHashes(bf.hasher, entries)
+	results := make([]bool, len(entries))
+	bf.mut.RLock()
+	for e := 0; e < len(hashes); e += 2 {
+		results[e/2] = bf.lookupHashes(hashes[e], hashes[e+1])
+	}
+	bf.mut.RUnlock()
+	return results, nil
+}
+
+/*Callers hold the lock (or don't, for the *Async methods).*/
+func (bf CountingBloomFilter) lookupHashes(h1, h2 uint64) bool {
+	for i := 0; i < bf.hf; i++ {
+		if bf.bv[nthHash(h1, h2, i)&(bf.size-1)] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+/*Inserts an entry into the CountingBloomFilter. Locks the filter once for all of the entry's counters.*/
+func (bf CountingBloomFilter) Insert(entry string) error {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	bf.mut.Lock()
+	bf.insertHashes(h1, h2)
+	bf.mut.Unlock()
+	return nil
+}
+
+/*Inserts an entry into the CountingBloomFilter. Doesn't lock the filter.*/
+func (bf CountingBloomFilter) InsertAsync(entry string) error {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	bf.insertHashes(h1, h2)
+	return nil
+}
+
+/*InsertBytes is Insert for a byte slice entry. It is equivalent to Insert(string(entry)) without converting or allocating.*/
+func (bf CountingBloomFilter) InsertBytes(entry []byte) error {
+	h1, h2 := baseHashes(bf.hasher, entry)
+	bf.mut.Lock()
+	bf.insertHashes(h1, h2)
+	bf.mut.Unlock()
+	return nil
+}
+
+/*InsertUint64 is Insert for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (bf CountingBloomFilter) InsertUint64(entry uint64) error {
+	h1, h2 := uint64Hashes(bf.hasher, entry)
+	bf.mut.Lock()
+	bf.insertHashes(h1, h2)
+	bf.mut.Unlock()
+	return nil
+}
+
+/*InsertBatch inserts every entry in entries. Takes the write lock once for the whole batch.*/
+func (bf CountingBloomFilter) InsertBatch(entries []string) error {
+	hashes := batchHashes(bf.hasher, entries)
+	bf.mut.Lock()
+	for e := 0; e < len(hashes); e += 2 {
+		bf.insertHashes(hashes[e], hashes[e+1])
+	}
+	bf.mut.Unlock()
+	return nil
+}
+
+/*
+TestAndInsert inserts entry and reports whether it was (probably) already present, as a single operation.
+Holds the write lock for the whole operation: of any number of concurrent TestAndInsert calls for the same entry, exactly one reports it absent.
+*/
+func (bf CountingBloomFilter) TestAndInsert(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	bf.mut.Lock()
+	defer bf.mut.Unlock()
+	present := bf.lookupHashes(h1, h2)
+	bf.insertHashes(h1, h2)
+	return present, nil
+}
+
+func (bf CountingBloomFilter) insertHashes(h1, h2 uint64) {
+	for i := 0; i < bf.hf; i++ {
+		incCounter(&bf.bv[nthHash(h1, h2, i)&(bf.size-1)])
+	}
+}
+
+/*
+Delete removes one insertion of entry from the filter. Returns ErrNotPresent (and changes nothing) if entry is not in the filter.
+Only delete entries that were actually inserted: deleting a false positive decrements other entries' counters and can make them disappear.
+Holds the write lock for the whole operation.
+*/
+func (bf CountingBloomFilter) Delete(entry string) error {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	bf.mut.Lock()
+	defer bf.mut.Unlock()
+	return bf.deleteHashes(h1, h2)
+}
+
+/*Delete without locking the filter.*/
+func (bf CountingBloomFilter) DeleteAsync(entry string) error {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.deleteHashes(h1, h2)
+}
+
+func (bf CountingBloomFilter) deleteHashes(h1, h2 uint64) error {
+	if !bf.lookupHashes(h1, h2) {
+		return ErrNotPresent
+	}
+	for i := 0; i < bf.hf; i++ {
+		decCounter(&bf.bv[nthHash(h1, h2, i)&(bf.size-1)])
+	}
+	return nil
+}
+
+/*Hasher returns the hasher the filter was built with.*/
+func (bf CountingBloomFilter) Hasher() Hasher {
+	return bf.hasher
+}
+
+/*
+WriteTo writes the filter (counters included) to w in the package's binary format (see serialization.go) and returns the number of bytes written. It implements io.WriterTo.
+Takes a reader lock on the filter while it is written.
+*/
+func (bf CountingBloomFilter) WriteTo(w io.Writer) (int64, error) {
+	h := newFileHeader(KindCounting, bf.size, bf.hf, 0, bf.hasher)
+	return writeFilter(w, h, func(w io.Writer) error {
+		bf.mut.RLock()
+		defer bf.mut.RUnlock()
+		_, err := w.Write(bf.bv)
+		return err
+	})
+}
+
+/*
+ReadFrom merges the filter with a serialized counting filter read from r, adding their counters, and returns the number of bytes read. It implements io.ReaderFrom.
+Exactly one serialized filter is consumed from r. It must be a counting filter (plain filters don't know how many entries each bucket holds) with the same size, hash count and hasher.
+The filter is read and verified in full before the filter is locked, once, for the merge.
+*/
+func (bf CountingBloomFilter) ReadFrom(r io.Reader) (int64, error) {
+	_, payload, n, err := readFilter(r, func(h fileHeader) error {
+		if err := h.checkCounting(); err != nil {
+			return err
+		}
+		return h.checkCompatible(KindCounting, bf.size, bf.hf, bf.hasher)
+	})
+	if err != nil {
+		return n, err
+	}
+	bf.mut.Lock()
+	bf.merge(payload)
+	bf.mut.Unlock()
+	return n, nil
+}
+
+func (bf CountingBloomFilter) merge(counters []byte) {
+	for i, c := range counters {
+		bf.bv[i] = addCounters(bf.bv[i], c)
+	}
+}
+
+/*MarshalBinary returns the filter in the package's binary format. It implements encoding.BinaryMarshaler (and so also works with encoding/gob).*/
+func (bf CountingBloomFilter) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := bf.WriteTo(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+/*
+UnmarshalBinary replaces the filter with a serialized counting filter, taking its size, hash count and hasher from data. It implements encoding.BinaryUnmarshaler.
+The filter's current hasher is kept if it matches the serialized one (which is the only way to unmarshal a filter using a keyed hasher). It must not be called concurrently with any other method.
+*/
+func (bf *CountingBloomFilter) UnmarshalBinary(data []byte) error {
+	h, payload, err := unmarshalFilter(data, KindCounting)
+	if err != nil {
+		return err
+	}
+	if err := h.checkCounting(); err != nil {
+		return err
+	}
+	hasher, err := h.resolveHasher(bf.hasher)
+	if err != nil {
+		return err
+	}
+	fresh, err := NewCountingBloomFilter(h.size, h.hf, WithHasher(hasher))
+	if err != nil {
+		return err
+	}
+	copy(fresh.bv, payload)
+	*bf = *fresh
+	return nil
+}
+
+/*Writes the filter to a file. See WriteTo.*/
+func (bf CountingBloomFilter) Write(filename string) error {
+	return writeFile(filename, bf.WriteTo)
+}
+
+/*Merges the filter with one loaded from a file. See ReadFrom.*/
+func (bf CountingBloomFilter) Load(filename string) error {
+	return readFile(filename, bf.ReadFrom)
+}
+
+/*Returns an error unless the serialized filter has counters.*/
+func (h fileHeader) checkCounting() error {
+	if !h.kind.counting() {
+		return fmt.Errorf("Can't load a %s filter into a counting filter", h.kind)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCounters(t *testing.T) {
+	var c byte
+	incCounter(&c)
+	incCounter(&c)
+	assert.Equal(t, byte(2), c)
+	decCounter(&c)
+	assert.Equal(t, byte(1), c)
+	decCounter(&c)
+	decCounter(&c)
+	assert.Equal(t, byte(0), c)
+
+	//Saturated counters stick
+	c = counterMax
+	incCounter(&c)
+	assert.Equal(t, byte(counterMax), c)
+	decCounter(&c)
+	assert.Equal(t, byte(counterMax), c)
+
+	assert.Equal(t, byte(5), addCounters(2, 3))
+	assert.Equal(t, byte(counterMax), addCounters(200, 100))
+}
+
+func TestNewCountingBloomFilter(t *testing.T) {
+	bf, err := NewCountingBloomFilter(100000, 4)
+	assert.NotNil(t, err)
+	assert.Nil(t, bf)
+
+	bf, err = NewCountingBloomFilter(1048576, 4)
+	assert.Nil(t, err)
+	assert.NotNil(t, bf)
+
+	bf, fpRate, err := NewCountingBloomFilterWithEstimates(1000000, 0.01)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1<<24), bf.size)
+	assert.True(t, fpRate <= 0.01)
+}
+
+/*Runs the deletion checks against both counting variants.*/
+func TestDelete(t *testing.T) {
+	for _, kind := range []Kind{KindCounting, KindStripedCounting} {
+		spec := Spec{Kind: kind, Size: 1 << 16, Hashes: 4}
+		if kind.striped() {
+			spec.Shards = 16
+		}
+		f, err := NewFilter(spec)
+		assert.Nil(t, err)
+		d := f.(Deleter)
+
+		assert.Equal(t, ErrNotPresent, d.Delete("foo"), "%s", kind)
+
+		//Inserted twice, so it takes two deletes to remove
+		assert.Nil(t, f.Insert("foo"))
+		assert.Nil(t, f.Insert("foo"))
+		assert.Nil(t, d.Delete("foo"))
+		exists, _ := f.Lookup("foo")
+		assert.True(t, exists, "%s", kind)
+		assert.Nil(t, d.DeleteAsync("foo"))
+		exists, _ = f.Lookup("foo")
+		assert.False(t, exists, "%s", kind)
+		assert.Equal(t, ErrNotPresent, d.Delete("foo"), "%s", kind)
+
+		//Deleting some entries leaves the others in place
+		for i := 0; i < 1000; i++ {
+			assert.Nil(t, f.Insert(fmt.Sprintf("entry-%d", i)))
+		}
+		for i := 0; i < 1000; i += 2 {
+			assert.Nil(t, d.Delete(fmt.Sprintf("entry-%d", i)))
+		}
+		for i := 1; i < 1000; i += 2 {
+			exists, _ := f.Lookup(fmt.Sprintf("entry-%d", i))
+			assert.True(t, exists, "%s: entry-%d", kind, i)
+		}
+		absent := 0
+		for i := 0; i < 1000; i += 2 {
+			if exists, _ := f.Lookup(fmt.Sprintf("entry-%d", i)); !exists {
+				absent++
+			}
+		}
+		assert.True(t, absent > 490, "%s: only %d of 500 deleted entries absent", kind, absent)
+	}
+}
+
+/*An entry whose counters are all saturated can't be deleted out of the filter.*/
+func TestDeleteSaturated(t *testing.T) {
+	bf, err := NewCountingBloomFilter(1024, 3)
+	assert.Nil(t, err)
+	for i := 0; i < 300; i++ {
+		assert.Nil(t, bf.Insert("foo"))
+	}
+	for i := 0; i < 300; i++ {
+		assert.Nil(t, bf.Delete("foo"))
+	}
+	exists, _ := bf.Lookup("foo")
+	assert.True(t, exists)
+}
+
+/*Loading a counting filter adds its counters, so entries deleted from one copy survive in the merge.*/
+func TestCountingMerge(t *testing.T) {
+	a, err := NewCountingBloomFilter(1024, 3)
+	assert.Nil(t, err)
+	b, err := NewCountingBloomFilter(1024, 3)
+	assert.Nil(t, err)
+	assert.Nil(t, a.Insert("foo"))
+	assert.Nil(t, b.Insert("foo"))
+
+	var buf bytes.Buffer
+	_, err = b.WriteTo(&buf)
+	assert.Nil(t, err)
+	_, err = a.ReadFrom(&buf)
+	assert.Nil(t, err)
+	assert.Nil(t, a.Delete("foo"))
+	exists, _ := a.Lookup("foo")
+	assert.True(t, exists)
+	assert.Nil(t, a.Delete("foo"))
+	exists, _ = a.Lookup("foo")
+	assert.False(t, exists)
+
+	//Plain filters have no counters to merge
+	plain, err := NewBloomFilter(1024, 3)
+	assert.Nil(t, err)
+	data, err := plain.MarshalBinary()
+	assert.Nil(t, err)
+	_, err = a.ReadFrom(bytes.NewReader(data))
+	assert.NotNil(t, err)
+	assert.NotNil(t, a.UnmarshalBinary(data))
+}
@@ -0,0 +1,364 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+)
+
+/*
+StripedCountingBloomFilter is a CountingBloomFilter that uses distributed locking via striping, like NaiveStripedBloomFilter. It supports both synchronous and asynchronous inserts, lookups and deletes.
+*/
+type StripedCountingBloomFilter struct {
+	bv       []byte        //Counter vector, a saturating counter per bucket
+	size     uint64        //Size of counter vector. MUST BE A POWER OF 2.
+	shards   uint64        //Number of shards. size must be multiple of shards.
+	hf       int           //Number of hash functions
+	hasher   Hasher        //Produces the base hashes for each entry
+	mutArr   []*sync.Mutex //Mutex for each shard
+	shardLen uint64        //Precomputed number of counters per shard
+}
+
+/*
+NewStripedCountingBloomFilter allocates a StripedCountingBloomFilter with a given size (in counters, i.e. bytes) and using a certain number of hashes.
+Size must be a power of 2 and larger than 64.
+Shards must be a power of 2 (smaller than size) and cannot exceed size/64.
+*/
+func NewStripedCountingBloomFilter(size uint64, hf int, shards uint64, opts ...Option) (*StripedCountingBloomFilter, error) {
+	var bf StripedCountingBloomFilter
+	bf.size = size
+	bf.shards = shards
+	if bf.size < 64 {
+		return nil, errors.New("Filter size must be at least 64")
+	} else if bf.shards == 0 {
+		return nil, errors.New("Shards must be nonzero")
+	} else if (bf.size & (bf.size - 1)) != 0 {
+		return nil, errors.New("Size must be a power of 2")
+	} else if (bf.shards & (bf.shards - 1)) != 0 {
+		return nil, errors.New("Shards must be a power of 2")
+	} else if bf.shards > bf.size/64 {
+		return nil, errors.New("Shards cannot exceed size/64")
+	} else if bf.size%bf.shards != 0 {
+		return nil, errors.New("Size must be a multiple of shards")
+	} else if err := checkHashes(hf); err != nil {
+		return nil, err
+	}
+	bf.bv = make([]byte, size)
+	bf.hf = int(hf)
+	bf.hasher = buildOptions(opts).hasher
+	bf.mutArr = make([]*sync.Mutex, shards)
+	for i := 0; i < int(shards); i++ {
+		bf.mutArr[i] = &sync.Mutex{}
+	}
+	bf.shardLen = bf.size / bf.shards
+	return &bf, nil
+}
+
+/*
+NewStripedCountingBloomFilterWithEstimates allocates a StripedCountingBloomFilter sized for n entries at a target false positive rate p (see EstimateParameters). The shard count is picked from GOMAXPROCS.
+It also returns the false positive rate expected once n entries have been inserted.
+*/
+func NewStripedCountingBloomFilterWithEstimates(n uint64, p float64, opts ...Option) (*StripedCountingBloomFilter, float64, error) {
+	size, hf, fpRate, err := EstimateParameters(n, p)
+	if err != nil {
+		return nil, 0, err
+	}
+	bf, err := NewStripedCountingBloomFilter(size, hf, estimateShards(size), opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bf, fpRate, nil
+}
+
+/*Returns the entry's counter indices.*/
+func (bf StripedCountingBloomFilter) indices(h1, h2 uint64) []uint64 {
+	indices := make([]uint64, bf.hf)
+	for i := range indices {
+		indices[i] = nthHash(h1, h2, i) & (bf.size - 1)
+	}
+	return indices
+}
+
+/*Looks up an entry in the StripedCountingBloomFilter. Returns true if a match is found, false otherwise. Locks one shard at a time.*/
+func (bf StripedCountingBloomFilter) Lookup(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2), nil
+}
+
+/*Looks up an entry in the StripedCountingBloomFilter. Returns true if a match is found, false otherwise. This won't lock the filter.*/
+func (bf StripedCountingBloomFilter) LookupAsync(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.lookupHashesAsync(h1, h2), nil
+}
+
+/*LookupBytes is Lookup for a byte slice entry. It gives the same answer as Lookup(string(entry)) without converting or allocating.*/
+func (bf StripedCountingBloomFilter) LookupBytes(entry []byte) (bool, error) {
+	h1, h2 := baseHashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2), nil
+}
+
+/*LookupUint64 is Lookup for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (bf StripedCountingBloomFilter) LookupUint64(entry uint64) (bool, error) {
+	h1, h2 := uint64Hashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2), nil
+}
+
+func (bf StripedCountingBloomFilter) lookupHashes(h1, h2 uint64) bool {
+	for i := 0; i < bf.hf; i++ {
+		lookup_idx := nthHash(h1, h2, i) & (bf.size - 1)
+		shardID := lookup_idx / bf.shardLen
+		bf.mutArr[shardID].Lock()
+		exists := bf.bv[lookup_idx] != 0
+		bf.mutArr[shardID].Unlock()
+		if !exists {
+			return false
+		}
+	}
+	return true
+}
+
+func (bf StripedCountingBloomFilter) lookupHashesAsync(h1, h2 uint64) bool {
+	for i := 0; i < bf.hf; i++ {
+		if bf.bv[nthHash(h1, h2, i)&(bf.size-1)] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+/*Inserts an entry into the StripedCountingBloomFilter. Locks one shard at a time.*/
+func (bf StripedCountingBloomFilter) Insert(entry string) error {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	bf.insertHashes(h1, h2)
+	return nil
+}
+
+/*Inserts an entry into the StripedCountingBloomFilter. Doesn't lock the filter.*/
+func (bf StripedCountingBloomFilter) InsertAsync(entry string) error {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	for i := 0; i < bf.hf; i++ {
+		incCounter(&bf.bv[nthHash(h1, h2, i)&(bf.size-1)])
+	}
+	return nil
+}
+
+/*InsertBytes is Insert for a byte slice entry. It is equivalent to Insert(string(entry)) without converting or allocating.*/
+func (bf StripedCountingBloomFilter) InsertBytes(entry []byte) error {
+	h1, h2 := baseHashes(bf.hasher, entry)
+	bf.insertHashes(h1, h2)
+	return nil
+}
+
+/*InsertUint64 is Insert for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (bf StripedCountingBloomFilter) InsertUint64(entry uint64) error {
+	h1, h2 := uint64Hashes(bf.hasher, entry)
+	bf.insertHashes(h1, h2)
+	return nil
+}
+
+func (bf StripedCountingBloomFilter) insertHashes(h1, h2 uint64) {
+	for i := 0; i < bf.hf; i++ {
+		insert_idx := nthHash(h1, h2, i) & (bf.size - 1)
+		shardID := insert_idx / bf.shardLen
+		bf.mutArr[shardID].Lock()
+		incCounter(&bf.bv[insert_idx])
+		bf.mutArr[shardID].Unlock()
+	}
+}
+
+/*
+TestAndInsert inserts entry and reports whether it was (probably) already present, as a single operation.
+Every shard holding one of the entry's counters is locked (in ascending order) for the whole operation, so of any number of concurrent TestAndInsert calls for the same entry exactly one reports it absent. As with NaiveStripedBloomFilter this doesn't hold against a racing Insert.
+*/
+func (bf StripedCountingBloomFilter) TestAndInsert(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	indices := bf.indices(h1, h2)
+	shards := lockShards(bf.mutArr, indices, bf.shardLen)
+	defer unlockShards(bf.mutArr, shards)
+	present := true
+	for _, idx := range indices {
+		if bf.bv[idx] == 0 {
+			present = false
+		}
+		incCounter(&bf.bv[idx])
+	}
+	return present, nil
+}
+
+/*
+InsertBatch inserts every entry in entries. Every entry is hashed first, then each shard touched by the batch is locked once.
+Inserts are not atomic as a whole: concurrent lookups may see some entries of the batch before others.
+*/
+func (bf StripedCountingBloomFilter) InsertBatch(entries []string) error {
+	indices := batchIndices(bf.hasher, entries, bf.hf, bf.size)
+	order, offsets := groupByShard(indices, bf.shardLen, bf.shards)
+	for s := uint64(0); s < bf.shards; s++ {
+		if offsets[s] == offsets[s+1] {
+			continue
+		}
+		bf.mutArr[s].Lock()
+		for _, pos := range order[offsets[s]:offsets[s+1]] {
+			incCounter(&bf.bv[indices[pos]])
+		}
+		bf.mutArr[s].Unlock()
+	}
+	return nil
+}
+
+/*
+LookupBatch looks up every entry in entries and returns whether each one was found, in the same order. Every entry is hashed first, then each shard touched by the batch is locked once.
+*/
+func (bf StripedCountingBloomFilter) LookupBatch(entries []string) ([]bool, error) {
+	indices := batchIndices(bf.hasher, entries, bf.hf, bf.size)
+	order, offsets := groupByShard(indices, bf.shardLen, bf.shards)
+	results := allPresent(len(entries))
+	for s := uint64(0); s < bf.shards; s++ {
+		if offsets[s] == offsets[s+1] {
+			continue
+		}
+		bf.mutArr[s].Lock()
+		for _, pos := range order[offsets[s]:offsets[s+1]] {
+			if !results[pos/bf.hf] {
+				continue //Already known to be absent
+			}
+			if bf.bv[indices[pos]] == 0 {
+				results[pos/bf.hf] = false
+			}
+		}
+		bf.mutArr[s].Unlock()
+	}
+	return results, nil
+}
+
+/*
+Delete removes one insertion of entry from the filter. Returns ErrNotPresent (and changes nothing) if entry is not in the filter.
+Only delete entries that were actually inserted: deleting a false positive decrements other entries' counters and can make them disappear.
+Every shard holding one of the entry's counters is locked for the whole operation, so the check and the decrements are atomic.
+*/
+func (bf StripedCountingBloomFilter) Delete(entry string) error {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	indices := bf.indices(h1, h2)
+	shards := lockShards(bf.mutArr, indices, bf.shardLen)
+	defer unlockShards(bf.mutArr, shards)
+	return bf.deleteIndices(indices)
+}
+
+/*Delete without locking the filter.*/
+func (bf StripedCountingBloomFilter) DeleteAsync(entry string) error {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.deleteIndices(bf.indices(h1, h2))
+}
+
+func (bf StripedCountingBloomFilter) deleteIndices(indices []uint64) error {
+	for _, idx := range indices {
+		if bf.bv[idx] == 0 {
+			return ErrNotPresent
+		}
+	}
+	for _, idx := range indices {
+		decCounter(&bf.bv[idx])
+	}
+	return nil
+}
+
+/*Hasher returns the hasher the filter was built with.*/
+func (bf StripedCountingBloomFilter) Hasher() Hasher {
+	return bf.hasher
+}
+
+/*
+WriteTo writes the filter (counters included) to w in the package's binary format (see serialization.go) and returns the number of bytes written. It implements io.WriterTo.
+Locks one shard at a time while it is written, so concurrent inserts may be captured in some shards but not others.
+*/
+func (bf StripedCountingBloomFilter) WriteTo(w io.Writer) (int64, error) {
+	h := newFileHeader(KindStripedCounting, bf.size, bf.hf, bf.shards, bf.hasher)
+	return writeFilter(w, h, func(w io.Writer) error {
+		for s := uint64(0); s < bf.shards; s++ {
+			bf.mutArr[s].Lock()
+			_, err := w.Write(bf.bv[s*bf.shardLen : (s+1)*bf.shardLen])
+			bf.mutArr[s].Unlock()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+/*
+ReadFrom merges the filter with a serialized counting filter read from r, adding their counters, and returns the number of bytes read. It implements io.ReaderFrom.
+Exactly one serialized filter is consumed from r. It must be a counting filter with the same size, hash count and hasher.
+The filter is read and verified in full before the merge, which locks each shard once.
+*/
+func (bf StripedCountingBloomFilter) ReadFrom(r io.Reader) (int64, error) {
+	_, payload, n, err := readFilter(r, func(h fileHeader) error {
+		if err := h.checkCounting(); err != nil {
+			return err
+		}
+		return h.checkCompatible(KindStripedCounting, bf.size, bf.hf, bf.hasher)
+	})
+	if err != nil {
+		return n, err
+	}
+	bf.merge(payload)
+	return n, nil
+}
+
+func (bf StripedCountingBloomFilter) merge(counters []byte) {
+	for s := uint64(0); s < bf.shards; s++ {
+		bf.mutArr[s].Lock()
+		for i := s * bf.shardLen; i < (s+1)*bf.shardLen; i++ {
+			bf.bv[i] = addCounters(bf.bv[i], counters[i])
+		}
+		bf.mutArr[s].Unlock()
+	}
+}
+
+/*MarshalBinary returns the filter in the package's binary format. It implements encoding.BinaryMarshaler (and so also works with encoding/gob).*/
+func (bf StripedCountingBloomFilter) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := bf.WriteTo(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+/*
+UnmarshalBinary replaces the filter with a serialized counting filter, taking its size, hash count and hasher from data. It implements encoding.BinaryUnmarshaler.
+The filter's current hasher is kept if it matches the serialized one (which is the only way to unmarshal a filter using a keyed hasher). It must not be called concurrently with any other method.
+*/
+func (bf *StripedCountingBloomFilter) UnmarshalBinary(data []byte) error {
+	h, payload, err := unmarshalFilter(data, KindStripedCounting)
+	if err != nil {
+		return err
+	}
+	if err := h.checkCounting(); err != nil {
+		return err
+	}
+	hasher, err := h.resolveHasher(bf.hasher)
+	if err != nil {
+		return err
+	}
+	shards := h.shards
+	if !h.kind.striped() {
+		shards = estimateShards(h.size)
+	}
+	fresh, err := NewStripedCountingBloomFilter(h.size, h.hf, shards, WithHasher(hasher))
+	if err != nil {
+		return err
+	}
+	copy(fresh.bv, payload)
+	*bf = *fresh
+	return nil
+}
+
+/*Writes the filter to a file. See WriteTo.*/
+func (bf StripedCountingBloomFilter) Write(filename string) error {
+	return writeFile(filename, bf.WriteTo)
+}
+
+/*Merges the filter with one loaded from a file. See ReadFrom.*/
+func (bf StripedCountingBloomFilter) Load(filename string) error {
+	return readFile(filename, bf.ReadFrom)
+}
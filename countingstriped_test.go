@@ -0,0 +1,54 @@
+package hyperbloom
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestNewStripedCountingBloomFilter(t *testing.T) {
+	bf, err := NewStripedCountingBloomFilter(1048576, 4, 0)
+	assert.NotNil(t, err)
+	assert.Nil(t, bf)
+
+	bf, err = NewStripedCountingBloomFilter(1048576, 4, 3)
+	assert.NotNil(t, err)
+	assert.Nil(t, bf)
+
+	bf, err = NewStripedCountingBloomFilter(1048576, 4, 64)
+	assert.Nil(t, err)
+	assert.NotNil(t, bf)
+
+	bf, fpRate, err := NewStripedCountingBloomFilterWithEstimates(1000000, 0.01)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1<<24), bf.size)
+	assert.True(t, fpRate <= 0.01)
+}
+
+/*Run with -race: each worker inserts and deletes its own entries while the others do the same.*/
+func TestStripedCountingConcurrentDelete(t *testing.T) {
+	bf, err := NewStripedCountingBloomFilter(1<<16, 4, 16)
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				bf.Insert(fmt.Sprintf("entry-%d-%d", w, i))
+			}
+			for i := 0; i < 500; i += 2 {
+				assert.Nil(t, bf.Delete(fmt.Sprintf("entry-%d-%d", w, i)))
+			}
+		}(w)
+	}
+	wg.Wait()
+	for w := 0; w < 4; w++ {
+		for i := 1; i < 500; i += 2 {
+			exists, err := bf.Lookup(fmt.Sprintf("entry-%d-%d", w, i))
+			assert.Nil(t, err)
+			assert.True(t, exists)
+		}
+	}
+}
@@ -0,0 +1,489 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+/*ErrFilterFull is returned when an entry can't be inserted into a cuckoo filter because its buckets, and those it could kick entries out to, are full.*/
+var ErrFilterFull = errors.New("Filter is full")
+
+const (
+	cuckooMaxKicks      = 500     //Entries moved before an insert gives up
+	cuckooMaxBucketSize = 8       //Most entries per bucket
+	cuckooMaxBuckets    = 1 << 40 //Most buckets
+	cuckooBucketSize    = 4       //Entries per bucket of filters sized from estimates
+	cuckooLoadFactor    = 0.9     //Occupancy filters sized from estimates are allowed to reach
+	cuckooMinBits       = 8       //Smallest fingerprint of filters sized from estimates
+)
+
+/*Returns an error unless a cuckoo filter can have the given number of buckets, bucket size and fingerprint size.*/
+func checkCuckooParams(buckets uint64, bucketSize int, fpBits int) error {
+	if buckets < 64 {
+		return errors.New("Filter size must be at least 64")
+	} else if (buckets & (buckets - 1)) != 0 {
+		return errors.New("Size must be a power of 2")
+	} else if buckets > cuckooMaxBuckets {
+		return errors.New("Filter size can't exceed 2^40 buckets")
+	} else if bucketSize < 1 || bucketSize > cuckooMaxBucketSize {
+		return fmt.Errorf("Bucket size must be between 1 and %d", cuckooMaxBucketSize)
+	} else if fpBits < 2 || fpBits > 32 {
+		return errors.New("Fingerprint size must be between 2 and 32 bits")
+	}
+	return nil
+}
+
+/*
+CuckooFilter is a cuckoo filter (Fan et al., "Cuckoo Filter: Practically Better Than Bloom"). Rather than setting bits, it stores a small fingerprint of each entry in one of two buckets, which makes it smaller than a bloom filter at low false positive rates (roughly below 0.3%) and lets it delete entries. It uses central locking via a RWMutex.
+Each entry can live in one of two buckets. When both are full, Insert moves ("kicks") entries already in the filter to their other bucket to make room. After 500 moves it gives up, undoes them and returns ErrFilterFull, leaving the filter as it was; the filter is then close to its capacity.
+Like CountingBloomFilter, inserting an entry again stores another copy of its fingerprint (so each insertion can be deleted), which means an entry can't be inserted more than 2*bucketSize times.
+*/
+type CuckooFilter struct {
+	table      []uint64       //Fingerprints packed fpBits bits each, bucketSize consecutive ones per bucket. 0 marks an empty slot.
+	buckets    uint64         //Number of buckets. MUST BE A POWER OF 2.
+	bucketSize int            //Fingerprints per bucket
+	fpBits     int            //Size of each fingerprint in bits
+	hasher     Hasher         //Produces the base hashes for each entry
+	count      *atomic.Uint64 //Number of fingerprints held
+	mut        *sync.RWMutex  //Centralized mutex
+}
+
+/*
+NewCuckooFilter allocates a CuckooFilter with a given number of buckets, each holding bucketSize fingerprints of fpBits bits.
+Buckets must be a power of 2 and at least 64, bucketSize between 1 and 8 and fpBits between 2 and 32. Buckets of 4 fingerprints let the filter fill to about 95% before inserts fail; with fpBits bits per fingerprint the false positive rate of a full filter is about 2*bucketSize/2^fpBits.
+*/
+func NewCuckooFilter(buckets uint64, bucketSize int, fpBits int, opts ...Option) (*CuckooFilter, error) {
+	if err := checkCuckooParams(buckets, bucketSize, fpBits); err != nil {
+		return nil, err
+	}
+	var cf CuckooFilter
+	cf.buckets = buckets
+	cf.bucketSize = bucketSize
+	cf.fpBits = fpBits
+	cf.table = make([]uint64, buckets*uint64(bucketSize)*uint64(fpBits)/64)
+	cf.hasher = buildOptions(opts).hasher
+	cf.count = &atomic.Uint64{}
+	cf.mut = &sync.RWMutex{}
+	return &cf, nil
+}
+
+/*
+NewCuckooFilterWithEstimates allocates a CuckooFilter sized for n entries at a target false positive rate p: buckets of 4 fingerprints, enough of them to hold n entries at 90% occupancy and the smallest fingerprint (at least 8 bits) that meets p.
+It also returns the false positive rate expected once n entries have been inserted.
+*/
+func NewCuckooFilterWithEstimates(n uint64, p float64, opts ...Option) (*CuckooFilter, float64, error) {
+	if n == 0 {
+		return nil, 0, errors.New("Expected number of entries must be nonzero")
+	} else if !(p > 0 && p < 1) {
+		return nil, 0, errors.New("False positive rate must be between 0 and 1")
+	}
+	optimal := math.Ceil(float64(n) / (cuckooBucketSize * cuckooLoadFactor))
+	if optimal > cuckooMaxBuckets {
+		return nil, 0, errors.New("Filter for these estimates would exceed 2^40 buckets")
+	}
+	buckets := max(64, nextPowerOf2(uint64(optimal)))
+	for fpBits := cuckooMinBits; fpBits <= 32; fpBits++ {
+		if fpRate := cuckooFalsePositiveRate(buckets, cuckooBucketSize, fpBits, n); fpRate <= p {
+			cf, err := NewCuckooFilter(buckets, cuckooBucketSize, fpBits, opts...)
+			if err != nil {
+				return nil, 0, err
+			}
+			return cf, fpRate, nil
+		}
+	}
+	return nil, 0, errors.New("False positive rate too low for a cuckoo filter (fingerprints are at most 32 bits)")
+}
+
+/*
+Returns the expected false positive rate of a cuckoo filter holding n entries. A lookup compares the entry's fingerprint with those in its two buckets, each of which matches with probability 1/(2^fpBits - 1).
+*/
+func cuckooFalsePositiveRate(buckets uint64, bucketSize int, fpBits int, n uint64) float64 {
+	occupancy := min(1, float64(n)/float64(buckets*uint64(bucketSize)))
+	return 1 - math.Pow(1-1/(math.Exp2(float64(fpBits))-1), 2*float64(bucketSize)*occupancy)
+}
+
+/*Count returns the number of entries in the filter.*/
+func (cf CuckooFilter) Count() uint64 {
+	return cf.count.Load()
+}
+
+/*Capacity returns the number of fingerprints the filter has room for. Inserts start failing somewhat before Count reaches it.*/
+func (cf CuckooFilter) Capacity() uint64 {
+	return cf.buckets * uint64(cf.bucketSize)
+}
+
+/*
+Returns an entry's first bucket and fingerprint. The fingerprint comes from the top bits of h2 and is never 0, which marks empty slots.
+*/
+func (cf CuckooFilter) locate(h1, h2 uint64) (uint64, uint32) {
+	fp := uint32(h2 >> (64 - cf.fpBits))
+	if fp == 0 {
+		fp = 1
+	}
+	return h1 & (cf.buckets - 1), fp
+}
+
+/*
+Returns the other bucket a fingerprint in bucket i can live in. Only the fingerprint is known when an entry is kicked out, so the two buckets differ by a hash of it (partial-key cuckoo hashing), which also makes altIndex its own inverse.
+*/
+func (cf CuckooFilter) altIndex(i uint64, fp uint32) uint64 {
+	x := uint64(fp) * 0x9e3779b97f4a7c15
+	return (i ^ x ^ (x >> 29)) & (cf.buckets - 1)
+}
+
+/*Returns the fingerprint in slot s (bucket s/bucketSize).*/
+func (cf CuckooFilter) slot(s uint64) uint32 {
+	bit := s * uint64(cf.fpBits)
+	w, off := bit/64, bit%64
+	v := cf.table[w] >> off
+	if off+uint64(cf.fpBits) > 64 {
+		v |= cf.table[w+1] << (64 - off)
+	}
+	return uint32(v & (1<<cf.fpBits - 1))
+}
+
+func (cf CuckooFilter) setSlot(s uint64, fp uint32) {
+	bit := s * uint64(cf.fpBits)
+	w, off := bit/64, bit%64
+	mask := uint64(1)<<cf.fpBits - 1
+	cf.table[w] = cf.table[w]&^(mask<<off) | uint64(fp)<<off
+	if off+uint64(cf.fpBits) > 64 {
+		cf.table[w+1] = cf.table[w+1]&^(mask>>(64-off)) | uint64(fp)>>(64-off)
+	}
+}
+
+/*Returns the slot holding fp in bucket i, or false if there is none.*/
+func (cf CuckooFilter) find(i uint64, fp uint32) (uint64, bool) {
+	for s := i * uint64(cf.bucketSize); s < (i+1)*uint64(cf.bucketSize); s++ {
+		if cf.slot(s) == fp {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+/*Callers hold the lock (or don't, for the *Async methods).*/
+func (cf CuckooFilter) lookupHashes(h1, h2 uint64) bool {
+	i, fp := cf.locate(h1, h2)
+	if _, ok := cf.find(i, fp); ok {
+		return true
+	}
+	_, ok := cf.find(cf.altIndex(i, fp), fp)
+	return ok
+}
+
+func (cf CuckooFilter) insertHashes(h1, h2 uint64) error {
+	i, fp := cf.locate(h1, h2)
+	if err := cf.insertFingerprint(i, fp); err != nil {
+		return err
+	}
+	cf.count.Add(1)
+	return nil
+}
+
+/*
+Stores fp in bucket i or its alternate, kicking other fingerprints out to their alternate buckets if both are full. Which fingerprint gets kicked out is picked pseudo randomly from fp and i, so the same inserts always leave the filter in the same state.
+If no free slot turns up within cuckooMaxKicks moves they are all undone and ErrFilterFull returned.
+*/
+func (cf CuckooFilter) insertFingerprint(i uint64, fp uint32) error {
+	alt := cf.altIndex(i, fp)
+	if s, ok := cf.find(i, 0); ok {
+		cf.setSlot(s, fp)
+		return nil
+	} else if s, ok := cf.find(alt, 0); ok {
+		cf.setSlot(s, fp)
+		return nil
+	}
+	var undo [cuckooMaxKicks]struct {
+		s  uint64
+		fp uint32
+	}
+	state := (uint64(fp)*0x9e3779b97f4a7c15 ^ i) | 1
+	if state&2 != 0 {
+		i = alt
+	}
+	for n := 0; n < cuckooMaxKicks; n++ {
+		//xorshift64
+		state ^= state << 13
+		state ^= state >> 7
+		state ^= state << 17
+		s := i*uint64(cf.bucketSize) + state%uint64(cf.bucketSize)
+		undo[n].s, undo[n].fp = s, cf.slot(s)
+		cf.setSlot(s, fp)
+		fp = undo[n].fp
+		i = cf.altIndex(i, fp)
+		if s, ok := cf.find(i, 0); ok {
+			cf.setSlot(s, fp)
+			return nil
+		}
+	}
+	for n := cuckooMaxKicks - 1; n >= 0; n-- {
+		cf.setSlot(undo[n].s, undo[n].fp)
+	}
+	return ErrFilterFull
+}
+
+/*Looks up an entry in the CuckooFilter. Returns true if a match is found, false otherwise. Takes a reader lock on the filter.*/
+func (cf CuckooFilter) Lookup(entry string) (bool, error) {
+	h1, h2 := stringHashes(cf.hasher, entry)
+	cf.mut.RLock()
+	defer cf.mut.RUnlock()
+	return cf.lookupHashes(h1, h2), nil
+}
+
+/*Looks up an entry in the CuckooFilter. Returns true if a match is found, false otherwise. This won't lock the filter.*/
+func (cf CuckooFilter) LookupAsync(entry string) (bool, error) {
+	h1, h2 := stringHashes(cf.hasher, entry)
+	return cf.lookupHashes(h1, h2), nil
+}
+
+/*LookupBytes is Lookup for a byte slice entry. It gives the same answer as Lookup(string(entry)) without converting or allocating.*/
+func (cf CuckooFilter) LookupBytes(entry []byte) (bool, error) {
+	h1, h2 := baseHashes(cf.hasher, entry)
+	cf.mut.RLock()
+	defer cf.mut.RUnlock()
+	return cf.lookupHashes(h1, h2), nil
+}
+
+/*LookupUint64 is Lookup for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (cf CuckooFilter) LookupUint64(entry uint64) (bool, error) {
+	h1, h2 := uint64Hashes(cf.hasher, entry)
+	cf.mut.RLock()
+	defer cf.mut.RUnlock()
+	return cf.lookupHashes(h1, h2), nil
+}
+
+/*LookupBatch looks up every entry in entries and returns whether each one was found, in the same order. Takes the reader lock once for the whole batch.*/
+func (cf CuckooFilter) LookupBatch(entries []string) ([]bool, error) {
+	hashes := batchHashes(cf.hasher, entries)
+	results := make([]bool, len(entries))
+	cf.mut.RLock()
+	for e := 0; e < len(hashes); e += 2 {
+		results[e/2] = cf.lookupHashes(hashes[e], hashes[e+1])
+	}
+	cf.mut.RUnlock()
+	return results, nil
+}
+
+/*Inserts an entry into the CuckooFilter. Returns ErrFilterFull if there's no room for it. Locks the filter.*/
+func (cf CuckooFilter) Insert(entry string) error {
+	h1, h2 := stringHashes(cf.hasher, entry)
+	cf.mut.Lock()
+	defer cf.mut.Unlock()
+	return cf.insertHashes(h1, h2)
+}
+
+/*Inserts an entry into the CuckooFilter. Returns ErrFilterFull if there's no room for it. Doesn't lock the filter.*/
+func (cf CuckooFilter) InsertAsync(entry string) error {
+	h1, h2 := stringHashes(cf.hasher, entry)
+	return cf.insertHashes(h1, h2)
+}
+
+/*InsertBytes is Insert for a byte slice entry. It is equivalent to Insert(string(entry)) without converting or allocating.*/
+func (cf CuckooFilter) InsertBytes(entry []byte) error {
+	h1, h2 := baseHashes(cf.hasher, entry)
+	cf.mut.Lock()
+	defer cf.mut.Unlock()
+	return cf.insertHashes(h1, h2)
+}
+
+/*InsertUint64 is Insert for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (cf CuckooFilter) InsertUint64(entry uint64) error {
+	h1, h2 := uint64Hashes(cf.hasher, entry)
+	cf.mut.Lock()
+	defer cf.mut.Unlock()
+	return cf.insertHashes(h1, h2)
+}
+
+/*
+InsertBatch inserts every entry in entries. Takes the write lock once for the whole batch.
+It stops at the first entry that doesn't fit and returns ErrFilterFull; the entries before it stay inserted.
+*/
+func (cf CuckooFilter) InsertBatch(entries []string) error {
+	hashes := batchHashes(cf.hasher, entries)
+	cf.mut.Lock()
+	defer cf.mut.Unlock()
+	for e := 0; e < len(hashes); e += 2 {
+		if err := cf.insertHashes(hashes[e], hashes[e+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+TestAndInsert inserts entry unless it is (probably) already present, and reports whether it was, as a single operation. Unlike Insert it never stores a second copy of an entry.
+Holds the write lock for the whole operation: of any number of concurrent TestAndInsert calls for the same entry, exactly one reports it absent.
+*/
+func (cf CuckooFilter) TestAndInsert(entry string) (bool, error) {
+	h1, h2 := stringHashes(cf.hasher, entry)
+	cf.mut.Lock()
+	defer cf.mut.Unlock()
+	if cf.lookupHashes(h1, h2) {
+		return true, nil
+	}
+	return false, cf.insertHashes(h1, h2)
+}
+
+/*
+Delete removes one insertion of entry from the filter. Returns ErrNotPresent (and changes nothing) if entry is not in the filter.
+Only delete entries that were actually inserted: deleting a false positive removes another entry's fingerprint.
+Holds the write lock for the whole operation.
+*/
+func (cf CuckooFilter) Delete(entry string) error {
+	h1, h2 := stringHashes(cf.hasher, entry)
+	cf.mut.Lock()
+	defer cf.mut.Unlock()
+	return cf.deleteHashes(h1, h2)
+}
+
+/*Delete without locking the filter.*/
+func (cf CuckooFilter) DeleteAsync(entry string) error {
+	h1, h2 := stringHashes(cf.hasher, entry)
+	return cf.deleteHashes(h1, h2)
+}
+
+func (cf CuckooFilter) deleteHashes(h1, h2 uint64) error {
+	i, fp := cf.locate(h1, h2)
+	s, ok := cf.find(i, fp)
+	if !ok {
+		if s, ok = cf.find(cf.altIndex(i, fp), fp); !ok {
+			return ErrNotPresent
+		}
+	}
+	cf.setSlot(s, 0)
+	cf.count.Add(^uint64(0))
+	return nil
+}
+
+/*Hasher returns the hasher the filter was built with.*/
+func (cf CuckooFilter) Hasher() Hasher {
+	return cf.hasher
+}
+
+func (cf CuckooFilter) fileHeader() fileHeader {
+	h := fileHeader{kind: KindCuckoo, hasher: cf.hasher.ID(), hf: cf.fpBits, bucketSize: cf.bucketSize, size: cf.buckets, seed: cf.hasher.Seed()}
+	h.payloadLen = h.expectedPayloadLen()
+	return h
+}
+
+/*Returns an error unless the serialized filter is a cuckoo filter with the given parameters.*/
+func (h fileHeader) checkCuckoo(buckets uint64, bucketSize int, fpBits int, hasher Hasher) error {
+	if err := h.checkKind(KindCuckoo); err != nil {
+		return err
+	} else if h.bucketSize != bucketSize {
+		return fmt.Errorf("File filter has %d entries per bucket, this filter has %d", h.bucketSize, bucketSize)
+	} else if h.hf != fpBits {
+		return fmt.Errorf("File filter uses %d bit fingerprints, this filter uses %d", h.hf, fpBits)
+	}
+	return h.checkParams(buckets, fpBits, hasher)
+}
+
+/*
+WriteTo writes the filter to w in the package's binary format (see serialization.go) and returns the number of bytes written. It implements io.WriterTo.
+Takes a reader lock on the filter while it is written.
+*/
+func (cf CuckooFilter) WriteTo(w io.Writer) (int64, error) {
+	return writeFilter(w, cf.fileHeader(), func(w io.Writer) error {
+		cf.mut.RLock()
+		defer cf.mut.RUnlock()
+		return writeWords(w, cf.table)
+	})
+}
+
+/*
+ReadFrom merges the filter with a serialized cuckoo filter read from r, inserting every entry it holds, and returns the number of bytes read. It implements io.ReaderFrom.
+Exactly one serialized filter is consumed from r. It must be a cuckoo filter with the same number of buckets, bucket size, fingerprint size and hasher.
+The filter is read and verified in full before the filter is locked, once, for the merge. If the entries don't all fit, ErrFilterFull is returned and the filter is left as it was.
+*/
+func (cf CuckooFilter) ReadFrom(r io.Reader) (int64, error) {
+	_, payload, n, err := readFilter(r, func(h fileHeader) error {
+		return h.checkCuckoo(cf.buckets, cf.bucketSize, cf.fpBits, cf.hasher)
+	})
+	if err != nil {
+		return n, err
+	}
+	from := cf
+	from.table = cuckooPayloadWords(payload)
+	cf.mut.Lock()
+	defer cf.mut.Unlock()
+	return n, cf.merge(from)
+}
+
+/*Inserts every fingerprint of from into a copy of the table, which replaces the table only if they all fit.*/
+func (cf CuckooFilter) merge(from CuckooFilter) error {
+	into := cf
+	into.table = append([]uint64(nil), cf.table...)
+	added := uint64(0)
+	for s := uint64(0); s < from.Capacity(); s++ {
+		if fp := from.slot(s); fp != 0 {
+			if err := into.insertFingerprint(s/uint64(cf.bucketSize), fp); err != nil {
+				return err
+			}
+			added++
+		}
+	}
+	copy(cf.table, into.table)
+	cf.count.Add(added)
+	return nil
+}
+
+func cuckooPayloadWords(payload []byte) []uint64 {
+	words := make([]uint64, len(payload)/8)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint64(payload[i*8:])
+	}
+	return words
+}
+
+/*MarshalBinary returns the filter in the package's binary format. It implements encoding.BinaryMarshaler (and so also works with encoding/gob).*/
+func (cf CuckooFilter) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := cf.WriteTo(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+/*
+UnmarshalBinary replaces the filter with a serialized cuckoo filter, taking its number of buckets, bucket size, fingerprint size and hasher from data. It implements encoding.BinaryUnmarshaler.
+The filter's current hasher is kept if it matches the serialized one (which is the only way to unmarshal a filter using a keyed hasher). It must not be called concurrently with any other method.
+*/
+func (cf *CuckooFilter) UnmarshalBinary(data []byte) error {
+	h, payload, err := unmarshalFilter(data, KindCuckoo)
+	if err != nil {
+		return err
+	}
+	hasher, err := h.resolveHasher(cf.hasher)
+	if err != nil {
+		return err
+	}
+	fresh, err := NewCuckooFilter(h.size, h.bucketSize, h.hf, WithHasher(hasher))
+	if err != nil {
+		return err
+	}
+	copy(fresh.table, cuckooPayloadWords(payload))
+	for s := uint64(0); s < fresh.Capacity(); s++ {
+		if fresh.slot(s) != 0 {
+			fresh.count.Add(1)
+		}
+	}
+	*cf = *fresh
+	return nil
+}
+
+/*Writes the filter to a file. See WriteTo.*/
+func (cf CuckooFilter) Write(filename string) error {
+	return writeFile(filename, cf.WriteTo)
+}
+
+/*Merges the filter with one loaded from a file. See ReadFrom.*/
+func (cf CuckooFilter) Load(filename string) error {
+	return readFile(filename, cf.ReadFrom)
+}
@@ -0,0 +1,202 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewCuckooFilter(t *testing.T) {
+	for _, params := range []struct {
+		buckets        uint64
+		bucketSize, fp int
+	}{{32, 4, 8}, {100, 4, 8}, {64, 0, 8}, {64, 9, 8}, {64, 4, 1}, {64, 4, 33}} {
+		cf, err := NewCuckooFilter(params.buckets, params.bucketSize, params.fp)
+		assert.NotNil(t, err, "%v", params)
+		assert.Nil(t, cf)
+	}
+	cf, err := NewCuckooFilter(1024, 4, 12)
+	assert.Nil(t, err)
+	assert.Equal(t, 1024*4*12/64, len(cf.table))
+	assert.Equal(t, uint64(4096), cf.Capacity())
+	assert.Equal(t, uint64(0), cf.Count())
+}
+
+/*Fingerprints that straddle two words must read back intact without disturbing their neighbours.*/
+func TestCuckooSlots(t *testing.T) {
+	for _, fpBits := range []int{2, 7, 12, 16, 31, 32} {
+		cf, err := NewCuckooFilter(64, 3, fpBits)
+		assert.Nil(t, err)
+		mask := uint32(1<<fpBits - 1)
+		for s := uint64(0); s < cf.Capacity(); s++ {
+			cf.setSlot(s, uint32(s*2654435761)&mask)
+		}
+		for s := uint64(0); s < cf.Capacity(); s++ {
+			assert.Equal(t, uint32(s*2654435761)&mask, cf.slot(s), "%d bits, slot %d", fpBits, s)
+		}
+	}
+}
+
+func TestCuckooAltIndex(t *testing.T) {
+	cf, err := NewCuckooFilter(1024, 4, 16)
+	assert.Nil(t, err)
+	for i := uint64(0); i < 1024; i += 37 {
+		for fp := uint32(1); fp < 1<<16; fp += 997 {
+			alt := cf.altIndex(i, fp)
+			assert.True(t, alt < 1024)
+			assert.Equal(t, i, cf.altIndex(alt, fp))
+		}
+	}
+}
+
+func TestCuckooDelete(t *testing.T) {
+	cf, err := NewCuckooFilter(1024, 4, 16)
+	assert.Nil(t, err)
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, cf.Insert(fmt.Sprintf("entry-%d", i)))
+	}
+	assert.Equal(t, uint64(1000), cf.Count())
+	for i := 0; i < 1000; i += 2 {
+		assert.Nil(t, cf.Delete(fmt.Sprintf("entry-%d", i)))
+	}
+	assert.Equal(t, uint64(500), cf.Count())
+	for i := 0; i < 1000; i++ {
+		exists, err := cf.Lookup(fmt.Sprintf("entry-%d", i))
+		assert.Nil(t, err)
+		if i%2 == 1 {
+			assert.True(t, exists)
+		}
+	}
+	assert.Equal(t, ErrNotPresent, cf.Delete("hahaidontexist"))
+	assert.Equal(t, uint64(500), cf.Count())
+
+	//Each insertion needs its own deletion
+	assert.Nil(t, cf.Insert("twice"))
+	assert.Nil(t, cf.InsertAsync("twice"))
+	assert.Nil(t, cf.Delete("twice"))
+	exists, _ := cf.Lookup("twice")
+	assert.True(t, exists)
+	assert.Nil(t, cf.DeleteAsync("twice"))
+	exists, _ = cf.Lookup("twice")
+	assert.False(t, exists)
+	assert.Equal(t, ErrNotPresent, cf.Delete("twice"))
+}
+
+/*Inserting past capacity must fail cleanly: the failed insert is undone and nothing inserted before it is lost.*/
+func TestCuckooFull(t *testing.T) {
+	cf, err := NewCuckooFilter(64, 4, 16)
+	assert.Nil(t, err)
+	inserted := 0
+	for ; ; inserted++ {
+		before, err := cf.MarshalBinary()
+		assert.Nil(t, err)
+		err = cf.Insert(fmt.Sprintf("entry-%d", inserted))
+		if err != nil {
+			assert.Equal(t, ErrFilterFull, err)
+			after, _ := cf.MarshalBinary()
+			assert.Equal(t, before, after)
+			break
+		}
+	}
+	assert.True(t, inserted > 200, "only %d inserted", inserted)
+	assert.Equal(t, uint64(inserted), cf.Count())
+	for i := 0; i < inserted; i++ {
+		exists, _ := cf.Lookup(fmt.Sprintf("entry-%d", i))
+		assert.True(t, exists)
+	}
+
+	//An entry fits 2*bucketSize times
+	cf, _ = NewCuckooFilter(64, 2, 16)
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, cf.Insert("foo"))
+	}
+	assert.Equal(t, ErrFilterFull, cf.Insert("foo"))
+	present, err := cf.TestAndInsert("foo")
+	assert.Nil(t, err)
+	assert.True(t, present)
+}
+
+func TestCuckooFalsePositiveRate(t *testing.T) {
+	const (
+		buckets = 65536
+		entries = 200000
+		probes  = 200000
+	)
+	cf, err := NewCuckooFilter(buckets, 4, 8)
+	assert.Nil(t, err)
+	for i := 0; i < entries; i++ {
+		assert.Nil(t, cf.InsertAsync(fmt.Sprintf("member-%d", i)))
+	}
+	fp := 0
+	for i := 0; i < probes; i++ {
+		if exists, _ := cf.LookupAsync(fmt.Sprintf("probe-%d", i)); exists {
+			fp++
+		}
+	}
+	expected := cuckooFalsePositiveRate(buckets, 4, 8, entries)
+	measured := float64(fp) / probes
+	assert.InDelta(t, expected, measured, expected*0.1, "measured %f, expected %f", measured, expected)
+}
+
+func TestNewCuckooFilterWithEstimates(t *testing.T) {
+	cf, fpRate, err := NewCuckooFilterWithEstimates(1000000, 0.0001)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1<<19), cf.buckets)
+	assert.Equal(t, 4, cf.bucketSize)
+	assert.Equal(t, 16, cf.fpBits)
+	assert.True(t, fpRate <= 0.0001)
+
+	//Fingerprints are at least 8 bits however loose the target
+	cf, _, err = NewCuckooFilterWithEstimates(10, 0.5)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(64), cf.buckets)
+	assert.Equal(t, 8, cf.fpBits)
+
+	_, _, err = NewCuckooFilterWithEstimates(1000, 1e-12)
+	assert.NotNil(t, err)
+	_, _, err = NewCuckooFilterWithEstimates(0, 0.01)
+	assert.NotNil(t, err)
+}
+
+func TestCuckooMerge(t *testing.T) {
+	a, err := NewCuckooFilter(64, 4, 16)
+	assert.Nil(t, err)
+	b, err := NewCuckooFilter(64, 4, 16)
+	assert.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, a.Insert(fmt.Sprintf("a-%d", i)))
+		assert.Nil(t, b.Insert(fmt.Sprintf("b-%d", i)))
+	}
+	data, err := b.MarshalBinary()
+	assert.Nil(t, err)
+	_, err = a.ReadFrom(bytes.NewReader(data))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(200), a.Count())
+	for i := 0; i < 100; i++ {
+		exists, _ := a.Lookup(fmt.Sprintf("a-%d", i))
+		assert.True(t, exists)
+		exists, _ = a.Lookup(fmt.Sprintf("b-%d", i))
+		assert.True(t, exists)
+	}
+
+	//A merge that doesn't fit leaves the filter as it was
+	before, _ := a.MarshalBinary()
+	_, err = a.ReadFrom(bytes.NewReader(before))
+	assert.Equal(t, ErrFilterFull, err)
+	after, _ := a.MarshalBinary()
+	assert.Equal(t, before, after)
+	assert.Equal(t, uint64(200), a.Count())
+
+	var restored CuckooFilter
+	assert.Nil(t, restored.UnmarshalBinary(before))
+	assert.Equal(t, uint64(200), restored.Count())
+	assert.Equal(t, a.table, restored.table)
+
+	other, _ := NewCuckooFilter(64, 4, 12)
+	_, err = other.ReadFrom(bytes.NewReader(data))
+	assert.NotNil(t, err)
+	other, _ = NewCuckooFilter(64, 2, 16)
+	_, err = other.ReadFrom(bytes.NewReader(data))
+	assert.NotNil(t, err)
+}
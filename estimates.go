@@ -0,0 +1,111 @@
+package hyperbloom
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"runtime"
+)
+
+/*
+EstimateParameters returns the size (in buckets) and number of hash functions that minimise the false positive rate of a filter holding n entries with a target false positive rate p.
+The optimal size is rounded up to the next power of 2 (and at least 64), and the hash count is then chosen for the rounded size, so the returned fpRate (the rate expected once n entries have been inserted) is never worse than p. The hash count is capped at 64, and for the tiny rates that would need more the size keeps doubling until p is met.
+*/
+func EstimateParameters(n uint64, p float64) (size uint64, hf int, fpRate float64, err error) {
+	if n == 0 {
+		return 0, 0, 0, errors.New("Expected number of entries must be nonzero")
+	} else if !(p > 0 && p < 1) {
+		return 0, 0, 0, errors.New("False positive rate must be between 0 and 1")
+	}
+	optimal := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	if optimal > 1<<63 {
+		return 0, 0, 0, errors.New("Filter for these estimates would exceed 2^63 buckets")
+	}
+	size = nextPowerOf2(uint64(optimal))
+	if size < 64 {
+		size = 64
+	}
+	for {
+		hf = min(max(1, int(math.Round(float64(size)/float64(n)*math.Ln2))), maxHashFunctions)
+		fpRate = FalsePositiveRate(size, hf, n)
+		if hf < maxHashFunctions || fpRate <= p {
+			return size, hf, fpRate, nil
+		} else if size >= 1<<62 {
+			return 0, 0, 0, errors.New("Filter for these estimates would exceed 2^63 buckets")
+		}
+		//Capped at maxHashFunctions, the rate is only met by a larger filter
+		size *= 2
+	}
+}
+
+/*Most hash functions a filter may use. Each is a probe on every insert and lookup, and no useful false positive rate needs more.*/
+const maxHashFunctions = 64
+
+/*Checks the hash count passed to a filter constructor.*/
+func checkHashes(hf int) error {
+	if hf < 1 || hf > maxHashFunctions {
+		return fmt.Errorf("Number of hash functions must be between 1 and %d", maxHashFunctions)
+	}
+	return nil
+}
+
+/*FalsePositiveRate returns the expected false positive rate of a filter of the given size and hash count once n distinct entries have been inserted.*/
+func FalsePositiveRate(size uint64, hf int, n uint64) float64 {
+	return math.Pow(1-math.Exp(-float64(hf)*float64(n)/float64(size)), float64(hf))
+}
+
+/*
+Picks a shard count for a striped filter of the given size: enough shards that lock contention stays low at the current GOMAXPROCS, without exceeding size/64.
+*/
+func estimateShards(size uint64) uint64 {
+	shards := nextPowerOf2(uint64(runtime.GOMAXPROCS(0)) * 4)
+	if shards > size/64 {
+		shards = size / 64
+	}
+	return shards
+}
+
+/*Smallest power of 2 greater than or equal to x (1 for x == 0).*/
+func nextPowerOf2(x uint64) uint64 {
+	if x <= 1 {
+		return 1
+	}
+	return 1 << (64 - bits.LeadingZeros64(x-1))
+}
+
+/*
+Returns the Swamidass-Baldi estimate of the number of distinct entries in a filter of the given size and hash count with popCount buckets set: -(size/hf) ln(1 - popCount/size).
+A full filter gives no estimate, so math.MaxUint64 is returned.
+*/
+func estimateCount(size uint64, hf int, popCount uint64) uint64 {
+	if popCount >= size {
+		return math.MaxUint64
+	}
+	return uint64(math.Round(-float64(size) / float64(hf) * math.Log1p(-float64(popCount)/float64(size))))
+}
+
+/*Returns the false positive rate of a filter of the given size and hash count with popCount buckets set: the odds that hf random buckets are all set.*/
+func fillFalsePositiveRate(size uint64, hf int, popCount uint64) float64 {
+	return math.Pow(float64(popCount)/float64(size), float64(hf))
+}
+
+/*Returns the number of bits set in words.*/
+func popCountWords(words []uint64) uint64 {
+	n := 0
+	for _, w := range words {
+		n += bits.OnesCount64(w)
+	}
+	return uint64(n)
+}
+
+/*Returns the number of nonzero bytes in bv.*/
+func popCountBytes(bv []byte) uint64 {
+	n := uint64(0)
+	for _, b := range bv {
+		if b != 0 {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,106 @@
+package hyperbloom
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestEstimateParameters(t *testing.T) {
+	//1M entries at 1% needs ~9.59M bits, so the size rounds up to 2^24 and k to round(16.78 * ln 2) = 12
+	size, hf, fpRate, err := EstimateParameters(1000000, 0.01)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1<<24), size)
+	assert.Equal(t, 12, hf)
+	assert.True(t, fpRate <= 0.01)
+	assert.InDelta(t, FalsePositiveRate(size, hf, 1000000), fpRate, 1e-12)
+
+	//Tiny filters are clamped to the 64 bucket minimum
+	size, hf, _, err = EstimateParameters(1, 0.5)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(64), size)
+	assert.True(t, hf >= 1)
+
+	//Hash counts are capped, and the size grows instead to meet tiny rates
+	size, hf, fpRate, err = EstimateParameters(1, 1e-30)
+	assert.Nil(t, err)
+	assert.Equal(t, maxHashFunctions, hf)
+	assert.True(t, fpRate <= 1e-30)
+	_, err = NewBloomFilter(size, hf)
+	assert.Nil(t, err)
+
+	_, _, _, err = EstimateParameters(0, 0.01)
+	assert.NotNil(t, err)
+	_, _, _, err = EstimateParameters(1000, 0)
+	assert.NotNil(t, err)
+	_, _, _, err = EstimateParameters(1000, 1)
+	assert.NotNil(t, err)
+	_, _, _, err = EstimateParameters(1000, math.NaN())
+	assert.NotNil(t, err)
+	_, _, _, err = EstimateParameters(math.MaxUint64, 1e-300)
+	assert.NotNil(t, err)
+}
+
+func TestNextPowerOf2(t *testing.T) {
+	assert.Equal(t, uint64(1), nextPowerOf2(0))
+	assert.Equal(t, uint64(1), nextPowerOf2(1))
+	assert.Equal(t, uint64(2), nextPowerOf2(2))
+	assert.Equal(t, uint64(4), nextPowerOf2(3))
+	assert.Equal(t, uint64(1024), nextPowerOf2(1000))
+	assert.Equal(t, uint64(1<<63), nextPowerOf2(1<<63))
+}
+
+func TestEstimateShards(t *testing.T) {
+	assert.Equal(t, uint64(1), estimateShards(64))
+	shards := estimateShards(1 << 30)
+	assert.True(t, shards >= 4)
+	assert.Equal(t, uint64(0), shards&(shards-1))
+}
+
+func TestNewFilterWithEstimates(t *testing.T) {
+	for _, spec := range conformanceSpecs {
+		f, fpRate, err := NewFilterWithEstimates(spec.Kind, 10000, 0.001)
+		assert.Nil(t, err)
+		assert.NotNil(t, f)
+		assert.True(t, fpRate <= 0.001)
+	}
+	f, _, err := NewFilterWithEstimates(KindBloom, 0, 0.001)
+	assert.NotNil(t, err)
+	assert.True(t, f == nil)
+}
+
+type fillEstimator interface {
+	Filter
+	PopCount() uint64
+	FillRatio() float64
+	EstimatedCount() uint64
+	EstimatedFalsePositiveRate() float64
+}
+
+func TestFillEstimates(t *testing.T) {
+	for _, spec := range conformanceSpecs[:4] {
+		f, err := NewFilter(Spec{Kind: spec.Kind, Size: 1 << 20, Hashes: 7, Shards: spec.Shards})
+		assert.Nil(t, err)
+		fe := f.(fillEstimator)
+		assert.Equal(t, uint64(0), fe.PopCount())
+		assert.Equal(t, uint64(0), fe.EstimatedCount())
+		assert.Equal(t, 0.0, fe.EstimatedFalsePositiveRate())
+
+		assert.Nil(t, f.InsertBatch(benchmarkEntries(100000)))
+		assert.InDelta(t, 100000, float64(fe.EstimatedCount()), 1000, spec.Kind.String())
+		assert.InDelta(t, 1-math.Exp(-7*100000.0/(1<<20)), fe.FillRatio(), 0.005, spec.Kind.String())
+		assert.InDelta(t, FalsePositiveRate(1<<20, 7, 100000), fe.EstimatedFalsePositiveRate(), 0.001, spec.Kind.String())
+		assert.Equal(t, float64(fe.PopCount())/(1<<20), fe.FillRatio())
+	}
+}
+
+func TestEstimateCount(t *testing.T) {
+	assert.Equal(t, uint64(0), estimateCount(1024, 3, 0))
+	assert.Equal(t, uint64(math.MaxUint64), estimateCount(1024, 3, 1024))
+	//A single entry sets hf bits (barring collisions)
+	assert.Equal(t, uint64(1), estimateCount(1<<20, 3, 3))
+	assert.Equal(t, 1.0, fillFalsePositiveRate(1024, 3, 1024))
+	assert.Equal(t, 0.125, fillFalsePositiveRate(1024, 3, 512))
+	assert.Equal(t, uint64(3), popCountWords([]uint64{1, 0, 1<<63 | 1}))
+	assert.Equal(t, uint64(2), popCountBytes([]byte{0, 1, 0, 255}))
+}
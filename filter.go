@@ -0,0 +1,386 @@
+package hyperbloom
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+Inserter is implemented by every filter that can have entries added to it.
+*/
+type Inserter interface {
+	Insert(entry string) error
+	InsertAsync(entry string) error
+	InsertBytes(entry []byte) error
+	InsertUint64(entry uint64) error
+	InsertBatch(entries []string) error
+}
+
+/*
+Querier is implemented by every filter that can be probed for membership.
+*/
+type Querier interface {
+	Lookup(entry string) (bool, error)
+	LookupAsync(entry string) (bool, error)
+	LookupBytes(entry []byte) (bool, error)
+	LookupUint64(entry uint64) (bool, error)
+	LookupBatch(entries []string) ([]bool, error)
+}
+
+/*
+Deleter is implemented by the filters that can remove entries: CountingBloomFilter, StripedCountingBloomFilter and CuckooFilter.
+*/
+type Deleter interface {
+	Delete(entry string) error
+	DeleteAsync(entry string) error
+}
+
+/*
+Filter is the method set shared by every Bloom filter variant in this package.
+Use it (together with NewFilter) when the variant should be chosen at runtime, e.g. from configuration.
+*/
+type Filter interface {
+	Inserter
+	Querier
+	io.WriterTo
+	io.ReaderFrom
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+	TestAndInsert(entry string) (bool, error)
+	Write(filename string) error
+	Load(filename string) error
+}
+
+var (
+	_ Filter = (*BloomFilter)(nil)
+	_ Filter = (*StripedBloomFilter)(nil)
+	_ Filter = (*NaiveBloomFilter)(nil)
+	_ Filter = (*NaiveStripedBloomFilter)(nil)
+	_ Filter = (*AtomicBloomFilter)(nil)
+	_ Filter = (*CountingBloomFilter)(nil)
+	_ Filter = (*StripedCountingBloomFilter)(nil)
+	_ Filter = (*ScalableBloomFilter)(nil)
+	_ Filter = (*BlockedBloomFilter)(nil)
+	_ Filter = (*StripedBlockedBloomFilter)(nil)
+	_ Filter = (*CuckooFilter)(nil)
+
+	_ Deleter = (*CountingBloomFilter)(nil)
+	_ Deleter = (*StripedCountingBloomFilter)(nil)
+	_ Deleter = (*CuckooFilter)(nil)
+
+	_ Querier = (*XorFilter)(nil)
+	_ Querier = (*BinaryFuseFilter)(nil)
+
+	_ Inserter = (*HyperLogLog)(nil)
+	_ Inserter = (*StripedHyperLogLog)(nil)
+)
+
+/*
+Kind identifies one of the filter variants provided by this package.
+*/
+type Kind uint8
+
+const (
+	KindBloom              Kind = iota //BloomFilter
+	KindStriped                        //StripedBloomFilter
+	KindNaive                          //NaiveBloomFilter
+	KindNaiveStriped                   //NaiveStripedBloomFilter
+	KindAtomic                         //AtomicBloomFilter
+	KindCounting                       //CountingBloomFilter
+	KindStripedCounting                //StripedCountingBloomFilter
+	KindScalable                       //ScalableBloomFilter with BloomFilter layers
+	KindStripedScalable                //ScalableBloomFilter with StripedBloomFilter layers
+	KindBlocked                        //BlockedBloomFilter
+	KindStripedBlocked                 //StripedBlockedBloomFilter
+	KindCuckoo                         //CuckooFilter
+	KindXor                            //XorFilter
+	KindBinaryFuse                     //BinaryFuseFilter
+	KindHyperLogLog                    //HyperLogLog
+	KindStripedHyperLogLog             //StripedHyperLogLog
+)
+
+var kindNames = map[Kind]string{
+	KindBloom:              "bloom",
+	KindStriped:            "striped",
+	KindNaive:              "naive",
+	KindNaiveStriped:       "naivestriped",
+	KindAtomic:             "atomic",
+	KindCounting:           "counting",
+	KindStripedCounting:    "stripedcounting",
+	KindScalable:           "scalable",
+	KindStripedScalable:    "stripedscalable",
+	KindBlocked:            "blocked",
+	KindStripedBlocked:     "stripedblocked",
+	KindCuckoo:             "cuckoo",
+	KindXor:                "xor",
+	KindBinaryFuse:         "binaryfuse",
+	KindHyperLogLog:        "hyperloglog",
+	KindStripedHyperLogLog: "stripedhyperloglog",
+}
+
+/*String returns the name of the kind as accepted by ParseKind.*/
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("Kind(%d)", uint8(k))
+}
+
+/*Reports whether the kind takes a shard count.*/
+func (k Kind) striped() bool {
+	return k == KindStriped || k == KindNaiveStriped || k == KindStripedCounting || k == KindStripedBlocked
+}
+
+/*Reports whether the kind keeps each entry's bits in a single block.*/
+func (k Kind) blocked() bool {
+	return k == KindBlocked || k == KindStripedBlocked
+}
+
+/*Reports whether the kind grows by adding layers, and so is only built from estimates.*/
+func (k Kind) scalable() bool {
+	return k == KindScalable || k == KindStripedScalable
+}
+
+/*Reports whether the kind is built once from a complete key set, and so isn't a Filter.*/
+func (k Kind) static() bool {
+	return k == KindXor || k == KindBinaryFuse
+}
+
+/*Reports whether the kind is a cardinality sketch rather than a filter.*/
+func (k Kind) sketch() bool {
+	return k == KindHyperLogLog || k == KindStripedHyperLogLog
+}
+
+/*Reports whether the kind keeps a counter per bucket (and so supports Delete).*/
+func (k Kind) counting() bool {
+	return k == KindCounting || k == KindStripedCounting
+}
+
+/*ParseKind returns the Kind with the given name ("bloom", "striped", "naive", "naivestriped", "atomic", "counting", "stripedcounting", "scalable", "stripedscalable", "blocked", "stripedblocked", "cuckoo", "xor", "binaryfuse", "hyperloglog" or "stripedhyperloglog"). Matching is case insensitive.*/
+func ParseKind(name string) (Kind, error) {
+	for k, n := range kindNames {
+		if strings.EqualFold(n, name) {
+			return k, nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown filter kind: %q", name)
+}
+
+/*
+Spec describes a filter to be built by NewFilter.
+Shards is only used by the striped kinds, and BucketSize and FingerprintBits only by KindCuckoo (which takes no Hashes); they must be left at zero for the others.
+*/
+type Spec struct {
+	Kind            Kind   //Which variant to build
+	Size            uint64 //Size of the filter in buckets. MUST BE A POWER OF 2.
+	Hashes          int    //Number of hash functions, 1 to 64
+	Shards          uint64 //Number of shards (striped kinds only)
+	BucketSize      int    //Entries per bucket (cuckoo only)
+	FingerprintBits int    //Size of each fingerprint in bits (cuckoo only)
+	Hasher          Hasher //Hasher to use. DefaultHasher if nil.
+}
+
+/*NewFilter allocates the filter variant described by spec. The same restrictions as the variant's own constructor apply.*/
+func NewFilter(spec Spec) (Filter, error) {
+	if spec.Kind.scalable() {
+		return nil, fmt.Errorf("A %s filter grows from estimates, build it with NewFilterWithEstimates", spec.Kind)
+	} else if spec.Kind.static() {
+		return nil, fmt.Errorf("A %s filter is built from its keys, see BuildXorFilter and BuildBinaryFuseFilter", spec.Kind)
+	} else if spec.Kind.sketch() {
+		return nil, fmt.Errorf("A %s is a cardinality sketch, see NewHyperLogLog and NewStripedHyperLogLog", spec.Kind)
+	} else if spec.Shards != 0 && !spec.Kind.striped() {
+		return nil, fmt.Errorf("Shards can't be set for a %s filter", spec.Kind)
+	} else if spec.Kind == KindCuckoo && spec.Hashes != 0 {
+		return nil, errors.New("A cuckoo filter stores fingerprints, set FingerprintBits rather than Hashes")
+	} else if spec.Kind != KindCuckoo && (spec.BucketSize != 0 || spec.FingerprintBits != 0) {
+		return nil, fmt.Errorf("BucketSize and FingerprintBits can't be set for a %s filter", spec.Kind)
+	}
+	return newFilter(spec, WithHasher(spec.Hasher))
+}
+
+/*
+NewFilterWithEstimates allocates a filter of the given kind sized for n entries at a target false positive rate p (see EstimateParameters).
+Striped kinds get a shard count picked from GOMAXPROCS. It also returns the false positive rate expected once n entries have been inserted.
+Scalable kinds start with a layer for n entries and keep the false positive rate of the whole stack below p as they grow, so the rate returned for them is p. KindCuckoo is sized as by NewCuckooFilterWithEstimates.
+*/
+func NewFilterWithEstimates(kind Kind, n uint64, p float64, opts ...Option) (Filter, float64, error) {
+	if kind.static() {
+		return nil, 0, fmt.Errorf("A %s filter is built from its keys, see BuildXorFilter and BuildBinaryFuseFilter", kind)
+	} else if kind.sketch() {
+		return nil, 0, fmt.Errorf("A %s is a cardinality sketch, see NewHyperLogLog and NewStripedHyperLogLog", kind)
+	} else if kind.scalable() {
+		sbf, err := newScalableBloomFilter(kind, n, p, opts...)
+		if err != nil {
+			return nil, 0, err
+		}
+		return sbf, sbf.fpRate, nil
+	} else if kind == KindCuckoo {
+		cf, fpRate, err := NewCuckooFilterWithEstimates(n, p, opts...)
+		if err != nil {
+			return nil, 0, err
+		}
+		return cf, fpRate, nil
+	}
+	estimate := EstimateParameters
+	if kind.blocked() {
+		estimate = estimateBlockedParameters
+	}
+	size, hf, fpRate, err := estimate(n, p)
+	if err != nil {
+		return nil, 0, err
+	}
+	spec := Spec{Kind: kind, Size: size, Hashes: hf}
+	if kind == KindStripedBlocked {
+		spec.Shards = estimateBlockedShards(size)
+	} else if kind.striped() {
+		spec.Shards = estimateShards(size)
+	}
+	f, err := newFilter(spec, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return f, fpRate, nil
+}
+
+func newFilter(spec Spec, opts ...Option) (Filter, error) {
+	switch spec.Kind {
+	case KindBloom:
+		bf, err := NewBloomFilter(spec.Size, spec.Hashes, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return bf, nil
+	case KindStriped:
+		bf, err := NewStripedBloomFilter(spec.Size, spec.Hashes, spec.Shards, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return bf, nil
+	case KindNaive:
+		bf, err := NewNaiveBloomFilter(spec.Size, spec.Hashes, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return bf, nil
+	case KindNaiveStriped:
+		bf, err := NewNaiveStripedBloomFilter(spec.Size, spec.Hashes, spec.Shards, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return bf, nil
+	case KindAtomic:
+		bf, err := NewAtomicBloomFilter(spec.Size, spec.Hashes, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return bf, nil
+	case KindCounting:
+		bf, err := NewCountingBloomFilter(spec.Size, spec.Hashes, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return bf, nil
+	case KindStripedCounting:
+		bf, err := NewStripedCountingBloomFilter(spec.Size, spec.Hashes, spec.Shards, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return bf, nil
+	case KindBlocked:
+		bf, err := NewBlockedBloomFilter(spec.Size, spec.Hashes, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return bf, nil
+	case KindStripedBlocked:
+		bf, err := NewStripedBlockedBloomFilter(spec.Size, spec.Hashes, spec.Shards, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return bf, nil
+	case KindCuckoo:
+		cf, err := NewCuckooFilter(spec.Size, spec.BucketSize, spec.FingerprintBits, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return cf, nil
+	}
+	return nil, errors.New("Unknown filter kind")
+}
+
+/*
+ParseSpec returns the Spec of a filter serialized in the package's binary format (see serialization.go), reading only its 48 byte header. Scalable filters report their first layer, static filters the length of their fingerprint array as Size and sketches their number of registers.
+The hasher passed with WithHasher is kept if it matches the serialized one (which is the only way to parse the spec of a filter using a keyed hasher).
+*/
+func ParseSpec(data []byte, opts ...Option) (Spec, error) {
+	if len(data) < headerLen {
+		return Spec{}, io.ErrUnexpectedEOF
+	}
+	h, err := parseFileHeader(data[:headerLen])
+	if err != nil {
+		return Spec{}, err
+	}
+	hasher, err := h.resolveHasher(buildOptions(opts).hasher)
+	if err != nil {
+		return Spec{}, err
+	}
+	spec := Spec{Kind: h.kind, Size: h.size, Hashes: h.hf, Shards: h.shards, Hasher: hasher}
+	if h.kind == KindCuckoo {
+		spec.Hashes, spec.BucketSize, spec.FingerprintBits = 0, h.bucketSize, h.hf
+	}
+	return spec, nil
+}
+
+/*
+UnmarshalFilter allocates the variant serialized in data and unmarshals it (see UnmarshalBinary), for when the kind of a saved filter isn't known in advance. The hasher passed with WithHasher is used if it matches the serialized one.
+Static filters and sketches aren't Filters, so they are rejected; unmarshal them with their own UnmarshalBinary.
+*/
+func UnmarshalFilter(data []byte, opts ...Option) (Filter, error) {
+	spec, err := ParseSpec(data, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalFilterAs(spec.Kind, data, WithHasher(spec.Hasher))
+}
+
+/*
+UnmarshalFilterAs allocates a filter of the given kind and unmarshals data into it, converting between variants: the serialized filter may be of any kind that lays its buckets out the same way (as for Load). Striped kinds keep the serialized shard count, or pick one from GOMAXPROCS when converting from an unstriped kind, and scalable filters keep the kind of their layers.
+The hasher passed with WithHasher is used if it matches the serialized one.
+*/
+func UnmarshalFilterAs(kind Kind, data []byte, opts ...Option) (Filter, error) {
+	hasher := buildOptions(opts).hasher
+	var f Filter
+	switch kind {
+	case KindBloom:
+		f = &BloomFilter{hasher: hasher}
+	case KindStriped:
+		f = &StripedBloomFilter{hasher: hasher}
+	case KindNaive:
+		f = &NaiveBloomFilter{hasher: hasher}
+	case KindNaiveStriped:
+		f = &NaiveStripedBloomFilter{hasher: hasher}
+	case KindAtomic:
+		f = &AtomicBloomFilter{hasher: hasher}
+	case KindCounting:
+		f = &CountingBloomFilter{hasher: hasher}
+	case KindStripedCounting:
+		f = &StripedCountingBloomFilter{hasher: hasher}
+	case KindScalable, KindStripedScalable:
+		f = &ScalableBloomFilter{hasher: hasher}
+	case KindBlocked:
+		f = &BlockedBloomFilter{hasher: hasher}
+	case KindStripedBlocked:
+		f = &StripedBlockedBloomFilter{hasher: hasher}
+	case KindCuckoo:
+		f = &CuckooFilter{hasher: hasher}
+	default:
+		return nil, fmt.Errorf("A %s isn't a Filter, unmarshal it with its own UnmarshalBinary", kind)
+	}
+	if err := f.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,263 @@
+package hyperbloom
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+var conformanceSpecs = []Spec{
+	{Kind: KindBloom, Size: 1048576, Hashes: 4},
+	{Kind: KindStriped, Size: 1048576, Hashes: 4, Shards: 64},
+	{Kind: KindNaive, Size: 1048576, Hashes: 4},
+	{Kind: KindNaiveStriped, Size: 1048576, Hashes: 4, Shards: 64},
+	{Kind: KindAtomic, Size: 1048576, Hashes: 4},
+	{Kind: KindCounting, Size: 1048576, Hashes: 4},
+	{Kind: KindStripedCounting, Size: 1048576, Hashes: 4, Shards: 64},
+	{Kind: KindBlocked, Size: 1048576, Hashes: 4},
+	{Kind: KindStripedBlocked, Size: 1048576, Hashes: 4, Shards: 64},
+	{Kind: KindCuckoo, Size: 65536, BucketSize: 4, FingerprintBits: 16},
+}
+
+func TestParseKind(t *testing.T) {
+	for _, spec := range conformanceSpecs {
+		k, err := ParseKind(spec.Kind.String())
+		assert.Nil(t, err)
+		assert.Equal(t, spec.Kind, k)
+	}
+	k, err := ParseKind("NaiveStriped")
+	assert.Nil(t, err)
+	assert.Equal(t, KindNaiveStriped, k)
+
+	_, err = ParseKind("quotient")
+	assert.NotNil(t, err)
+	assert.Equal(t, "Kind(42)", Kind(42).String())
+}
+
+func TestNewFilter(t *testing.T) {
+	f, err := NewFilter(Spec{Kind: KindBloom, Size: 1048576, Hashes: 4})
+	assert.Nil(t, err)
+	assert.IsType(t, &BloomFilter{}, f)
+
+	f, err = NewFilter(Spec{Kind: KindNaiveStriped, Size: 1048576, Hashes: 4, Shards: 64})
+	assert.Nil(t, err)
+	assert.IsType(t, &NaiveStripedBloomFilter{}, f)
+
+	//Errors must come back as an untyped nil Filter
+	f, err = NewFilter(Spec{Kind: KindBloom, Size: 100000, Hashes: 4})
+	assert.NotNil(t, err)
+	assert.True(t, f == nil)
+
+	f, err = NewFilter(Spec{Kind: KindStriped, Size: 1048576, Hashes: 4})
+	assert.NotNil(t, err)
+	assert.True(t, f == nil)
+
+	f, err = NewFilter(Spec{Kind: KindNaiveStriped, Size: 1048576, Hashes: 4})
+	assert.NotNil(t, err)
+	assert.True(t, f == nil)
+
+	f, err = NewFilter(Spec{Kind: KindNaive, Size: 1048576, Hashes: 4, Shards: 64})
+	assert.NotNil(t, err)
+	assert.True(t, f == nil)
+
+	f, err = NewFilter(Spec{Kind: KindCuckoo, Size: 65536, Hashes: 4, FingerprintBits: 16})
+	assert.NotNil(t, err)
+	assert.True(t, f == nil)
+
+	f, err = NewFilter(Spec{Kind: KindBloom, Size: 1048576, Hashes: 4, FingerprintBits: 16})
+	assert.NotNil(t, err)
+	assert.True(t, f == nil)
+
+	f, err = NewFilter(Spec{Kind: Kind(42), Size: 1048576, Hashes: 4})
+	assert.NotNil(t, err)
+	assert.True(t, f == nil)
+}
+
+/*Runs the same behavioural checks against every variant through the Filter interface.*/
+func TestFilterConformance(t *testing.T) {
+	for _, spec := range conformanceSpecs {
+		spec := spec
+		t.Run(spec.Kind.String(), func(t *testing.T) {
+			f, err := NewFilter(spec)
+			assert.Nil(t, err)
+
+			entries := make([]string, 100)
+			for i := range entries {
+				entries[i] = fmt.Sprintf("entry-%d", i)
+			}
+			for i, e := range entries {
+				if i%2 == 0 {
+					assert.Nil(t, f.Insert(e))
+				} else {
+					assert.Nil(t, f.InsertAsync(e))
+				}
+			}
+			for _, e := range entries {
+				exists, err := f.Lookup(e)
+				assert.Nil(t, err)
+				assert.True(t, exists, e)
+
+				exists, err = f.LookupAsync(e)
+				assert.Nil(t, err)
+				assert.True(t, exists, e)
+			}
+
+			exists, err := f.Lookup("hahaidontexist")
+			assert.Nil(t, err)
+			assert.False(t, exists)
+
+			exists, err = f.LookupAsync("lavacakes")
+			assert.Nil(t, err)
+			assert.False(t, exists)
+		})
+	}
+}
+
+func TestTestAndInsert(t *testing.T) {
+	for _, spec := range conformanceSpecs {
+		f, err := NewFilter(spec)
+		assert.Nil(t, err)
+		present, err := f.TestAndInsert("foo")
+		assert.Nil(t, err)
+		assert.False(t, present)
+		present, err = f.TestAndInsert("foo")
+		assert.Nil(t, err)
+		assert.True(t, present)
+		exists, err := f.Lookup("foo")
+		assert.Nil(t, err)
+		assert.True(t, exists)
+
+		assert.Nil(t, f.Insert("bar"))
+		present, err = f.TestAndInsert("bar")
+		assert.Nil(t, err)
+		assert.True(t, present)
+	}
+}
+
+/*Of many goroutines racing to TestAndInsert the same entries, exactly one must see each entry as new (at least one for AtomicBloomFilter).*/
+func TestTestAndInsertConcurrent(t *testing.T) {
+	const (
+		workers = 8
+		entries = 500
+	)
+	for _, spec := range conformanceSpecs {
+		f, err := NewFilter(spec)
+		assert.Nil(t, err)
+		var wg sync.WaitGroup
+		absent := make([][]bool, workers)
+		for w := 0; w < workers; w++ {
+			absent[w] = make([]bool, entries)
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < entries; i++ {
+					present, _ := f.TestAndInsert(fmt.Sprintf("entry-%d", (i+w*37)%entries))
+					absent[w][(i+w*37)%entries] = !present
+				}
+			}(w)
+		}
+		wg.Wait()
+		for i := 0; i < entries; i++ {
+			n := 0
+			for w := 0; w < workers; w++ {
+				if absent[w][i] {
+					n++
+				}
+			}
+			if spec.Kind == KindAtomic {
+				//Lock free, so only at least one caller is guaranteed to see the entry as new
+				assert.True(t, n >= 1, "%s: entry-%d", spec.Kind, i)
+			} else {
+				assert.Equal(t, 1, n, "%s: entry-%d", spec.Kind, i)
+			}
+		}
+	}
+}
+
+/*Every variant comes back from UnmarshalFilter as itself, with the Spec it was built from.*/
+func TestUnmarshalFilter(t *testing.T) {
+	for _, spec := range conformanceSpecs {
+		f, err := NewFilter(spec)
+		assert.Nil(t, err)
+		assert.Nil(t, f.Insert("foo"))
+		data, err := f.MarshalBinary()
+		assert.Nil(t, err)
+
+		parsed, err := ParseSpec(data)
+		assert.Nil(t, err)
+		want := spec
+		want.Hasher = DefaultHasher
+		assert.Equal(t, want, parsed)
+
+		restored, err := UnmarshalFilter(data)
+		assert.Nil(t, err)
+		assert.IsType(t, f, restored)
+		if specced, ok := restored.(interface{ Spec() Spec }); ok {
+			assert.Equal(t, want, specced.Spec())
+		}
+		exists, err := restored.Lookup("foo")
+		assert.Nil(t, err)
+		assert.True(t, exists, spec.Kind.String())
+	}
+
+	sbf, err := NewScalableBloomFilter(1000, 0.01)
+	assert.Nil(t, err)
+	data, err := sbf.MarshalBinary()
+	assert.Nil(t, err)
+	f, err := UnmarshalFilter(data)
+	assert.Nil(t, err)
+	assert.IsType(t, &ScalableBloomFilter{}, f)
+
+	//Static filters, sketches and garbage are rejected
+	xf, err := BuildXorFilter([]string{"foo"})
+	assert.Nil(t, err)
+	data, err = xf.MarshalBinary()
+	assert.Nil(t, err)
+	spec, err := ParseSpec(data)
+	assert.Nil(t, err)
+	assert.Equal(t, KindXor, spec.Kind)
+	f, err = UnmarshalFilter(data)
+	assert.NotNil(t, err)
+	assert.True(t, f == nil)
+	_, err = ParseSpec(data[:10])
+	assert.NotNil(t, err)
+	_, err = UnmarshalFilter([]byte("not a filter, but long enough to hold a header!!"))
+	assert.NotNil(t, err)
+
+	//Keyed hashers must be passed in
+	sip := NewSipHasher([16]byte{1})
+	bf, err := NewBloomFilter(1024, 3, WithHasher(sip))
+	assert.Nil(t, err)
+	data, err = bf.MarshalBinary()
+	assert.Nil(t, err)
+	_, err = UnmarshalFilter(data)
+	assert.NotNil(t, err)
+	f, err = UnmarshalFilter(data, WithHasher(sip))
+	assert.Nil(t, err)
+	assert.Equal(t, sip, f.(*BloomFilter).Hasher())
+}
+
+func TestUnmarshalFilterAs(t *testing.T) {
+	bf, err := NewBloomFilter(1<<16, 4)
+	assert.Nil(t, err)
+	assert.Nil(t, bf.Insert("foo"))
+	data, err := bf.MarshalBinary()
+	assert.Nil(t, err)
+	for _, kind := range []Kind{KindStriped, KindNaive, KindAtomic, KindBloom} {
+		f, err := UnmarshalFilterAs(kind, data)
+		assert.Nil(t, err)
+		exists, _ := f.Lookup("foo")
+		assert.True(t, exists, kind.String())
+		converted, err := f.MarshalBinary()
+		assert.Nil(t, err)
+		spec, err := ParseSpec(converted)
+		assert.Nil(t, err)
+		assert.Equal(t, kind, spec.Kind)
+	}
+	for _, kind := range []Kind{KindBlocked, KindCuckoo, KindXor} {
+		f, err := UnmarshalFilterAs(kind, data)
+		assert.NotNil(t, err, kind.String())
+		assert.True(t, f == nil)
+	}
+}
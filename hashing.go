@@ -1,18 +1,240 @@
 package hyperbloom
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"unsafe"
+
 	XXHN "github.com/OneOfOne/xxhash"
+	"github.com/dchest/siphash"
+	"github.com/spaolacci/murmur3"
+)
+
+/*Seeds for the two base hashes used by the xxhash64 hasher. Any two distinct values will do.*/
+const (
+	hashSeed1 uint64 = 0x9E3779B97F4A7C15
+	hashSeed2 uint64 = 0xC2B2AE3D27D4EB4F
 )
 
-func hashEntry(entry []byte, n int) []uint64 {
+/*
+HasherID identifies a hashing algorithm. It is recorded alongside a filter when it is written out so that filters built with different hashers are never combined.
+*/
+type HasherID uint8
+
+const (
+	HasherXXHash64 HasherID = iota //Seeded xxhash64 (the default)
+	HasherFNV1a                    //Seeded 64 bit FNV-1a
+	HasherMurmur3                  //Seeded 128 bit murmur3 (x64 variant)
+	HasherSipHash                  //Keyed SipHash-2-4 (128 bit output)
+)
+
+var hasherNames = map[HasherID]string{
+	HasherXXHash64: "xxhash64",
+	HasherFNV1a:    "fnv1a",
+	HasherMurmur3:  "murmur3",
+	HasherSipHash:  "siphash",
+}
+
+/*String returns the name of the hashing algorithm.*/
+func (id HasherID) String() string {
+	if name, ok := hasherNames[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("HasherID(%d)", uint8(id))
+}
+
+/*
+Hasher produces the two base hashes from which a filter derives its k indices via double hashing.
+The two halves returned by Sum128 must be independent of each other, and Sum128 must neither modify nor retain data. ID and Seed together must identify the hasher's output exactly: two hashers reporting the same pair must hash every input identically.
+*/
+type Hasher interface {
+	Sum128(data []byte) (uint64, uint64)
+	ID() HasherID
+	Seed() uint64
+}
+
+/*
+Uint64Hasher is optionally implemented by a Hasher that can hash an integer without it escaping to the heap. Sum128Uint64(v) must equal Sum128 of the 8 byte little endian encoding of v.
+All built in hashers implement it; for other hashers InsertUint64 and LookupUint64 allocate a buffer per call.
+*/
+type Uint64Hasher interface {
+	Sum128Uint64(v uint64) (uint64, uint64)
+}
+
+/*DefaultHasher is used by every filter that isn't given a hasher explicitly.*/
+var DefaultHasher Hasher = NewXXHasher(0)
+
+type xxHasher struct {
+	seed uint64
+}
+
+/*NewXXHasher returns a Hasher computing two seeded xxhash64 sums of each entry.*/
+func NewXXHasher(seed uint64) Hasher {
+	return xxHasher{seed: seed}
+}
+
+func (h xxHasher) Sum128(data []byte) (uint64, uint64) {
+	return XXHN.Checksum64S(data, h.seed^hashSeed1), XXHN.Checksum64S(data, h.seed^hashSeed2)
+}
+
+func (h xxHasher) Sum128Uint64(v uint64) (uint64, uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return h.Sum128(buf[:])
+}
+
+func (h xxHasher) ID() HasherID { return HasherXXHash64 }
+func (h xxHasher) Seed() uint64 { return h.seed }
+
+const (
+	fnvOffset64 uint64 = 14695981039346656037
+	fnvPrime64  uint64 = 1099511628211
+)
+
+type fnvHasher struct {
+	seed uint64
+}
+
+/*
+NewFNV1aHasher returns a Hasher based on 64 bit FNV-1a, with the seed folded into the offset basis.
+FNV's low bits mix poorly and filters index by the low bits, so both halves are passed through the murmur3 finalizer.
+*/
+func NewFNV1aHasher(seed uint64) Hasher {
+	return fnvHasher{seed: seed}
+}
+
+func (h fnvHasher) Sum128(data []byte) (uint64, uint64) {
+	sum := fnvOffset64 ^ h.seed
+	for _, c := range data {
+		sum ^= uint64(c)
+		sum *= fnvPrime64
+	}
+	return fmix64(sum), fmix64(sum ^ hashSeed2)
+}
+
+func (h fnvHasher) Sum128Uint64(v uint64) (uint64, uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return h.Sum128(buf[:])
+}
+
+func (h fnvHasher) ID() HasherID { return HasherFNV1a }
+func (h fnvHasher) Seed() uint64 { return h.seed }
+
+type murmur3Hasher struct {
+	seed uint32
+}
+
+/*NewMurmur3Hasher returns a Hasher using the two halves of seeded 128 bit murmur3.*/
+func NewMurmur3Hasher(seed uint32) Hasher {
+	return murmur3Hasher{seed: seed}
+}
+
+func (h murmur3Hasher) Sum128(data []byte) (uint64, uint64) {
+	return murmur3.Sum128WithSeed(data, h.seed)
+}
+
+func (h murmur3Hasher) Sum128Uint64(v uint64) (uint64, uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return h.Sum128(buf[:])
+}
+
+func (h murmur3Hasher) ID() HasherID { return HasherMurmur3 }
+func (h murmur3Hasher) Seed() uint64 { return uint64(h.seed) }
+
+type sipHasher struct {
+	k0, k1 uint64
+	tag    uint64
+}
+
+/*
+NewSipHasher returns a Hasher using keyed 128 bit SipHash-2-4. Use it when entries may be chosen by an adversary trying to provoke false positives.
+The key itself is never written out: Seed returns a keyed fingerprint that identifies it without revealing it.
+*/
+func NewSipHasher(key [16]byte) Hasher {
+	k0 := binary.LittleEndian.Uint64(key[:8])
+	k1 := binary.LittleEndian.Uint64(key[8:])
+	return sipHasher{k0: k0, k1: k1, tag: siphash.Hash(k0, k1, []byte("hyperbloom"))}
+}
+
+func (h sipHasher) Sum128(data []byte) (uint64, uint64) {
+	return siphash.Hash128(h.k0, h.k1, data)
+}
+
+func (h sipHasher) Sum128Uint64(v uint64) (uint64, uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return h.Sum128(buf[:])
+}
+
+func (h sipHasher) ID() HasherID { return HasherSipHash }
+func (h sipHasher) Seed() uint64 { return h.tag }
+
+/*
+NewHasher rebuilds one of the built in unkeyed hashers from its ID and seed (see Hasher), as recorded in serialized filters. SipHash hashers are keyed, so they can't be rebuilt from their ID and seed.
+*/
+func NewHasher(id HasherID, seed uint64) (Hasher, error) {
+	switch id {
+	case HasherXXHash64:
+		return NewXXHasher(seed), nil
+	case HasherFNV1a:
+		return NewFNV1aHasher(seed), nil
+	case HasherMurmur3:
+		if seed <= math.MaxUint32 {
+			return NewMurmur3Hasher(uint32(seed)), nil
+		}
+	case HasherSipHash:
+		return nil, errors.New("A siphash filter can only be unmarshaled into a filter constructed with the same key")
+	}
+	return nil, fmt.Errorf("Can't rebuild hasher %s(seed=%#x)", id, seed)
+}
+
+/*The 64 bit finalizer from murmur3*/
+func fmix64(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
+
+/*Describes a hasher the same way in every error message*/
+func hasherString(h Hasher) string {
+	return fmt.Sprintf("%s(seed=%#x)", h.ID(), h.Seed())
+}
+
+/*Returns the two base hashes of an entry, with the second forced odd (see nthHash).*/
+func baseHashes(h Hasher, entry []byte) (uint64, uint64) {
+	h1, h2 := h.Sum128(entry)
+	return h1, h2 | 1
+}
+
+/*baseHashes for a string entry, without copying it (hashers never modify their input).*/
+func stringHashes(h Hasher, entry string) (uint64, uint64) {
+	return baseHashes(h, unsafe.Slice(unsafe.StringData(entry), len(entry)))
+}
+
+/*baseHashes for the 8 byte little endian encoding of an integer entry.*/
+func uint64Hashes(h Hasher, entry uint64) (uint64, uint64) {
+	if uh, ok := h.(Uint64Hasher); ok {
+		h1, h2 := uh.Sum128Uint64(entry)
+		return h1, h2 | 1
+	}
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, entry)
+	return baseHashes(h, buf)
+}
+
+func nthHash(h1, h2 uint64, i int) uint64 {
 	/*
-	 * Hash an entry "n" number of times with a 64 bit hash
+	 * Derive the i-th hash of an entry by double hashing (Kirsch & Mitzenmacher):
+	 * g_i(x) = h1(x) + i*h2(x), where h1 and h2 are the two halves of the hasher's output.
+	 * h2 is odd (see baseHashes) so that, modulo any power of 2 filter size, the first
+	 * n indices are all distinct (an odd number is invertible mod 2^k).
 	 */
-	out := make([]uint64, n)
-	for i := 0; i < n; i++ {
-		pert := entry
-		pert[len(pert)-1] = (pert[len(pert)-1] & 0xFF) //x Mod y = x & (y-1) if y is power of 2
-		out[i] = XXHN.Checksum64(entry)
-	}
-	return out
+	return h1 + uint64(i)*h2
 }
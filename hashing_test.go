@@ -0,0 +1,181 @@
+package hyperbloom
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestNthHash(t *testing.T) {
+	h1, h2 := baseHashes(DefaultHasher, []byte("b99afb65c9f97b2e0feea844eea55f69"))
+
+	//Indices must be distinct even after reduction to a small power of 2
+	seen := make(map[uint64]bool)
+	for i := 0; i < 8; i++ {
+		seen[nthHash(h1, h2, i)&63] = true
+	}
+	assert.Equal(t, 8, len(seen))
+
+	//Deterministic, and the input must not be modified
+	entry := []byte("foobar")
+	a1, a2 := baseHashes(DefaultHasher, entry)
+	b1, b2 := stringHashes(DefaultHasher, "foobar")
+	assert.Equal(t, a1, b1)
+	assert.Equal(t, a2, b2)
+	assert.Equal(t, "foobar", string(entry))
+
+	//Empty entries are valid
+	e1, e2 := stringHashes(DefaultHasher, "")
+	f1, f2 := baseHashes(DefaultHasher, nil)
+	assert.Equal(t, e1, f1)
+	assert.Equal(t, e2, f2)
+}
+
+/*A hasher that doesn't implement Uint64Hasher*/
+type plainHasher struct{ Hasher }
+
+func TestUint64Hashes(t *testing.T) {
+	for _, h := range testHashers {
+		uh, ok := h.(Uint64Hasher)
+		assert.True(t, ok, hasherString(h))
+		u1, u2 := uh.Sum128Uint64(0x0102030405060708)
+		b1, b2 := h.Sum128([]byte{8, 7, 6, 5, 4, 3, 2, 1})
+		assert.Equal(t, b1, u1)
+		assert.Equal(t, b2, u2)
+
+		p1, p2 := uint64Hashes(plainHasher{h}, 0x0102030405060708)
+		assert.Equal(t, b1, p1)
+		assert.Equal(t, b2|1, p2)
+	}
+}
+
+/*
+Checks that the measured false positive rate of every variant matches the theoretical (1 - e^(-kn/m))^k (or its blocked counterpart).
+With k identical hash functions the rate would be close to 1 - e^(-n/m) instead, roughly 9x higher here.
+*/
+func TestFalsePositiveRate(t *testing.T) {
+	const (
+		size    = 1 << 20
+		hf      = 4
+		entries = 100000
+		probes  = 200000
+	)
+	for _, spec := range conformanceSpecs {
+		if spec.Kind == KindCuckoo {
+			//Stores fingerprints rather than setting hf bits, see TestCuckooFalsePositiveRate
+			continue
+		}
+		spec := spec
+		spec.Size = size
+		spec.Hashes = hf
+		expected := math.Pow(1-math.Exp(-float64(hf*entries)/float64(size)), hf)
+		if spec.Kind.blocked() {
+			expected = blockedFalsePositiveRate(size, hf, entries)
+		}
+		t.Run(spec.Kind.String(), func(t *testing.T) {
+			f, err := NewFilter(spec)
+			assert.Nil(t, err)
+			for i := 0; i < entries; i++ {
+				assert.Nil(t, f.InsertAsync(fmt.Sprintf("member-%d", i)))
+			}
+			fp := 0
+			for i := 0; i < probes; i++ {
+				exists, err := f.LookupAsync(fmt.Sprintf("probe-%d", i))
+				assert.Nil(t, err)
+				if exists {
+					fp++
+				}
+			}
+			measured := float64(fp) / probes
+			//About 2000 expected false positives, so 15% is several standard deviations
+			assert.InDelta(t, expected, measured, expected*0.15, "measured %f, expected %f", measured, expected)
+		})
+	}
+}
+
+var testHashers = []Hasher{
+	NewXXHasher(0),
+	NewXXHasher(42),
+	NewFNV1aHasher(0),
+	NewMurmur3Hasher(7),
+	NewSipHasher([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}),
+}
+
+func TestHashers(t *testing.T) {
+	for _, h := range testHashers {
+		a1, a2 := h.Sum128([]byte("foobar"))
+		b1, b2 := h.Sum128([]byte("foobar"))
+		assert.Equal(t, a1, b1)
+		assert.Equal(t, a2, b2)
+		assert.NotEqual(t, a1, a2, hasherString(h))
+
+		c1, _ := h.Sum128([]byte("foobaz"))
+		assert.NotEqual(t, a1, c1, hasherString(h))
+	}
+	//Different seeds or keys must change the output and the identity
+	x1, _ := NewXXHasher(1).Sum128([]byte("foobar"))
+	x2, _ := NewXXHasher(2).Sum128([]byte("foobar"))
+	assert.NotEqual(t, x1, x2)
+
+	k1 := NewSipHasher([16]byte{1})
+	k2 := NewSipHasher([16]byte{2})
+	assert.NotEqual(t, k1.Seed(), k2.Seed())
+	assert.Equal(t, HasherSipHash, k1.ID())
+	assert.Equal(t, "murmur3", HasherMurmur3.String())
+
+	//Unkeyed hashers can be rebuilt from their identity
+	for _, h := range testHashers[:len(testHashers)-1] {
+		rebuilt, err := NewHasher(h.ID(), h.Seed())
+		assert.Nil(t, err)
+		assert.Equal(t, h, rebuilt)
+	}
+	_, err := NewHasher(k1.ID(), k1.Seed())
+	assert.NotNil(t, err)
+	_, err = NewHasher(HasherMurmur3, 1<<32)
+	assert.NotNil(t, err)
+}
+
+func TestHasherFalsePositiveRate(t *testing.T) {
+	const (
+		size    = 1 << 20
+		hf      = 4
+		entries = 100000
+		probes  = 100000
+	)
+	expected := math.Pow(1-math.Exp(-float64(hf*entries)/float64(size)), hf)
+
+	for _, h := range testHashers {
+		bf, err := NewBloomFilter(size, hf, WithHasher(h))
+		assert.Nil(t, err)
+		for i := 0; i < entries; i++ {
+			assert.Nil(t, bf.InsertAsync(fmt.Sprintf("member-%d", i)))
+		}
+		fp := 0
+		for i := 0; i < probes; i++ {
+			if exists, _ := bf.LookupAsync(fmt.Sprintf("probe-%d", i)); exists {
+				fp++
+			}
+		}
+		measured := float64(fp) / probes
+		assert.InDelta(t, expected, measured, expected*0.2, "%s: measured %f, expected %f", hasherString(h), measured, expected)
+	}
+}
+
+func TestLoadHasherMismatch(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "filter")
+	for _, spec := range conformanceSpecs {
+		spec.Hasher = NewMurmur3Hasher(1)
+		f, err := NewFilter(spec)
+		assert.Nil(t, err)
+		assert.Nil(t, f.Write(filename))
+
+		spec.Hasher = NewMurmur3Hasher(2)
+		g, err := NewFilter(spec)
+		assert.Nil(t, err)
+		err = g.Load(filename)
+		assert.NotNil(t, err, spec.Kind.String())
+		assert.Contains(t, err.Error(), "hasher")
+	}
+}
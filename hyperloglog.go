@@ -0,0 +1,552 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"slices"
+	"sync"
+)
+
+/*
+HyperLogLog sketches estimate the number of distinct entries inserted into them (Heule et al., "HyperLogLog in Practice", better known as HLL++). An entry's 64 bit hash picks one of 2^precision registers with its high bits, and the register keeps the largest number of leading zeros (plus one) seen in the remaining bits.
+
+Small sketches use the sparse representation of HLL++: rather than every register, they keep a sorted list of the (index, leading zeros) pairs seen at a precision of 25, which is both smaller and more accurate while few entries have been inserted. A sketch switches to the dense representation (a byte per register) once the list would take more space.
+Estimates use Ertl's improved estimator ("New cardinality estimation algorithms for HyperLogLog sketches", 2017), which is unbiased across the whole range without the empirical bias correction tables of HLL++.
+
+Serialized sketches record 2^precision as their size, and their payload is a representation flag (8 bytes, 0 for sparse and 1 for dense) followed by either the sorted sparse entries (4 bytes each) or the registers (a byte each).
+*/
+const (
+	hllMinPrecision    = 4
+	hllMaxPrecision    = 18
+	hllSparsePrecision = 25
+	hllSparse          = 0
+	hllDense           = 1
+)
+
+/*Returns a hash's sparse entry: its index at the sparse precision and the leading zeros (plus one) of the rest, in the low 6 bits.*/
+func hllEncode(hash uint64) uint32 {
+	rho := bits.LeadingZeros64(hash<<hllSparsePrecision|1<<(hllSparsePrecision-1)) + 1
+	return uint32(hash>>(64-hllSparsePrecision))<<6 | uint32(rho)
+}
+
+/*Returns the register and register value a sparse entry stands for at precision p. These are exactly the register and value the entry's hash would have set.*/
+func hllDecode(e uint32, p uint8) (uint64, byte) {
+	idx := e >> 6
+	extra := uint(hllSparsePrecision - p)
+	if r := idx & (1<<extra - 1); r != 0 {
+		return uint64(idx >> extra), byte(int(extra) - bits.Len32(r) + 1)
+	}
+	return uint64(idx >> extra), byte(extra) + byte(e&63)
+}
+
+/*
+A range of a sketch's registers, sparse or dense. HyperLogLog has a single shard covering every register, StripedHyperLogLog one per lock.
+*/
+type hllShard struct {
+	p      uint8    //Precision of the sketch
+	regs   uint64   //Number of registers in the shard. MUST BE A POWER OF 2.
+	dense  []byte   //Registers, once the shard is dense
+	sparse []uint32 //Sparse entries, sorted and with one per index
+	tmp    []uint32 //Sparse entries not yet merged into sparse
+}
+
+func newHLLShard(p uint8, regs uint64) *hllShard {
+	return &hllShard{p: p, regs: regs}
+}
+
+func (s *hllShard) insert(hash uint64) {
+	if s.dense != nil {
+		i := hash >> (64 - s.p) & (s.regs - 1)
+		if rho := byte(bits.LeadingZeros64(hash<<s.p|1<<(s.p-1)) + 1); rho > s.dense[i] {
+			s.dense[i] = rho
+		}
+		return
+	}
+	s.tmp = append(s.tmp, hllEncode(hash))
+	if uint64(len(s.tmp)) >= max(16, s.regs/16) {
+		s.flush()
+	}
+}
+
+/*Merges the pending entries into the sparse list, switching to dense registers if the list has grown larger than they would be.*/
+func (s *hllShard) flush() {
+	if len(s.tmp) == 0 {
+		return
+	}
+	slices.Sort(s.tmp)
+	s.sparse = mergeSparse(s.sparse, s.tmp)
+	s.tmp = s.tmp[:0]
+	if uint64(len(s.sparse))*4 > s.regs {
+		s.toDense()
+	}
+}
+
+/*Merges two sorted lists of sparse entries, keeping the largest value for each index.*/
+func mergeSparse(a, b []uint32) []uint32 {
+	merged := make([]uint32, 0, len(a)+len(b))
+	add := func(e uint32) {
+		if n := len(merged); n > 0 && merged[n-1]>>6 == e>>6 {
+			merged[n-1] = max(merged[n-1], e)
+		} else {
+			merged = append(merged, e)
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] <= b[j] {
+			add(a[i])
+			i++
+		} else {
+			add(b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		add(a[i])
+	}
+	for ; j < len(b); j++ {
+		add(b[j])
+	}
+	return merged
+}
+
+func (s *hllShard) toDense() {
+	s.flush()
+	if s.dense != nil {
+		return
+	}
+	s.dense = make([]byte, s.regs)
+	for _, e := range s.sparse {
+		i, rho := hllDecode(e, s.p)
+		s.dense[i&(s.regs-1)] = max(s.dense[i&(s.regs-1)], rho)
+	}
+	s.sparse, s.tmp = nil, nil
+}
+
+/*Merges another shard covering the same registers into s.*/
+func (s *hllShard) merge(other *hllShard) {
+	s.flush()
+	other.flush()
+	if s.dense == nil && other.dense == nil {
+		s.sparse = mergeSparse(s.sparse, other.sparse)
+		if uint64(len(s.sparse))*4 > s.regs {
+			s.toDense()
+		}
+		return
+	}
+	s.toDense()
+	if other.dense != nil {
+		for i, rho := range other.dense {
+			s.dense[i] = max(s.dense[i], rho)
+		}
+		return
+	}
+	for _, e := range other.sparse {
+		i, rho := hllDecode(e, s.p)
+		s.dense[i&(s.regs-1)] = max(s.dense[i&(s.regs-1)], rho)
+	}
+}
+
+/*
+Adds the shard's registers to a histogram of register values, and returns whether the shard is sparse and, if so, how many sparse entries it holds.
+*/
+func (s *hllShard) tally(hist []uint64) (bool, uint64) {
+	s.flush()
+	if s.dense != nil {
+		for _, rho := range s.dense {
+			hist[rho]++
+		}
+		return false, 0
+	}
+	set := uint64(0)
+	last, lastRho := uint64(math.MaxUint64), byte(0)
+	for _, e := range s.sparse {
+		i, rho := hllDecode(e, s.p)
+		if i != last {
+			if last != math.MaxUint64 {
+				hist[lastRho]++
+				set++
+			}
+			last, lastRho = i, 0
+		}
+		lastRho = max(lastRho, rho)
+	}
+	if last != math.MaxUint64 {
+		hist[lastRho]++
+		set++
+	}
+	hist[0] += s.regs - set
+	return true, uint64(len(s.sparse))
+}
+
+/*Returns a flushed deep copy of the shard.*/
+func (s *hllShard) clone() *hllShard {
+	s.flush()
+	return &hllShard{p: s.p, regs: s.regs, dense: slices.Clone(s.dense), sparse: slices.Clone(s.sparse)}
+}
+
+/*Joins shards covering consecutive ranges of registers into a single shard covering all of them.*/
+func joinHLLShards(shards []*hllShard) *hllShard {
+	joined := newHLLShard(shards[0].p, shards[0].regs*uint64(len(shards)))
+	dense := false
+	for _, s := range shards {
+		dense = dense || s.dense != nil
+	}
+	for _, s := range shards {
+		if dense {
+			s.toDense()
+			joined.dense = append(joined.dense, s.dense...)
+		} else {
+			joined.sparse = append(joined.sparse, s.sparse...) //Shards split the index space in order, so this stays sorted
+		}
+	}
+	return joined
+}
+
+/*Splits a shard into n shards covering consecutive ranges of its registers. The pieces share memory with s.*/
+func (s *hllShard) split(n uint64) []*hllShard {
+	s.flush()
+	pieces := make([]*hllShard, n)
+	regs := s.regs / n
+	rest := s.sparse
+	for i := range pieces {
+		pieces[i] = newHLLShard(s.p, regs)
+		if s.dense != nil {
+			pieces[i].dense = s.dense[uint64(i)*regs : uint64(i+1)*regs]
+			continue
+		}
+		//Entries of piece i are those whose register is below (i+1)*regs
+		end := len(rest)
+		for j, e := range rest {
+			if r, _ := hllDecode(e, s.p); r&(s.regs-1) >= uint64(i+1)*regs {
+				end = j
+				break
+			}
+		}
+		pieces[i].sparse, rest = rest[:end], rest[end:]
+	}
+	return pieces
+}
+
+/*
+Estimates the number of distinct entries from a histogram of register values (see tally). Sketches that are entirely sparse are estimated by linear counting of their sparse entries, which is close to exact at that scale.
+*/
+func hllEstimate(p uint8, hist []uint64, sparse bool, entries uint64) uint64 {
+	if sparse {
+		m := float64(uint64(1) << hllSparsePrecision)
+		return uint64(math.Round(m * math.Log(m/(m-float64(entries)))))
+	}
+	m := float64(uint64(1) << p)
+	q := 64 - int(p)
+	z := m * hllTau((m-float64(hist[q+1]))/m)
+	for k := q; k >= 1; k-- {
+		z = 0.5 * (z + float64(hist[k]))
+	}
+	z += m * hllSigma(float64(hist[0])/m)
+	return uint64(math.Round(m * m / (2 * math.Ln2 * z)))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if z == prev {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= (1 - x) * (1 - x) * y
+		if z == prev {
+			return z / 3
+		}
+	}
+}
+
+func checkHLLPrecision(p uint8) error {
+	if p < hllMinPrecision || p > hllMaxPrecision {
+		return fmt.Errorf("Precision must be between %d and %d", hllMinPrecision, hllMaxPrecision)
+	}
+	return nil
+}
+
+/*Writes a sketch of the given kind whose registers are in s (which must cover all of them).*/
+func writeHLL(w io.Writer, kind Kind, shards uint64, hasher Hasher, s *hllShard) (int64, error) {
+	h := newFileHeader(kind, s.regs, 0, shards, hasher)
+	h.payloadLen = 8 + uint64(len(s.dense)) + 4*uint64(len(s.sparse))
+	return writeFilter(w, h, func(w io.Writer) error {
+		buf := make([]byte, 0, h.payloadLen)
+		if s.dense != nil {
+			buf = binary.LittleEndian.AppendUint64(buf, hllDense)
+			buf = append(buf, s.dense...)
+		} else {
+			buf = binary.LittleEndian.AppendUint64(buf, hllSparse)
+			for _, e := range s.sparse {
+				buf = binary.LittleEndian.AppendUint32(buf, e)
+			}
+		}
+		_, err := w.Write(buf)
+		return err
+	})
+}
+
+/*Parses and validates a sketch's payload into a shard covering all of its registers.*/
+func parseHLL(h fileHeader, payload []byte) (*hllShard, error) {
+	p := uint8(bits.TrailingZeros64(h.size))
+	if err := checkHLLPrecision(p); err != nil {
+		return nil, err
+	}
+	s := newHLLShard(p, h.size)
+	maxRho := byte(64 - p + 1)
+	switch binary.LittleEndian.Uint64(payload) {
+	case hllDense:
+		if uint64(len(payload)-8) != h.size {
+			return nil, fmt.Errorf("Payload length %d doesn't match a sketch of %d registers", len(payload), h.size)
+		}
+		s.dense = payload[8:]
+		for _, rho := range s.dense {
+			if rho > maxRho {
+				return nil, fmt.Errorf("Invalid register value %d", rho)
+			}
+		}
+	case hllSparse:
+		if (len(payload)-8)%4 != 0 {
+			return nil, fmt.Errorf("Invalid sparse payload length %d", len(payload))
+		}
+		s.sparse = make([]uint32, (len(payload)-8)/4)
+		for i := range s.sparse {
+			e := binary.LittleEndian.Uint32(payload[8+4*i:])
+			if rho := e & 63; rho == 0 || rho > 64-hllSparsePrecision+1 || e>>(6+hllSparsePrecision) != 0 {
+				return nil, fmt.Errorf("Invalid sparse entry %#x", e)
+			} else if i > 0 && e>>6 <= s.sparse[i-1]>>6 {
+				return nil, errors.New("Sparse entries out of order")
+			}
+			s.sparse[i] = e
+		}
+	default:
+		return nil, errors.New("Unknown sketch representation")
+	}
+	return s, nil
+}
+
+/*
+HyperLogLog is a HyperLogLog++ cardinality sketch: it estimates how many distinct entries have been inserted into it, in at most 2^precision bytes. The standard error of the estimate is about 1.04/sqrt(2^precision), e.g. 0.8% at precision 14 (16KB), and much lower while the sketch is sparse.
+It hashes entries with the same Hashers as the filters, and uses central locking via a Mutex (see StripedHyperLogLog for a sharded variant).
+*/
+type HyperLogLog struct {
+	shard  *hllShard   //All of the registers
+	p      uint8       //Precision: there are 2^p registers
+	hasher Hasher      //Produces the base hashes for each entry
+	mut    *sync.Mutex //Centralized mutex
+}
+
+/*
+NewHyperLogLog allocates an empty HyperLogLog with 2^precision registers. Precision must be between 4 and 18; 14 is a common choice.
+*/
+func NewHyperLogLog(precision uint8, opts ...Option) (*HyperLogLog, error) {
+	if err := checkHLLPrecision(precision); err != nil {
+		return nil, err
+	}
+	var hll HyperLogLog
+	hll.p = precision
+	hll.shard = newHLLShard(precision, 1<<precision)
+	hll.hasher = buildOptions(opts).hasher
+	hll.mut = &sync.Mutex{}
+	return &hll, nil
+}
+
+/*Inserts an entry into the HyperLogLog. Locks the sketch.*/
+func (hll HyperLogLog) Insert(entry string) error {
+	h1, _ := stringHashes(hll.hasher, entry)
+	hll.mut.Lock()
+	hll.shard.insert(h1)
+	hll.mut.Unlock()
+	return nil
+}
+
+/*Inserts an entry into the HyperLogLog. Doesn't lock the sketch.*/
+func (hll HyperLogLog) InsertAsync(entry string) error {
+	h1, _ := stringHashes(hll.hasher, entry)
+	hll.shard.insert(h1)
+	return nil
+}
+
+/*InsertBytes is Insert for a byte slice entry. It is equivalent to Insert(string(entry)) without converting or allocating.*/
+func (hll HyperLogLog) InsertBytes(entry []byte) error {
+	h1, _ := baseHashes(hll.hasher, entry)
+	hll.mut.Lock()
+	hll.shard.insert(h1)
+	hll.mut.Unlock()
+	return nil
+}
+
+/*InsertUint64 is Insert for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (hll HyperLogLog) InsertUint64(entry uint64) error {
+	h1, _ := uint64Hashes(hll.hasher, entry)
+	hll.mut.Lock()
+	hll.shard.insert(h1)
+	hll.mut.Unlock()
+	return nil
+}
+
+/*InsertBatch inserts every entry in entries. Takes the lock once for the whole batch.*/
+func (hll HyperLogLog) InsertBatch(entries []string) error {
+	hashes := batchHashes(hll.hasher, entries)
+	hll.mut.Lock()
+	for e := 0; e < len(hashes); e += 2 {
+		hll.shard.insert(hashes[e])
+	}
+	hll.mut.Unlock()
+	return nil
+}
+
+/*Count returns the estimated number of distinct entries inserted into the sketch. Locks the sketch.*/
+func (hll HyperLogLog) Count() uint64 {
+	hist := make([]uint64, 64-hll.p+2)
+	hll.mut.Lock()
+	sparse, entries := hll.shard.tally(hist)
+	hll.mut.Unlock()
+	return hllEstimate(hll.p, hist, sparse, entries)
+}
+
+/*
+Merge adds every entry counted by other to the sketch, so that it estimates the size of the union of both. The sketches must have the same precision and hasher.
+other is copied under its own lock before the sketch is locked, so concurrent merges in both directions can't deadlock. To merge a StripedHyperLogLog, serialize it and ReadFrom (or Load) it instead.
+*/
+func (hll HyperLogLog) Merge(other *HyperLogLog) error {
+	if err := checkHLLMerge(hll.p, hll.hasher, other.p, other.hasher); err != nil {
+		return err
+	}
+	other.mut.Lock()
+	snapshot := other.shard.clone()
+	other.mut.Unlock()
+	hll.mut.Lock()
+	hll.shard.merge(snapshot)
+	hll.mut.Unlock()
+	return nil
+}
+
+func checkHLLMerge(p uint8, hasher Hasher, otherP uint8, otherHasher Hasher) error {
+	if p != otherP {
+		return fmt.Errorf("Can't merge a sketch of precision %d into one of precision %d", otherP, p)
+	} else if hasher.ID() != otherHasher.ID() || hasher.Seed() != otherHasher.Seed() {
+		return fmt.Errorf("Can't merge a sketch using %s into one using %s", hasherString(otherHasher), hasherString(hasher))
+	}
+	return nil
+}
+
+/*Precision returns the sketch's precision: it has 2^precision registers.*/
+func (hll HyperLogLog) Precision() uint8 {
+	return hll.p
+}
+
+/*Hasher returns the hasher the sketch was built with.*/
+func (hll HyperLogLog) Hasher() Hasher {
+	return hll.hasher
+}
+
+/*
+WriteTo writes the sketch to w in the package's binary format (see serialization.go) and returns the number of bytes written. It implements io.WriterTo.
+The sketch is copied under its lock, then written without holding it.
+*/
+func (hll HyperLogLog) WriteTo(w io.Writer) (int64, error) {
+	hll.mut.Lock()
+	snapshot := hll.shard.clone()
+	hll.mut.Unlock()
+	return writeHLL(w, KindHyperLogLog, 0, hll.hasher, snapshot)
+}
+
+/*Returns an error unless the serialized filter is a sketch with the given precision and hasher.*/
+func (h fileHeader) checkHLL(p uint8, hasher Hasher) error {
+	if err := h.checkKind(KindHyperLogLog); err != nil {
+		return err
+	}
+	return h.checkParams(1<<p, 0, hasher)
+}
+
+/*
+ReadFrom merges the sketch with a serialized HyperLogLog or StripedHyperLogLog read from r and returns the number of bytes read. It implements io.ReaderFrom.
+Exactly one serialized sketch is consumed from r. It must have the same precision and hasher.
+The sketch is read and verified in full before the sketch is locked, once, for the merge.
+*/
+func (hll HyperLogLog) ReadFrom(r io.Reader) (int64, error) {
+	h, payload, n, err := readFilter(r, func(h fileHeader) error {
+		return h.checkHLL(hll.p, hll.hasher)
+	})
+	if err != nil {
+		return n, err
+	}
+	s, err := parseHLL(h, payload)
+	if err != nil {
+		return n, err
+	}
+	hll.mut.Lock()
+	hll.shard.merge(s)
+	hll.mut.Unlock()
+	return n, nil
+}
+
+/*MarshalBinary returns the sketch in the package's binary format. It implements encoding.BinaryMarshaler (and so also works with encoding/gob).*/
+func (hll HyperLogLog) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := hll.WriteTo(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+/*
+UnmarshalBinary replaces the sketch with a serialized HyperLogLog or StripedHyperLogLog, taking its precision and hasher from data. It implements encoding.BinaryUnmarshaler.
+The sketch's current hasher is kept if it matches the serialized one (which is the only way to unmarshal a sketch using a keyed hasher). It must not be called concurrently with any other method.
+*/
+func (hll *HyperLogLog) UnmarshalBinary(data []byte) error {
+	h, payload, err := unmarshalFilter(data, KindHyperLogLog)
+	if err != nil {
+		return err
+	}
+	s, err := parseHLL(h, payload)
+	if err != nil {
+		return err
+	}
+	hasher, err := h.resolveHasher(hll.hasher)
+	if err != nil {
+		return err
+	}
+	fresh, err := NewHyperLogLog(s.p, WithHasher(hasher))
+	if err != nil {
+		return err
+	}
+	fresh.shard.merge(s)
+	*hll = *fresh
+	return nil
+}
+
+/*Writes the sketch to a file. See WriteTo.*/
+func (hll HyperLogLog) Write(filename string) error {
+	return writeFile(filename, hll.WriteTo)
+}
+
+/*Merges the sketch with one loaded from a file. See ReadFrom.*/
+func (hll HyperLogLog) Load(filename string) error {
+	return readFile(filename, hll.ReadFrom)
+}
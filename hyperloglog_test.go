@@ -0,0 +1,271 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"hash/crc32"
+	"math"
+	"math/bits"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewHyperLogLog(t *testing.T) {
+	for _, p := range []uint8{0, 3, 19} {
+		hll, err := NewHyperLogLog(p)
+		assert.NotNil(t, err, "precision %d", p)
+		assert.Nil(t, hll)
+	}
+	hll, err := NewHyperLogLog(14)
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(14), hll.Precision())
+	assert.Equal(t, uint64(0), hll.Count())
+}
+
+/*A sparse entry must decode to exactly the register and value its hash sets in a dense sketch.*/
+func TestHLLEncoding(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		hash := rng.Uint64()
+		if i%4 == 0 {
+			hash &^= math.MaxUint64 >> 24 >> uint(i%40) //Long runs of zeros
+		}
+		for p := uint8(hllMinPrecision); p <= hllMaxPrecision; p++ {
+			idx, rho := hllDecode(hllEncode(hash), p)
+			assert.Equal(t, hash>>(64-p), idx)
+			if want := byte(bits.LeadingZeros64(hash<<p|1<<(p-1)) + 1); rho != want {
+				t.Fatalf("hash %#x at precision %d: rho %d, want %d", hash, p, rho, want)
+			}
+		}
+	}
+}
+
+func TestHyperLogLogAccuracy(t *testing.T) {
+	hll, err := NewHyperLogLog(14)
+	assert.Nil(t, err)
+	inserted := 0
+	for _, n := range []int{1, 10, 100, 1000, 4000, 10000, 50000, 200000, 1000000} {
+		for ; inserted < n; inserted++ {
+			assert.Nil(t, hll.Insert(fmt.Sprintf("entry-%d", inserted)))
+		}
+		//Sparse estimates are close to exact, dense ones within a few standard errors (0.8%)
+		tolerance := 0.025
+		if hll.shard.dense == nil {
+			tolerance = 0.005
+		}
+		assert.InDelta(t, float64(n), float64(hll.Count()), math.Max(1, tolerance*float64(n)), "n=%d", n)
+	}
+	assert.NotNil(t, hll.shard.dense)
+
+	//Duplicates aren't counted
+	before := hll.Count()
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, hll.Insert(fmt.Sprintf("entry-%d", i)))
+	}
+	assert.Equal(t, before, hll.Count())
+}
+
+/*Switching from sparse to dense must produce the registers a dense sketch would have had all along.*/
+func TestHyperLogLogSparseToDense(t *testing.T) {
+	sparse, err := NewHyperLogLog(10)
+	assert.Nil(t, err)
+	dense, err := NewHyperLogLog(10)
+	assert.Nil(t, err)
+	dense.shard.toDense()
+	for i := 0; i < 200; i++ {
+		assert.Nil(t, sparse.InsertUint64(uint64(i)))
+		assert.Nil(t, dense.InsertUint64(uint64(i)))
+	}
+	sparse.shard.flush()
+	assert.Nil(t, sparse.shard.dense)
+	for i := 200; i < 2000; i++ {
+		assert.Nil(t, sparse.InsertUint64(uint64(i)))
+		assert.Nil(t, dense.InsertUint64(uint64(i)))
+	}
+	sparse.shard.flush()
+	assert.NotNil(t, sparse.shard.dense)
+	assert.Equal(t, dense.shard.dense, sparse.shard.dense)
+	assert.Equal(t, dense.Count(), sparse.Count())
+}
+
+func TestHyperLogLogInsertVariants(t *testing.T) {
+	a, err := NewHyperLogLog(12)
+	assert.Nil(t, err)
+	b, err := NewHyperLogLog(12)
+	assert.Nil(t, err)
+	entries := staticKeys("entry", 5000)
+	assert.Nil(t, a.InsertBatch(entries))
+	for i, e := range entries {
+		switch i % 3 {
+		case 0:
+			assert.Nil(t, b.Insert(e))
+		case 1:
+			assert.Nil(t, b.InsertBytes([]byte(e)))
+		case 2:
+			assert.Nil(t, b.InsertAsync(e))
+		}
+	}
+	assert.Equal(t, a.shard.clone(), b.shard.clone())
+}
+
+func TestHyperLogLogMerge(t *testing.T) {
+	//Every combination of sparse and dense sketches merges to the sketch of the union
+	for _, sizes := range [][2]int{{10, 20}, {10, 5000}, {5000, 10}, {5000, 8000}} {
+		a, err := NewHyperLogLog(12)
+		assert.Nil(t, err)
+		b, err := NewHyperLogLog(12)
+		assert.Nil(t, err)
+		union, err := NewHyperLogLog(12)
+		assert.Nil(t, err)
+		for i := 0; i < sizes[0]; i++ {
+			assert.Nil(t, a.InsertUint64(uint64(i)))
+			assert.Nil(t, union.InsertUint64(uint64(i)))
+		}
+		for i := 0; i < sizes[1]; i++ {
+			assert.Nil(t, b.InsertUint64(uint64(i+sizes[0]/2)))
+			assert.Nil(t, union.InsertUint64(uint64(i+sizes[0]/2)))
+		}
+		assert.Nil(t, a.Merge(b))
+		a.shard.toDense()
+		union.shard.toDense()
+		assert.Equal(t, union.shard.dense, a.shard.dense, "%v", sizes)
+		assert.Equal(t, union.Count(), a.Count())
+	}
+
+	a, err := NewHyperLogLog(12)
+	assert.Nil(t, err)
+	other, err := NewHyperLogLog(13)
+	assert.Nil(t, err)
+	assert.NotNil(t, a.Merge(other))
+	other, err = NewHyperLogLog(12, WithHasher(NewMurmur3Hasher(0)))
+	assert.Nil(t, err)
+	assert.NotNil(t, a.Merge(other))
+	assert.Nil(t, a.Merge(a))
+}
+
+func TestHyperLogLogSerialization(t *testing.T) {
+	for _, n := range []int{0, 100, 20000} {
+		hll, err := NewHyperLogLog(12, WithHasher(NewMurmur3Hasher(3)))
+		assert.Nil(t, err)
+		for i := 0; i < n; i++ {
+			assert.Nil(t, hll.InsertUint64(uint64(i)))
+		}
+		var stream bytes.Buffer
+		written, err := hll.WriteTo(&stream)
+		assert.Nil(t, err)
+		data := bytes.Clone(stream.Bytes())
+
+		var restored HyperLogLog
+		assert.Nil(t, restored.UnmarshalBinary(data))
+		assert.Equal(t, hll.Count(), restored.Count())
+		assert.Equal(t, hll.Hasher(), restored.Hasher())
+
+		//ReadFrom merges
+		merged, err := NewHyperLogLog(12, WithHasher(NewMurmur3Hasher(3)))
+		assert.Nil(t, err)
+		assert.Nil(t, merged.InsertUint64(math.MaxUint64))
+		read, err := merged.ReadFrom(&stream)
+		assert.Nil(t, err)
+		assert.Equal(t, written, read)
+		assert.InDelta(t, float64(n+1), float64(merged.Count()), math.Max(1, 0.025*float64(n)))
+
+		//Other precisions and hashers are rejected
+		other, err := NewHyperLogLog(11, WithHasher(NewMurmur3Hasher(3)))
+		assert.Nil(t, err)
+		_, err = other.ReadFrom(bytes.NewReader(data))
+		assert.NotNil(t, err)
+		other, err = NewHyperLogLog(12)
+		assert.Nil(t, err)
+		_, err = other.ReadFrom(bytes.NewReader(data))
+		assert.NotNil(t, err)
+	}
+
+	//Corrupt or inconsistent payloads are rejected
+	hll, err := NewHyperLogLog(8)
+	assert.Nil(t, err)
+	assert.Nil(t, hll.InsertBatch(staticKeys("entry", 20)))
+	data, err := hll.MarshalBinary()
+	assert.Nil(t, err)
+	var restored HyperLogLog
+	assert.NotNil(t, restored.UnmarshalBinary(append(bytes.Clone(data), 0)))
+	bad := bytes.Clone(data)
+	bad[headerLen+8] ^= 1
+	assert.NotNil(t, restored.UnmarshalBinary(bad))
+	unsorted := bytes.Clone(data)
+	copy(unsorted[headerLen+8:], data[headerLen+12:headerLen+16])
+	copy(unsorted[headerLen+12:], data[headerLen+8:headerLen+12])
+	resum(unsorted)
+	assert.NotNil(t, restored.UnmarshalBinary(unsorted))
+}
+
+/*Recomputes the trailing checksum of a serialized filter after it has been tampered with.*/
+func resum(data []byte) {
+	n := len(data) - 4
+	binary.LittleEndian.PutUint32(data[n:], crc32.Checksum(data[:n], crcTable))
+}
+
+/*Sketches and filters don't load into one another.*/
+func TestHyperLogLogKinds(t *testing.T) {
+	hll, err := NewHyperLogLog(10)
+	assert.Nil(t, err)
+	data, err := hll.MarshalBinary()
+	assert.Nil(t, err)
+	bf, err := NewBloomFilter(1024, 3)
+	assert.Nil(t, err)
+	_, err = bf.ReadFrom(bytes.NewReader(data))
+	assert.NotNil(t, err)
+	filterData, err := bf.MarshalBinary()
+	assert.Nil(t, err)
+	_, err = hll.ReadFrom(bytes.NewReader(filterData))
+	assert.NotNil(t, err)
+
+	for _, kind := range []Kind{KindHyperLogLog, KindStripedHyperLogLog} {
+		f, err := NewFilter(Spec{Kind: kind, Size: 1024, Hashes: 3})
+		assert.NotNil(t, err)
+		assert.True(t, f == nil)
+		f, _, err = NewFilterWithEstimates(kind, 1000, 0.01)
+		assert.NotNil(t, err)
+		assert.True(t, f == nil)
+	}
+}
+
+/*The serialized sketches in testdata must be reproduced exactly, and read back into the same sketches.*/
+func TestHyperLogLogGoldenFiles(t *testing.T) {
+	sparse, err := NewHyperLogLog(4)
+	assert.Nil(t, err)
+	assert.Nil(t, sparse.InsertBatch(goldenEntries))
+	dense, err := NewHyperLogLog(4)
+	assert.Nil(t, err)
+	assert.Nil(t, dense.InsertBatch(staticKeys("entry", 100)))
+	for name, hll := range map[string]*HyperLogLog{"hyperloglog-sparse": sparse, "hyperloglog-dense": dense} {
+		got, err := hll.MarshalBinary()
+		assert.Nil(t, err)
+		golden := filepath.Join("testdata", name+".golden")
+		if *update {
+			assert.Nil(t, os.WriteFile(golden, got, 0666))
+		}
+		want, err := os.ReadFile(golden)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got, name)
+
+		loaded, err := NewHyperLogLog(4)
+		assert.Nil(t, err)
+		assert.Nil(t, loaded.Load(golden))
+		assert.Equal(t, hll.shard.clone(), loaded.shard.clone())
+	}
+	assert.Equal(t, uint64(len(goldenEntries)), sparse.Count())
+}
+
+func BenchmarkHyperLogLogInsert(b *testing.B) {
+	hll, err := NewHyperLogLog(14)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		hll.InsertUint64(uint64(i))
+	}
+}
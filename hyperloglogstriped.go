@@ -0,0 +1,249 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+)
+
+/*
+StripedHyperLogLog is a HyperLogLog whose registers are split into shards, each with its own lock and its own sparse or dense representation. It uses distributed locking via striping and supports both synchronous and asynchronous inserts.
+It holds the same registers as a HyperLogLog with the same precision and hasher, so either can load the other. Its shards switch to the dense representation independently, so around the switch its estimates may differ slightly from a HyperLogLog's (they are within the same error).
+*/
+type StripedHyperLogLog struct {
+	shardArr []*hllShard   //Registers of each shard
+	p        uint8         //Precision: there are 2^p registers
+	shards   uint64        //Number of shards
+	hasher   Hasher        //Produces the base hashes for each entry
+	mutArr   []*sync.Mutex //Mutex for each shard
+	shardLen uint64        //Precomputed number of registers per shard
+}
+
+/*
+NewStripedHyperLogLog allocates an empty StripedHyperLogLog with 2^precision registers. Precision must be between 4 and 18.
+Shards must be a power of 2 and cannot exceed 2^precision/16.
+*/
+func NewStripedHyperLogLog(precision uint8, shards uint64, opts ...Option) (*StripedHyperLogLog, error) {
+	if err := checkHLLPrecision(precision); err != nil {
+		return nil, err
+	} else if shards == 0 {
+		return nil, errors.New("Shards must be nonzero")
+	} else if shards&(shards-1) != 0 {
+		return nil, errors.New("Shards must be a power of 2")
+	} else if shards > (1<<precision)/16 {
+		return nil, errors.New("Shards cannot exceed 2^precision/16")
+	}
+	var hll StripedHyperLogLog
+	hll.p = precision
+	hll.shards = shards
+	hll.shardLen = (1 << precision) / shards
+	hll.hasher = buildOptions(opts).hasher
+	hll.shardArr = make([]*hllShard, shards)
+	hll.mutArr = make([]*sync.Mutex, shards)
+	for i := range hll.shardArr {
+		hll.shardArr[i] = newHLLShard(precision, hll.shardLen)
+		hll.mutArr[i] = &sync.Mutex{}
+	}
+	return &hll, nil
+}
+
+/*Picks a shard count for a StripedHyperLogLog with the given number of registers, as estimateShards does for filters.*/
+func estimateHLLShards(regs uint64) uint64 {
+	return min(nextPowerOf2(uint64(runtime.GOMAXPROCS(0))*4), regs/16)
+}
+
+func (hll StripedHyperLogLog) insertHash(h1 uint64) {
+	s := (h1 >> (64 - hll.p)) / hll.shardLen
+	hll.mutArr[s].Lock()
+	hll.shardArr[s].insert(h1)
+	hll.mutArr[s].Unlock()
+}
+
+/*Inserts an entry into the StripedHyperLogLog. Locks the entry's shard.*/
+func (hll StripedHyperLogLog) Insert(entry string) error {
+	h1, _ := stringHashes(hll.hasher, entry)
+	hll.insertHash(h1)
+	return nil
+}
+
+/*Inserts an entry into the StripedHyperLogLog. Doesn't lock the sketch.*/
+func (hll StripedHyperLogLog) InsertAsync(entry string) error {
+	h1, _ := stringHashes(hll.hasher, entry)
+	hll.shardArr[(h1>>(64-hll.p))/hll.shardLen].insert(h1)
+	return nil
+}
+
+/*InsertBytes is Insert for a byte slice entry. It is equivalent to Insert(string(entry)) without converting or allocating.*/
+func (hll StripedHyperLogLog) InsertBytes(entry []byte) error {
+	h1, _ := baseHashes(hll.hasher, entry)
+	hll.insertHash(h1)
+	return nil
+}
+
+/*InsertUint64 is Insert for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (hll StripedHyperLogLog) InsertUint64(entry uint64) error {
+	h1, _ := uint64Hashes(hll.hasher, entry)
+	hll.insertHash(h1)
+	return nil
+}
+
+/*
+InsertBatch inserts every entry in entries. Every entry is hashed first, then each shard touched by the batch is locked once.
+*/
+func (hll StripedHyperLogLog) InsertBatch(entries []string) error {
+	hashes := make([]uint64, len(entries))
+	indices := make([]uint64, len(entries))
+	for i, entry := range entries {
+		hashes[i], _ = stringHashes(hll.hasher, entry)
+		indices[i] = hashes[i] >> (64 - hll.p)
+	}
+	order, offsets := groupByShard(indices, hll.shardLen, hll.shards)
+	for s := uint64(0); s < hll.shards; s++ {
+		if offsets[s] == offsets[s+1] {
+			continue
+		}
+		hll.mutArr[s].Lock()
+		for _, pos := range order[offsets[s]:offsets[s+1]] {
+			hll.shardArr[s].insert(hashes[pos])
+		}
+		hll.mutArr[s].Unlock()
+	}
+	return nil
+}
+
+/*
+Count returns the estimated number of distinct entries inserted into the sketch. Locks one shard at a time, so concurrent inserts may be counted in some shards but not others.
+*/
+func (hll StripedHyperLogLog) Count() uint64 {
+	hist := make([]uint64, 64-hll.p+2)
+	sparse, entries := true, uint64(0)
+	for s := range hll.shardArr {
+		hll.mutArr[s].Lock()
+		shardSparse, n := hll.shardArr[s].tally(hist)
+		hll.mutArr[s].Unlock()
+		sparse = sparse && shardSparse
+		entries += n
+	}
+	return hllEstimate(hll.p, hist, sparse, entries)
+}
+
+/*Returns a copy of every register as a single shard, copying one shard at a time under its lock.*/
+func (hll StripedHyperLogLog) snapshot() *hllShard {
+	clones := make([]*hllShard, hll.shards)
+	for s := range hll.shardArr {
+		hll.mutArr[s].Lock()
+		clones[s] = hll.shardArr[s].clone()
+		hll.mutArr[s].Unlock()
+	}
+	return joinHLLShards(clones)
+}
+
+/*Merges a shard covering every register into the sketch, locking each shard once.*/
+func (hll StripedHyperLogLog) mergeAll(other *hllShard) {
+	for s, piece := range other.split(hll.shards) {
+		hll.mutArr[s].Lock()
+		hll.shardArr[s].merge(piece)
+		hll.mutArr[s].Unlock()
+	}
+}
+
+/*
+Merge adds every entry counted by other to the sketch, so that it estimates the size of the union of both. The sketches must have the same precision and hasher, but may have different shard counts.
+other is copied before the sketch is locked, so concurrent merges in both directions can't deadlock. To merge a HyperLogLog, serialize it and ReadFrom (or Load) it instead.
+*/
+func (hll StripedHyperLogLog) Merge(other *StripedHyperLogLog) error {
+	if err := checkHLLMerge(hll.p, hll.hasher, other.p, other.hasher); err != nil {
+		return err
+	}
+	hll.mergeAll(other.snapshot())
+	return nil
+}
+
+/*Precision returns the sketch's precision: it has 2^precision registers.*/
+func (hll StripedHyperLogLog) Precision() uint8 {
+	return hll.p
+}
+
+/*Hasher returns the hasher the sketch was built with.*/
+func (hll StripedHyperLogLog) Hasher() Hasher {
+	return hll.hasher
+}
+
+/*
+WriteTo writes the sketch to w in the package's binary format (see serialization.go) and returns the number of bytes written. It implements io.WriterTo.
+Copies one shard at a time under its lock, so concurrent inserts may be captured in some shards but not others.
+*/
+func (hll StripedHyperLogLog) WriteTo(w io.Writer) (int64, error) {
+	return writeHLL(w, KindStripedHyperLogLog, hll.shards, hll.hasher, hll.snapshot())
+}
+
+/*
+ReadFrom merges the sketch with a serialized HyperLogLog or StripedHyperLogLog read from r and returns the number of bytes read. It implements io.ReaderFrom.
+Exactly one serialized sketch is consumed from r. It must have the same precision and hasher, but may have any shard count.
+The sketch is read and verified in full before the merge, which locks each shard once.
+*/
+func (hll StripedHyperLogLog) ReadFrom(r io.Reader) (int64, error) {
+	h, payload, n, err := readFilter(r, func(h fileHeader) error {
+		return h.checkHLL(hll.p, hll.hasher)
+	})
+	if err != nil {
+		return n, err
+	}
+	s, err := parseHLL(h, payload)
+	if err != nil {
+		return n, err
+	}
+	hll.mergeAll(s)
+	return n, nil
+}
+
+/*MarshalBinary returns the sketch in the package's binary format. It implements encoding.BinaryMarshaler (and so also works with encoding/gob).*/
+func (hll StripedHyperLogLog) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := hll.WriteTo(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+/*
+UnmarshalBinary replaces the sketch with a serialized HyperLogLog or StripedHyperLogLog, taking its precision, shard count and hasher from data (a HyperLogLog gets a shard count picked from GOMAXPROCS). It implements encoding.BinaryUnmarshaler.
+The sketch's current hasher is kept if it matches the serialized one (which is the only way to unmarshal a sketch using a keyed hasher). It must not be called concurrently with any other method.
+*/
+func (hll *StripedHyperLogLog) UnmarshalBinary(data []byte) error {
+	h, payload, err := unmarshalFilter(data, KindStripedHyperLogLog)
+	if err != nil {
+		return err
+	}
+	s, err := parseHLL(h, payload)
+	if err != nil {
+		return err
+	}
+	hasher, err := h.resolveHasher(hll.hasher)
+	if err != nil {
+		return err
+	}
+	shards := h.shards
+	if h.kind != KindStripedHyperLogLog {
+		shards = estimateHLLShards(h.size)
+	}
+	fresh, err := NewStripedHyperLogLog(s.p, shards, WithHasher(hasher))
+	if err != nil {
+		return err
+	}
+	fresh.mergeAll(s)
+	*hll = *fresh
+	return nil
+}
+
+/*Writes the sketch to a file. See WriteTo.*/
+func (hll StripedHyperLogLog) Write(filename string) error {
+	return writeFile(filename, hll.WriteTo)
+}
+
+/*Merges the sketch with one loaded from a file. See ReadFrom.*/
+func (hll StripedHyperLogLog) Load(filename string) error {
+	return readFile(filename, hll.ReadFrom)
+}
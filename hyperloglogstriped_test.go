@@ -0,0 +1,132 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestNewStripedHyperLogLog(t *testing.T) {
+	for _, params := range []struct {
+		p      uint8
+		shards uint64
+	}{{3, 1}, {19, 1}, {14, 0}, {14, 3}, {8, 32}} {
+		hll, err := NewStripedHyperLogLog(params.p, params.shards)
+		assert.NotNil(t, err, "%v", params)
+		assert.Nil(t, hll)
+	}
+	hll, err := NewStripedHyperLogLog(4, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), hll.Count())
+}
+
+/*A StripedHyperLogLog must hold the same registers as a HyperLogLog fed the same entries, and give the same estimates unless only some of its shards have switched to dense.*/
+func TestStripedHyperLogLogMatchesUnstriped(t *testing.T) {
+	plain, err := NewHyperLogLog(12)
+	assert.Nil(t, err)
+	striped, err := NewStripedHyperLogLog(12, 16)
+	assert.Nil(t, err)
+	inserted := 0
+	for _, n := range []int{0, 10, 100, 1000, 3000, 100000} {
+		entries := staticKeys("entry", n)[inserted:]
+		assert.Nil(t, plain.InsertBatch(entries))
+		for i, e := range entries {
+			if i%2 == 0 {
+				assert.Nil(t, striped.Insert(e))
+			} else {
+				assert.Nil(t, striped.InsertBatch([]string{e}))
+			}
+		}
+		inserted = n
+		if n <= 100 || n >= 100000 {
+			assert.Equal(t, plain.Count(), striped.Count(), "n=%d", n)
+		} else {
+			assert.InDelta(t, float64(plain.Count()), float64(striped.Count()), 0.03*float64(n), "n=%d", n)
+		}
+
+		//Both serialize the same registers
+		want, got := plain.shard.clone(), striped.snapshot()
+		want.toDense()
+		got.toDense()
+		assert.Equal(t, want.dense, got.dense, "n=%d", n)
+	}
+}
+
+func TestStripedHyperLogLogConcurrent(t *testing.T) {
+	hll, err := NewStripedHyperLogLog(14, 64)
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 20000; i++ {
+				hll.InsertUint64(uint64(w*20000 + i))
+				if i%5000 == 0 {
+					hll.Count()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	assert.InDelta(t, 160000, float64(hll.Count()), 0.025*160000)
+}
+
+func TestStripedHyperLogLogMerge(t *testing.T) {
+	a, err := NewStripedHyperLogLog(12, 4)
+	assert.Nil(t, err)
+	b, err := NewStripedHyperLogLog(12, 64)
+	assert.Nil(t, err)
+	union, err := NewHyperLogLog(12)
+	assert.Nil(t, err)
+	for i := 0; i < 10000; i++ {
+		assert.Nil(t, union.InsertUint64(uint64(i)))
+		if i < 6000 {
+			assert.Nil(t, a.InsertUint64(uint64(i)))
+		}
+		if i >= 5990 {
+			assert.Nil(t, b.InsertUint64(uint64(i)))
+		}
+	}
+	assert.Nil(t, a.Merge(b))
+	assert.Equal(t, union.Count(), a.Count())
+
+	other, err := NewStripedHyperLogLog(13, 4)
+	assert.Nil(t, err)
+	assert.NotNil(t, a.Merge(other))
+}
+
+/*Striped and unstriped sketches load into one another, whatever their shard counts.*/
+func TestStripedHyperLogLogSerialization(t *testing.T) {
+	for _, n := range []int{50, 50000} {
+		striped, err := NewStripedHyperLogLog(12, 8)
+		assert.Nil(t, err)
+		for i := 0; i < n; i++ {
+			assert.Nil(t, striped.InsertUint64(uint64(i)))
+		}
+		data, err := striped.MarshalBinary()
+		assert.Nil(t, err)
+
+		var restored StripedHyperLogLog
+		assert.Nil(t, restored.UnmarshalBinary(data))
+		assert.Equal(t, uint64(8), restored.shards)
+		assert.Equal(t, striped.Count(), restored.Count())
+
+		var plain HyperLogLog
+		assert.Nil(t, plain.UnmarshalBinary(data))
+		assert.Equal(t, striped.Count(), plain.Count())
+
+		plainData, err := plain.MarshalBinary()
+		assert.Nil(t, err)
+		assert.Nil(t, restored.UnmarshalBinary(plainData))
+		assert.Equal(t, estimateHLLShards(1<<12), restored.shards)
+		assert.Equal(t, striped.Count(), restored.Count())
+
+		merged, err := NewStripedHyperLogLog(12, 2)
+		assert.Nil(t, err)
+		_, err = merged.ReadFrom(bytes.NewReader(plainData))
+		assert.Nil(t, err)
+		assert.Equal(t, striped.Count(), merged.Count())
+	}
+}
@@ -0,0 +1,71 @@
+package hyperbloom
+
+/*
+Hashable is the set of key types accepted by the generic Insert and Lookup.
+Strings and byte slices with the same contents are the same key. Integers are hashed by value as 8 byte little endian, so uint32(5), int64(5) and uint64(5) are all the same key (and negative values are the same key as their two's complement uint64).
+*/
+type Hashable interface {
+	string | []byte | uint64 | uint32 | uint16 | uint8 | uint | int64 | int32 | int16 | int8 | int
+}
+
+/*Insert adds key to f, dispatching to Insert, InsertBytes or InsertUint64 by key type without allocating.*/
+func Insert[K Hashable](f Inserter, key K) error {
+	switch k := any(key).(type) {
+	case string:
+		return f.Insert(k)
+	case []byte:
+		return f.InsertBytes(k)
+	case uint64:
+		return f.InsertUint64(k)
+	case uint32:
+		return f.InsertUint64(uint64(k))
+	case uint16:
+		return f.InsertUint64(uint64(k))
+	case uint8:
+		return f.InsertUint64(uint64(k))
+	case uint:
+		return f.InsertUint64(uint64(k))
+	case int64:
+		return f.InsertUint64(uint64(k))
+	case int32:
+		return f.InsertUint64(uint64(k))
+	case int16:
+		return f.InsertUint64(uint64(k))
+	case int8:
+		return f.InsertUint64(uint64(k))
+	case int:
+		return f.InsertUint64(uint64(k))
+	}
+	panic("unreachable")
+}
+
+/*Lookup probes q for key, dispatching to Lookup, LookupBytes or LookupUint64 by key type without allocating.*/
+func Lookup[K Hashable](q Querier, key K) (bool, error) {
+	switch k := any(key).(type) {
+	case string:
+		return q.Lookup(k)
+	case []byte:
+		return q.LookupBytes(k)
+	case uint64:
+		return q.LookupUint64(k)
+	case uint32:
+		return q.LookupUint64(uint64(k))
+	case uint16:
+		return q.LookupUint64(uint64(k))
+	case uint8:
+		return q.LookupUint64(uint64(k))
+	case uint:
+		return q.LookupUint64(uint64(k))
+	case int64:
+		return q.LookupUint64(uint64(k))
+	case int32:
+		return q.LookupUint64(uint64(k))
+	case int16:
+		return q.LookupUint64(uint64(k))
+	case int8:
+		return q.LookupUint64(uint64(k))
+	case int:
+		return q.LookupUint64(uint64(k))
+	}
+	panic("unreachable")
+}
@@ -0,0 +1,135 @@
+package hyperbloom
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestKeyAPIs(t *testing.T) {
+	for _, spec := range conformanceSpecs {
+		f, err := NewFilter(spec)
+		assert.Nil(t, err)
+
+		//Strings and byte slices are interchangeable
+		assert.Nil(t, f.InsertBytes([]byte("foo")))
+		exists, err := f.Lookup("foo")
+		assert.Nil(t, err)
+		assert.True(t, exists)
+		assert.Nil(t, f.Insert("bar"))
+		exists, err = f.LookupBytes([]byte("bar"))
+		assert.Nil(t, err)
+		assert.True(t, exists)
+
+		//Integers hash as their little endian encoding
+		assert.Nil(t, f.InsertUint64(0x0102030405060708))
+		exists, err = f.LookupBytes([]byte{8, 7, 6, 5, 4, 3, 2, 1})
+		assert.Nil(t, err)
+		assert.True(t, exists)
+		exists, err = f.LookupUint64(0x0102030405060709)
+		assert.Nil(t, err)
+		assert.False(t, exists)
+
+		//The generic path dispatches by key type
+		assert.Nil(t, Insert(f, int32(-7)))
+		exists, err = Lookup(f, uint64(0xFFFFFFFFFFFFFFF9))
+		assert.Nil(t, err)
+		assert.True(t, exists)
+		assert.Nil(t, Insert(f, uint8(5)))
+		exists, err = Lookup(f, 5)
+		assert.Nil(t, err)
+		assert.True(t, exists)
+		assert.Nil(t, Insert(f, "baz"))
+		exists, err = Lookup(f, []byte("baz"))
+		assert.Nil(t, err)
+		assert.True(t, exists)
+		exists, err = Lookup(f, "qux")
+		assert.Nil(t, err)
+		assert.False(t, exists)
+	}
+}
+
+func TestKeyAPIsDontAllocate(t *testing.T) {
+	key := []byte("b99afb65c9f97b2e0feea844eea55f69")
+	str := string(key)
+	for _, spec := range conformanceSpecs {
+		f, err := NewFilter(spec)
+		assert.Nil(t, err)
+		allocs := testing.AllocsPerRun(100, func() {
+			f.Insert(str)
+			f.InsertBytes(key)
+			f.InsertUint64(123456789)
+			f.Lookup(str)
+			f.LookupBytes(key)
+			f.LookupUint64(123456789)
+			Insert(f, uint64(123456789))
+			Lookup(f, key)
+			Lookup(f, int64(-123456789))
+		})
+		assert.Equal(t, 0.0, allocs, spec.Kind.String())
+	}
+}
+
+func benchmarkKinds(b *testing.B, fn func(b *testing.B, f Filter)) {
+	for _, spec := range conformanceSpecs {
+		b.Run(spec.Kind.String(), func(b *testing.B) {
+			f, err := NewFilter(spec)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			fn(b, f)
+		})
+	}
+}
+
+func BenchmarkInsertString(b *testing.B) {
+	key := "b99afb65c9f97b2e0feea844eea55f69"
+	benchmarkKinds(b, func(b *testing.B, f Filter) {
+		for i := 0; i < b.N; i++ {
+			f.Insert(key)
+		}
+	})
+}
+
+func BenchmarkInsertBytes(b *testing.B) {
+	key := []byte("b99afb65c9f97b2e0feea844eea55f69")
+	benchmarkKinds(b, func(b *testing.B, f Filter) {
+		for i := 0; i < b.N; i++ {
+			f.InsertBytes(key)
+		}
+	})
+}
+
+func BenchmarkInsertUint64(b *testing.B) {
+	benchmarkKinds(b, func(b *testing.B, f Filter) {
+		for i := 0; i < b.N; i++ {
+			f.InsertUint64(uint64(i))
+		}
+	})
+}
+
+func BenchmarkInsertGeneric(b *testing.B) {
+	benchmarkKinds(b, func(b *testing.B, f Filter) {
+		for i := 0; i < b.N; i++ {
+			Insert(f, i)
+		}
+	})
+}
+
+func BenchmarkLookupBytes(b *testing.B) {
+	key := []byte("b99afb65c9f97b2e0feea844eea55f69")
+	benchmarkKinds(b, func(b *testing.B, f Filter) {
+		for i := 0; i < b.N; i++ {
+			f.LookupBytes(key)
+		}
+	})
+}
+
+func BenchmarkLookupUint64(b *testing.B) {
+	benchmarkKinds(b, func(b *testing.B, f Filter) {
+		for i := 0; i < b.N; i++ {
+			f.LookupUint64(uint64(i))
+		}
+	})
+}
@@ -0,0 +1,450 @@
+package hyperbloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sync"
+	"unsafe"
+)
+
+/*
+Memory mapped filters keep their bit (or byte) vector in a file mapped into memory, laid out exactly as the package's serialization format (see serialization.go): the 48 byte header, the payload and the CRC-32C. A file saved with Write can be opened mapped and, once synced, a mapped file can be read with Load.
+Opening a mapped filter doesn't read the vector, so even very large filters open immediately and their pages are read in as they are used.
+
+The checksum is only brought up to date by Sync and Close, and isn't verified when the file is opened, so a file whose writer crashed still opens mapped (with whichever of its updates reached the disk) but is rejected by Load until it has been synced again.
+Mapping the vector in place relies on the host being little endian, like the format; on big endian hosts opening a mapped filter returns an error.
+*/
+
+/*MapMode selects how a memory mapped filter's file is opened.*/
+type MapMode int
+
+const (
+	MapReadWrite MapMode = iota //Inserts are written through to the file, which is shared with every process mapping it read-write
+	MapReadOnly                 //The file is never written. Pages are shared with other processes until the filter is inserted into, which copies the page into private memory.
+)
+
+/*Returned by UnmarshalBinary on memory mapped filters, as replacing the filter would leave its mapping and file open and out of reach of Sync and Close.*/
+var errUnmarshalMapped = errors.New("Can't unmarshal into a memory mapped filter: merge into it with ReadFrom, or unmarshal into a filter that isn't mapped")
+
+/*A filter file mapped into memory.*/
+type mappedFile struct {
+	file     *os.File
+	data     []byte //The whole file: header, payload and checksum
+	writable bool
+}
+
+/*Creates a file holding an empty filter with the given header and maps it read-write. Fails if the file already exists.*/
+func createMappedFile(path string, h fileHeader) (*mappedFile, []byte, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = f.WriteAt(h.marshal(), 0)
+	if err == nil {
+		err = f.Truncate(int64(headerLen + h.payloadLen + 4)) //The payload of an empty filter is all zeros
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, nil, err
+	}
+	m, payload, err := mapFile(f, h, MapReadWrite)
+	if err == nil {
+		err = m.sync()
+	}
+	if err != nil {
+		if m != nil {
+			m.close()
+		}
+		os.Remove(path)
+		return nil, nil, err
+	}
+	return m, payload, nil
+}
+
+/*
+Opens and maps a filter file, checking its header with check. Returns the header and the payload, which aliases the mapping.
+*/
+func openMappedFile(path string, mode MapMode, check func(fileHeader) error) (*mappedFile, fileHeader, []byte, error) {
+	flag := os.O_RDWR
+	if mode == MapReadOnly {
+		flag = os.O_RDONLY
+	}
+	f, err := os.OpenFile(path, flag, 0)
+	if err != nil {
+		return nil, fileHeader{}, nil, err
+	}
+	hb := make([]byte, headerLen)
+	if _, err := f.ReadAt(hb, 0); err != nil {
+		f.Close()
+		return nil, fileHeader{}, nil, err
+	}
+	h, err := parseFileHeader(hb)
+	if err == nil {
+		err = check(h)
+	}
+	if err != nil {
+		f.Close()
+		return nil, h, nil, err
+	}
+	if info, err := f.Stat(); err != nil {
+		f.Close()
+		return nil, h, nil, err
+	} else if info.Size() != int64(headerLen+h.payloadLen+4) {
+		f.Close()
+		return nil, h, nil, fmt.Errorf("File is %d bytes, a %s filter of size %d takes %d", info.Size(), h.kind, h.size, headerLen+h.payloadLen+4)
+	}
+	m, payload, err := mapFile(f, h, mode)
+	return m, h, payload, err
+}
+
+func mapFile(f *os.File, h fileHeader, mode MapMode) (*mappedFile, []byte, error) {
+	if !littleEndianHost() {
+		f.Close()
+		return nil, nil, errors.New("Memory mapped filters need a little endian host")
+	}
+	data, err := mmapFile(f, int(headerLen+h.payloadLen+4), mode == MapReadWrite)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return &mappedFile{file: f, data: data, writable: mode == MapReadWrite}, data[headerLen : headerLen+h.payloadLen], nil
+}
+
+func littleEndianHost() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}
+
+/*Returns a mapped payload as 64 bit words. The payload starts 48 bytes into a page aligned mapping, so it is suitably aligned.*/
+func mappedWords(payload []byte) []uint64 {
+	return unsafe.Slice((*uint64)(unsafe.Pointer(unsafe.SliceData(payload))), len(payload)/8)
+}
+
+/*Recomputes the checksum and flushes the mapping to the file.*/
+func (m *mappedFile) sync() error {
+	if m.data == nil {
+		return errors.New("Filter has been closed")
+	} else if !m.writable {
+		return errors.New("Filter is mapped read-only")
+	}
+	n := len(m.data) - 4
+	binary.LittleEndian.PutUint32(m.data[n:], crc32.Checksum(m.data[:n], crcTable))
+	return msync(m.data)
+}
+
+/*Syncs (if writable) and unmaps the file.*/
+func (m *mappedFile) close() error {
+	if m.data == nil {
+		return nil
+	}
+	var err error
+	if m.writable {
+		err = m.sync()
+	}
+	if unmapErr := munmap(m.data); err == nil {
+		err = unmapErr
+	}
+	m.data = nil
+	if closeErr := m.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+/*Returns an error unless a filter with a bit vector of 64 bit words can be mapped from the file: BloomFilter, StripedBloomFilter and AtomicBloomFilter files.*/
+func checkMappedWords(h fileHeader) error {
+	if !h.bitPayload() || h.kind.blocked() {
+		return fmt.Errorf("Can't map a %s filter as a bit vector", h.kind)
+	}
+	return nil
+}
+
+/*Returns an error unless a filter with a byte vector can be mapped from the file: NaiveBloomFilter and NaiveStripedBloomFilter files.*/
+func checkMappedBytes(h fileHeader) error {
+	if h.kind != KindNaive && h.kind != KindNaiveStriped {
+		return fmt.Errorf("Can't map a %s filter as a byte vector", h.kind)
+	}
+	return nil
+}
+
+/*Checks the size (and, for striped filters, the shard count) of a filter to be created mapped, as the constructors do.*/
+func checkMappedSize(size uint64, shards uint64, striped bool) error {
+	if size < 64 {
+		return errors.New("Filter size must be at least 64")
+	} else if size&(size-1) != 0 {
+		return errors.New("Size must be a power of 2")
+	} else if !striped {
+		return nil
+	} else if shards == 0 {
+		return errors.New("Shards must be nonzero")
+	} else if shards&(shards-1) != 0 {
+		return errors.New("Shards must be a power of 2")
+	} else if shards > size/64 {
+		return errors.New("Shards cannot exceed size/64")
+	}
+	return nil
+}
+
+/*Returns the shard count for a striped filter mapped from a file: the file's own, or one picked from GOMAXPROCS for unstriped files.*/
+func mappedShards(h fileHeader) uint64 {
+	if h.kind.striped() {
+		return h.shards
+	}
+	return estimateShards(h.size)
+}
+
+/*
+CreateMappedBloomFilter creates a file at path holding an empty BloomFilter with the given size (in bits) and hash count, and returns the filter mapped read-write from it. It fails if the file already exists.
+Call Sync to flush the filter to the file and Close to unmap it; the filter must not be used after Close.
+*/
+func CreateMappedBloomFilter(path string, size uint64, hf int, opts ...Option) (*BloomFilter, error) {
+	if err := checkMappedSize(size, 0, false); err != nil {
+		return nil, err
+	} else if err := checkHashes(hf); err != nil {
+		return nil, err
+	}
+	hasher := buildOptions(opts).hasher
+	m, payload, err := createMappedFile(path, newFileHeader(KindBloom, size, hf, 0, hasher))
+	if err != nil {
+		return nil, err
+	}
+	return &BloomFilter{bv: mappedWords(payload), size: size, hf: hf, hasher: hasher, mut: &sync.RWMutex{}, mapping: m}, nil
+}
+
+/*
+OpenMappedBloomFilter maps the BloomFilter (or StripedBloomFilter or AtomicBloomFilter) saved at path, taking its size, hash count and hasher from the file. The hasher passed with WithHasher is kept if it matches the file's (which is the only way to open a filter using a keyed hasher).
+Call Close to unmap it; the filter must not be used after Close.
+*/
+func OpenMappedBloomFilter(path string, mode MapMode, opts ...Option) (*BloomFilter, error) {
+	m, h, payload, err := openMappedFile(path, mode, checkMappedWords)
+	if err != nil {
+		return nil, err
+	}
+	hasher, err := h.resolveHasher(buildOptions(opts).hasher)
+	if err != nil {
+		m.close()
+		return nil, err
+	}
+	return &BloomFilter{bv: mappedWords(payload), size: h.size, hf: h.hf, hasher: hasher, mut: &sync.RWMutex{}, mapping: m}, nil
+}
+
+/*Sync flushes a memory mapped filter to its file and brings the file's checksum up to date. Read locks the filter. It returns an error for filters that aren't mapped or are mapped read-only.*/
+func (bf BloomFilter) Sync() error {
+	if bf.mapping == nil {
+		return errors.New("Filter is not memory mapped")
+	}
+	bf.mut.RLock()
+	defer bf.mut.RUnlock()
+	return bf.mapping.sync()
+}
+
+/*Close syncs a memory mapped filter (unless it is read-only) and unmaps it. The filter must not be used afterwards. It does nothing for filters that aren't mapped.*/
+func (bf BloomFilter) Close() error {
+	if bf.mapping == nil {
+		return nil
+	}
+	bf.mut.Lock()
+	defer bf.mut.Unlock()
+	return bf.mapping.close()
+}
+
+/*
+CreateMappedStripedBloomFilter creates a file at path holding an empty StripedBloomFilter with the given size (in bits), hash count and shard count, and returns the filter mapped read-write from it. It fails if the file already exists.
+Call Sync to flush the filter to the file and Close to unmap it; the filter must not be used after Close.
+*/
+func CreateMappedStripedBloomFilter(path string, size uint64, hf int, shards uint64, opts ...Option) (*StripedBloomFilter, error) {
+	if err := checkMappedSize(size, shards, true); err != nil {
+		return nil, err
+	} else if err := checkHashes(hf); err != nil {
+		return nil, err
+	}
+	hasher := buildOptions(opts).hasher
+	m, payload, err := createMappedFile(path, newFileHeader(KindStriped, size, hf, shards, hasher))
+	if err != nil {
+		return nil, err
+	}
+	return newMappedStriped(m, mappedWords(payload), size, hf, shards, hasher), nil
+}
+
+/*
+OpenMappedStripedBloomFilter maps the StripedBloomFilter (or BloomFilter or AtomicBloomFilter) saved at path, taking its size, hash count, shard count and hasher from the file (unstriped files get a shard count picked from GOMAXPROCS). The hasher passed with WithHasher is kept if it matches the file's.
+Call Close to unmap it; the filter must not be used after Close.
+*/
+func OpenMappedStripedBloomFilter(path string, mode MapMode, opts ...Option) (*StripedBloomFilter, error) {
+	m, h, payload, err := openMappedFile(path, mode, checkMappedWords)
+	if err != nil {
+		return nil, err
+	}
+	hasher, err := h.resolveHasher(buildOptions(opts).hasher)
+	if err == nil {
+		err = checkMappedSize(h.size, mappedShards(h), true)
+	}
+	if err != nil {
+		m.close()
+		return nil, err
+	}
+	return newMappedStriped(m, mappedWords(payload), h.size, h.hf, mappedShards(h), hasher), nil
+}
+
+func newMappedStriped(m *mappedFile, bv []uint64, size uint64, hf int, shards uint64, hasher Hasher) *StripedBloomFilter {
+	bf := &StripedBloomFilter{bv: bv, size: size, shards: shards, hf: hf, hasher: hasher, shardLen: size / shards, mapping: m}
+	bf.mutArr = make([]*sync.Mutex, shards)
+	for i := range bf.mutArr {
+		bf.mutArr[i] = &sync.Mutex{}
+	}
+	return bf
+}
+
+/*Locks every shard, in order. Returns the function that unlocks them.*/
+func lockAll(mutArr []*sync.Mutex) func() {
+	for _, mut := range mutArr {
+		mut.Lock()
+	}
+	return func() {
+		for _, mut := range mutArr {
+			mut.Unlock()
+		}
+	}
+}
+
+/*Sync flushes a memory mapped filter to its file and brings the file's checksum up to date. Locks every shard, so that the checksum matches the vector. It returns an error for filters that aren't mapped or are mapped read-only.*/
+func (bf StripedBloomFilter) Sync() error {
+	if bf.mapping == nil {
+		return errors.New("Filter is not memory mapped")
+	}
+	defer lockAll(bf.mutArr)()
+	return bf.mapping.sync()
+}
+
+/*Close syncs a memory mapped filter (unless it is read-only) and unmaps it. The filter must not be used afterwards. It does nothing for filters that aren't mapped.*/
+func (bf StripedBloomFilter) Close() error {
+	if bf.mapping == nil {
+		return nil
+	}
+	defer lockAll(bf.mutArr)()
+	return bf.mapping.close()
+}
+
+/*
+CreateMappedNaiveBloomFilter creates a file at path holding an empty NaiveBloomFilter with the given size (in bytes) and hash count, and returns the filter mapped read-write from it. It fails if the file already exists.
+Call Sync to flush the filter to the file and Close to unmap it; the filter must not be used after Close.
+*/
+func CreateMappedNaiveBloomFilter(path string, size uint64, hf int, opts ...Option) (*NaiveBloomFilter, error) {
+	if err := checkMappedSize(size, 0, false); err != nil {
+		return nil, err
+	} else if err := checkHashes(hf); err != nil {
+		return nil, err
+	}
+	hasher := buildOptions(opts).hasher
+	m, payload, err := createMappedFile(path, newFileHeader(KindNaive, size, hf, 0, hasher))
+	if err != nil {
+		return nil, err
+	}
+	return &NaiveBloomFilter{bv: payload, size: size, hf: hf, hasher: hasher, mut: &sync.RWMutex{}, mapping: m}, nil
+}
+
+/*
+OpenMappedNaiveBloomFilter maps the NaiveBloomFilter (or NaiveStripedBloomFilter) saved at path, taking its size, hash count and hasher from the file. The hasher passed with WithHasher is kept if it matches the file's.
+Call Close to unmap it; the filter must not be used after Close.
+*/
+func OpenMappedNaiveBloomFilter(path string, mode MapMode, opts ...Option) (*NaiveBloomFilter, error) {
+	m, h, payload, err := openMappedFile(path, mode, checkMappedBytes)
+	if err != nil {
+		return nil, err
+	}
+	hasher, err := h.resolveHasher(buildOptions(opts).hasher)
+	if err != nil {
+		m.close()
+		return nil, err
+	}
+	return &NaiveBloomFilter{bv: payload, size: h.size, hf: h.hf, hasher: hasher, mut: &sync.RWMutex{}, mapping: m}, nil
+}
+
+/*Sync flushes a memory mapped filter to its file and brings the file's checksum up to date. Read locks the filter. It returns an error for filters that aren't mapped or are mapped read-only.*/
+func (bf NaiveBloomFilter) Sync() error {
+	if bf.mapping == nil {
+		return errors.New("Filter is not memory mapped")
+	}
+	bf.mut.RLock()
+	defer bf.mut.RUnlock()
+	return bf.mapping.sync()
+}
+
+/*Close syncs a memory mapped filter (unless it is read-only) and unmaps it. The filter must not be used afterwards. It does nothing for filters that aren't mapped.*/
+func (bf NaiveBloomFilter) Close() error {
+	if bf.mapping == nil {
+		return nil
+	}
+	bf.mut.Lock()
+	defer bf.mut.Unlock()
+	return bf.mapping.close()
+}
+
+/*
+CreateMappedNaiveStripedBloomFilter creates a file at path holding an empty NaiveStripedBloomFilter with the given size (in bytes), hash count and shard count, and returns the filter mapped read-write from it. It fails if the file already exists.
+Call Sync to flush the filter to the file and Close to unmap it; the filter must not be used after Close.
+*/
+func CreateMappedNaiveStripedBloomFilter(path string, size uint64, hf int, shards uint64, opts ...Option) (*NaiveStripedBloomFilter, error) {
+	if err := checkMappedSize(size, shards, true); err != nil {
+		return nil, err
+	} else if err := checkHashes(hf); err != nil {
+		return nil, err
+	}
+	hasher := buildOptions(opts).hasher
+	m, payload, err := createMappedFile(path, newFileHeader(KindNaiveStriped, size, hf, shards, hasher))
+	if err != nil {
+		return nil, err
+	}
+	return newMappedNaiveStriped(m, payload, size, hf, shards, hasher), nil
+}
+
+/*
+OpenMappedNaiveStripedBloomFilter maps the NaiveStripedBloomFilter (or NaiveBloomFilter) saved at path, taking its size, hash count, shard count and hasher from the file (unstriped files get a shard count picked from GOMAXPROCS). The hasher passed with WithHasher is kept if it matches the file's.
+Call Close to unmap it; the filter must not be used after Close.
+*/
+func OpenMappedNaiveStripedBloomFilter(path string, mode MapMode, opts ...Option) (*NaiveStripedBloomFilter, error) {
+	m, h, payload, err := openMappedFile(path, mode, checkMappedBytes)
+	if err != nil {
+		return nil, err
+	}
+	hasher, err := h.resolveHasher(buildOptions(opts).hasher)
+	if err == nil {
+		err = checkMappedSize(h.size, mappedShards(h), true)
+	}
+	if err != nil {
+		m.close()
+		return nil, err
+	}
+	return newMappedNaiveStriped(m, payload, h.size, h.hf, mappedShards(h), hasher), nil
+}
+
+func newMappedNaiveStriped(m *mappedFile, bv []byte, size uint64, hf int, shards uint64, hasher Hasher) *NaiveStripedBloomFilter {
+	bf := &NaiveStripedBloomFilter{bv: bv, size: size, shards: shards, hf: hf, hasher: hasher, shardLen: size / shards, mapping: m}
+	bf.mutArr = make([]*sync.Mutex, shards)
+	for i := range bf.mutArr {
+		bf.mutArr[i] = &sync.Mutex{}
+	}
+	return bf
+}
+
+/*Sync flushes a memory mapped filter to its file and brings the file's checksum up to date. Locks every shard, so that the checksum matches the vector. It returns an error for filters that aren't mapped or are mapped read-only.*/
+func (bf NaiveStripedBloomFilter) Sync() error {
+	if bf.mapping == nil {
+		return errors.New("Filter is not memory mapped")
+	}
+	defer lockAll(bf.mutArr)()
+	return bf.mapping.sync()
+}
+
+/*Close syncs a memory mapped filter (unless it is read-only) and unmaps it. The filter must not be used afterwards. It does nothing for filters that aren't mapped.*/
+func (bf NaiveStripedBloomFilter) Close() error {
+	if bf.mapping == nil {
+		return nil
+	}
+	defer lockAll(bf.mutArr)()
+	return bf.mapping.close()
+}
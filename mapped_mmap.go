@@ -0,0 +1,31 @@
+//go:build linux || darwin || freebsd || openbsd || dragonfly
+
+package hyperbloom
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+/*Maps the first length bytes of f. Read-only mappings are private, so writes to them are never carried through to the file.*/
+func mmapFile(f *os.File, length int, writable bool) ([]byte, error) {
+	flags := syscall.MAP_SHARED
+	if !writable {
+		flags = syscall.MAP_PRIVATE
+	}
+	return syscall.Mmap(int(f.Fd()), 0, length, syscall.PROT_READ|syscall.PROT_WRITE, flags)
+}
+
+func munmap(data []byte) error {
+	return syscall.Munmap(data)
+}
+
+/*Flushes the mapping to the file and waits for the writes to finish.*/
+func msync(data []byte) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(unsafe.SliceData(data))), uintptr(len(data)), syscall.MS_SYNC)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build !(linux || darwin || freebsd || openbsd || dragonfly)
+
+package hyperbloom
+
+import (
+	"errors"
+	"os"
+)
+
+var errMapUnsupported = errors.New("Memory mapped filters are not supported on this platform")
+
+func mmapFile(f *os.File, length int, writable bool) ([]byte, error) {
+	return nil, errMapUnsupported
+}
+
+func munmap(data []byte) error {
+	return errMapUnsupported
+}
+
+func msync(data []byte) error {
+	return errMapUnsupported
+}
@@ -0,0 +1,213 @@
+//go:build linux || darwin || freebsd || openbsd || dragonfly
+
+package hyperbloom
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMappedBloomFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter")
+	entries := benchmarkEntries(1000)
+	bf, err := CreateMappedBloomFilter(path, 1<<16, 4)
+	assert.Nil(t, err)
+	assert.Nil(t, bf.InsertBatch(entries[:500]))
+	assert.Nil(t, bf.Sync())
+	_, err = CreateMappedBloomFilter(path, 1<<16, 4)
+	assert.NotNil(t, err)
+
+	//A synced file loads like one saved with Write
+	loaded, err := NewBloomFilter(1<<16, 4)
+	assert.Nil(t, err)
+	assert.Nil(t, loaded.Load(path))
+	assert.True(t, loaded.Equal(bf))
+	assert.Nil(t, bf.InsertBatch(entries[500:]))
+	assert.Nil(t, bf.Close())
+
+	rw, err := OpenMappedBloomFilter(path, MapReadWrite)
+	assert.Nil(t, err)
+	results, err := rw.LookupBatch(entries)
+	assert.Nil(t, err)
+	assert.NotContains(t, results, false)
+	ro, err := OpenMappedBloomFilter(path, MapReadOnly)
+	assert.Nil(t, err)
+	assert.True(t, ro.Equal(rw))
+
+	//Read-write inserts are shared with every mapping, read-only ones stay private, and clones aren't mapped
+	assert.Nil(t, rw.Insert("read-write"))
+	exists, _ := ro.Lookup("read-write")
+	assert.True(t, exists)
+	assert.Nil(t, ro.Insert("read-only"))
+	exists, _ = rw.Lookup("read-only")
+	assert.False(t, exists)
+	assert.NotNil(t, ro.Sync())
+	assert.NotNil(t, rw.Clone().Sync())
+	assert.Nil(t, ro.Close())
+	assert.Nil(t, rw.Close())
+	assert.Nil(t, rw.Close())
+
+	loaded.Reset()
+	assert.Nil(t, loaded.Load(path))
+	exists, _ = loaded.Lookup("read-write")
+	assert.True(t, exists)
+	exists, _ = loaded.Lookup("read-only")
+	assert.False(t, exists)
+
+	//Only mapped filters sync
+	assert.NotNil(t, loaded.Sync())
+	assert.Nil(t, loaded.Close())
+}
+
+func TestMappedFromWrite(t *testing.T) {
+	dir := t.TempDir()
+	entries := benchmarkEntries(1000)
+	bf, err := NewBloomFilter(1<<16, 4, WithHasher(NewMurmur3Hasher(7)))
+	assert.Nil(t, err)
+	assert.Nil(t, bf.InsertBatch(entries))
+	path := filepath.Join(dir, "bloom")
+	assert.Nil(t, bf.Write(path))
+
+	mapped, err := OpenMappedBloomFilter(path, MapReadOnly)
+	assert.Nil(t, err)
+	assert.True(t, mapped.Equal(bf))
+	assert.Nil(t, mapped.Close())
+	striped, err := OpenMappedStripedBloomFilter(path, MapReadWrite)
+	assert.Nil(t, err)
+	assert.Equal(t, estimateShards(1<<16), striped.shards)
+	results, err := striped.LookupBatch(entries)
+	assert.Nil(t, err)
+	assert.NotContains(t, results, false)
+	assert.Nil(t, striped.Close())
+	assert.Nil(t, bf.Load(path))
+
+	//Filters laid out differently are rejected
+	naive, err := NewNaiveBloomFilter(1<<16, 4)
+	assert.Nil(t, err)
+	naivePath := filepath.Join(dir, "naive")
+	assert.Nil(t, naive.Write(naivePath))
+	_, err = OpenMappedBloomFilter(naivePath, MapReadOnly)
+	assert.NotNil(t, err)
+	_, err = OpenMappedNaiveBloomFilter(path, MapReadOnly)
+	assert.NotNil(t, err)
+	blocked, err := NewBlockedBloomFilter(1<<16, 4)
+	assert.Nil(t, err)
+	blockedPath := filepath.Join(dir, "blocked")
+	assert.Nil(t, blocked.Write(blockedPath))
+	_, err = OpenMappedBloomFilter(blockedPath, MapReadOnly)
+	assert.NotNil(t, err)
+
+	//So are truncated files
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	truncated := filepath.Join(dir, "truncated")
+	assert.Nil(t, os.WriteFile(truncated, data[:len(data)-8], 0666))
+	_, err = OpenMappedBloomFilter(truncated, MapReadOnly)
+	assert.NotNil(t, err)
+
+	//The file's hasher wins over a mismatched one
+	mapped, err = OpenMappedBloomFilter(path, MapReadOnly, WithHasher(NewMurmur3Hasher(8)))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(7), mapped.Hasher().Seed())
+	assert.Nil(t, mapped.Close())
+}
+
+func TestMappedStriped(t *testing.T) {
+	dir := t.TempDir()
+	entries := benchmarkEntries(1000)
+	_, err := CreateMappedStripedBloomFilter(filepath.Join(dir, "bad"), 1<<16, 4, 3)
+	assert.NotNil(t, err)
+
+	path := filepath.Join(dir, "striped")
+	bf, err := CreateMappedStripedBloomFilter(path, 1<<16, 4, 16)
+	assert.Nil(t, err)
+	assert.Nil(t, bf.InsertBatch(entries))
+	assert.Nil(t, bf.Close())
+	plain, err := OpenMappedBloomFilter(path, MapReadOnly)
+	assert.Nil(t, err)
+	reopened, err := OpenMappedStripedBloomFilter(path, MapReadOnly)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(16), reopened.shards)
+	for _, f := range []Filter{plain, reopened} {
+		results, err := f.LookupBatch(entries)
+		assert.Nil(t, err)
+		assert.NotContains(t, results, false)
+	}
+	assert.Nil(t, plain.Close())
+	assert.Nil(t, reopened.Close())
+
+	naivePath := filepath.Join(dir, "naivestriped")
+	nb, err := CreateMappedNaiveStripedBloomFilter(naivePath, 1<<16, 4, 16)
+	assert.Nil(t, err)
+	assert.Nil(t, nb.InsertBatch(entries))
+	assert.Nil(t, nb.Sync())
+	loaded, err := NewNaiveStripedBloomFilter(1<<16, 4, 4)
+	assert.Nil(t, err)
+	assert.Nil(t, loaded.Load(naivePath))
+	assert.True(t, loaded.Equal(nb))
+	assert.Nil(t, nb.Close())
+	naive, err := OpenMappedNaiveBloomFilter(naivePath, MapReadWrite)
+	assert.Nil(t, err)
+	results, err := naive.LookupBatch(entries)
+	assert.Nil(t, err)
+	assert.NotContains(t, results, false)
+	assert.Nil(t, naive.Close())
+}
+
+func TestMappedNaiveBloomFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "naive")
+	entries := benchmarkEntries(1000)
+	bf, err := CreateMappedNaiveBloomFilter(path, 1<<16, 4)
+	assert.Nil(t, err)
+	assert.Nil(t, bf.InsertBatch(entries))
+	assert.Nil(t, bf.Close())
+	striped, err := OpenMappedNaiveStripedBloomFilter(path, MapReadOnly)
+	assert.Nil(t, err)
+	assert.Equal(t, estimateShards(1<<16), striped.shards)
+	results, err := striped.LookupBatch(entries)
+	assert.Nil(t, err)
+	assert.NotContains(t, results, false)
+	assert.Nil(t, striped.Close())
+}
+
+func TestMappedUnmarshal(t *testing.T) {
+	dir := t.TempDir()
+	bf, err := NewBloomFilter(1<<12, 3)
+	assert.Nil(t, err)
+	assert.Nil(t, bf.Insert("alice"))
+	data, err := bf.MarshalBinary()
+	assert.Nil(t, err)
+
+	mbf, err := CreateMappedBloomFilter(filepath.Join(dir, "bloom"), 1<<12, 3)
+	assert.Nil(t, err)
+	sbf, err := CreateMappedStripedBloomFilter(filepath.Join(dir, "striped"), 1<<12, 3, 4)
+	assert.Nil(t, err)
+	nbf, err := CreateMappedNaiveBloomFilter(filepath.Join(dir, "naive"), 1<<12, 3)
+	assert.Nil(t, err)
+	nsbf, err := CreateMappedNaiveStripedBloomFilter(filepath.Join(dir, "naivestriped"), 1<<12, 3, 4)
+	assert.Nil(t, err)
+	clone := mbf.Clone()
+	for _, f := range []interface {
+		Filter
+		Sync() error
+		Close() error
+	}{mbf, sbf, nbf, nsbf} {
+		//The filter keeps its mapping, which can still be merged into, synced and closed
+		assert.Equal(t, errUnmarshalMapped, f.UnmarshalBinary(data))
+		assert.Nil(t, f.Insert("bob"))
+		assert.Nil(t, f.Sync())
+		assert.Nil(t, f.Close())
+	}
+	loaded, err := NewBloomFilter(1<<12, 3)
+	assert.Nil(t, err)
+	assert.Nil(t, loaded.Load(filepath.Join(dir, "bloom")))
+	exists, _ := loaded.Lookup("bob")
+	assert.True(t, exists)
+
+	//A clone isn't mapped, so it can be unmarshalled into
+	assert.Nil(t, clone.UnmarshalBinary(data))
+	exists, _ = clone.Lookup("alice")
+	assert.True(t, exists)
+}
@@ -80,3 +80,15 @@ func TestNaiveBloomFilter(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, false, fake4Exists)
 }
+
+func TestNewNaiveBloomFilterWithEstimates(t *testing.T) {
+	bf, fpRate, err := NewNaiveBloomFilterWithEstimates(1000000, 0.01)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1<<24), bf.size)
+	assert.Equal(t, 12, bf.hf)
+	assert.True(t, fpRate <= 0.01)
+
+	bf, _, err = NewNaiveBloomFilterWithEstimates(0, 0.01)
+	assert.NotNil(t, err)
+	assert.Nil(t, bf)
+}
@@ -1,13 +1,10 @@
 package hyperbloom
 
 import (
-	"bufio"
 	"bytes"
 	"errors"
-	"fmt"
 	"io"
-	"log"
-	"os"
+	"slices"
 	"sync"
 )
 
@@ -19,15 +16,18 @@ type NaiveStripedBloomFilter struct {
 	size     uint64        //Size of bitvector. MUST BE A POWER OF 2.
 	shards   uint64        //Number of shards. size must be multiple of shards.
 	hf       int           //Number of hash functions
+	hasher   Hasher        //Produces the base hashes for each entry
 	mutArr   []*sync.Mutex //Mutex for each shard
 	shardLen uint64        //Precomputed number of bits per shard
+	mapping  *mappedFile   //File the bitvector is mapped from, if any
 }
 
-/*NewNaiveStripedBloomfilter allocates a NaiveStripedBloomFilter with a given size (in bits) and using a certain number of hashes.
+/*
+NewNaiveStripedBloomfilter allocates a NaiveStripedBloomFilter with a given size (in bits) and using a certain number of hashes.
 Size must be a power of 2 and larger than 64.
 Shards must be a power of 2 (smaller than size) and cannot exceed size/64.
 */
-func NewNaiveStripedBloomFilter(size uint64, hf int, shards uint64) (*NaiveStripedBloomFilter, error) {
+func NewNaiveStripedBloomFilter(size uint64, hf int, shards uint64, opts ...Option) (*NaiveStripedBloomFilter, error) {
 	/*
 	 * Create a new bloomfilter of size "size"
 	 * The bloomfilter will hash "hf" times
@@ -37,6 +37,8 @@ func NewNaiveStripedBloomFilter(size uint64, hf int, shards uint64) (*NaiveStrip
 	bf.shards = shards
 	if bf.size < 64 {
 		return nil, errors.New("Filter size must be at least 64")
+	} else if bf.shards == 0 {
+		return nil, errors.New("Shards must be nonzero")
 	} else if (bf.size & (bf.size - 1)) != 0 {
 		return nil, errors.New("Size must be a power of 2")
 	} else if (bf.shards & (bf.shards - 1)) != 0 {
@@ -45,9 +47,12 @@ func NewNaiveStripedBloomFilter(size uint64, hf int, shards uint64) (*NaiveStrip
 		return nil, errors.New("Shards cannot exceed size/64")
 	} else if bf.size%bf.shards != 0 {
 		return nil, errors.New("Size must be a multiple of shards")
+	} else if err := checkHashes(hf); err != nil {
+		return nil, err
 	}
 	bf.bv = make([]byte, size)
 	bf.hf = int(hf)
+	bf.hasher = buildOptions(opts).hasher
 	bf.mutArr = make([]*sync.Mutex, shards)
 
 	for i := 0; i < int(shards); i++ {
@@ -62,6 +67,22 @@ func NewNaiveStripedBloomFilter(size uint64, hf int, shards uint64) (*NaiveStrip
 	return &bf, nil
 }
 
+/*
+NewNaiveStripedBloomFilterWithEstimates allocates a NaiveStripedBloomFilter sized for n entries at a target false positive rate p (see EstimateParameters). The shard count is picked from GOMAXPROCS.
+It also returns the false positive rate expected once n entries have been inserted.
+*/
+func NewNaiveStripedBloomFilterWithEstimates(n uint64, p float64, opts ...Option) (*NaiveStripedBloomFilter, float64, error) {
+	size, hf, fpRate, err := EstimateParameters(n, p)
+	if err != nil {
+		return nil, 0, err
+	}
+	bf, err := NewNaiveStripedBloomFilter(size, hf, estimateShards(size), opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bf, fpRate, nil
+}
+
 func (bf NaiveStripedBloomFilter) setByte(idx uint64) error {
 	if idx > (bf.size - 1) {
 		return errors.New("Index can't be larger than filter size")
@@ -104,9 +125,34 @@ func (bf NaiveStripedBloomFilter) getByteAsync(idx uint64) (bool, error) {
 /*Looks up an entry in the NaiveStripedBloomFilter. Returns true if a match is found, false otherwise. If an error occurs, will also return false. This will lock one shard of the filter.
  */
 func (bf NaiveStripedBloomFilter) Lookup(entry string) (bool, error) {
-	hashes := hashEntry([]byte(entry), bf.hf)
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2)
+}
+
+/*
+Looks up an entry in the NaiveStripedBloomFilter. Returns true if a match is found, false otherwise. If an error occurs, will also return false.
+This won't lock the filter.
+*/
+func (bf NaiveStripedBloomFilter) LookupAsync(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.lookupHashesAsync(h1, h2)
+}
+
+/*LookupBytes is Lookup for a byte slice entry. It gives the same answer as Lookup(string(entry)) without converting or allocating.*/
+func (bf NaiveStripedBloomFilter) LookupBytes(entry []byte) (bool, error) {
+	h1, h2 := baseHashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2)
+}
+
+/*LookupUint64 is Lookup for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (bf NaiveStripedBloomFilter) LookupUint64(entry uint64) (bool, error) {
+	h1, h2 := uint64Hashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2)
+}
+
+func (bf NaiveStripedBloomFilter) lookupHashes(h1, h2 uint64) (bool, error) {
 	for i := 0; i < bf.hf; i++ {
-		lookup_idx := hashes[i] & (bf.size - 1)
+		lookup_idx := nthHash(h1, h2, i) & (bf.size - 1)
 		if exists, err := bf.getByte(lookup_idx); !exists {
 			if err != nil {
 				return false, err
@@ -117,13 +163,9 @@ func (bf NaiveStripedBloomFilter) Lookup(entry string) (bool, error) {
 	return true, nil
 }
 
-/*Looks up an entry in the NaiveStripedBloomFilter. Returns true if a match is found, false otherwise. If an error occurs, will also return false.
-This won't lock the filter.
-*/
-func (bf NaiveStripedBloomFilter) LookupAsync(entry string) (bool, error) {
-	hashes := hashEntry([]byte(entry), bf.hf)
+func (bf NaiveStripedBloomFilter) lookupHashesAsync(h1, h2 uint64) (bool, error) {
 	for i := 0; i < bf.hf; i++ {
-		lookup_idx := hashes[i] & (bf.size - 1)
+		lookup_idx := nthHash(h1, h2, i) & (bf.size - 1)
 		if exists, err := bf.getByteAsync(lookup_idx); !exists {
 			if err != nil {
 				return false, err
@@ -136,9 +178,31 @@ func (bf NaiveStripedBloomFilter) LookupAsync(entry string) (bool, error) {
 
 /*Inserts an entry into the NaiveStripedBloomFilter. Locks one shard of the filter.*/
 func (bf NaiveStripedBloomFilter) Insert(entry string) error {
-	hashes := hashEntry([]byte(entry), bf.hf)
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.insertHashes(h1, h2)
+}
+
+/*Inserts an entry into the NaiveBloomFilter. Doesn't lock the filter.*/
+func (bf NaiveStripedBloomFilter) InsertAsync(entry string) error {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.insertHashesAsync(h1, h2)
+}
+
+/*InsertBytes is Insert for a byte slice entry. It is equivalent to Insert(string(entry)) without converting or allocating.*/
+func (bf NaiveStripedBloomFilter) InsertBytes(entry []byte) error {
+	h1, h2 := baseHashes(bf.hasher, entry)
+	return bf.insertHashes(h1, h2)
+}
+
+/*InsertUint64 is Insert for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (bf NaiveStripedBloomFilter) InsertUint64(entry uint64) error {
+	h1, h2 := uint64Hashes(bf.hasher, entry)
+	return bf.insertHashes(h1, h2)
+}
+
+func (bf NaiveStripedBloomFilter) insertHashes(h1, h2 uint64) error {
 	for i := 0; i < bf.hf; i++ {
-		insert_idx := hashes[i] & (bf.size - 1)
+		insert_idx := nthHash(h1, h2, i) & (bf.size - 1)
 		err := bf.setByte(insert_idx)
 		if err != nil {
 			return err
@@ -147,11 +211,9 @@ func (bf NaiveStripedBloomFilter) Insert(entry string) error {
 	return nil
 }
 
-/*Inserts an entry into the NaiveBloomFilter. Doesn't lock the filter.*/
-func (bf NaiveStripedBloomFilter) InsertAsync(entry string) error {
-	hashes := hashEntry([]byte(entry), bf.hf)
+func (bf NaiveStripedBloomFilter) insertHashesAsync(h1, h2 uint64) error {
 	for i := 0; i < bf.hf; i++ {
-		insert_idx := hashes[i] & (bf.size - 1)
+		insert_idx := nthHash(h1, h2, i) & (bf.size - 1)
 		err := bf.setByteAsync(insert_idx)
 		if err != nil {
 			return err
@@ -160,52 +222,327 @@ func (bf NaiveStripedBloomFilter) InsertAsync(entry string) error {
 	return nil
 }
 
-/*Writes underlying bit vector to a file.*/
-func (bf NaiveStripedBloomFilter) Write(filename string) error {
-	/*Writes bit vector to file*/
-	var err error
-	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
-	defer f.Close()
-	if err != nil {
+/*
+TestAndInsert inserts entry and reports whether it was (probably) already present, as a single operation. This replaces a racy Lookup followed by Insert and hashes the entry once.
+Every shard holding one of the entry's buckets is locked (in ascending order, so concurrent calls can't deadlock) for the whole operation. This makes it atomic with respect to other TestAndInsert calls: of any number of concurrent TestAndInsert calls for the same entry, exactly one reports it absent.
+It is not atomic with respect to Insert, InsertBatch or Load, which lock one shard at a time: racing with an Insert of the same entry, both may treat the entry as new.
+*/
+func (bf NaiveStripedBloomFilter) TestAndInsert(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	indices := make([]uint64, bf.hf)
+	for i := range indices {
+		indices[i] = nthHash(h1, h2, i) & (bf.size - 1)
+	}
+	shards := lockShards(bf.mutArr, indices, bf.shardLen)
+	defer unlockShards(bf.mutArr, shards)
+	present := true
+	for _, idx := range indices {
+		if bf.bv[idx] != 1 {
+			present = false
+			bf.bv[idx] = 1
+		}
+	}
+	return present, nil
+}
+
+/*
+InsertBatch inserts every entry in entries. Every entry is hashed first, then each shard touched by the batch is locked once.
+Inserts are not atomic as a whole: concurrent lookups may see some entries of the batch before others.
+*/
+func (bf NaiveStripedBloomFilter) InsertBatch(entries []string) error {
+	indices := batchIndices(bf.hasher, entries, bf.hf, bf.size)
+	order, offsets := groupByShard(indices, bf.shardLen, bf.shards)
+	for s := uint64(0); s < bf.shards; s++ {
+		if offsets[s] == offsets[s+1] {
+			continue
+		}
+		bf.mutArr[s].Lock()
+		for _, pos := range order[offsets[s]:offsets[s+1]] {
+			bf.bv[indices[pos]] = 1
+		}
+		bf.mutArr[s].Unlock()
+	}
+	return nil
+}
+
+/*
+LookupBatch looks up every entry in entries and returns whether each one was found, in the same order. Every entry is hashed first, then each shard touched by the batch is locked once.
+*/
+func (bf NaiveStripedBloomFilter) LookupBatch(entries []string) ([]bool, error) {
+	indices := batchIndices(bf.hasher, entries, bf.hf, bf.size)
+	order, offsets := groupByShard(indices, bf.shardLen, bf.shards)
+	results := allPresent(len(entries))
+	for s := uint64(0); s < bf.shards; s++ {
+		if offsets[s] == offsets[s+1] {
+			continue
+		}
+		bf.mutArr[s].Lock()
+		for _, pos := range order[offsets[s]:offsets[s+1]] {
+			if !results[pos/bf.hf] {
+				continue //Already known to be absent
+			}
+			if bf.bv[indices[pos]] != 1 {
+				results[pos/bf.hf] = false
+			}
+		}
+		bf.mutArr[s].Unlock()
+	}
+	return results, nil
+}
+
+/*Hasher returns the hasher the filter was built with.*/
+func (bf NaiveStripedBloomFilter) Hasher() Hasher {
+	return bf.hasher
+}
+
+/*Spec returns the Spec describing the filter, from which NewFilter builds an empty filter like it.*/
+func (bf NaiveStripedBloomFilter) Spec() Spec {
+	return Spec{Kind: KindNaiveStriped, Size: bf.size, Hashes: bf.hf, Shards: bf.shards, Hasher: bf.hasher}
+}
+
+/*PopCount returns the number of bytes set in the filter. Locks one shard at a time, so concurrent inserts may be counted in some shards but not others.*/
+func (bf NaiveStripedBloomFilter) PopCount() uint64 {
+	n := uint64(0)
+	for s := uint64(0); s < bf.shards; s++ {
+		bf.mutArr[s].Lock()
+		n += popCountBytes(bf.bv[s*bf.shardLen : (s+1)*bf.shardLen])
+		bf.mutArr[s].Unlock()
+	}
+	return n
+}
+
+/*FillRatio returns the fraction of the filter's bytes that are set.*/
+func (bf NaiveStripedBloomFilter) FillRatio() float64 {
+	return float64(bf.PopCount()) / float64(bf.size)
+}
+
+/*
+EstimatedCount returns an estimate of the number of distinct entries inserted into the filter, from the number of bytes set (Swamidass & Baldi). It returns math.MaxUint64 once every byte is set, as the filter is then saturated.
+*/
+func (bf NaiveStripedBloomFilter) EstimatedCount() uint64 {
+	return estimateCount(bf.size, bf.hf, bf.PopCount())
+}
+
+/*EstimatedFalsePositiveRate returns the filter's current false positive rate, estimated from its fill ratio (FillRatio()^hf).*/
+func (bf NaiveStripedBloomFilter) EstimatedFalsePositiveRate() float64 {
+	return fillFalsePositiveRate(bf.size, bf.hf, bf.PopCount())
+}
+
+/*
+UnionWith sets every byte that is set in other, so the filter holds every entry of either. other must have the same size, hash count and hasher, but may have a different shard count.
+Works a stripe at a time: each stripe of other is copied under its locks, then the matching stripe of the filter is locked and updated. No two locks are held at once, so concurrent calls can't deadlock.
+*/
+func (bf NaiveStripedBloomFilter) UnionWith(other *NaiveStripedBloomFilter) error {
+	return bf.combine(other, orBytes)
+}
+
+/*
+IntersectWith clears every byte that isn't set in other, so the filter only matches entries that both filters match. other must have the same size, hash count and hasher, but may have a different shard count. Locks as UnionWith does.
+The result has at least the false positive rate of a filter built from the common entries alone, as it keeps bytes that other entries set in both filters.
+*/
+func (bf NaiveStripedBloomFilter) IntersectWith(other *NaiveStripedBloomFilter) error {
+	return bf.combine(other, andBytes)
+}
+
+func (bf NaiveStripedBloomFilter) combine(other *NaiveStripedBloomFilter, op func(dst, src []byte)) error {
+	if err := checkCombinable(bf.size, bf.hf, bf.hasher, other.size, other.hf, other.hasher); err != nil {
 		return err
 	}
-	var b bytes.Buffer
-	for i := 0; i < int(bf.size); i++ {
-		bin := fmt.Sprintf("%d", bf.bv[i])
-		b.Write([]byte(bin))
-	}
-	bv := b.Bytes()
-	log.Println("Writing bit vector to file...")
-	f.Write(bv)
-	f.Close()
-	log.Printf("Successfully wrote bitvector to file: %s\n", filename)
+	stripe := make([]byte, bf.shardLen)
+	for s := uint64(0); s < bf.shards; s++ {
+		other.copyStripe(s*bf.shardLen, stripe)
+		bf.mutArr[s].Lock()
+		op(bf.bv[s*bf.shardLen:(s+1)*bf.shardLen], stripe)
+		bf.mutArr[s].Unlock()
+	}
 	return nil
 }
 
-/*Merges current bit vector with one loaded from a file.
-This locks the filter for each nonzero byte being written.
+/*Copies the filter's bytes from start into dst, locking each shard they span in turn.*/
+func (bf NaiveStripedBloomFilter) copyStripe(start uint64, dst []byte) {
+	end := start + uint64(len(dst))
+	for i := start; i < end; {
+		s := i / (bf.shardLen)
+		next := min((s+1)*(bf.shardLen), end)
+		bf.mutArr[s].Lock()
+		copy(dst[i-start:], bf.bv[i:next])
+		bf.mutArr[s].Unlock()
+		i = next
+	}
+}
+
+/*Union returns a new filter with the same shard count holding every entry of either filter, leaving both unchanged. See UnionWith.*/
+func (bf NaiveStripedBloomFilter) Union(other *NaiveStripedBloomFilter) (*NaiveStripedBloomFilter, error) {
+	if err := checkCombinable(bf.size, bf.hf, bf.hasher, other.size, other.hf, other.hasher); err != nil {
+		return nil, err
+	}
+	fresh := bf.Clone()
+	return fresh, fresh.UnionWith(other)
+}
+
+/*Intersect returns a new filter with the same shard count matching the entries both filters match, leaving both unchanged. See IntersectWith.*/
+func (bf NaiveStripedBloomFilter) Intersect(other *NaiveStripedBloomFilter) (*NaiveStripedBloomFilter, error) {
+	if err := checkCombinable(bf.size, bf.hf, bf.hasher, other.size, other.hf, other.hasher); err != nil {
+		return nil, err
+	}
+	fresh := bf.Clone()
+	return fresh, fresh.IntersectWith(other)
+}
+
+/*
+EstimatedIntersectionCount estimates the number of entries inserted into both filters, from the bytes set in each and in their union. other must have the same size, hash count and hasher. Locks as UnionWith does.
+It returns math.MaxUint64 if the union of the filters is saturated.
 */
-func (bf NaiveStripedBloomFilter) Load(filename string) error {
-	f, err := os.Open(filename)
-	defer f.Close()
+func (bf NaiveStripedBloomFilter) EstimatedIntersectionCount(other *NaiveStripedBloomFilter) (uint64, error) {
+	if err := checkCombinable(bf.size, bf.hf, bf.hasher, other.size, other.hf, other.hasher); err != nil {
+		return 0, err
+	}
+	popA, popB, popU := uint64(0), uint64(0), uint64(0)
+	stripe := make([]byte, bf.shardLen)
+	for s := uint64(0); s < bf.shards; s++ {
+		other.copyStripe(s*bf.shardLen, stripe)
+		bf.mutArr[s].Lock()
+		a, b, u := unionPopCountBytes(bf.bv[s*bf.shardLen:(s+1)*bf.shardLen], stripe)
+		bf.mutArr[s].Unlock()
+		popA, popB, popU = popA+a, popB+b, popU+u
+	}
+	return estimateIntersection(bf.size, bf.hf, popA, popB, popU), nil
+}
+
+/*Reset clears every byte in the filter, leaving it empty with the same parameters. Locks one shard at a time.*/
+func (bf NaiveStripedBloomFilter) Reset() {
+	for s := uint64(0); s < bf.shards; s++ {
+		bf.mutArr[s].Lock()
+		clear(bf.bv[s*bf.shardLen : (s+1)*bf.shardLen])
+		bf.mutArr[s].Unlock()
+	}
+}
+
+/*Clone returns a deep copy of the filter, with its own byte vector and mutexes. Locks one shard at a time while it is copied.*/
+func (bf NaiveStripedBloomFilter) Clone() *NaiveStripedBloomFilter {
+	clone := bf
+	clone.mapping = nil
+	clone.bv = make([]byte, len(bf.bv))
+	bf.copyStripe(0, clone.bv)
+	clone.mutArr = make([]*sync.Mutex, bf.shards)
+	for i := range clone.mutArr {
+		clone.mutArr[i] = &sync.Mutex{}
+	}
+	return &clone
+}
+
+/*
+Equal reports whether other has the same size, hash count, hasher and bytes as the filter. The shard counts may differ. Compares a stripe at a time, locking as UnionWith does.
+*/
+func (bf NaiveStripedBloomFilter) Equal(other *NaiveStripedBloomFilter) bool {
+	if checkCombinable(bf.size, bf.hf, bf.hasher, other.size, other.hf, other.hasher) != nil {
+		return false
+	}
+	stripe := make([]byte, bf.shardLen)
+	for s := uint64(0); s < bf.shards; s++ {
+		other.copyStripe(s*bf.shardLen, stripe)
+		bf.mutArr[s].Lock()
+		equal := slices.Equal(bf.bv[s*bf.shardLen:(s+1)*bf.shardLen], stripe)
+		bf.mutArr[s].Unlock()
+		if !equal {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+WriteTo writes the filter to w in the package's binary format (see serialization.go) and returns the number of bytes written. It implements io.WriterTo.
+Locks one shard at a time while it is written, so concurrent inserts may be captured in some shards but not others.
+*/
+func (bf NaiveStripedBloomFilter) WriteTo(w io.Writer) (int64, error) {
+	h := newFileHeader(KindNaiveStriped, bf.size, bf.hf, bf.shards, bf.hasher)
+	return writeFilter(w, h, func(w io.Writer) error {
+		for s := uint64(0); s < bf.shards; s++ {
+			bf.mutArr[s].Lock()
+			_, err := w.Write(bf.bv[s*bf.shardLen : (s+1)*bf.shardLen])
+			bf.mutArr[s].Unlock()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+/*
+ReadFrom merges the filter with a serialized one read from r and returns the number of bytes read. It implements io.ReaderFrom.
+Exactly one serialized filter is consumed from r. It may have been written by any variant but must have the same size, hash count and hasher.
+The filter is read and verified in full before the merge, which locks each shard once.
+*/
+func (bf NaiveStripedBloomFilter) ReadFrom(r io.Reader) (int64, error) {
+	h, payload, n, err := readFilter(r, func(h fileHeader) error {
+		return h.checkCompatible(KindNaiveStriped, bf.size, bf.hf, bf.hasher)
+	})
 	if err != nil {
-		return err
+		return n, err
 	}
+	bf.merge(h, payload)
+	return n, nil
+}
 
-	buf_rdr := bufio.NewReader(f)
-	bv, err := buf_rdr.ReadBytes('\n')
-	if err != nil && err != io.EOF {
+func (bf NaiveStripedBloomFilter) merge(h fileHeader, payload []byte) {
+	bv := payloadBytes(h, payload)
+	for s := uint64(0); s < bf.shards; s++ {
+		bf.mutArr[s].Lock()
+		for i := s * bf.shardLen; i < (s+1)*bf.shardLen; i++ {
+			if bv[i] != 0 {
+				bf.bv[i] = 1
+			}
+		}
+		bf.mutArr[s].Unlock()
+	}
+}
+
+/*MarshalBinary returns the filter in the package's binary format. It implements encoding.BinaryMarshaler (and so also works with encoding/gob).*/
+func (bf NaiveStripedBloomFilter) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := bf.WriteTo(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+/*
+UnmarshalBinary replaces the filter with a serialized one, taking its size, hash count and hasher from data. It implements encoding.BinaryUnmarshaler.
+The filter's current hasher is kept if it matches the serialized one (which is the only way to unmarshal a filter using a keyed hasher). It must not be called concurrently with any other method. Memory mapped filters can't be unmarshalled into (merge into them with ReadFrom).
+*/
+func (bf *NaiveStripedBloomFilter) UnmarshalBinary(data []byte) error {
+	if bf.mapping != nil {
+		return errUnmarshalMapped
+	}
+	h, payload, err := unmarshalFilter(data, KindNaiveStriped)
+	if err != nil {
 		return err
 	}
-	log.Println("Bytes read successfully")
-	if bf.size != uint64(len(bv)-1) {
-		return errors.New(fmt.Sprintf("File bitvector size: %d. Specified size: %d. Mismatch.", len(bv)-1, bf.size))
+	hasher, err := h.resolveHasher(bf.hasher)
+	if err != nil {
+		return err
 	}
-	for i := 0; i < len(bv); i++ {
-		if bv[i] > 0 {
-			bf.setByte(uint64(i))
-		}
+	shards := h.shards
+	if !h.kind.striped() {
+		shards = estimateShards(h.size)
 	}
-	fmt.Printf("Loaded bitvector from file: %s\n", filename)
+	fresh, err := NewNaiveStripedBloomFilter(h.size, h.hf, shards, WithHasher(hasher))
+	if err != nil {
+		return err
+	}
+	fresh.merge(h, payload)
+	*bf = *fresh
 	return nil
 }
+
+/*Writes the filter to a file. See WriteTo.*/
+func (bf NaiveStripedBloomFilter) Write(filename string) error {
+	return writeFile(filename, bf.WriteTo)
+}
+
+/*Merges the filter with one loaded from a file. See ReadFrom.*/
+func (bf NaiveStripedBloomFilter) Load(filename string) error {
+	return readFile(filename, bf.ReadFrom)
+}
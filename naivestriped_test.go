@@ -84,3 +84,16 @@ func TestNaiveStripedBloomFilter(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, false, fake4Exists)
 }
+
+func TestNewNaiveStripedBloomFilterWithEstimates(t *testing.T) {
+	bf, fpRate, err := NewNaiveStripedBloomFilterWithEstimates(1000000, 0.01)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1<<24), bf.size)
+	assert.Equal(t, 12, bf.hf)
+	assert.Equal(t, estimateShards(bf.size), bf.shards)
+	assert.True(t, fpRate <= 0.01)
+
+	bf, _, err = NewNaiveStripedBloomFilterWithEstimates(10, 0.1)
+	assert.Nil(t, err)
+	assert.True(t, bf.shards <= bf.size/64)
+}
@@ -0,0 +1,45 @@
+package hyperbloom
+
+/*
+Option configures optional behaviour of a filter at construction time. Every constructor in this package accepts a trailing list of options.
+*/
+type Option func(*options)
+
+type options struct {
+	hasher     Hasher
+	growth     uint64
+	tightening float64
+}
+
+/*WithHasher makes the filter hash its entries with h instead of DefaultHasher.*/
+func WithHasher(h Hasher) Option {
+	return func(o *options) {
+		if h != nil {
+			o.hasher = h
+		}
+	}
+}
+
+/*WithGrowth sets the factor by which the capacity of each new layer of a ScalableBloomFilter grows (2 by default). Other filters ignore it.*/
+func WithGrowth(factor uint64) Option {
+	return func(o *options) {
+		o.growth = factor
+	}
+}
+
+/*
+WithTightening sets the ratio by which the false positive rate of each new layer of a ScalableBloomFilter shrinks (0.9 by default). Other filters ignore it.
+*/
+func WithTightening(ratio float64) Option {
+	return func(o *options) {
+		o.tightening = ratio
+	}
+}
+
+func buildOptions(opts []Option) options {
+	o := options{hasher: DefaultHasher, growth: 2, tightening: 0.9}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,424 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/iamthebot/hyperbloom"
+	"github.com/iamthebot/hyperbloom/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*Batches are sent in messages of at most this many keys and bytes of keys, well within gRPC's default 4MB message limit.*/
+const (
+	maxChunkKeys  = 4096
+	maxChunkBytes = 1 << 20
+)
+
+var _ hyperbloom.Filter = (*Client)(nil)
+
+/*
+Client is a filter hosted by a FilterService, with the methods of hyperbloom.Filter so that callers can swap a local filter for a remote one.
+Every method is a call to the server, made with the client's context (see WithContext). Errors for unknown filters, taken names and a missing snapshot directory are server.ErrNotFound, server.ErrExists and server.ErrNoDir, as with a local Store, and filters too large for the server wrap server.ErrTooLarge.
+The Async methods are the same as the locking ones, since the server decides how to lock. Batches larger than a message are streamed with BatchInsert and BatchLookup.
+Client also has the local filters' accessors, Reset, UnionWith and Equal, which return an error as well since they are calls too. The filter methods that take or return another local filter (Union, Intersect, IntersectWith, Clone) aren't offered: export the filters with MarshalBinary to combine them locally.
+Serialized filters travel in single messages, so filters larger than 4MB need a larger grpc.MaxCallRecvMsgSize on the client (and grpc.MaxRecvMsgSize on the server to send them).
+*/
+type Client struct {
+	client FilterServiceClient
+	name   string
+	ctx    context.Context
+}
+
+/*NewClient returns a client for the filter called name on the server at the other end of cc. The filter needn't exist yet (see Create).*/
+func NewClient(cc grpc.ClientConnInterface, name string) *Client {
+	return &Client{client: NewFilterServiceClient(cc), name: name, ctx: context.Background()}
+}
+
+/*WithContext returns a copy of the client that makes its calls with ctx.*/
+func (c *Client) WithContext(ctx context.Context) *Client {
+	clone := *c
+	clone.ctx = ctx
+	return &clone
+}
+
+/*Name returns the name of the filter on the server.*/
+func (c *Client) Name() string {
+	return c.name
+}
+
+/*Returns the store's error for the status codes Service returns them as.*/
+func fromStatus(err error) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return server.ErrNotFound
+	case codes.AlreadyExists:
+		return server.ErrExists
+	case codes.FailedPrecondition:
+		return server.ErrNoDir
+	case codes.ResourceExhausted:
+		return fmt.Errorf("%w: %s", server.ErrTooLarge, status.Convert(err).Message())
+	}
+	return err
+}
+
+func bytesKeys(entries []string) [][]byte {
+	keys := make([][]byte, len(entries))
+	for i, entry := range entries {
+		keys[i] = []byte(entry)
+	}
+	return keys
+}
+
+/*Splits keys into runs of at most maxChunkKeys keys and maxChunkBytes bytes (or a single key). There is always at least one chunk.*/
+func chunks(keys [][]byte) [][][]byte {
+	var out [][][]byte
+	start, size := 0, 0
+	for i, key := range keys {
+		if i > start && (i-start == maxChunkKeys || size+len(key) > maxChunkBytes) {
+			out = append(out, keys[start:i])
+			start, size = i, 0
+		}
+		size += len(key)
+	}
+	return append(out, keys[start:])
+}
+
+/*Create creates the filter on the server from req (see server.CreateRequest).*/
+func (c *Client) Create(req server.CreateRequest) error {
+	_, err := c.client.CreateFilter(c.ctx, &CreateFilterRequest{Name: c.name, Kind: req.Kind, N: req.N, P: req.P, Size: req.Size, Hashes: int32(req.Hashes), Shards: req.Shards})
+	return fromStatus(err)
+}
+
+/*Delete removes the filter from the server.*/
+func (c *Client) Delete() error {
+	_, err := c.client.DeleteFilter(c.ctx, &DeleteFilterRequest{Name: c.name})
+	return fromStatus(err)
+}
+
+/*Stats describes the filter.*/
+func (c *Client) Stats() (server.Stats, error) {
+	info, err := c.client.GetFilter(c.ctx, &GetFilterRequest{Name: c.name})
+	if err != nil {
+		return server.Stats{}, fromStatus(err)
+	}
+	return server.Stats{
+		Name:                       info.Name,
+		Kind:                       info.Kind,
+		Size:                       info.Size,
+		Hashes:                     int(info.Hashes),
+		Shards:                     info.Shards,
+		Hasher:                     info.Hasher,
+		FillRatio:                  info.FillRatio,
+		EstimatedCount:             info.EstimatedCount,
+		EstimatedFalsePositiveRate: info.EstimatedFalsePositiveRate,
+		PopCount:                   info.PopCount,
+	}, nil
+}
+
+func (c *Client) info() (*FilterInfo, error) {
+	info, err := c.client.GetFilter(c.ctx, &GetFilterRequest{Name: c.name})
+	return info, fromStatus(err)
+}
+
+/*Spec returns the filter's parameters, with its hasher rebuilt by hyperbloom.NewHasher.*/
+func (c *Client) Spec() (hyperbloom.Spec, error) {
+	info, err := c.info()
+	if err != nil {
+		return hyperbloom.Spec{}, err
+	}
+	kind, err := hyperbloom.ParseKind(info.Kind)
+	if err != nil {
+		return hyperbloom.Spec{}, err
+	}
+	hasher, err := hyperbloom.NewHasher(hyperbloom.HasherID(info.HasherId), info.HasherSeed)
+	if err != nil {
+		return hyperbloom.Spec{}, err
+	}
+	return hyperbloom.Spec{Kind: kind, Size: info.Size, Hashes: int(info.Hashes), Shards: info.Shards, Hasher: hasher}, nil
+}
+
+/*Hasher returns the hasher the filter hashes entries with. See Spec.*/
+func (c *Client) Hasher() (hyperbloom.Hasher, error) {
+	spec, err := c.Spec()
+	return spec.Hasher, err
+}
+
+/*PopCount returns the number of set buckets in the filter.*/
+func (c *Client) PopCount() (uint64, error) {
+	info, err := c.info()
+	if err != nil {
+		return 0, err
+	}
+	return info.PopCount, nil
+}
+
+/*FillRatio returns the fraction of set buckets in the filter.*/
+func (c *Client) FillRatio() (float64, error) {
+	info, err := c.info()
+	if err != nil {
+		return 0, err
+	}
+	return info.FillRatio, nil
+}
+
+/*EstimatedCount estimates the number of distinct entries inserted into the filter from its fill ratio.*/
+func (c *Client) EstimatedCount() (uint64, error) {
+	info, err := c.info()
+	if err != nil {
+		return 0, err
+	}
+	return info.EstimatedCount, nil
+}
+
+/*EstimatedFalsePositiveRate estimates the filter's current false positive rate from its fill ratio.*/
+func (c *Client) EstimatedFalsePositiveRate() (float64, error) {
+	info, err := c.info()
+	if err != nil {
+		return 0, err
+	}
+	return info.EstimatedFalsePositiveRate, nil
+}
+
+/*Reset empties the filter, keeping its parameters.*/
+func (c *Client) Reset() error {
+	_, err := c.client.Reset(c.ctx, &ResetRequest{Name: c.name})
+	return fromStatus(err)
+}
+
+/*Inserts keys with a single call, or streams them if they don't fit in a message.*/
+func (c *Client) insert(keys [][]byte) error {
+	parts := chunks(keys)
+	if len(parts) == 1 {
+		_, err := c.client.Insert(c.ctx, &InsertRequest{Name: c.name, Keys: keys})
+		return fromStatus(err)
+	}
+	stream, err := c.client.BatchInsert(c.ctx)
+	if err != nil {
+		return fromStatus(err)
+	}
+	for _, part := range parts {
+		if err := stream.Send(&InsertRequest{Name: c.name, Keys: part}); err != nil {
+			break //The stream's status comes back from CloseAndRecv
+		}
+	}
+	_, err = stream.CloseAndRecv()
+	return fromStatus(err)
+}
+
+/*Looks keys up with a single call, or streams them if they don't fit in a message.*/
+func (c *Client) lookup(keys [][]byte) ([]bool, error) {
+	parts := chunks(keys)
+	if len(parts) == 1 {
+		resp, err := c.client.Lookup(c.ctx, &LookupRequest{Name: c.name, Keys: keys})
+		if err != nil {
+			return nil, fromStatus(err)
+		}
+		return resp.Present, nil
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	defer cancel() //Stops the sender if a reply fails
+	stream, err := c.client.BatchLookup(ctx)
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	go func() {
+		for _, part := range parts {
+			if stream.Send(&LookupRequest{Name: c.name, Keys: part}) != nil {
+				return
+			}
+		}
+		stream.CloseSend()
+	}()
+	results := make([]bool, 0, len(keys))
+	for _, part := range parts {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, fromStatus(err)
+		} else if len(resp.Present) != len(part) {
+			return nil, errors.New("Lookup returned the wrong number of results")
+		}
+		results = append(results, resp.Present...)
+	}
+	return results, nil
+}
+
+func (c *Client) lookupOne(key []byte) (bool, error) {
+	results, err := c.lookup([][]byte{key})
+	if err != nil {
+		return false, err
+	}
+	return results[0], nil
+}
+
+/*Inserts an entry into the filter.*/
+func (c *Client) Insert(entry string) error {
+	return c.insert([][]byte{[]byte(entry)})
+}
+
+/*InsertAsync is Insert.*/
+func (c *Client) InsertAsync(entry string) error {
+	return c.Insert(entry)
+}
+
+/*InsertBytes is Insert for a byte slice entry, equivalent to Insert(string(entry)).*/
+func (c *Client) InsertBytes(entry []byte) error {
+	return c.insert([][]byte{entry})
+}
+
+/*InsertUint64 is Insert for an integer entry, which is hashed as its 8 byte little endian encoding like the local filters do.*/
+func (c *Client) InsertUint64(entry uint64) error {
+	return c.insert([][]byte{binary.LittleEndian.AppendUint64(nil, entry)})
+}
+
+/*InsertBatch inserts every entry in entries. Each message of the batch is inserted as one batch on the server.*/
+func (c *Client) InsertBatch(entries []string) error {
+	return c.insert(bytesKeys(entries))
+}
+
+/*Looks up an entry in the filter. Returns true if it may be present.*/
+func (c *Client) Lookup(entry string) (bool, error) {
+	return c.lookupOne([]byte(entry))
+}
+
+/*LookupAsync is Lookup.*/
+func (c *Client) LookupAsync(entry string) (bool, error) {
+	return c.Lookup(entry)
+}
+
+/*LookupBytes is Lookup for a byte slice entry, equivalent to Lookup(string(entry)).*/
+func (c *Client) LookupBytes(entry []byte) (bool, error) {
+	return c.lookupOne(entry)
+}
+
+/*LookupUint64 is Lookup for an integer entry, which is hashed as its 8 byte little endian encoding like the local filters do.*/
+func (c *Client) LookupUint64(entry uint64) (bool, error) {
+	return c.lookupOne(binary.LittleEndian.AppendUint64(nil, entry))
+}
+
+/*LookupBatch looks up every entry in entries and returns whether each may be present, in the same order.*/
+func (c *Client) LookupBatch(entries []string) ([]bool, error) {
+	return c.lookup(bytesKeys(entries))
+}
+
+/*TestAndInsert inserts entry and reports whether it may already have been present, as a single operation on the server.*/
+func (c *Client) TestAndInsert(entry string) (bool, error) {
+	resp, err := c.client.TestAndInsert(c.ctx, &TestAndInsertRequest{Name: c.name, Keys: [][]byte{[]byte(entry)}})
+	if err != nil {
+		return false, fromStatus(err)
+	} else if len(resp.Present) != 1 {
+		return false, errors.New("TestAndInsert returned the wrong number of results")
+	}
+	return resp.Present[0], nil
+}
+
+/*UnionWith merges the filter called other on the same server into this one, so that it holds the entries of both. They must have the same size, hash count and hasher.*/
+func (c *Client) UnionWith(other string) error {
+	_, err := c.client.Merge(c.ctx, &MergeRequest{Name: c.name, Source: &MergeRequest_Filter{Filter: other}})
+	return fromStatus(err)
+}
+
+/*Equal reports whether the filter and other (which may be on another server) have the same parameters, hasher and contents, by comparing them serialized.*/
+func (c *Client) Equal(other *Client) (bool, error) {
+	data, err := c.MarshalBinary()
+	if err != nil {
+		return false, err
+	}
+	otherData, err := other.MarshalBinary()
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(data, otherData), nil
+}
+
+/*Snapshot saves the filter to the server's snapshot directory and returns the path written on the server.*/
+func (c *Client) Snapshot() (string, error) {
+	resp, err := c.client.Snapshot(c.ctx, &SnapshotRequest{Name: c.name})
+	if err != nil {
+		return "", fromStatus(err)
+	}
+	return resp.Path, nil
+}
+
+/*MarshalBinary returns the filter in the package's binary format. It implements encoding.BinaryMarshaler.*/
+func (c *Client) MarshalBinary() ([]byte, error) {
+	resp, err := c.client.Export(c.ctx, &ExportRequest{Name: c.name})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return resp.Data, nil
+}
+
+/*UnmarshalBinary replaces the filter on the server with a serialized one (or adds it), which keeps its variant, parameters and hasher. It implements encoding.BinaryUnmarshaler.*/
+func (c *Client) UnmarshalBinary(data []byte) error {
+	_, err := c.client.Replace(c.ctx, &ReplaceRequest{Name: c.name, Data: data})
+	return fromStatus(err)
+}
+
+/*WriteTo writes the filter to w in the package's binary format and returns the number of bytes written. It implements io.WriterTo.*/
+func (c *Client) WriteTo(w io.Writer) (int64, error) {
+	data, err := c.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+/*
+ReadFrom merges the filter with a serialized one read from r and returns the number of bytes read. It implements io.ReaderFrom.
+Exactly one serialized filter is consumed from r; the server checks it and merges it.
+*/
+func (c *Client) ReadFrom(r io.Reader) (int64, error) {
+	header := make([]byte, hyperbloom.HeaderLen)
+	n, err := io.ReadFull(r, header)
+	if err != nil {
+		return int64(n), err
+	}
+	length, err := hyperbloom.SerializedLen(header)
+	if err != nil {
+		return int64(n), err
+	}
+	var buf bytes.Buffer
+	buf.Write(header)
+	//Copied rather than allocated up front, as the payload length can't be trusted until the server checks the filter
+	m, err := io.CopyN(&buf, r, int64(min(length-hyperbloom.HeaderLen, 1<<62)))
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return int64(n) + m, err
+	}
+	_, err = c.client.Merge(c.ctx, &MergeRequest{Name: c.name, Source: &MergeRequest_Data{Data: buf.Bytes()}})
+	return int64(n) + m, fromStatus(err)
+}
+
+/*Writes the filter to a file. See WriteTo.*/
+func (c *Client) Write(filename string) error {
+	data, err := c.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0666)
+}
+
+/*Merges the filter with one loaded from a file. See ReadFrom.*/
+func (c *Client) Load(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = c.ReadFrom(bufio.NewReader(f))
+	return err
+}
@@ -0,0 +1,297 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/iamthebot/hyperbloom"
+	"github.com/iamthebot/hyperbloom/server"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+/*Runs the same operations on a local filter and a remote one with the same parameters, which must give the same answers.*/
+func TestClientMatchesLocal(t *testing.T) {
+	remote := NewClient(startService(t, server.NewStore("")), "users")
+	assert.Nil(t, remote.Create(server.CreateRequest{Kind: "striped", Size: 1 << 16, Hashes: 4, Shards: 8}))
+	local, err := hyperbloom.NewStripedBloomFilter(1<<16, 4, 8)
+	assert.Nil(t, err)
+
+	for _, f := range []hyperbloom.Filter{local, remote} {
+		assert.Nil(t, f.Insert("alice"))
+		assert.Nil(t, f.InsertAsync("bob"))
+		assert.Nil(t, f.InsertBytes([]byte("carol")))
+		assert.Nil(t, f.InsertUint64(42))
+		assert.Nil(t, f.InsertBatch([]string{"dave", "erin"}))
+		seen, err := f.TestAndInsert("alice")
+		assert.Nil(t, err)
+		assert.True(t, seen)
+		seen, err = f.TestAndInsert("frank")
+		assert.Nil(t, err)
+		assert.False(t, seen)
+	}
+	entries := []string{"alice", "bob", "carol", "dave", "erin", "frank"}
+	for i := 0; i < 200; i++ {
+		entries = append(entries, fmt.Sprint("absent-", i))
+	}
+	want, err := local.LookupBatch(entries)
+	assert.Nil(t, err)
+	got, err := remote.LookupBatch(entries)
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+	for i, entry := range entries[:10] {
+		found, err := remote.Lookup(entry)
+		assert.Nil(t, err)
+		assert.Equal(t, want[i], found, entry)
+	}
+	for _, f := range []hyperbloom.Querier{local, remote} {
+		found, err := f.LookupBytes([]byte("carol"))
+		assert.Nil(t, err)
+		assert.True(t, found)
+		found, err = f.LookupUint64(42)
+		assert.Nil(t, err)
+		assert.True(t, found)
+		found, err = f.LookupBytes(binary.LittleEndian.AppendUint64(nil, 42))
+		assert.Nil(t, err)
+		assert.True(t, found)
+		found, err = f.LookupAsync("nobody")
+		assert.Nil(t, err)
+		assert.False(t, found)
+	}
+
+	//The remote filter serializes to exactly the local one
+	localData, err := local.MarshalBinary()
+	assert.Nil(t, err)
+	remoteData, err := remote.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, localData, remoteData)
+	var b bytes.Buffer
+	n, err := remote.WriteTo(&b)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(localData)), n)
+	assert.Equal(t, localData, b.Bytes())
+}
+
+/*The accessors, Reset and Equal report what the local filter's would.*/
+func TestClientAccessors(t *testing.T) {
+	conn := startService(t, server.NewStore(""))
+	remote := NewClient(conn, "users")
+	assert.Nil(t, remote.Create(server.CreateRequest{Kind: "striped", Size: 1 << 16, Hashes: 4, Shards: 8}))
+	local, err := hyperbloom.NewStripedBloomFilter(1<<16, 4, 8)
+	assert.Nil(t, err)
+	entries := []string{"alice", "bob", "carol"}
+	assert.Nil(t, local.InsertBatch(entries))
+	assert.Nil(t, remote.InsertBatch(entries))
+
+	spec, err := remote.Spec()
+	assert.Nil(t, err)
+	assert.Equal(t, local.Spec(), spec)
+	hasher, err := remote.Hasher()
+	assert.Nil(t, err)
+	assert.Equal(t, local.Hasher(), hasher)
+	popCount, err := remote.PopCount()
+	assert.Nil(t, err)
+	assert.Equal(t, local.PopCount(), popCount)
+	fillRatio, err := remote.FillRatio()
+	assert.Nil(t, err)
+	assert.Equal(t, local.FillRatio(), fillRatio)
+	count, err := remote.EstimatedCount()
+	assert.Nil(t, err)
+	assert.Equal(t, local.EstimatedCount(), count)
+	fpRate, err := remote.EstimatedFalsePositiveRate()
+	assert.Nil(t, err)
+	assert.Equal(t, local.EstimatedFalsePositiveRate(), fpRate)
+
+	copied := NewClient(conn, "copy")
+	assert.Nil(t, copied.Create(server.CreateRequest{Kind: "striped", Size: 1 << 16, Hashes: 4, Shards: 8}))
+	equal, err := remote.Equal(copied)
+	assert.Nil(t, err)
+	assert.False(t, equal)
+	assert.Nil(t, copied.UnionWith("users"))
+	equal, err = remote.Equal(copied)
+	assert.Nil(t, err)
+	assert.True(t, equal)
+
+	assert.Nil(t, remote.Reset())
+	popCount, err = remote.PopCount()
+	assert.Nil(t, err)
+	assert.Zero(t, popCount)
+	found, err := remote.Lookup("alice")
+	assert.Nil(t, err)
+	assert.False(t, found)
+	spec, err = remote.Spec()
+	assert.Nil(t, err)
+	assert.Equal(t, local.Spec(), spec)
+
+	missing := NewClient(conn, "missing")
+	_, err = missing.Spec()
+	assert.Equal(t, server.ErrNotFound, err)
+	_, err = missing.FillRatio()
+	assert.Equal(t, server.ErrNotFound, err)
+	assert.Equal(t, server.ErrNotFound, missing.Reset())
+	_, err = remote.Equal(missing)
+	assert.Equal(t, server.ErrNotFound, err)
+}
+
+/*Batches too large for one message are streamed.*/
+func TestClientLargeBatches(t *testing.T) {
+	remote := NewClient(startService(t, server.NewStore("")), "big")
+	assert.Nil(t, remote.Create(server.CreateRequest{Kind: "bloom", N: 100000, P: 0.001}))
+	entries := make([]string, 3*maxChunkKeys+10)
+	for i := range entries {
+		entries[i] = fmt.Sprint("entry-", i)
+	}
+	//A few keys large enough to split chunks by size too
+	entries[5] = strings.Repeat("x", maxChunkBytes)
+	entries[6] = strings.Repeat("y", maxChunkBytes/2)
+	assert.Nil(t, remote.InsertBatch(entries[:len(entries)/2]))
+
+	results, err := remote.LookupBatch(entries)
+	assert.Nil(t, err)
+	assert.Len(t, results, len(entries))
+	missed, falsePositives := 0, 0
+	for i, found := range results {
+		if i < len(entries)/2 && !found {
+			missed++
+		} else if i >= len(entries)/2 && found {
+			falsePositives++
+		}
+	}
+	assert.Equal(t, 0, missed)
+	assert.Less(t, falsePositives, 20)
+	stats, err := remote.Stats()
+	assert.Nil(t, err)
+	assert.InDelta(t, len(entries)/2, stats.EstimatedCount, float64(len(entries))/100)
+
+	assert.Equal(t, server.ErrNotFound, NewClient(startService(t, server.NewStore("")), "missing").InsertBatch(entries))
+}
+
+func TestClientSerialization(t *testing.T) {
+	conn := startService(t, server.NewStore(t.TempDir()))
+	a, b := NewClient(conn, "a"), NewClient(conn, "b")
+	for _, c := range []*Client{a, b} {
+		assert.Nil(t, c.Create(server.CreateRequest{Kind: "naivestriped", Size: 1 << 12, Hashes: 3, Shards: 4}))
+		assert.Nil(t, c.Insert(c.Name()))
+	}
+
+	//ReadFrom merges, consuming exactly one filter
+	local, err := hyperbloom.NewBloomFilter(1<<12, 3)
+	assert.Nil(t, err)
+	assert.Nil(t, local.Insert("local"))
+	var stream bytes.Buffer
+	_, err = local.WriteTo(&stream)
+	assert.Nil(t, err)
+	size := stream.Len()
+	stream.WriteString("trailing")
+	n, err := a.ReadFrom(&stream)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(size), n)
+	assert.Equal(t, "trailing", stream.String())
+	_, err = a.ReadFrom(strings.NewReader("HBLF"))
+	assert.NotNil(t, err)
+
+	//Merge on the server, then files
+	assert.Nil(t, a.UnionWith("b"))
+	results, err := a.LookupBatch([]string{"a", "b", "local", "nobody"})
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, true, true, false}, results)
+	path := filepath.Join(t.TempDir(), "a.bloom")
+	assert.Nil(t, a.Write(path))
+	fromFile, err := hyperbloom.NewNaiveStripedBloomFilter(1<<12, 3, 4)
+	assert.Nil(t, err)
+	assert.Nil(t, fromFile.Load(path))
+	found, err := fromFile.Lookup("local")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Nil(t, local.Insert("from file"))
+	assert.Nil(t, local.Write(path))
+	assert.Nil(t, b.Load(path))
+	found, err = b.Lookup("from file")
+	assert.Nil(t, err)
+	assert.True(t, found)
+
+	//UnmarshalBinary replaces the filter, creating it if need be
+	data, err := local.MarshalBinary()
+	assert.Nil(t, err)
+	c := NewClient(conn, "c")
+	assert.Nil(t, c.UnmarshalBinary(data))
+	stats, err := c.Stats()
+	assert.Nil(t, err)
+	assert.Equal(t, "bloom", stats.Kind)
+	assert.Equal(t, uint64(2), stats.EstimatedCount)
+	assert.Nil(t, a.UnmarshalBinary(data))
+	found, err = a.Lookup("a")
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	path, err = a.Snapshot()
+	assert.Nil(t, err)
+	assert.Equal(t, "a.bloom", filepath.Base(path))
+}
+
+func TestClientErrors(t *testing.T) {
+	conn := startService(t, server.NewStore(""))
+	c := NewClient(conn, "users")
+	assert.Nil(t, c.Create(server.CreateRequest{Kind: "bloom", N: 1000}))
+	assert.Equal(t, server.ErrExists, c.Create(server.CreateRequest{Kind: "bloom", N: 1000}))
+	assert.NotNil(t, c.Create(server.CreateRequest{Kind: "bloom"}))
+	assert.ErrorIs(t, NewClient(conn, "big").Create(server.CreateRequest{Kind: "bloom", Size: 1 << 45, Hashes: 3}), server.ErrTooLarge)
+	_, err := c.Snapshot()
+	assert.Equal(t, server.ErrNoDir, err)
+	assert.NotNil(t, c.UnionWith("users-old"))
+
+	missing := NewClient(conn, "missing")
+	assert.Equal(t, server.ErrNotFound, missing.Insert("alice"))
+	_, err = missing.Lookup("alice")
+	assert.Equal(t, server.ErrNotFound, err)
+	_, err = missing.TestAndInsert("alice")
+	assert.Equal(t, server.ErrNotFound, err)
+	_, err = missing.Stats()
+	assert.Equal(t, server.ErrNotFound, err)
+	_, err = missing.MarshalBinary()
+	assert.Equal(t, server.ErrNotFound, err)
+	assert.Equal(t, server.ErrNotFound, missing.Delete())
+	assert.Nil(t, c.Delete())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NotNil(t, NewClient(conn, "users").WithContext(ctx).Insert("alice"))
+}
+
+func TestClientConcurrent(t *testing.T) {
+	conn := startService(t, server.NewStore(""))
+	assert.Nil(t, NewClient(conn, "shared").Create(server.CreateRequest{Kind: "striped", N: 100000}))
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			c := NewClient(conn, "shared")
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("%d-%d", w, i)
+				seen, err := c.TestAndInsert(key)
+				assert.Nil(t, err)
+				assert.False(t, seen, key)
+				found, err := c.Lookup(key)
+				assert.Nil(t, err)
+				assert.True(t, found)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func TestChunks(t *testing.T) {
+	assert.Equal(t, [][][]byte{nil}, chunks(nil))
+	big := make([]byte, maxChunkBytes)
+	assert.Equal(t, [][][]byte{{big}, {big}, {{1}}}, chunks([][]byte{big, big, {1}}))
+	many := make([][]byte, 2*maxChunkKeys+1)
+	parts := chunks(many)
+	assert.Len(t, parts, 3)
+	assert.Len(t, parts[0], maxChunkKeys)
+	assert.Len(t, parts[2], 1)
+}
@@ -0,0 +1,696 @@
+// The gRPC API of hyperbloomd: named filters hosted by a server.Store, which
+// other services insert keys into and look keys up in.
+//
+// Keys are bytes and hash exactly like the same bytes passed to the filters'
+// InsertBytes and LookupBytes, so a string key is its UTF-8 bytes and an
+// integer key (InsertUint64) its 8 byte little endian encoding. Filters travel
+// in the package's binary serialization format.
+//
+// Errors use the standard codes: NOT_FOUND for unknown filters,
+// ALREADY_EXISTS for names already taken, FAILED_PRECONDITION when the server
+// has no snapshot directory, RESOURCE_EXHAUSTED for filters larger than the
+// server allows and INVALID_ARGUMENT otherwise.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: hyperbloom.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	FilterService_CreateFilter_FullMethodName  = "/hyperbloom.v1.FilterService/CreateFilter"
+	FilterService_DeleteFilter_FullMethodName  = "/hyperbloom.v1.FilterService/DeleteFilter"
+	FilterService_GetFilter_FullMethodName     = "/hyperbloom.v1.FilterService/GetFilter"
+	FilterService_ListFilters_FullMethodName   = "/hyperbloom.v1.FilterService/ListFilters"
+	FilterService_Insert_FullMethodName        = "/hyperbloom.v1.FilterService/Insert"
+	FilterService_Lookup_FullMethodName        = "/hyperbloom.v1.FilterService/Lookup"
+	FilterService_TestAndInsert_FullMethodName = "/hyperbloom.v1.FilterService/TestAndInsert"
+	FilterService_BatchInsert_FullMethodName   = "/hyperbloom.v1.FilterService/BatchInsert"
+	FilterService_BatchLookup_FullMethodName   = "/hyperbloom.v1.FilterService/BatchLookup"
+	FilterService_Merge_FullMethodName         = "/hyperbloom.v1.FilterService/Merge"
+	FilterService_Reset_FullMethodName         = "/hyperbloom.v1.FilterService/Reset"
+	FilterService_Export_FullMethodName        = "/hyperbloom.v1.FilterService/Export"
+	FilterService_Replace_FullMethodName       = "/hyperbloom.v1.FilterService/Replace"
+	FilterService_Snapshot_FullMethodName      = "/hyperbloom.v1.FilterService/Snapshot"
+	FilterService_Load_FullMethodName          = "/hyperbloom.v1.FilterService/Load"
+)
+
+// FilterServiceClient is the client API for FilterService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FilterServiceClient interface {
+	// Creates an empty filter.
+	CreateFilter(ctx context.Context, in *CreateFilterRequest, opts ...grpc.CallOption) (*FilterInfo, error)
+	// Removes a filter (but not its snapshot).
+	DeleteFilter(ctx context.Context, in *DeleteFilterRequest, opts ...grpc.CallOption) (*DeleteFilterResponse, error)
+	// Describes a filter.
+	GetFilter(ctx context.Context, in *GetFilterRequest, opts ...grpc.CallOption) (*FilterInfo, error)
+	// Lists the names of every filter, sorted.
+	ListFilters(ctx context.Context, in *ListFiltersRequest, opts ...grpc.CallOption) (*ListFiltersResponse, error)
+	// Inserts keys into a filter as one batch.
+	Insert(ctx context.Context, in *InsertRequest, opts ...grpc.CallOption) (*InsertResponse, error)
+	// Looks keys up in a filter as one batch.
+	Lookup(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (*LookupResponse, error)
+	// Inserts keys one at a time, reporting whether each may already have
+	// been present.
+	TestAndInsert(ctx context.Context, in *TestAndInsertRequest, opts ...grpc.CallOption) (*TestAndInsertResponse, error)
+	// Inserts the keys of every request in the stream, each as one batch, and
+	// returns the number of keys inserted once the client closes the stream.
+	// It stops at the first error.
+	BatchInsert(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[InsertRequest, BatchInsertResponse], error)
+	// Answers each request in the stream with a LookupResponse, in order. It
+	// stops at the first error.
+	BatchLookup(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[LookupRequest, LookupResponse], error)
+	// Merges another filter into a filter, so it holds the entries of both.
+	// The filters must have the same size, hash count and hasher.
+	Merge(ctx context.Context, in *MergeRequest, opts ...grpc.CallOption) (*FilterInfo, error)
+	// Empties a filter, keeping its parameters.
+	Reset(ctx context.Context, in *ResetRequest, opts ...grpc.CallOption) (*FilterInfo, error)
+	// Returns a filter serialized.
+	Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (*ExportResponse, error)
+	// Replaces a filter with a serialized one (or adds it), which keeps its
+	// variant, parameters and hasher.
+	Replace(ctx context.Context, in *ReplaceRequest, opts ...grpc.CallOption) (*FilterInfo, error)
+	// Saves a filter to its file in the server's snapshot directory.
+	Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*SnapshotResponse, error)
+	// Replaces a filter with its saved snapshot (or adds it).
+	Load(ctx context.Context, in *LoadRequest, opts ...grpc.CallOption) (*FilterInfo, error)
+}
+
+type filterServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFilterServiceClient(cc grpc.ClientConnInterface) FilterServiceClient {
+	return &filterServiceClient{cc}
+}
+
+func (c *filterServiceClient) CreateFilter(ctx context.Context, in *CreateFilterRequest, opts ...grpc.CallOption) (*FilterInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FilterInfo)
+	err := c.cc.Invoke(ctx, FilterService_CreateFilter_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) DeleteFilter(ctx context.Context, in *DeleteFilterRequest, opts ...grpc.CallOption) (*DeleteFilterResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteFilterResponse)
+	err := c.cc.Invoke(ctx, FilterService_DeleteFilter_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) GetFilter(ctx context.Context, in *GetFilterRequest, opts ...grpc.CallOption) (*FilterInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FilterInfo)
+	err := c.cc.Invoke(ctx, FilterService_GetFilter_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) ListFilters(ctx context.Context, in *ListFiltersRequest, opts ...grpc.CallOption) (*ListFiltersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListFiltersResponse)
+	err := c.cc.Invoke(ctx, FilterService_ListFilters_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) Insert(ctx context.Context, in *InsertRequest, opts ...grpc.CallOption) (*InsertResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InsertResponse)
+	err := c.cc.Invoke(ctx, FilterService_Insert_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) Lookup(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (*LookupResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LookupResponse)
+	err := c.cc.Invoke(ctx, FilterService_Lookup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) TestAndInsert(ctx context.Context, in *TestAndInsertRequest, opts ...grpc.CallOption) (*TestAndInsertResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TestAndInsertResponse)
+	err := c.cc.Invoke(ctx, FilterService_TestAndInsert_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) BatchInsert(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[InsertRequest, BatchInsertResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FilterService_ServiceDesc.Streams[0], FilterService_BatchInsert_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[InsertRequest, BatchInsertResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FilterService_BatchInsertClient = grpc.ClientStreamingClient[InsertRequest, BatchInsertResponse]
+
+func (c *filterServiceClient) BatchLookup(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[LookupRequest, LookupResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FilterService_ServiceDesc.Streams[1], FilterService_BatchLookup_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[LookupRequest, LookupResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FilterService_BatchLookupClient = grpc.BidiStreamingClient[LookupRequest, LookupResponse]
+
+func (c *filterServiceClient) Merge(ctx context.Context, in *MergeRequest, opts ...grpc.CallOption) (*FilterInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FilterInfo)
+	err := c.cc.Invoke(ctx, FilterService_Merge_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) Reset(ctx context.Context, in *ResetRequest, opts ...grpc.CallOption) (*FilterInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FilterInfo)
+	err := c.cc.Invoke(ctx, FilterService_Reset_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (*ExportResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExportResponse)
+	err := c.cc.Invoke(ctx, FilterService_Export_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) Replace(ctx context.Context, in *ReplaceRequest, opts ...grpc.CallOption) (*FilterInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FilterInfo)
+	err := c.cc.Invoke(ctx, FilterService_Replace_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*SnapshotResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SnapshotResponse)
+	err := c.cc.Invoke(ctx, FilterService_Snapshot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filterServiceClient) Load(ctx context.Context, in *LoadRequest, opts ...grpc.CallOption) (*FilterInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FilterInfo)
+	err := c.cc.Invoke(ctx, FilterService_Load_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FilterServiceServer is the server API for FilterService service.
+// All implementations must embed UnimplementedFilterServiceServer
+// for forward compatibility.
+type FilterServiceServer interface {
+	// Creates an empty filter.
+	CreateFilter(context.Context, *CreateFilterRequest) (*FilterInfo, error)
+	// Removes a filter (but not its snapshot).
+	DeleteFilter(context.Context, *DeleteFilterRequest) (*DeleteFilterResponse, error)
+	// Describes a filter.
+	GetFilter(context.Context, *GetFilterRequest) (*FilterInfo, error)
+	// Lists the names of every filter, sorted.
+	ListFilters(context.Context, *ListFiltersRequest) (*ListFiltersResponse, error)
+	// Inserts keys into a filter as one batch.
+	Insert(context.Context, *InsertRequest) (*InsertResponse, error)
+	// Looks keys up in a filter as one batch.
+	Lookup(context.Context, *LookupRequest) (*LookupResponse, error)
+	// Inserts keys one at a time, reporting whether each may already have
+	// been present.
+	TestAndInsert(context.Context, *TestAndInsertRequest) (*TestAndInsertResponse, error)
+	// Inserts the keys of every request in the stream, each as one batch, and
+	// returns the number of keys inserted once the client closes the stream.
+	// It stops at the first error.
+	BatchInsert(grpc.ClientStreamingServer[InsertRequest, BatchInsertResponse]) error
+	// Answers each request in the stream with a LookupResponse, in order. It
+	// stops at the first error.
+	BatchLookup(grpc.BidiStreamingServer[LookupRequest, LookupResponse]) error
+	// Merges another filter into a filter, so it holds the entries of both.
+	// The filters must have the same size, hash count and hasher.
+	Merge(context.Context, *MergeRequest) (*FilterInfo, error)
+	// Empties a filter, keeping its parameters.
+	Reset(context.Context, *ResetRequest) (*FilterInfo, error)
+	// Returns a filter serialized.
+	Export(context.Context, *ExportRequest) (*ExportResponse, error)
+	// Replaces a filter with a serialized one (or adds it), which keeps its
+	// variant, parameters and hasher.
+	Replace(context.Context, *ReplaceRequest) (*FilterInfo, error)
+	// Saves a filter to its file in the server's snapshot directory.
+	Snapshot(context.Context, *SnapshotRequest) (*SnapshotResponse, error)
+	// Replaces a filter with its saved snapshot (or adds it).
+	Load(context.Context, *LoadRequest) (*FilterInfo, error)
+	mustEmbedUnimplementedFilterServiceServer()
+}
+
+// UnimplementedFilterServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedFilterServiceServer struct{}
+
+func (UnimplementedFilterServiceServer) CreateFilter(context.Context, *CreateFilterRequest) (*FilterInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateFilter not implemented")
+}
+func (UnimplementedFilterServiceServer) DeleteFilter(context.Context, *DeleteFilterRequest) (*DeleteFilterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteFilter not implemented")
+}
+func (UnimplementedFilterServiceServer) GetFilter(context.Context, *GetFilterRequest) (*FilterInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFilter not implemented")
+}
+func (UnimplementedFilterServiceServer) ListFilters(context.Context, *ListFiltersRequest) (*ListFiltersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFilters not implemented")
+}
+func (UnimplementedFilterServiceServer) Insert(context.Context, *InsertRequest) (*InsertResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Insert not implemented")
+}
+func (UnimplementedFilterServiceServer) Lookup(context.Context, *LookupRequest) (*LookupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Lookup not implemented")
+}
+func (UnimplementedFilterServiceServer) TestAndInsert(context.Context, *TestAndInsertRequest) (*TestAndInsertResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TestAndInsert not implemented")
+}
+func (UnimplementedFilterServiceServer) BatchInsert(grpc.ClientStreamingServer[InsertRequest, BatchInsertResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method BatchInsert not implemented")
+}
+func (UnimplementedFilterServiceServer) BatchLookup(grpc.BidiStreamingServer[LookupRequest, LookupResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method BatchLookup not implemented")
+}
+func (UnimplementedFilterServiceServer) Merge(context.Context, *MergeRequest) (*FilterInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Merge not implemented")
+}
+func (UnimplementedFilterServiceServer) Reset(context.Context, *ResetRequest) (*FilterInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reset not implemented")
+}
+func (UnimplementedFilterServiceServer) Export(context.Context, *ExportRequest) (*ExportResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Export not implemented")
+}
+func (UnimplementedFilterServiceServer) Replace(context.Context, *ReplaceRequest) (*FilterInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Replace not implemented")
+}
+func (UnimplementedFilterServiceServer) Snapshot(context.Context, *SnapshotRequest) (*SnapshotResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Snapshot not implemented")
+}
+func (UnimplementedFilterServiceServer) Load(context.Context, *LoadRequest) (*FilterInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Load not implemented")
+}
+func (UnimplementedFilterServiceServer) mustEmbedUnimplementedFilterServiceServer() {}
+func (UnimplementedFilterServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeFilterServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FilterServiceServer will
+// result in compilation errors.
+type UnsafeFilterServiceServer interface {
+	mustEmbedUnimplementedFilterServiceServer()
+}
+
+func RegisterFilterServiceServer(s grpc.ServiceRegistrar, srv FilterServiceServer) {
+	// If the following call pancis, it indicates UnimplementedFilterServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&FilterService_ServiceDesc, srv)
+}
+
+func _FilterService_CreateFilter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateFilterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).CreateFilter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FilterService_CreateFilter_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).CreateFilter(ctx, req.(*CreateFilterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_DeleteFilter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteFilterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).DeleteFilter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FilterService_DeleteFilter_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).DeleteFilter(ctx, req.(*DeleteFilterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_GetFilter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFilterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).GetFilter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FilterService_GetFilter_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).GetFilter(ctx, req.(*GetFilterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_ListFilters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFiltersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).ListFilters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FilterService_ListFilters_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).ListFilters(ctx, req.(*ListFiltersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_Insert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InsertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).Insert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FilterService_Insert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).Insert(ctx, req.(*InsertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_Lookup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).Lookup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FilterService_Lookup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).Lookup(ctx, req.(*LookupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_TestAndInsert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TestAndInsertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).TestAndInsert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FilterService_TestAndInsert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).TestAndInsert(ctx, req.(*TestAndInsertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_BatchInsert_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FilterServiceServer).BatchInsert(&grpc.GenericServerStream[InsertRequest, BatchInsertResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FilterService_BatchInsertServer = grpc.ClientStreamingServer[InsertRequest, BatchInsertResponse]
+
+func _FilterService_BatchLookup_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FilterServiceServer).BatchLookup(&grpc.GenericServerStream[LookupRequest, LookupResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FilterService_BatchLookupServer = grpc.BidiStreamingServer[LookupRequest, LookupResponse]
+
+func _FilterService_Merge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MergeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).Merge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FilterService_Merge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).Merge(ctx, req.(*MergeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_Reset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).Reset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FilterService_Reset_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).Reset(ctx, req.(*ResetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_Export_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).Export(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FilterService_Export_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).Export(ctx, req.(*ExportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_Replace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReplaceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).Replace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FilterService_Replace_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).Replace(ctx, req.(*ReplaceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_Snapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).Snapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FilterService_Snapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).Snapshot(ctx, req.(*SnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FilterService_Load_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilterServiceServer).Load(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FilterService_Load_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilterServiceServer).Load(ctx, req.(*LoadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// FilterService_ServiceDesc is the grpc.ServiceDesc for FilterService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FilterService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "hyperbloom.v1.FilterService",
+	HandlerType: (*FilterServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateFilter",
+			Handler:    _FilterService_CreateFilter_Handler,
+		},
+		{
+			MethodName: "DeleteFilter",
+			Handler:    _FilterService_DeleteFilter_Handler,
+		},
+		{
+			MethodName: "GetFilter",
+			Handler:    _FilterService_GetFilter_Handler,
+		},
+		{
+			MethodName: "ListFilters",
+			Handler:    _FilterService_ListFilters_Handler,
+		},
+		{
+			MethodName: "Insert",
+			Handler:    _FilterService_Insert_Handler,
+		},
+		{
+			MethodName: "Lookup",
+			Handler:    _FilterService_Lookup_Handler,
+		},
+		{
+			MethodName: "TestAndInsert",
+			Handler:    _FilterService_TestAndInsert_Handler,
+		},
+		{
+			MethodName: "Merge",
+			Handler:    _FilterService_Merge_Handler,
+		},
+		{
+			MethodName: "Reset",
+			Handler:    _FilterService_Reset_Handler,
+		},
+		{
+			MethodName: "Export",
+			Handler:    _FilterService_Export_Handler,
+		},
+		{
+			MethodName: "Replace",
+			Handler:    _FilterService_Replace_Handler,
+		},
+		{
+			MethodName: "Snapshot",
+			Handler:    _FilterService_Snapshot_Handler,
+		},
+		{
+			MethodName: "Load",
+			Handler:    _FilterService_Load_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BatchInsert",
+			Handler:       _FilterService_BatchInsert_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "BatchLookup",
+			Handler:       _FilterService_BatchLookup_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "hyperbloom.proto",
+}
@@ -0,0 +1,214 @@
+/*
+Package rpc serves a server.Store over gRPC, with the API defined in hyperbloom.proto, and provides Client, a hosted filter with the methods of the package's filters so that it can stand in for a local one.
+hyperbloom.pb.go and hyperbloom_grpc.pb.go are generated from hyperbloom.proto by protoc-gen-go and protoc-gen-go-grpc; run go generate after changing it.
+*/
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative hyperbloom.proto
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/iamthebot/hyperbloom/server"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*Service implements FilterServiceServer on top of a Store. Register it on a grpc.Server with RegisterFilterServiceServer.*/
+type Service struct {
+	UnimplementedFilterServiceServer
+	store *server.Store
+}
+
+/*NewService returns a Service serving the filters of s.*/
+func NewService(s *server.Store) *Service {
+	return &Service{store: s}
+}
+
+/*Returns err as a gRPC status with the code matching the store's errors (see hyperbloom.proto).*/
+func toStatus(err error) error {
+	code := codes.InvalidArgument
+	switch {
+	case errors.Is(err, server.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, server.ErrExists):
+		code = codes.AlreadyExists
+	case errors.Is(err, server.ErrNoDir):
+		code = codes.FailedPrecondition
+	case errors.Is(err, server.ErrTooLarge):
+		code = codes.ResourceExhausted
+	}
+	return status.Error(code, err.Error())
+}
+
+/*Keys are inserted as strings, which hash exactly like their bytes.*/
+func stringKeys(keys [][]byte) []string {
+	strs := make([]string, len(keys))
+	for i, key := range keys {
+		strs[i] = string(key)
+	}
+	return strs
+}
+
+func (s *Service) info(name string) (*FilterInfo, error) {
+	stats, err := s.store.Stats(name)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	spec, err := s.store.Spec(name)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &FilterInfo{
+		Name:                       stats.Name,
+		Kind:                       stats.Kind,
+		Size:                       stats.Size,
+		Hashes:                     int32(stats.Hashes),
+		Shards:                     stats.Shards,
+		Hasher:                     stats.Hasher,
+		FillRatio:                  stats.FillRatio,
+		EstimatedCount:             stats.EstimatedCount,
+		EstimatedFalsePositiveRate: stats.EstimatedFalsePositiveRate,
+		HasherId:                   uint32(spec.Hasher.ID()),
+		HasherSeed:                 spec.Hasher.Seed(),
+		PopCount:                   stats.PopCount,
+	}, nil
+}
+
+func (s *Service) CreateFilter(ctx context.Context, req *CreateFilterRequest) (*FilterInfo, error) {
+	err := s.store.CreateFrom(req.Name, server.CreateRequest{Kind: req.Kind, N: req.N, P: req.P, Size: req.Size, Hashes: int(req.Hashes), Shards: req.Shards})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return s.info(req.Name)
+}
+
+func (s *Service) DeleteFilter(ctx context.Context, req *DeleteFilterRequest) (*DeleteFilterResponse, error) {
+	if err := s.store.Delete(req.Name); err != nil {
+		return nil, toStatus(err)
+	}
+	return &DeleteFilterResponse{}, nil
+}
+
+func (s *Service) GetFilter(ctx context.Context, req *GetFilterRequest) (*FilterInfo, error) {
+	return s.info(req.Name)
+}
+
+func (s *Service) ListFilters(ctx context.Context, req *ListFiltersRequest) (*ListFiltersResponse, error) {
+	return &ListFiltersResponse{Names: s.store.Names()}, nil
+}
+
+func (s *Service) Insert(ctx context.Context, req *InsertRequest) (*InsertResponse, error) {
+	if err := s.store.Insert(req.Name, stringKeys(req.Keys)...); err != nil {
+		return nil, toStatus(err)
+	}
+	return &InsertResponse{}, nil
+}
+
+func (s *Service) Lookup(ctx context.Context, req *LookupRequest) (*LookupResponse, error) {
+	results, err := s.store.Lookup(req.Name, stringKeys(req.Keys)...)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &LookupResponse{Present: results}, nil
+}
+
+func (s *Service) TestAndInsert(ctx context.Context, req *TestAndInsertRequest) (*TestAndInsertResponse, error) {
+	results, err := s.store.TestAndInsert(req.Name, stringKeys(req.Keys)...)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &TestAndInsertResponse{Present: results}, nil
+}
+
+func (s *Service) BatchInsert(stream FilterService_BatchInsertServer) error {
+	var inserted uint64
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&BatchInsertResponse{Inserted: inserted})
+		} else if err != nil {
+			return err
+		}
+		if err := s.store.Insert(req.Name, stringKeys(req.Keys)...); err != nil {
+			return toStatus(err)
+		}
+		inserted += uint64(len(req.Keys))
+	}
+}
+
+func (s *Service) BatchLookup(stream FilterService_BatchLookupServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		results, err := s.store.Lookup(req.Name, stringKeys(req.Keys)...)
+		if err != nil {
+			return toStatus(err)
+		}
+		if err := stream.Send(&LookupResponse{Present: results}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Service) Merge(ctx context.Context, req *MergeRequest) (*FilterInfo, error) {
+	var data []byte
+	switch source := req.Source.(type) {
+	case *MergeRequest_Filter:
+		var err error
+		if data, err = s.store.Export(source.Filter); err != nil {
+			return nil, toStatus(err)
+		}
+	case *MergeRequest_Data:
+		data = source.Data
+	default:
+		return nil, status.Error(codes.InvalidArgument, "Set the filter or the data to merge")
+	}
+	if err := s.store.Merge(req.Name, data); err != nil {
+		return nil, toStatus(err)
+	}
+	return s.info(req.Name)
+}
+
+func (s *Service) Reset(ctx context.Context, req *ResetRequest) (*FilterInfo, error) {
+	if err := s.store.Reset(req.Name); err != nil {
+		return nil, toStatus(err)
+	}
+	return s.info(req.Name)
+}
+
+func (s *Service) Export(ctx context.Context, req *ExportRequest) (*ExportResponse, error) {
+	data, err := s.store.Export(req.Name)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &ExportResponse{Data: data}, nil
+}
+
+func (s *Service) Replace(ctx context.Context, req *ReplaceRequest) (*FilterInfo, error) {
+	if err := s.store.Replace(req.Name, req.Data); err != nil {
+		return nil, toStatus(err)
+	}
+	return s.info(req.Name)
+}
+
+func (s *Service) Snapshot(ctx context.Context, req *SnapshotRequest) (*SnapshotResponse, error) {
+	path, err := s.store.Snapshot(req.Name)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &SnapshotResponse{Path: path}, nil
+}
+
+func (s *Service) Load(ctx context.Context, req *LoadRequest) (*FilterInfo, error) {
+	if err := s.store.Load(req.Name); err != nil {
+		return nil, toStatus(err)
+	}
+	return s.info(req.Name)
+}
@@ -0,0 +1,264 @@
+package rpc
+
+import (
+	"context"
+	"github.com/iamthebot/hyperbloom"
+	"github.com/iamthebot/hyperbloom/server"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"testing"
+)
+
+/*Serves s over an in-memory connection and returns a connection to it. Both are closed when the test ends.*/
+func startService(t *testing.T, s *server.Store) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	RegisterFilterServiceServer(gs, NewService(s))
+	go gs.Serve(lis)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+		gs.Stop()
+	})
+	return conn
+}
+
+func keys(strs ...string) [][]byte {
+	return bytesKeys(strs)
+}
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	c := NewFilterServiceClient(startService(t, server.NewStore(t.TempDir())))
+
+	info, err := c.CreateFilter(ctx, &CreateFilterRequest{Name: "users", Kind: "striped", Size: 1 << 16, Hashes: 4, Shards: 16})
+	assert.Nil(t, err)
+	assert.Equal(t, "striped", info.Kind)
+	assert.Equal(t, uint64(1<<16), info.Size)
+	assert.Equal(t, int32(4), info.Hashes)
+	assert.Equal(t, uint64(16), info.Shards)
+	assert.Equal(t, "xxhash64(seed=0)", info.Hasher)
+	_, err = c.CreateFilter(ctx, &CreateFilterRequest{Name: "sessions", Kind: "naive", N: 1000, P: 0.001})
+	assert.Nil(t, err)
+	names, err := c.ListFilters(ctx, &ListFiltersRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"sessions", "users"}, names.Names)
+
+	_, err = c.Insert(ctx, &InsertRequest{Name: "users", Keys: keys("alice", "bob")})
+	assert.Nil(t, err)
+	lookup, err := c.Lookup(ctx, &LookupRequest{Name: "users", Keys: keys("alice", "bob", "carol")})
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, true, false}, lookup.Present)
+	tai, err := c.TestAndInsert(ctx, &TestAndInsertRequest{Name: "users", Keys: keys("carol", "alice", "carol")})
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{false, true, true}, tai.Present)
+	info, err = c.GetFilter(ctx, &GetFilterRequest{Name: "users"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), info.EstimatedCount)
+
+	//Snapshot, change, then load the snapshot back
+	snap, err := c.Snapshot(ctx, &SnapshotRequest{Name: "users"})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, snap.Path)
+	_, err = c.Insert(ctx, &InsertRequest{Name: "users", Keys: keys("dave")})
+	assert.Nil(t, err)
+	info, err = c.Load(ctx, &LoadRequest{Name: "users"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), info.EstimatedCount)
+
+	_, err = c.DeleteFilter(ctx, &DeleteFilterRequest{Name: "sessions"})
+	assert.Nil(t, err)
+	names, err = c.ListFilters(ctx, &ListFiltersRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"users"}, names.Names)
+}
+
+func TestServiceStreams(t *testing.T) {
+	ctx := context.Background()
+	s := server.NewStore("")
+	assert.Nil(t, s.CreateWithEstimates("users", hyperbloom.KindStriped, 10000, 0.001))
+	assert.Nil(t, s.CreateWithEstimates("groups", hyperbloom.KindBloom, 10000, 0.001))
+	c := NewFilterServiceClient(startService(t, s))
+
+	insert, err := c.BatchInsert(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, insert.Send(&InsertRequest{Name: "users", Keys: keys("alice", "bob")}))
+	assert.Nil(t, insert.Send(&InsertRequest{Name: "groups", Keys: keys("admins")}))
+	assert.Nil(t, insert.Send(&InsertRequest{Name: "users", Keys: keys("carol")}))
+	resp, err := insert.CloseAndRecv()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(4), resp.Inserted)
+
+	lookup, err := c.BatchLookup(ctx)
+	assert.Nil(t, err)
+	for _, req := range []*LookupRequest{
+		{Name: "users", Keys: keys("alice", "admins", "carol")},
+		{Name: "groups", Keys: keys("admins", "alice")},
+		{Name: "users"},
+	} {
+		assert.Nil(t, lookup.Send(req))
+	}
+	assert.Nil(t, lookup.CloseSend())
+	for _, want := range [][]bool{{true, false, true}, {true, false}, nil} {
+		resp, err := lookup.Recv()
+		assert.Nil(t, err)
+		assert.Equal(t, want, resp.Present)
+	}
+	_, err = lookup.Recv()
+	assert.Equal(t, io.EOF, err)
+
+	//Streams stop at the first error
+	insert, err = c.BatchInsert(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, insert.Send(&InsertRequest{Name: "missing", Keys: keys("alice")}))
+	_, err = insert.CloseAndRecv()
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	lookup, err = c.BatchLookup(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, lookup.Send(&LookupRequest{Name: "missing", Keys: keys("alice")}))
+	_, err = lookup.Recv()
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServiceMergeExportReplace(t *testing.T) {
+	ctx := context.Background()
+	s := server.NewStore("")
+	for name, spec := range map[string]hyperbloom.Spec{
+		"a": {Kind: hyperbloom.KindBloom, Size: 1 << 16, Hashes: 4},
+		"b": {Kind: hyperbloom.KindStriped, Size: 1 << 16, Hashes: 4, Shards: 4},
+		"c": {Kind: hyperbloom.KindNaive, Size: 1 << 16, Hashes: 4},
+	} {
+		assert.Nil(t, s.Create(name, spec))
+		assert.Nil(t, s.Insert(name, name))
+	}
+	c := NewFilterServiceClient(startService(t, s))
+
+	//Merge another hosted filter, then serialized data
+	_, err := c.Merge(ctx, &MergeRequest{Name: "a", Source: &MergeRequest_Filter{Filter: "b"}})
+	assert.Nil(t, err)
+	local, err := hyperbloom.NewBloomFilter(1<<16, 4)
+	assert.Nil(t, err)
+	assert.Nil(t, local.Insert("local"))
+	data, err := local.MarshalBinary()
+	assert.Nil(t, err)
+	info, err := c.Merge(ctx, &MergeRequest{Name: "a", Source: &MergeRequest_Data{Data: data}})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), info.EstimatedCount)
+	lookup, err := c.Lookup(ctx, &LookupRequest{Name: "a", Keys: keys("a", "b", "c", "local")})
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, true, false, true}, lookup.Present)
+
+	//Export the merged filter and replace another with it, which takes its kind
+	exported, err := c.Export(ctx, &ExportRequest{Name: "a"})
+	assert.Nil(t, err)
+	info, err = c.Replace(ctx, &ReplaceRequest{Name: "c", Data: exported.Data})
+	assert.Nil(t, err)
+	assert.Equal(t, "bloom", info.Kind)
+	lookup, err = c.Lookup(ctx, &LookupRequest{Name: "c", Keys: keys("a", "b", "c", "local")})
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, true, false, true}, lookup.Present)
+	_, err = c.Replace(ctx, &ReplaceRequest{Name: "d", Data: exported.Data})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, s.Names())
+}
+
+func TestServiceErrors(t *testing.T) {
+	ctx := context.Background()
+	s := server.NewStore("")
+	assert.Nil(t, s.CreateWithEstimates("users", hyperbloom.KindBloom, 1000, 0.01))
+	assert.Nil(t, s.CreateWithEstimates("other", hyperbloom.KindBloom, 100000, 0.01))
+	c := NewFilterServiceClient(startService(t, s))
+	cbf, err := hyperbloom.NewCountingBloomFilter(1<<16, 4)
+	assert.Nil(t, err)
+	counting, err := cbf.MarshalBinary()
+	assert.Nil(t, err)
+
+	for _, e := range []struct {
+		call func() error
+		code codes.Code
+	}{
+		{func() error {
+			_, err := c.CreateFilter(ctx, &CreateFilterRequest{Name: "users", Kind: "bloom", N: 1000})
+			return err
+		}, codes.AlreadyExists},
+		{func() error {
+			_, err := c.CreateFilter(ctx, &CreateFilterRequest{Name: "new", Kind: "cuckoo", N: 1000})
+			return err
+		}, codes.InvalidArgument},
+		{func() error {
+			_, err := c.CreateFilter(ctx, &CreateFilterRequest{Name: "new", Kind: "bloom"})
+			return err
+		}, codes.InvalidArgument},
+		{func() error {
+			_, err := c.CreateFilter(ctx, &CreateFilterRequest{Name: "new", Kind: "bloom", Size: 1024})
+			return err
+		}, codes.InvalidArgument},
+		{func() error {
+			_, err := c.CreateFilter(ctx, &CreateFilterRequest{Name: "../new", Kind: "bloom", N: 10})
+			return err
+		}, codes.InvalidArgument},
+		{func() error { _, err := c.DeleteFilter(ctx, &DeleteFilterRequest{Name: "missing"}); return err }, codes.NotFound},
+		{func() error { _, err := c.GetFilter(ctx, &GetFilterRequest{Name: "missing"}); return err }, codes.NotFound},
+		{func() error { _, err := c.Insert(ctx, &InsertRequest{Name: "missing"}); return err }, codes.NotFound},
+		{func() error { _, err := c.Lookup(ctx, &LookupRequest{Name: "missing"}); return err }, codes.NotFound},
+		{func() error { _, err := c.TestAndInsert(ctx, &TestAndInsertRequest{Name: "missing"}); return err }, codes.NotFound},
+		{func() error { _, err := c.Merge(ctx, &MergeRequest{Name: "users"}); return err }, codes.InvalidArgument},
+		{func() error {
+			_, err := c.Merge(ctx, &MergeRequest{Name: "users", Source: &MergeRequest_Filter{Filter: "missing"}})
+			return err
+		}, codes.NotFound},
+		{func() error {
+			_, err := c.Merge(ctx, &MergeRequest{Name: "users", Source: &MergeRequest_Filter{Filter: "other"}})
+			return err
+		}, codes.InvalidArgument},
+		{func() error {
+			_, err := c.Merge(ctx, &MergeRequest{Name: "users", Source: &MergeRequest_Data{Data: []byte("junk")}})
+			return err
+		}, codes.InvalidArgument},
+		{func() error { _, err := c.Export(ctx, &ExportRequest{Name: "missing"}); return err }, codes.NotFound},
+		{func() error { _, err := c.Replace(ctx, &ReplaceRequest{Name: "users", Data: counting}); return err }, codes.InvalidArgument},
+		{func() error { _, err := c.Replace(ctx, &ReplaceRequest{Name: ".hidden", Data: counting}); return err }, codes.InvalidArgument},
+		{func() error { _, err := c.Snapshot(ctx, &SnapshotRequest{Name: "users"}); return err }, codes.FailedPrecondition},
+		{func() error { _, err := c.Load(ctx, &LoadRequest{Name: "users"}); return err }, codes.FailedPrecondition},
+	} {
+		assert.Equal(t, e.code, status.Code(e.call()))
+	}
+}
+
+/*Filters past the store's MaxFilterBytes are refused before the server allocates them.*/
+func TestServiceFilterTooLarge(t *testing.T) {
+	ctx := context.Background()
+	s := server.NewStore("")
+	c := NewFilterServiceClient(startService(t, s))
+	for _, req := range []*CreateFilterRequest{
+		{Name: "big", Kind: "bloom", Size: 35184372088832, Hashes: 3},
+		{Name: "big", Kind: "striped", N: 100000000000000000, P: 0.01},
+		{Name: "big", Kind: "naivestriped", Size: 1 << 20, Hashes: 3, Shards: 1 << 40},
+	} {
+		_, err := c.CreateFilter(ctx, req)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err), "%v", req)
+	}
+	assert.Empty(t, s.Names())
+
+	s.MaxFilterBytes = 1 << 10
+	_, err := c.CreateFilter(ctx, &CreateFilterRequest{Name: "small", Kind: "bloom", N: 1000})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	_, err = c.CreateFilter(ctx, &CreateFilterRequest{Name: "small", Kind: "bloom", N: 100})
+	assert.Nil(t, err)
+
+	//Serialized filters are held to the same limit
+	exported, err := c.Export(ctx, &ExportRequest{Name: "small"})
+	assert.Nil(t, err)
+	s.MaxFilterBytes = 64
+	_, err = c.Replace(ctx, &ReplaceRequest{Name: "copy", Data: exported.Data})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
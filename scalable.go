@@ -0,0 +1,536 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+The payload of a serialized ScalableBloomFilter starts with its parameters, all little endian:
+
+	offset size field
+	0      8    target false positive rate (float64 bits)
+	8      8    tightening ratio (float64 bits)
+	16     8    growth factor
+	24     8    capacity of the first layer
+	32     8    number of layers
+
+followed, for each layer in order, by the number of entries inserted into it (8 bytes) and the layer itself, serialized with WriteTo.
+*/
+const scalableParamsLen = 40
+
+/*The shortest encoding of a layer: its count, header, the payload of the smallest filter (64 bits) and the checksum.*/
+const minScalableLayerLen = 8 + headerLen + 8 + 4
+
+/*Bounds on the growth factor and tightening ratio. Beyond them layers grow or tighten so fast that the stack is no use past a few layers.*/
+const (
+	scalableMaxGrowth     = 1 << 10
+	scalableMinTightening = 0.01
+)
+
+/*The methods a ScalableBloomFilter needs from its layers, so that each entry is hashed once for the whole stack.*/
+type layerFilter interface {
+	Filter
+	lookupHashes(h1, h2 uint64) (bool, error)
+	lookupHashesAsync(h1, h2 uint64) (bool, error)
+	insertHashes(h1, h2 uint64) error
+	insertHashesAsync(h1, h2 uint64) error
+}
+
+var (
+	_ layerFilter = (*BloomFilter)(nil)
+	_ layerFilter = (*StripedBloomFilter)(nil)
+)
+
+type scalableLayer struct {
+	filter   layerFilter
+	size     uint64
+	hf       int
+	capacity uint64        //Number of entries the layer was sized for
+	fpRate   float64       //False positive rate expected at capacity
+	count    atomic.Uint64 //Number of entries inserted into the layer
+}
+
+/*
+ScalableBloomFilter is a bloomfilter that grows as entries are added, so it needn't be sized up front (Almeida et al., "Scalable Bloom Filters").
+It is a stack of BloomFilter (or StripedBloomFilter) layers. Entries are inserted into the newest layer only, and once that layer holds as many entries as it was sized for a new one is added, with its capacity multiplied by the growth factor and its false positive rate by the tightening ratio. As the rates form a geometric series, the false positive rate of the whole stack stays below the target however far it grows.
+Lookups check every layer. The layer list is guarded by a RWMutex, which inserts and lookups only take for reading, so they can run concurrently with each other (the layers do their own locking).
+*/
+type ScalableBloomFilter struct {
+	layers     []*scalableLayer //Oldest first
+	kind       Kind             //KindScalable or KindStripedScalable
+	capacity   uint64           //Number of entries the first layer is sized for
+	fpRate     float64          //Target false positive rate of the whole stack
+	growth     uint64           //Capacity ratio of consecutive layers
+	tightening float64          //False positive rate ratio of consecutive layers
+	hasher     Hasher           //Produces the base hashes for each entry
+	mut        *sync.RWMutex    //Guards layers
+}
+
+/*
+NewScalableBloomFilter allocates a ScalableBloomFilter of BloomFilter layers, the first sized for n entries. The false positive rate of the whole filter stays below p as it grows.
+The growth factor and tightening ratio can be changed with WithGrowth and WithTightening.
+*/
+func NewScalableBloomFilter(n uint64, p float64, opts ...Option) (*ScalableBloomFilter, error) {
+	return newScalableBloomFilter(KindScalable, n, p, opts...)
+}
+
+/*
+NewStripedScalableBloomFilter is NewScalableBloomFilter with StripedBloomFilter layers, each with a shard count picked from GOMAXPROCS.
+*/
+func NewStripedScalableBloomFilter(n uint64, p float64, opts ...Option) (*ScalableBloomFilter, error) {
+	return newScalableBloomFilter(KindStripedScalable, n, p, opts...)
+}
+
+func newScalableBloomFilter(kind Kind, n uint64, p float64, opts ...Option) (*ScalableBloomFilter, error) {
+	sbf, err := newScalableStack(kind, n, p, opts...)
+	if err != nil {
+		return nil, err
+	}
+	layer, err := sbf.newLayer(0)
+	if err != nil {
+		return nil, err
+	}
+	sbf.layers = []*scalableLayer{layer}
+	return sbf, nil
+}
+
+/*Checks the parameters of a scalable filter and returns it without any layers.*/
+func newScalableStack(kind Kind, n uint64, p float64, opts ...Option) (*ScalableBloomFilter, error) {
+	o := buildOptions(opts)
+	if o.growth < 2 || o.growth > scalableMaxGrowth {
+		return nil, fmt.Errorf("Growth factor must be between 2 and %d", scalableMaxGrowth)
+	} else if !(o.tightening >= scalableMinTightening && o.tightening < 1) {
+		return nil, fmt.Errorf("Tightening ratio must be at least %g and less than 1", scalableMinTightening)
+	}
+	return &ScalableBloomFilter{
+		kind:       kind,
+		capacity:   n,
+		fpRate:     p,
+		growth:     o.growth,
+		tightening: o.tightening,
+		hasher:     o.hasher,
+		mut:        &sync.RWMutex{},
+	}, nil
+}
+
+/*Returns the capacity, size, hash count and expected false positive rate of the i-th layer of the stack, without allocating it.*/
+func (sbf *ScalableBloomFilter) layerParams(i int) (uint64, uint64, int, float64, error) {
+	capacity := sbf.capacity
+	for j := 0; j < i; j++ {
+		hi, lo := bits.Mul64(capacity, sbf.growth)
+		if hi != 0 {
+			return 0, 0, 0, 0, errors.New("Scalable filter can't grow any further")
+		}
+		capacity = lo
+	}
+	//Layer rates p(1-r), p(1-r)r, p(1-r)r^2, ... sum to less than p
+	p := sbf.fpRate * (1 - sbf.tightening) * math.Pow(sbf.tightening, float64(i))
+	size, hf, fpRate, err := EstimateParameters(capacity, p)
+	return capacity, size, hf, fpRate, err
+}
+
+/*Allocates the i-th layer of the stack.*/
+func (sbf *ScalableBloomFilter) newLayer(i int) (*scalableLayer, error) {
+	capacity, size, hf, fpRate, err := sbf.layerParams(i)
+	if err != nil {
+		return nil, err
+	}
+	layer := &scalableLayer{size: size, hf: hf, capacity: capacity, fpRate: fpRate}
+	if sbf.kind == KindStripedScalable {
+		layer.filter, err = NewStripedBloomFilter(size, hf, estimateShards(size), WithHasher(sbf.hasher))
+	} else {
+		layer.filter, err = NewBloomFilter(size, hf, WithHasher(sbf.hasher))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return layer, nil
+}
+
+/*Adds a layer unless one has already been added since full was the newest. Takes the write lock.*/
+func (sbf *ScalableBloomFilter) grow(full *scalableLayer) error {
+	sbf.mut.Lock()
+	defer sbf.mut.Unlock()
+	return sbf.growAsync(full)
+}
+
+func (sbf *ScalableBloomFilter) growAsync(full *scalableLayer) error {
+	if sbf.layers[len(sbf.layers)-1] != full {
+		return nil
+	}
+	layer, err := sbf.newLayer(len(sbf.layers))
+	if err != nil {
+		return err
+	}
+	sbf.layers = append(sbf.layers, layer)
+	return nil
+}
+
+/*ScalableLayer describes one layer of a ScalableBloomFilter.*/
+type ScalableLayer struct {
+	Size              uint64  //Size of the layer in bits
+	Hashes            int     //Number of hash functions
+	Capacity          uint64  //Number of entries the layer was sized for
+	Count             uint64  //Number of entries inserted into the layer
+	FalsePositiveRate float64 //False positive rate expected once Capacity entries have been inserted
+}
+
+/*Layers describes every layer of the filter, oldest first. The last layer is the one being filled.*/
+func (sbf *ScalableBloomFilter) Layers() []ScalableLayer {
+	sbf.mut.RLock()
+	defer sbf.mut.RUnlock()
+	layers := make([]ScalableLayer, len(sbf.layers))
+	for i, l := range sbf.layers {
+		layers[i] = ScalableLayer{Size: l.size, Hashes: l.hf, Capacity: l.capacity, Count: l.count.Load(), FalsePositiveRate: l.fpRate}
+	}
+	return layers
+}
+
+/*Looks up an entry in the ScalableBloomFilter. Returns true if a match is found in any layer, false otherwise.*/
+func (sbf *ScalableBloomFilter) Lookup(entry string) (bool, error) {
+	h1, h2 := stringHashes(sbf.hasher, entry)
+	return sbf.lookupHashes(h1, h2)
+}
+
+/*Looks up an entry in the ScalableBloomFilter without locking the filter or its layers.*/
+func (sbf *ScalableBloomFilter) LookupAsync(entry string) (bool, error) {
+	h1, h2 := stringHashes(sbf.hasher, entry)
+	return sbf.lookupHashesAsync(h1, h2)
+}
+
+/*LookupBytes is Lookup for a byte slice entry. It gives the same answer as Lookup(string(entry)) without converting or allocating.*/
+func (sbf *ScalableBloomFilter) LookupBytes(entry []byte) (bool, error) {
+	h1, h2 := baseHashes(sbf.hasher, entry)
+	return sbf.lookupHashes(h1, h2)
+}
+
+/*LookupUint64 is Lookup for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (sbf *ScalableBloomFilter) LookupUint64(entry uint64) (bool, error) {
+	h1, h2 := uint64Hashes(sbf.hasher, entry)
+	return sbf.lookupHashes(h1, h2)
+}
+
+/*LookupBatch looks up every entry in entries and returns whether each one was found, in the same order. Takes the reader lock on the layer list once.*/
+func (sbf *ScalableBloomFilter) LookupBatch(entries []string) ([]bool, error) {
+	hashes := batchHashes(sbf.hasher, entries)
+	results := make([]bool, len(entries))
+	sbf.mut.RLock()
+	defer sbf.mut.RUnlock()
+	for e := 0; e < len(hashes); e += 2 {
+		exists, err := sbf.lookupLayers(hashes[e], hashes[e+1])
+		if err != nil {
+			return nil, err
+		}
+		results[e/2] = exists
+	}
+	return results, nil
+}
+
+func (sbf *ScalableBloomFilter) lookupHashes(h1, h2 uint64) (bool, error) {
+	sbf.mut.RLock()
+	defer sbf.mut.RUnlock()
+	return sbf.lookupLayers(h1, h2)
+}
+
+/*Checks the layers newest first, as the newest are the largest and hold the most entries. Callers hold the reader lock.*/
+func (sbf *ScalableBloomFilter) lookupLayers(h1, h2 uint64) (bool, error) {
+	for i := len(sbf.layers) - 1; i >= 0; i-- {
+		if exists, err := sbf.layers[i].filter.lookupHashes(h1, h2); exists || err != nil {
+			return exists, err
+		}
+	}
+	return false, nil
+}
+
+func (sbf *ScalableBloomFilter) lookupHashesAsync(h1, h2 uint64) (bool, error) {
+	for i := len(sbf.layers) - 1; i >= 0; i-- {
+		if exists, err := sbf.layers[i].filter.lookupHashesAsync(h1, h2); exists || err != nil {
+			return exists, err
+		}
+	}
+	return false, nil
+}
+
+/*
+Inserts an entry into the ScalableBloomFilter. Entries already present are skipped so that they don't use up the capacity of the newest layer.
+Returns an error if the newest layer is full and the filter can't grow any further (the entry is still inserted, but the false positive rate may then exceed the target).
+*/
+func (sbf *ScalableBloomFilter) Insert(entry string) error {
+	h1, h2 := stringHashes(sbf.hasher, entry)
+	_, err := sbf.insertHashes(h1, h2)
+	return err
+}
+
+/*Inserts an entry into the ScalableBloomFilter without locking the filter or its layers, including when a layer is added.*/
+func (sbf *ScalableBloomFilter) InsertAsync(entry string) error {
+	h1, h2 := stringHashes(sbf.hasher, entry)
+	present, err := sbf.lookupHashesAsync(h1, h2)
+	if present || err != nil {
+		return err
+	}
+	last := sbf.layers[len(sbf.layers)-1]
+	if err := last.filter.insertHashesAsync(h1, h2); err != nil {
+		return err
+	}
+	if last.count.Add(1) >= last.capacity {
+		return sbf.growAsync(last)
+	}
+	return nil
+}
+
+/*InsertBytes is Insert for a byte slice entry. It is equivalent to Insert(string(entry)) without converting or allocating.*/
+func (sbf *ScalableBloomFilter) InsertBytes(entry []byte) error {
+	h1, h2 := baseHashes(sbf.hasher, entry)
+	_, err := sbf.insertHashes(h1, h2)
+	return err
+}
+
+/*InsertUint64 is Insert for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (sbf *ScalableBloomFilter) InsertUint64(entry uint64) error {
+	h1, h2 := uint64Hashes(sbf.hasher, entry)
+	_, err := sbf.insertHashes(h1, h2)
+	return err
+}
+
+/*InsertBatch inserts every entry in entries, in order. Every entry is hashed first.*/
+func (sbf *ScalableBloomFilter) InsertBatch(entries []string) error {
+	hashes := batchHashes(sbf.hasher, entries)
+	for e := 0; e < len(hashes); e += 2 {
+		if _, err := sbf.insertHashes(hashes[e], hashes[e+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+TestAndInsert inserts entry and reports whether it was (probably) already present. Insert does the same test, so this only adds the result.
+The layer list is only read locked, so the guarantee is that of AtomicBloomFilter: of several concurrent TestAndInsert calls for the same new entry, at least one reports it absent.
+*/
+func (sbf *ScalableBloomFilter) TestAndInsert(entry string) (bool, error) {
+	h1, h2 := stringHashes(sbf.hasher, entry)
+	return sbf.insertHashes(h1, h2)
+}
+
+/*Inserts an entry into the newest layer unless some layer already has it, and reports whether one did.*/
+func (sbf *ScalableBloomFilter) insertHashes(h1, h2 uint64) (bool, error) {
+	sbf.mut.RLock()
+	present, err := sbf.lookupLayers(h1, h2)
+	if present || err != nil {
+		sbf.mut.RUnlock()
+		return present, err
+	}
+	last := sbf.layers[len(sbf.layers)-1]
+	err = last.filter.insertHashes(h1, h2)
+	full := last.count.Add(1) >= last.capacity
+	sbf.mut.RUnlock()
+	if err != nil {
+		return false, err
+	}
+	if full {
+		return false, sbf.grow(last)
+	}
+	return false, nil
+}
+
+/*Hasher returns the hasher the filter was built with.*/
+func (sbf *ScalableBloomFilter) Hasher() Hasher {
+	return sbf.hasher
+}
+
+/*
+WriteTo writes the filter, with every layer and its fill, to w in the package's binary format (see serialization.go) and returns the number of bytes written. It implements io.WriterTo.
+Takes a reader lock on the layer list while it is written. Each layer locks itself as it is written.
+*/
+func (sbf *ScalableBloomFilter) WriteTo(w io.Writer) (int64, error) {
+	sbf.mut.RLock()
+	defer sbf.mut.RUnlock()
+	first := sbf.layers[0]
+	h := newFileHeader(sbf.kind, first.size, first.hf, 0, sbf.hasher)
+	h.payloadLen = scalableParamsLen
+	for _, l := range sbf.layers {
+		h.payloadLen += 8 + headerLen + l.size/8 + 4
+	}
+	return writeFilter(w, h, func(w io.Writer) error {
+		params := make([]byte, 0, scalableParamsLen)
+		params = binary.LittleEndian.AppendUint64(params, math.Float64bits(sbf.fpRate))
+		params = binary.LittleEndian.AppendUint64(params, math.Float64bits(sbf.tightening))
+		params = binary.LittleEndian.AppendUint64(params, sbf.growth)
+		params = binary.LittleEndian.AppendUint64(params, sbf.capacity)
+		params = binary.LittleEndian.AppendUint64(params, uint64(len(sbf.layers)))
+		if _, err := w.Write(params); err != nil {
+			return err
+		}
+		for _, l := range sbf.layers {
+			if _, err := w.Write(binary.LittleEndian.AppendUint64(nil, l.count.Load())); err != nil {
+				return err
+			}
+			if _, err := l.filter.WriteTo(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+/*
+ReadFrom merges the filter with a serialized scalable filter read from r and returns the number of bytes read. It implements io.ReaderFrom.
+Exactly one serialized filter is consumed from r. It must have been built with the same parameters (capacity, target false positive rate, growth factor, tightening ratio and hasher), but may have a different number of layers and either kind of layer. Each layer is merged into the matching one, adding layers as needed, and their fill is added up.
+The filter is read and verified in full before the layer list is locked, once, for the merge.
+*/
+func (sbf *ScalableBloomFilter) ReadFrom(r io.Reader) (int64, error) {
+	first := sbf.layers[0]
+	_, payload, n, err := readFilter(r, func(h fileHeader) error {
+		return h.checkCompatible(sbf.kind, first.size, first.hf, sbf.hasher)
+	})
+	if err != nil {
+		return n, err
+	}
+	sbf.mut.Lock()
+	defer sbf.mut.Unlock()
+	return n, sbf.merge(payload)
+}
+
+/*Checks a scalable payload against the filter's parameters and layers, then merges it. Callers hold the write lock.*/
+func (sbf *ScalableBloomFilter) merge(payload []byte) error {
+	if len(payload) < scalableParamsLen {
+		return io.ErrUnexpectedEOF
+	}
+	fpRate := math.Float64frombits(binary.LittleEndian.Uint64(payload[0:]))
+	tightening := math.Float64frombits(binary.LittleEndian.Uint64(payload[8:]))
+	growth := binary.LittleEndian.Uint64(payload[16:])
+	capacity := binary.LittleEndian.Uint64(payload[24:])
+	layers := binary.LittleEndian.Uint64(payload[32:])
+	if fpRate != sbf.fpRate || tightening != sbf.tightening || growth != sbf.growth || capacity != sbf.capacity {
+		return fmt.Errorf("File filter has capacity %d, p %g, growth %d and tightening %g; this filter has capacity %d, p %g, growth %d and tightening %g",
+			capacity, fpRate, growth, tightening, sbf.capacity, sbf.fpRate, sbf.growth, sbf.tightening)
+	}
+
+	rest := payload[scalableParamsLen:]
+	if layers == 0 {
+		return errors.New("Scalable filter has no layers")
+	} else if layers > uint64(len(rest))/minScalableLayerLen {
+		return fmt.Errorf("Payload of %d bytes can't hold %d layers", len(rest), layers)
+	}
+
+	//Check every layer before touching the filter or allocating new layers, so that a bad file leaves it unchanged and allocates nothing it doesn't hold
+	counts := make([]uint64, layers)
+	for i := uint64(0); i < layers; i++ {
+		if len(rest) < 8+headerLen {
+			return io.ErrUnexpectedEOF
+		}
+		counts[i] = binary.LittleEndian.Uint64(rest)
+		h, err := parseFileHeader(rest[8 : 8+headerLen])
+		if err != nil {
+			return err
+		}
+		var size uint64
+		var hf int
+		if i < uint64(len(sbf.layers)) {
+			size, hf = sbf.layers[i].size, sbf.layers[i].hf
+		} else if _, size, hf, _, err = sbf.layerParams(int(i)); err != nil {
+			return err
+		}
+		if err := h.checkCompatible(KindBloom, size, hf, sbf.hasher); err != nil {
+			return fmt.Errorf("Layer %d: %w", i, err)
+		}
+		layerLen := 8 + headerLen + h.payloadLen + 4
+		if uint64(len(rest)) < layerLen {
+			return io.ErrUnexpectedEOF
+		}
+		rest = rest[layerLen:]
+	}
+	if len(rest) != 0 {
+		return errors.New("Trailing data after the last layer")
+	}
+
+	var fresh []*scalableLayer
+	for i := len(sbf.layers); uint64(i) < layers; i++ {
+		layer, err := sbf.newLayer(i)
+		if err != nil {
+			return err
+		}
+		fresh = append(fresh, layer)
+	}
+	sbf.layers = append(sbf.layers, fresh...)
+	rest = payload[scalableParamsLen:]
+	for i := uint64(0); i < layers; i++ {
+		n, err := sbf.layers[i].filter.ReadFrom(bytes.NewReader(rest[8:]))
+		if err != nil {
+			return err
+		}
+		sbf.layers[i].count.Add(counts[i])
+		rest = rest[8+n:]
+	}
+	if last := sbf.layers[len(sbf.layers)-1]; last.count.Load() >= last.capacity {
+		return sbf.growAsync(last)
+	}
+	return nil
+}
+
+/*MarshalBinary returns the filter in the package's binary format. It implements encoding.BinaryMarshaler (and so also works with encoding/gob).*/
+func (sbf *ScalableBloomFilter) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := sbf.WriteTo(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+/*
+UnmarshalBinary replaces the filter with a serialized scalable filter, taking its parameters, layers and hasher from data. It implements encoding.BinaryUnmarshaler.
+The filter's current hasher is kept if it matches the serialized one (which is the only way to unmarshal a filter using a keyed hasher). It must not be called concurrently with any other method.
+*/
+func (sbf *ScalableBloomFilter) UnmarshalBinary(data []byte) error {
+	//Either kind of scalable filter may be unmarshaled, into a zero value too
+	h, payload, err := unmarshalFilter(data, KindScalable)
+	if err != nil {
+		return err
+	}
+	if len(payload) < scalableParamsLen {
+		return io.ErrUnexpectedEOF
+	}
+	hasher, err := h.resolveHasher(sbf.hasher)
+	if err != nil {
+		return err
+	}
+	//The stack starts without layers, so that merge checks them all against the payload before allocating any
+	fresh, err := newScalableStack(h.kind,
+		binary.LittleEndian.Uint64(payload[24:]),
+		math.Float64frombits(binary.LittleEndian.Uint64(payload[0:])),
+		WithHasher(hasher),
+		WithGrowth(binary.LittleEndian.Uint64(payload[16:])),
+		WithTightening(math.Float64frombits(binary.LittleEndian.Uint64(payload[8:]))))
+	if err != nil {
+		return err
+	}
+	_, size, hf, _, err := fresh.layerParams(0)
+	if err != nil {
+		return err
+	} else if size != h.size || hf != h.hf {
+		return fmt.Errorf("Parameters give a first layer of size %d with %d hash functions, the header has size %d with %d", size, hf, h.size, h.hf)
+	}
+	if err := fresh.merge(payload); err != nil {
+		return err
+	}
+	*sbf = *fresh
+	return nil
+}
+
+/*Writes the filter to a file. See WriteTo.*/
+func (sbf *ScalableBloomFilter) Write(filename string) error {
+	return writeFile(filename, sbf.WriteTo)
+}
+
+/*Merges the filter with one loaded from a file. See ReadFrom.*/
+func (sbf *ScalableBloomFilter) Load(filename string) error {
+	return readFile(filename, sbf.ReadFrom)
+}
@@ -0,0 +1,272 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"hash/crc32"
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestNewScalableBloomFilter(t *testing.T) {
+	_, err := NewScalableBloomFilter(0, 0.01)
+	assert.NotNil(t, err)
+	_, err = NewScalableBloomFilter(1000, 0.01, WithGrowth(1))
+	assert.NotNil(t, err)
+	_, err = NewScalableBloomFilter(1000, 0.01, WithTightening(1))
+	assert.NotNil(t, err)
+	_, err = NewScalableBloomFilter(1000, 0.01, WithGrowth(scalableMaxGrowth+1))
+	assert.NotNil(t, err)
+	_, err = NewScalableBloomFilter(1000, 0.01, WithTightening(scalableMinTightening/2))
+	assert.NotNil(t, err)
+
+	sbf, err := NewScalableBloomFilter(1000, 0.01)
+	assert.Nil(t, err)
+	layers := sbf.Layers()
+	assert.Equal(t, 1, len(layers))
+	assert.Equal(t, uint64(1000), layers[0].Capacity)
+	assert.True(t, layers[0].FalsePositiveRate <= 0.001)
+
+	_, err = NewFilter(Spec{Kind: KindScalable, Size: 1024, Hashes: 3})
+	assert.NotNil(t, err)
+	for _, kind := range []Kind{KindScalable, KindStripedScalable} {
+		f, fpRate, err := NewFilterWithEstimates(kind, 1000, 0.01)
+		assert.Nil(t, err)
+		//The rate of the whole stack, not that of the tightened first layer
+		assert.True(t, fpRate <= 0.01)
+		assert.InDelta(t, 0.01, fpRate, 0.001)
+		assert.Less(t, f.(*ScalableBloomFilter).Layers()[0].FalsePositiveRate, fpRate)
+		assert.Equal(t, kind, f.(*ScalableBloomFilter).kind)
+	}
+}
+
+/*The filter must grow as entries are added and keep its false positive rate below the target.*/
+func TestScalableGrowth(t *testing.T) {
+	for _, kind := range []Kind{KindScalable, KindStripedScalable} {
+		sbf, err := newScalableBloomFilter(kind, 1000, 0.01)
+		assert.Nil(t, err)
+		for i := 0; i < 50000; i++ {
+			assert.Nil(t, sbf.Insert(fmt.Sprintf("entry-%d", i)))
+		}
+		layers := sbf.Layers()
+		assert.True(t, len(layers) >= 5, "%s: %d layers", kind, len(layers))
+		var total uint64
+		for i, l := range layers {
+			total += l.Count
+			if i > 0 {
+				assert.Equal(t, 2*layers[i-1].Capacity, l.Capacity)
+			}
+			if i < len(layers)-1 {
+				assert.Equal(t, l.Capacity, l.Count, "%s: layer %d", kind, i)
+			}
+		}
+		//A few entries are false positives of earlier layers and so are skipped
+		assert.True(t, total <= 50000 && total > 49500, "%s: %d entries counted", kind, total)
+
+		for i := 0; i < 50000; i++ {
+			exists, err := sbf.Lookup(fmt.Sprintf("entry-%d", i))
+			assert.Nil(t, err)
+			assert.True(t, exists)
+		}
+		fp := 0
+		for i := 0; i < 100000; i++ {
+			if exists, _ := sbf.Lookup(fmt.Sprintf("absent-%d", i)); exists {
+				fp++
+			}
+		}
+		assert.True(t, float64(fp)/100000 < 0.01, "%s: false positive rate %f", kind, float64(fp)/100000)
+	}
+}
+
+/*Entries already present must not use up capacity.*/
+func TestScalableDuplicates(t *testing.T) {
+	sbf, err := NewScalableBloomFilter(100, 0.01)
+	assert.Nil(t, err)
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, sbf.Insert("foo"))
+	}
+	assert.Equal(t, uint64(1), sbf.Layers()[0].Count)
+	present, err := sbf.TestAndInsert("foo")
+	assert.Nil(t, err)
+	assert.True(t, present)
+	present, err = sbf.TestAndInsert("bar")
+	assert.Nil(t, err)
+	assert.False(t, present)
+}
+
+func TestScalableSerialization(t *testing.T) {
+	a, err := NewStripedScalableBloomFilter(100, 0.01)
+	assert.Nil(t, err)
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, a.Insert(fmt.Sprintf("a-%d", i)))
+	}
+	data, err := a.MarshalBinary()
+	assert.Nil(t, err)
+
+	var u ScalableBloomFilter
+	assert.Nil(t, u.UnmarshalBinary(data))
+	assert.Equal(t, a.Layers(), u.Layers())
+	assert.Equal(t, KindStripedScalable, u.kind)
+	for i := 0; i < 1000; i++ {
+		exists, _ := u.Lookup(fmt.Sprintf("a-%d", i))
+		assert.True(t, exists)
+	}
+	again, err := u.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, data, again)
+
+	//Merging a deeper stack into a shallower one (of the other kind of layer) adds the missing layers
+	b, err := NewScalableBloomFilter(100, 0.01)
+	assert.Nil(t, err)
+	assert.Nil(t, b.Insert("b"))
+	n, err := b.ReadFrom(bytes.NewReader(data))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, len(a.Layers()), len(b.Layers()))
+	assert.Equal(t, a.Layers()[0].Count+1, b.Layers()[0].Count)
+	for _, e := range []string{"b", "a-0", "a-999"} {
+		exists, _ := b.Lookup(e)
+		assert.True(t, exists, e)
+	}
+
+	//Mismatched parameters and fixed size filters are rejected both ways
+	c, err := NewScalableBloomFilter(100, 0.01, WithGrowth(4))
+	assert.Nil(t, err)
+	_, err = c.ReadFrom(bytes.NewReader(data))
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, len(c.Layers()))
+	bf, err := NewBloomFilter(a.Layers()[0].Size, a.Layers()[0].Hashes)
+	assert.Nil(t, err)
+	_, err = bf.ReadFrom(bytes.NewReader(data))
+	assert.NotNil(t, err)
+	assert.NotNil(t, bf.UnmarshalBinary(data))
+	plain, err := bf.MarshalBinary()
+	assert.Nil(t, err)
+	_, err = c.ReadFrom(bytes.NewReader(plain))
+	assert.NotNil(t, err)
+	assert.NotNil(t, c.UnmarshalBinary(plain))
+}
+
+/*Crafted payloads with a valid checksum must be rejected without allocating what their lengths claim.*/
+func TestScalableRejectsCorrupt(t *testing.T) {
+	sbf, err := NewScalableBloomFilter(100, 0.01)
+	assert.Nil(t, err)
+	data, err := sbf.MarshalBinary()
+	assert.Nil(t, err)
+	resum := func(b []byte) []byte {
+		binary.LittleEndian.PutUint32(b[len(b)-4:], crc32.Checksum(b[:len(b)-4], crcTable))
+		return b
+	}
+
+	for _, layers := range []uint64{1 << 60, 2, 0} {
+		b := bytes.Clone(data)
+		binary.LittleEndian.PutUint64(b[headerLen+32:], layers)
+		var u ScalableBloomFilter
+		assert.NotNil(t, u.UnmarshalBinary(resum(b)), "%d layers", layers)
+		_, err := sbf.ReadFrom(bytes.NewReader(b))
+		assert.NotNil(t, err, "%d layers", layers)
+	}
+
+	//A header claiming a huge payload runs out of data instead of allocating it
+	for _, payloadLen := range []uint64{1 << 62, 1 << 36, 16} {
+		b := bytes.Clone(data[:headerLen])
+		binary.LittleEndian.PutUint64(b[40:], payloadLen)
+		_, err := sbf.ReadFrom(bytes.NewReader(b))
+		assert.NotNil(t, err, "payload of %d bytes", payloadLen)
+		var u ScalableBloomFilter
+		assert.NotNil(t, u.UnmarshalBinary(b), "payload of %d bytes", payloadLen)
+	}
+	exists, _ := sbf.Lookup("anything")
+	assert.False(t, exists)
+	assert.Equal(t, 1, len(sbf.Layers()))
+}
+
+/*Parameters from the payload that would size a layer far beyond the data are rejected before any layer is allocated.*/
+func TestScalableRejectsHugeLayers(t *testing.T) {
+	sbf, err := NewScalableBloomFilter(100, 0.01)
+	assert.Nil(t, err)
+	data, err := sbf.MarshalBinary()
+	assert.Nil(t, err)
+	resum := func(b []byte) []byte {
+		binary.LittleEndian.PutUint32(b[len(b)-4:], crc32.Checksum(b[:len(b)-4], crcTable))
+		return b
+	}
+	patch := func(offset int, v uint64) []byte {
+		b := bytes.Clone(data)
+		binary.LittleEndian.PutUint64(b[headerLen+offset:], v)
+		return b
+	}
+
+	for _, capacity := range []uint64{1 << 50, 1 << 36} {
+		//Inconsistent with the header's first layer
+		b := patch(24, capacity)
+		var u ScalableBloomFilter
+		assert.NotNil(t, u.UnmarshalBinary(resum(b)), "capacity %d", capacity)
+
+		//Consistent with the header, but not with the data
+		huge := &ScalableBloomFilter{capacity: capacity, fpRate: 0.01, growth: 2, tightening: 0.9}
+		_, size, hf, _, err := huge.layerParams(0)
+		assert.Nil(t, err)
+		binary.LittleEndian.PutUint32(b[8:], uint32(hf))
+		binary.LittleEndian.PutUint64(b[16:], size)
+		assert.NotNil(t, u.UnmarshalBinary(resum(b)), "capacity %d", capacity)
+	}
+	for _, b := range [][]byte{
+		patch(16, 1<<20),                         //Growth
+		patch(8, math.Float64bits(1e-9)),         //Tightening
+		patch(0, math.Float64bits(math.NaN())),   //Target rate
+		patch(0, math.Float64bits(1)),            //Target rate
+		patch(0, math.Float64bits(0.01/(1<<10))), //A valid rate, but not the header's first layer
+	} {
+		var u ScalableBloomFilter
+		assert.NotNil(t, u.UnmarshalBinary(resum(b)))
+	}
+
+	//Layers added by a merge are checked against the data before they are allocated
+	b := patch(32, 2)
+	b = append(b[:len(b)-4], data[headerLen+scalableParamsLen:len(data)-4]...)
+	b = append(b, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint64(b[40:], uint64(len(b)-headerLen-4))
+	_, err = sbf.ReadFrom(bytes.NewReader(resum(b)))
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, len(sbf.Layers()))
+}
+
+/*Run with -race: concurrent inserts that grow the filter must not race with lookups or writes.*/
+func TestScalableConcurrent(t *testing.T) {
+	sbf, err := NewStripedScalableBloomFilter(100, 0.01)
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				assert.Nil(t, sbf.Insert(fmt.Sprintf("entry-%d-%d", w, i)))
+			}
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				sbf.Lookup(fmt.Sprintf("entry-%d-%d", w, i))
+			}
+		}(w)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := sbf.MarshalBinary()
+		assert.Nil(t, err)
+	}()
+	wg.Wait()
+	for w := 0; w < 4; w++ {
+		for i := 0; i < 1000; i++ {
+			exists, err := sbf.Lookup(fmt.Sprintf("entry-%d-%d", w, i))
+			assert.Nil(t, err)
+			assert.True(t, exists)
+		}
+	}
+}
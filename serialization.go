@@ -0,0 +1,408 @@
+package hyperbloom
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"slices"
+)
+
+/*
+Serialized filters are laid out as a fixed 48 byte header, the payload and a CRC-32C of everything before it. All integers are little endian.
+
+	offset size field
+	0      4    magic "HBLF"
+	4      2    format version
+	6      1    filter kind (see Kind)
+	7      1    hasher (see HasherID)
+	8      4    number of hash functions (fingerprint size in bits for cuckoo filters)
+	12     4    entries per bucket for cuckoo filters, 0 otherwise
+	16     8    filter size in buckets
+	24     8    number of shards (0 for unstriped kinds)
+	32     8    hasher seed (see Hasher)
+	40     8    payload length in bytes
+
+The payload of the bit vector kinds is the bit vector as 64 bit words, that of the byte vector (naive and counting) kinds is the byte vector itself.
+The payload of cuckoo filters is their table of fingerprints, packed into 64 bit words.
+Scalable filters record their first layer in the header; their payload is described in scalable.go. The size of static (xor and binary fuse) filters is the length of their fingerprint array, which needn't be a power of 2; their payload is described in xor.go.
+Cardinality sketches (HyperLogLog and StripedHyperLogLog) record their number of registers as their size, which may be as small as 16; their payload is described in hyperloglog.go.
+*/
+const (
+	formatMagic   = "HBLF"
+	formatVersion = 1
+	headerLen     = 48
+)
+
+/*HeaderLen is the length of the header that starts every serialized filter, which is all ParseSpec and SerializedLen read.*/
+const HeaderLen = headerLen
+
+/*
+SerializedLen returns the length in bytes of a filter serialized in the package's binary format, header and checksum included, reading only its HeaderLen byte header. The header is checked as by ParseSpec; the payload isn't.
+It lets a serialized filter be cut out of a stream without reading it into a filter, e.g. to pass it on.
+*/
+func SerializedLen(header []byte) (uint64, error) {
+	if len(header) < headerLen {
+		return 0, io.ErrUnexpectedEOF
+	}
+	h, err := parseFileHeader(header[:headerLen])
+	if err != nil {
+		return 0, err
+	} else if h.payloadLen > math.MaxUint64-headerLen-4 {
+		return 0, fmt.Errorf("Payload length %d is too long", h.payloadLen)
+	}
+	return headerLen + h.payloadLen + 4, nil
+}
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+type fileHeader struct {
+	kind       Kind
+	hasher     HasherID
+	hf         int
+	bucketSize int
+	size       uint64
+	shards     uint64
+	seed       uint64
+	payloadLen uint64
+}
+
+/*Builds the header describing a filter of the given kind. The payload length follows from the kind and size.*/
+func newFileHeader(kind Kind, size uint64, hf int, shards uint64, hasher Hasher) fileHeader {
+	h := fileHeader{kind: kind, hasher: hasher.ID(), hf: hf, size: size, shards: shards, seed: hasher.Seed()}
+	h.payloadLen = h.expectedPayloadLen()
+	return h
+}
+
+/*Reports whether the payload is a bit vector stored as 64 bit words (as opposed to a byte per bucket).*/
+func (h fileHeader) bitPayload() bool {
+	return h.kind == KindBloom || h.kind == KindStriped || h.kind == KindAtomic || h.kind.blocked()
+}
+
+func (h fileHeader) expectedPayloadLen() uint64 {
+	if h.kind == KindCuckoo {
+		return h.size * uint64(h.bucketSize) * uint64(h.hf) / 8
+	} else if h.kind.static() {
+		return staticParamsLen + h.size
+	} else if h.bitPayload() {
+		return h.size / 8
+	}
+	return h.size
+}
+
+func (h fileHeader) marshal() []byte {
+	b := make([]byte, headerLen)
+	copy(b, formatMagic)
+	binary.LittleEndian.PutUint16(b[4:], formatVersion)
+	b[6] = byte(h.kind)
+	b[7] = byte(h.hasher)
+	binary.LittleEndian.PutUint32(b[8:], uint32(h.hf))
+	binary.LittleEndian.PutUint32(b[12:], uint32(h.bucketSize))
+	binary.LittleEndian.PutUint64(b[16:], h.size)
+	binary.LittleEndian.PutUint64(b[24:], h.shards)
+	binary.LittleEndian.PutUint64(b[32:], h.seed)
+	binary.LittleEndian.PutUint64(b[40:], h.payloadLen)
+	return b
+}
+
+func parseFileHeader(b []byte) (fileHeader, error) {
+	var h fileHeader
+	if string(b[:4]) != formatMagic {
+		return h, errors.New("Not a hyperbloom filter: bad magic number")
+	}
+	if v := binary.LittleEndian.Uint16(b[4:]); v != formatVersion {
+		return h, fmt.Errorf("Unsupported format version %d", v)
+	}
+	h.kind = Kind(b[6])
+	h.hasher = HasherID(b[7])
+	h.hf = int(binary.LittleEndian.Uint32(b[8:]))
+	h.bucketSize = int(binary.LittleEndian.Uint32(b[12:]))
+	h.size = binary.LittleEndian.Uint64(b[16:])
+	h.shards = binary.LittleEndian.Uint64(b[24:])
+	h.seed = binary.LittleEndian.Uint64(b[32:])
+	h.payloadLen = binary.LittleEndian.Uint64(b[40:])
+	if _, ok := kindNames[h.kind]; !ok {
+		return h, fmt.Errorf("Unknown filter kind %d", b[6])
+	}
+	if h.kind.sketch() {
+		if h.hf != 0 {
+			return h, fmt.Errorf("Invalid %s: %d hash functions", h.kind, h.hf)
+		}
+	} else if h.hf < 1 || h.hf > maxHashFunctions {
+		return h, fmt.Errorf("Invalid filter: %d hash functions, expected 1 to %d", h.hf, maxHashFunctions)
+	}
+	minSize := uint64(64)
+	if h.kind.sketch() {
+		minSize = 1 << hllMinPrecision
+	}
+	if !h.kind.static() && (h.size < minSize || h.size&(h.size-1) != 0) {
+		return h, fmt.Errorf("Invalid filter size %d", h.size)
+	}
+	if h.kind == KindCuckoo {
+		if err := checkCuckooParams(h.size, h.bucketSize, h.hf); err != nil {
+			return h, err
+		}
+	}
+	if h.kind.sketch() {
+		if h.payloadLen < 8 || h.payloadLen > 8+h.size { //Sparse sketches are never larger than dense ones
+			return h, fmt.Errorf("Payload length %d doesn't match a %s of %d registers", h.payloadLen, h.kind, h.size)
+		}
+	} else if h.kind.scalable() {
+		if h.payloadLen < scalableParamsLen+minScalableLayerLen {
+			return h, fmt.Errorf("Payload length %d is too short for a %s filter", h.payloadLen, h.kind)
+		}
+	} else if h.payloadLen != h.expectedPayloadLen() {
+		return h, fmt.Errorf("Payload length %d doesn't match a %s filter of size %d", h.payloadLen, h.kind, h.size)
+	}
+	return h, nil
+}
+
+/*
+Returns an error unless the serialized filter can be merged into a filter of the given kind and parameters. The kinds may differ as long as they lay their buckets out the same way (see checkKind).
+*/
+func (h fileHeader) checkCompatible(kind Kind, size uint64, hf int, hasher Hasher) error {
+	if err := h.checkKind(kind); err != nil {
+		return err
+	}
+	return h.checkParams(size, hf, hasher)
+}
+
+/*
+Returns an error unless a filter of the given kind can hold the serialized one. Scalable filters serialize a stack of filters rather than a single one, blocked filters place an entry's bits differently from the others, cuckoo and static filters store fingerprints rather than bits and sketches store registers, so none of them mixes with other kinds.
+*/
+func (h fileHeader) checkKind(kind Kind) error {
+	if h.kind.family() != kind.family() {
+		return fmt.Errorf("Can't load a %s filter into a %s filter", h.kind, kind)
+	}
+	return nil
+}
+
+/*Returns the kind standing for every kind whose serialized filters can be loaded into one another (see checkKind).*/
+func (k Kind) family() Kind {
+	switch {
+	case k.scalable():
+		return KindScalable
+	case k.blocked():
+		return KindBlocked
+	case k.sketch():
+		return KindHyperLogLog
+	case k == KindCuckoo || k.static():
+		return k
+	}
+	return KindBloom
+}
+
+/*Returns an error unless the serialized filter has the given size, hash count and hasher.*/
+func (h fileHeader) checkParams(size uint64, hf int, hasher Hasher) error {
+	if h.size != size {
+		return fmt.Errorf("File filter size: %d. Specified size: %d. Mismatch.", h.size, size)
+	} else if h.hf != hf {
+		return fmt.Errorf("File filter uses %d hash functions, this filter uses %d", h.hf, hf)
+	} else if h.hasher != hasher.ID() || h.seed != hasher.Seed() {
+		return fmt.Errorf("File was written with hasher %s(seed=%#x), this filter uses %s", h.hasher, h.seed, hasherString(hasher))
+	}
+	return nil
+}
+
+/*
+Returns the hasher a serialized filter was written with: current if it matches, otherwise one rebuilt from the recorded ID and seed.
+Keyed hashers can't be rebuilt, so filters using them must be unmarshaled into a filter already constructed with the right key.
+*/
+func (h fileHeader) resolveHasher(current Hasher) (Hasher, error) {
+	if current != nil && current.ID() == h.hasher && current.Seed() == h.seed {
+		return current, nil
+	}
+	return NewHasher(h.hasher, h.seed)
+}
+
+/*Writes the header, the payload produced by fill and the trailing checksum to w. Returns the number of bytes written.*/
+func writeFilter(w io.Writer, h fileHeader, fill func(io.Writer) error) (int64, error) {
+	cw := &countingWriter{w: w}
+	crc := crc32.New(crcTable)
+	mw := io.MultiWriter(cw, crc)
+	if _, err := mw.Write(h.marshal()); err != nil {
+		return cw.n, err
+	}
+	if err := fill(mw); err != nil {
+		return cw.n, err
+	}
+	var sum [4]byte
+	binary.LittleEndian.PutUint32(sum[:], crc.Sum32())
+	_, err := cw.Write(sum[:])
+	return cw.n, err
+}
+
+/*
+Reads a serialized filter from r, consuming exactly its bytes, and returns the number of bytes read. check is called with the header before the payload is read so that incompatible filters are rejected without reading (or allocating) their payload.
+Nothing is returned unless the checksum matches, so callers never merge a corrupt payload.
+*/
+func readFilter(r io.Reader, check func(fileHeader) error) (fileHeader, []byte, int64, error) {
+	cr := &countingReader{r: r}
+	crc := crc32.New(crcTable)
+	tr := io.TeeReader(cr, crc)
+	hb := make([]byte, headerLen)
+	if _, err := io.ReadFull(tr, hb); err != nil {
+		return fileHeader{}, nil, cr.n, err
+	}
+	h, err := parseFileHeader(hb)
+	if err != nil {
+		return h, nil, cr.n, err
+	}
+	if err := check(h); err != nil {
+		return h, nil, cr.n, err
+	}
+	payload, err := readPayload(tr, h.payloadLen)
+	if err != nil {
+		return h, nil, cr.n, err
+	}
+	var sum [4]byte
+	if _, err := io.ReadFull(cr, sum[:]); err != nil {
+		return h, nil, cr.n, err
+	}
+	if binary.LittleEndian.Uint32(sum[:]) != crc.Sum32() {
+		return h, nil, cr.n, errors.New("Checksum mismatch: filter data is corrupt")
+	}
+	return h, payload, cr.n, nil
+}
+
+/*Payloads up to this long are allocated in one go by readPayload.*/
+const payloadPrealloc = 1 << 20
+
+/*
+Reads an n byte payload from r. Beyond payloadPrealloc the buffer is grown as the data arrives, at most doubling each time and never past n, so that a corrupt or hostile header length fails at the end of the data rather than allocating its length up front.
+*/
+func readPayload(r io.Reader, n uint64) ([]byte, error) {
+	buf := make([]byte, 0, min(n, payloadPrealloc))
+	for uint64(len(buf)) < n {
+		if len(buf) == cap(buf) {
+			buf = slices.Grow(buf, int(min(n-uint64(len(buf)), uint64(len(buf)))))
+		}
+		k, err := io.ReadFull(r, buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+k]
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+/*
+Reads a complete serialized filter from data into a filter of the given kind, as used by UnmarshalBinary. Unlike readFilter there is no filter to check the parameters against, so the header is only checked against the kind and the length of data.
+*/
+func unmarshalFilter(data []byte, kind Kind) (fileHeader, []byte, error) {
+	h, payload, n, err := readFilter(bytes.NewReader(data), func(h fileHeader) error {
+		if h.payloadLen > uint64(len(data)) {
+			return io.ErrUnexpectedEOF
+		}
+		return h.checkKind(kind)
+	})
+	if err == nil && n != int64(len(data)) {
+		err = errors.New("Trailing data after serialized filter")
+	}
+	return h, payload, err
+}
+
+/*Writes words to w in little endian order, a buffer at a time.*/
+func writeWords(w io.Writer, words []uint64) error {
+	buf := make([]byte, 0, 32*1024)
+	for _, word := range words {
+		buf = binary.LittleEndian.AppendUint64(buf, word)
+		if len(buf) == cap(buf) {
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+/*Returns the payload as a bit vector, packing it if it holds a byte per bucket.*/
+func payloadWords(h fileHeader, payload []byte) []uint64 {
+	words := make([]uint64, h.size/64)
+	if h.bitPayload() {
+		for i := range words {
+			words[i] = binary.LittleEndian.Uint64(payload[i*8:])
+		}
+		return words
+	}
+	for i, b := range payload {
+		if b != 0 {
+			words[i/64] |= 1 << (uint(i) & 63)
+		}
+	}
+	return words
+}
+
+/*Returns the payload as a byte per bucket, unpacking it if it holds a bit vector.*/
+func payloadBytes(h fileHeader, payload []byte) []byte {
+	if !h.bitPayload() {
+		return payload
+	}
+	bv := make([]byte, h.size)
+	for i := range bv {
+		if payload[i/8]&(1<<(uint(i)&7)) != 0 {
+			bv[i] = 1
+		}
+	}
+	return bv
+}
+
+/*Creates (or truncates) filename and writes a filter to it with writeTo.*/
+func writeFile(filename string, writeTo func(io.Writer) (int64, error)) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	if _, err := writeTo(bw); err != nil {
+		f.Close()
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+/*Opens filename and reads a filter from it with readFrom.*/
+func readFile(filename string, readFrom func(io.Reader) (int64, error)) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = readFrom(bufio.NewReader(f))
+	return err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,290 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var goldenSpecs = []Spec{
+	{Kind: KindBloom, Size: 1024, Hashes: 3},
+	{Kind: KindStriped, Size: 1024, Hashes: 3, Shards: 4},
+	{Kind: KindNaive, Size: 256, Hashes: 3},
+	{Kind: KindNaiveStriped, Size: 256, Hashes: 3, Shards: 2},
+	{Kind: KindAtomic, Size: 1024, Hashes: 3},
+	{Kind: KindCounting, Size: 256, Hashes: 3},
+	{Kind: KindStripedCounting, Size: 256, Hashes: 3, Shards: 2},
+	{Kind: KindBlocked, Size: 1024, Hashes: 3},
+	{Kind: KindStripedBlocked, Size: 1024, Hashes: 3, Shards: 2},
+	{Kind: KindCuckoo, Size: 64, BucketSize: 4, FingerprintBits: 12},
+}
+
+var goldenEntries = []string{"foo", "bar", "baz", "b99afb65c9f97b2e0feea844eea55f69"}
+
+func newGoldenFilter(t *testing.T, spec Spec) Filter {
+	f, err := NewFilter(spec)
+	assert.Nil(t, err)
+	for _, e := range goldenEntries {
+		assert.Nil(t, f.Insert(e))
+	}
+	return f
+}
+
+/*Write must produce exactly the bytes in testdata, and Write after Load must reproduce them.*/
+func TestGoldenFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, spec := range goldenSpecs {
+		spec := spec
+		t.Run(spec.Kind.String(), func(t *testing.T) {
+			golden := filepath.Join("testdata", spec.Kind.String()+".golden")
+			written := filepath.Join(dir, spec.Kind.String())
+			assert.Nil(t, newGoldenFilter(t, spec).Write(written))
+			got, err := os.ReadFile(written)
+			assert.Nil(t, err)
+			if *update {
+				assert.Nil(t, os.WriteFile(golden, got, 0666))
+			}
+			want, err := os.ReadFile(golden)
+			assert.Nil(t, err)
+			assert.Equal(t, want, got)
+
+			//Round trip through a fresh filter
+			f, err := NewFilter(spec)
+			assert.Nil(t, err)
+			assert.Nil(t, f.Load(golden))
+			rewritten := filepath.Join(dir, spec.Kind.String()+".rewritten")
+			assert.Nil(t, f.Write(rewritten))
+			again, err := os.ReadFile(rewritten)
+			assert.Nil(t, err)
+			assert.Equal(t, want, again)
+			for _, e := range goldenEntries {
+				exists, err := f.Lookup(e)
+				assert.Nil(t, err)
+				assert.True(t, exists)
+			}
+		})
+	}
+}
+
+func TestGoldenHeader(t *testing.T) {
+	want, err := os.ReadFile(filepath.Join("testdata", "striped.golden"))
+	assert.Nil(t, err)
+	assert.Equal(t, headerLen+1024/8+4, len(want))
+	h, err := parseFileHeader(want[:headerLen])
+	assert.Nil(t, err)
+	assert.Equal(t, fileHeader{kind: KindStriped, hasher: HasherXXHash64, hf: 3, size: 1024, shards: 4, payloadLen: 128}, h)
+}
+
+/*
+Files written by one variant can be merged into another with the same size, hash count and hasher. Counting filters only accept files with counters, and blocked filters only files from other blocked filters.
+*/
+func TestLoadAcrossKinds(t *testing.T) {
+	dir := t.TempDir()
+	for _, from := range conformanceSpecs {
+		filename := filepath.Join(dir, from.Kind.String())
+		src, err := NewFilter(from)
+		assert.Nil(t, err)
+		assert.Nil(t, src.Insert("foo"))
+		assert.Nil(t, src.Write(filename))
+		for _, to := range conformanceSpecs {
+			dst, err := NewFilter(to)
+			assert.Nil(t, err)
+			if to.Kind.counting() && !from.Kind.counting() || to.Kind.blocked() != from.Kind.blocked() || (to.Kind == KindCuckoo) != (from.Kind == KindCuckoo) {
+				assert.NotNil(t, dst.Load(filename), "%s into %s", from.Kind, to.Kind)
+				continue
+			}
+			assert.Nil(t, dst.Load(filename), "%s into %s", from.Kind, to.Kind)
+			exists, err := dst.Lookup("foo")
+			assert.Nil(t, err)
+			assert.True(t, exists, "%s into %s", from.Kind, to.Kind)
+			exists, err = dst.Lookup("bar")
+			assert.Nil(t, err)
+			assert.False(t, exists, "%s into %s", from.Kind, to.Kind)
+		}
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "filter")
+	bf := newGoldenFilter(t, goldenSpecs[0])
+	assert.Nil(t, bf.Write(filename))
+	data, err := os.ReadFile(filename)
+	assert.Nil(t, err)
+
+	//Mismatched size and hash count
+	other, err := NewBloomFilter(2048, 3)
+	assert.Nil(t, err)
+	assert.NotNil(t, other.Load(filename))
+	other, err = NewBloomFilter(1024, 4)
+	assert.Nil(t, err)
+	assert.NotNil(t, other.Load(filename))
+
+	corrupt := func(mutate func([]byte)) error {
+		b := bytes.Clone(data)
+		mutate(b)
+		assert.Nil(t, os.WriteFile(filename, b, 0666))
+		fresh, err := NewBloomFilter(1024, 3)
+		assert.Nil(t, err)
+		err = fresh.Load(filename)
+		//A rejected file must leave the filter untouched
+		for _, e := range goldenEntries {
+			exists, _ := fresh.Lookup(e)
+			assert.False(t, exists)
+		}
+		return err
+	}
+	assert.NotNil(t, corrupt(func(b []byte) { b[0] = 'X' }))
+	assert.NotNil(t, corrupt(func(b []byte) { b[4] = 9 }))
+	assert.NotNil(t, corrupt(func(b []byte) { b[6] = 200 }))
+	//Hash counts are checked even when the checksum is fixed up to match
+	for _, hf := range []uint32{0, maxHashFunctions + 1, 200000000} {
+		assert.NotNil(t, corrupt(func(b []byte) {
+			binary.LittleEndian.PutUint32(b[8:], hf)
+			binary.LittleEndian.PutUint32(b[len(b)-4:], crc32.Checksum(b[:len(b)-4], crcTable))
+		}), "%d hash functions", hf)
+		b := bytes.Clone(data)
+		binary.LittleEndian.PutUint32(b[8:], hf)
+		binary.LittleEndian.PutUint32(b[len(b)-4:], crc32.Checksum(b[:len(b)-4], crcTable))
+		_, err := UnmarshalFilter(b)
+		assert.NotNil(t, err, "%d hash functions", hf)
+	}
+	assert.NotNil(t, corrupt(func(b []byte) { b[headerLen+5] ^= 0xFF }))
+	assert.NotNil(t, corrupt(func(b []byte) { b[len(b)-1] ^= 0xFF }))
+	assert.Nil(t, os.WriteFile(filename, data[:len(data)-2], 0666))
+	assert.NotNil(t, bf.Load(filename))
+
+	assert.NotNil(t, bf.Load(filepath.Join(dir, "missing")))
+}
+
+/*Several filters written back to back to one stream must be read back one at a time.*/
+func TestWriteToReadFromStream(t *testing.T) {
+	var stream bytes.Buffer
+	var written int64
+	for _, spec := range goldenSpecs {
+		n, err := newGoldenFilter(t, spec).WriteTo(&stream)
+		assert.Nil(t, err)
+		written += n
+	}
+	assert.Equal(t, int64(stream.Len()), written)
+
+	for _, spec := range goldenSpecs {
+		f, err := NewFilter(spec)
+		assert.Nil(t, err)
+		n, err := f.ReadFrom(&stream)
+		assert.Nil(t, err)
+		h := newFileHeader(spec.Kind, spec.Size, spec.FingerprintBits, 0, DefaultHasher)
+		h.bucketSize = spec.BucketSize
+		assert.Equal(t, int64(headerLen+4)+int64(h.expectedPayloadLen()), n)
+		for _, e := range goldenEntries {
+			exists, _ := f.Lookup(e)
+			assert.True(t, exists)
+		}
+	}
+	assert.Equal(t, 0, stream.Len())
+}
+
+func TestMarshalBinary(t *testing.T) {
+	for _, spec := range goldenSpecs {
+		data, err := newGoldenFilter(t, spec).MarshalBinary()
+		assert.Nil(t, err)
+		want, err := os.ReadFile(filepath.Join("testdata", spec.Kind.String()+".golden"))
+		assert.Nil(t, err)
+		assert.Equal(t, want, data)
+	}
+
+	//Unmarshaling into a zero value adopts the serialized parameters
+	data, err := newGoldenFilter(t, goldenSpecs[1]).MarshalBinary()
+	assert.Nil(t, err)
+	var sbf StripedBloomFilter
+	assert.Nil(t, sbf.UnmarshalBinary(data))
+	assert.Equal(t, uint64(1024), sbf.size)
+	assert.Equal(t, uint64(4), sbf.shards)
+	assert.Equal(t, 3, sbf.hf)
+	exists, err := sbf.Lookup("foo")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+
+	//Unmarshaling replaces rather than merges, and can change the variant's layout
+	var nbf NaiveBloomFilter
+	assert.Nil(t, nbf.UnmarshalBinary(data))
+	assert.Nil(t, nbf.Insert("other"))
+	other, err := NewNaiveBloomFilter(1024, 3)
+	assert.Nil(t, err)
+	otherData, err := other.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Nil(t, nbf.UnmarshalBinary(otherData))
+	exists, _ = nbf.Lookup("other")
+	assert.False(t, exists)
+
+	var bf BloomFilter
+	assert.NotNil(t, bf.UnmarshalBinary(data[:len(data)-1]))
+	assert.NotNil(t, bf.UnmarshalBinary(append(bytes.Clone(data), 0)))
+	assert.NotNil(t, bf.UnmarshalBinary(nil))
+}
+
+func TestSerializedLen(t *testing.T) {
+	bf, err := NewBloomFilter(1024, 3)
+	assert.Nil(t, err)
+	data, err := bf.MarshalBinary()
+	assert.Nil(t, err)
+	n, err := SerializedLen(data[:HeaderLen])
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(len(data)), n)
+
+	_, err = SerializedLen(data[:HeaderLen-1])
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = SerializedLen(make([]byte, HeaderLen))
+	assert.NotNil(t, err)
+}
+
+func TestUnmarshalKeyedHasher(t *testing.T) {
+	key := [16]byte{3, 1, 4, 1, 5, 9, 2, 6}
+	bf, err := NewBloomFilter(1024, 3, WithHasher(NewSipHasher(key)))
+	assert.Nil(t, err)
+	assert.Nil(t, bf.Insert("foo"))
+	data, err := bf.MarshalBinary()
+	assert.Nil(t, err)
+
+	//The key can't be recovered from the serialized filter
+	var zero BloomFilter
+	assert.NotNil(t, zero.UnmarshalBinary(data))
+
+	keyed, err := NewBloomFilter(64, 1, WithHasher(NewSipHasher(key)))
+	assert.Nil(t, err)
+	assert.Nil(t, keyed.UnmarshalBinary(data))
+	exists, err := keyed.Lookup("foo")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+}
+
+func TestGob(t *testing.T) {
+	type snapshot struct {
+		Name    string
+		Filter  *StripedBloomFilter
+		Entries int
+	}
+	src, err := NewStripedBloomFilter(1024, 3, 4, WithHasher(NewMurmur3Hasher(9)))
+	assert.Nil(t, err)
+	assert.Nil(t, src.Insert("foo"))
+
+	var b bytes.Buffer
+	assert.Nil(t, gob.NewEncoder(&b).Encode(snapshot{Name: "users", Filter: src, Entries: 1}))
+	var dst snapshot
+	assert.Nil(t, gob.NewDecoder(&b).Decode(&dst))
+	assert.Equal(t, "users", dst.Name)
+	assert.Equal(t, 1, dst.Entries)
+	assert.Equal(t, HasherMurmur3, dst.Filter.Hasher().ID())
+	exists, err := dst.Filter.Lookup("foo")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+}
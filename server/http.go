@@ -0,0 +1,221 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+/*Largest request body accepted, which bounds the size of a batch.*/
+const maxBodyBytes = 64 << 20
+
+/*
+CreateRequest is the body of a create request. Either N (and P) or Size and Hashes must be set; Shards is only used by the striped kinds, and defaults to a count picked from GOMAXPROCS when sizing from N.
+*/
+type CreateRequest struct {
+	Kind   string  `json:"kind"`
+	N      uint64  `json:"n,omitempty"`
+	P      float64 `json:"p,omitempty"`
+	Size   uint64  `json:"size,omitempty"`
+	Hashes int     `json:"hashes,omitempty"`
+	Shards uint64  `json:"shards,omitempty"`
+}
+
+/*KeysRequest is the body of a batch insert or lookup.*/
+type KeysRequest struct {
+	Keys []string `json:"keys"`
+}
+
+/*LookupResponse answers a lookup of a single key.*/
+type LookupResponse struct {
+	Key     string `json:"key"`
+	Present bool   `json:"present"`
+}
+
+/*BatchLookupResponse answers a batch lookup, with one result per key in order.*/
+type BatchLookupResponse struct {
+	Present []bool `json:"present"`
+}
+
+type insertResponse struct {
+	Inserted int `json:"inserted"`
+}
+
+type namesResponse struct {
+	Filters []string `json:"filters"`
+}
+
+type snapshotResponse struct {
+	Path string `json:"path"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+/*
+NewHandler serves the store over HTTP. Bodies are JSON, and errors come back as {"error": "..."} with status 404 for unknown filters, 409 for names already taken and 400 otherwise.
+
+	GET    /filters                    list the filters: {"filters": [...]}
+	PUT    /filters/{name}             create a filter from a CreateRequest, returning its Stats
+	GET    /filters/{name}             the filter's Stats
+	DELETE /filters/{name}             remove the filter
+	PUT    /filters/{name}/keys/{key}  insert a key
+	GET    /filters/{name}/keys/{key}  look a key up, returning a LookupResponse
+	POST   /filters/{name}/insert      insert the keys of a KeysRequest: {"inserted": n}
+	POST   /filters/{name}/lookup      look up the keys of a KeysRequest, returning a BatchLookupResponse
+	POST   /filters/{name}/snapshot    save the filter to the snapshot directory: {"path": "..."}
+	POST   /filters/{name}/load        replace (or add) the filter with its saved snapshot, returning its Stats
+
+Keys in paths must be escaped, and may contain '/'.
+*/
+func NewHandler(s *Store) http.Handler {
+	h := &handler{store: s}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /filters", h.list)
+	mux.HandleFunc("PUT /filters/{name}", h.create)
+	mux.HandleFunc("GET /filters/{name}", h.stats)
+	mux.HandleFunc("DELETE /filters/{name}", h.delete)
+	mux.HandleFunc("PUT /filters/{name}/keys/{key...}", h.insertKey)
+	mux.HandleFunc("GET /filters/{name}/keys/{key...}", h.lookupKey)
+	mux.HandleFunc("POST /filters/{name}/insert", h.insertBatch)
+	mux.HandleFunc("POST /filters/{name}/lookup", h.lookupBatch)
+	mux.HandleFunc("POST /filters/{name}/snapshot", h.snapshot)
+	mux.HandleFunc("POST /filters/{name}/load", h.load)
+	return mux
+}
+
+type handler struct {
+	store *Store
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, ErrNotFound) {
+		status = http.StatusNotFound
+	} else if errors.Is(err, ErrExists) {
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+/*Decodes a JSON request body into v, rejecting unknown fields and trailing data.*/
+func readJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("Bad request body: %v", err)
+	} else if dec.More() {
+		return errors.New("Bad request body: trailing data")
+	}
+	return nil
+}
+
+func (h *handler) list(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, namesResponse{Filters: h.store.Names()})
+}
+
+func (h *handler) create(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var req CreateRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.store.CreateFrom(name, req); err != nil {
+		writeError(w, err)
+		return
+	}
+	h.writeStats(w, http.StatusCreated, name)
+}
+
+func (h *handler) writeStats(w http.ResponseWriter, status int, name string) {
+	stats, err := h.store.Stats(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, status, stats)
+}
+
+func (h *handler) stats(w http.ResponseWriter, r *http.Request) {
+	h.writeStats(w, http.StatusOK, r.PathValue("name"))
+}
+
+func (h *handler) delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.Delete(r.PathValue("name")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) insertKey(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.Insert(r.PathValue("name"), r.PathValue("key")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) lookupKey(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	results, err := h.store.Lookup(r.PathValue("name"), key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, LookupResponse{Key: key, Present: results[0]})
+}
+
+func (h *handler) insertBatch(w http.ResponseWriter, r *http.Request) {
+	var req KeysRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.store.Insert(r.PathValue("name"), req.Keys...); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, insertResponse{Inserted: len(req.Keys)})
+}
+
+func (h *handler) lookupBatch(w http.ResponseWriter, r *http.Request) {
+	var req KeysRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	results, err := h.store.Lookup(r.PathValue("name"), req.Keys...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, BatchLookupResponse{Present: results})
+}
+
+func (h *handler) snapshot(w http.ResponseWriter, r *http.Request) {
+	path, err := h.store.Snapshot(r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, snapshotResponse{Path: path})
+}
+
+func (h *handler) load(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := h.store.Load(name); err != nil {
+		writeError(w, err)
+		return
+	}
+	h.writeStats(w, http.StatusOK, name)
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+/*Sends a request with body (JSON encoded unless it is a string) to the test server and decodes the JSON response into out, if it isn't nil. Returns the status.*/
+func do(t *testing.T, ts *httptest.Server, method, path string, body any, out any) int {
+	var r io.Reader
+	if s, ok := body.(string); ok {
+		r = bytes.NewBufferString(s)
+	} else if body != nil {
+		data, err := json.Marshal(body)
+		assert.Nil(t, err)
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, ts.URL+path, r)
+	assert.Nil(t, err)
+	resp, err := ts.Client().Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	if out != nil {
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func TestHandler(t *testing.T) {
+	ts := httptest.NewServer(NewHandler(NewStore(t.TempDir())))
+	defer ts.Close()
+
+	var stats Stats
+	assert.Equal(t, http.StatusCreated, do(t, ts, "PUT", "/filters/users", CreateRequest{Kind: "striped", Size: 1 << 16, Hashes: 4, Shards: 16}, &stats))
+	assert.Equal(t, Stats{Name: "users", Kind: "striped", Size: 1 << 16, Hashes: 4, Shards: 16, Hasher: "xxhash64(seed=0)"}, stats)
+	assert.Equal(t, http.StatusCreated, do(t, ts, "PUT", "/filters/sessions", CreateRequest{Kind: "naive", N: 1000, P: 0.001}, &stats))
+	assert.Equal(t, "naive", stats.Kind)
+	var names namesResponse
+	assert.Equal(t, http.StatusOK, do(t, ts, "GET", "/filters", nil, &names))
+	assert.Equal(t, []string{"sessions", "users"}, names.Filters)
+
+	//Single keys, escaped in the path
+	assert.Equal(t, http.StatusNoContent, do(t, ts, "PUT", "/filters/users/keys/"+url.PathEscape("alice@example.com/home"), nil, nil))
+	var lookup LookupResponse
+	assert.Equal(t, http.StatusOK, do(t, ts, "GET", "/filters/users/keys/"+url.PathEscape("alice@example.com/home"), nil, &lookup))
+	assert.Equal(t, LookupResponse{Key: "alice@example.com/home", Present: true}, lookup)
+	assert.Equal(t, http.StatusOK, do(t, ts, "GET", "/filters/users/keys/bob", nil, &lookup))
+	assert.Equal(t, LookupResponse{Key: "bob", Present: false}, lookup)
+
+	//Batches
+	var inserted insertResponse
+	assert.Equal(t, http.StatusOK, do(t, ts, "POST", "/filters/users/insert", KeysRequest{Keys: []string{"bob", "carol"}}, &inserted))
+	assert.Equal(t, 2, inserted.Inserted)
+	var batch BatchLookupResponse
+	assert.Equal(t, http.StatusOK, do(t, ts, "POST", "/filters/users/lookup", KeysRequest{Keys: []string{"alice@example.com/home", "bob", "dave"}}, &batch))
+	assert.Equal(t, []bool{true, true, false}, batch.Present)
+	assert.Equal(t, http.StatusOK, do(t, ts, "GET", "/filters/users", nil, &stats))
+	assert.Equal(t, uint64(3), stats.EstimatedCount)
+
+	//Snapshot, change, then load the snapshot back
+	var snap snapshotResponse
+	assert.Equal(t, http.StatusOK, do(t, ts, "POST", "/filters/users/snapshot", nil, &snap))
+	assert.NotEmpty(t, snap.Path)
+	assert.Equal(t, http.StatusNoContent, do(t, ts, "PUT", "/filters/users/keys/erin", nil, nil))
+	assert.Equal(t, http.StatusOK, do(t, ts, "POST", "/filters/users/load", nil, &stats))
+	assert.Equal(t, uint64(3), stats.EstimatedCount)
+	assert.Equal(t, http.StatusOK, do(t, ts, "GET", "/filters/users/keys/erin", nil, &lookup))
+	assert.False(t, lookup.Present)
+
+	//A deleted filter can be loaded back from its snapshot
+	assert.Equal(t, http.StatusNoContent, do(t, ts, "DELETE", "/filters/users", nil, nil))
+	var e errorResponse
+	assert.Equal(t, http.StatusNotFound, do(t, ts, "GET", "/filters/users", nil, &e))
+	assert.NotEmpty(t, e.Error)
+	assert.Equal(t, http.StatusOK, do(t, ts, "POST", "/filters/users/load", nil, &stats))
+	assert.Equal(t, http.StatusOK, do(t, ts, "GET", "/filters/users/keys/bob", nil, &lookup))
+	assert.True(t, lookup.Present)
+}
+
+func TestHandlerErrors(t *testing.T) {
+	ts := httptest.NewServer(NewHandler(NewStore("")))
+	defer ts.Close()
+	assert.Equal(t, http.StatusCreated, do(t, ts, "PUT", "/filters/users", CreateRequest{Kind: "bloom", N: 1000}, &Stats{}))
+
+	for _, c := range []struct {
+		method, path string
+		body         any
+		status       int
+	}{
+		{"PUT", "/filters/users", CreateRequest{Kind: "bloom", N: 1000}, http.StatusConflict},
+		{"PUT", "/filters/other", CreateRequest{Kind: "cuckoo", N: 1000}, http.StatusBadRequest},
+		{"PUT", "/filters/other", CreateRequest{Kind: "quotient", N: 1000}, http.StatusBadRequest},
+		{"PUT", "/filters/other", CreateRequest{Kind: "bloom"}, http.StatusBadRequest},
+		{"PUT", "/filters/other", CreateRequest{Kind: "bloom", N: 1000, Size: 1024}, http.StatusBadRequest},
+		{"PUT", "/filters/other", CreateRequest{Kind: "striped", N: 1000, Shards: 4}, http.StatusBadRequest},
+		{"PUT", "/filters/other", CreateRequest{Kind: "bloom", Size: 1000, Hashes: 4}, http.StatusBadRequest},
+		{"PUT", "/filters/other", `{"kind": "bloom", "size": 1024}`, http.StatusBadRequest},
+		{"PUT", "/filters/other", CreateRequest{Kind: "bloom", Size: 1024, Hashes: -1}, http.StatusBadRequest},
+		{"PUT", "/filters/.hidden", CreateRequest{Kind: "bloom", N: 1000}, http.StatusBadRequest},
+		{"PUT", "/filters/other", `{"kind": "bloom", "n": 1000, "colour": "blue"}`, http.StatusBadRequest},
+		{"PUT", "/filters/other", `{"kind": "bloom", "n": 1000}{}`, http.StatusBadRequest},
+		{"POST", "/filters/users/insert", `{"keys": "alice"}`, http.StatusBadRequest},
+		{"POST", "/filters/users/lookup", `not json`, http.StatusBadRequest},
+		{"POST", "/filters/missing/insert", KeysRequest{Keys: []string{"alice"}}, http.StatusNotFound},
+		{"POST", "/filters/missing/lookup", KeysRequest{Keys: []string{"alice"}}, http.StatusNotFound},
+		{"GET", "/filters/missing/keys/alice", nil, http.StatusNotFound},
+		{"PUT", "/filters/missing/keys/alice", nil, http.StatusNotFound},
+		{"DELETE", "/filters/missing", nil, http.StatusNotFound},
+		{"POST", "/filters/users/snapshot", nil, http.StatusBadRequest},
+		{"POST", "/filters/users/load", nil, http.StatusBadRequest},
+	} {
+		var e errorResponse
+		assert.Equal(t, c.status, do(t, ts, c.method, c.path, c.body, &e), "%s %s", c.method, c.path)
+		assert.NotEmpty(t, e.Error, "%s %s", c.method, c.path)
+	}
+	assert.Equal(t, http.StatusMethodNotAllowed, do(t, ts, "POST", "/filters/users", nil, nil))
+}
+
+/*Filters beyond the store's MaxFilterBytes are refused before anything is allocated for them.*/
+func TestHandlerFilterTooLarge(t *testing.T) {
+	ts := httptest.NewServer(NewHandler(NewStore("")))
+	defer ts.Close()
+	for _, req := range []CreateRequest{
+		{Kind: "bloom", Size: 35184372088832, Hashes: 3},
+		{Kind: "naive", Size: 1 << 62, Hashes: 3},
+		{Kind: "striped", N: 100000000000000000, P: 0.01},
+		{Kind: "naivestriped", Size: 1 << 20, Hashes: 3, Shards: 1 << 40},
+	} {
+		var e errorResponse
+		assert.Equal(t, http.StatusBadRequest, do(t, ts, "PUT", "/filters/big", req, &e), "%+v", req)
+		assert.Contains(t, e.Error, "too large", "%+v", req)
+	}
+	var names namesResponse
+	assert.Equal(t, http.StatusOK, do(t, ts, "GET", "/filters", nil, &names))
+	assert.Empty(t, names.Filters)
+}
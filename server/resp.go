@@ -0,0 +1,530 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iamthebot/hyperbloom"
+)
+
+/*Limits on the commands a RESP client may send, which bound the memory a single command can use. A whole command may be as large as an HTTP request body.*/
+const (
+	maxRESPArgs    = 1 << 20
+	maxRESPBulkLen = maxBodyBytes
+	maxRESPInline  = 64 << 10
+)
+
+/*ErrRESPServerClosed is returned by RESPServer.Serve once the server has been shut down.*/
+var ErrRESPServerClosed = errors.New("RESP server closed")
+
+/*
+RESPServer serves a Store to Redis clients over RESP2, speaking the RedisBloom commands:
+
+	BF.RESERVE key error_rate capacity [NONSCALING]  create a StripedBloomFilter sized for capacity entries
+	BF.ADD key item                                  insert an item: 1 if it was new, 0 if it may have been present
+	BF.MADD key item [item ...]                      BF.ADD for several items, returning an array
+	BF.EXISTS key item                               1 if the item may be present, 0 if it isn't
+	BF.MEXISTS key item [item ...]                   BF.EXISTS for several items, returning an array
+	BF.INFO key [CAPACITY|SIZE|FILTERS|ITEMS|EXPANSION]
+
+as well as PING, ECHO, QUIT and COMMAND. As with RedisBloom, BF.ADD and BF.MADD create missing filters with the default capacity and error rate, and BF.EXISTS and BF.MEXISTS report items in missing filters as absent.
+Filters don't scale, so BF.RESERVE rejects EXPANSION. BF.INFO reports the number of entries the filter's hash count is optimal for as its capacity, and its estimated count as the number of items inserted.
+Filters of the other hosted variants (e.g. created over HTTP) are served too. Filters created by BF.RESERVE, BF.ADD and BF.MADD are limited to the store's MaxFilterBytes.
+A command that panics gets an error reply and is logged; the connection carries on.
+*/
+type RESPServer struct {
+	Store            *Store
+	DefaultCapacity  uint64      //Capacity of the filters created by BF.ADD and BF.MADD. 100 if 0.
+	DefaultErrorRate float64     //False positive rate of the filters created by BF.ADD and BF.MADD. 0.01 if 0.
+	ErrorLog         *log.Logger //Logs failed connections and commands. The log package's standard logger if nil.
+
+	mut       sync.Mutex
+	closed    bool
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup //Running connections
+}
+
+/*Serve accepts connections on ln and serves each on its own goroutine until the server is shut down, when it returns ErrRESPServerClosed.*/
+func (s *RESPServer) Serve(ln net.Listener) error {
+	s.mut.Lock()
+	if s.closed {
+		s.mut.Unlock()
+		ln.Close()
+		return ErrRESPServerClosed
+	}
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
+		s.conns = make(map[net.Conn]struct{})
+	}
+	s.listeners[ln] = struct{}{}
+	s.mut.Unlock()
+	defer func() {
+		s.mut.Lock()
+		delete(s.listeners, ln)
+		s.mut.Unlock()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.mut.Lock()
+			closed := s.closed
+			s.mut.Unlock()
+			if closed {
+				return ErrRESPServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		s.mut.Lock()
+		if s.closed {
+			s.mut.Unlock()
+			conn.Close()
+			return ErrRESPServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mut.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+/*
+Shutdown stops the server: it closes every listener, then lets each connection finish the commands it has already received before closing it. If ctx is done first the remaining connections are closed at once and ctx's error is returned.
+*/
+func (s *RESPServer) Shutdown(ctx context.Context) error {
+	s.mut.Lock()
+	s.closed = true
+	for ln := range s.listeners {
+		ln.Close()
+	}
+	for conn := range s.conns {
+		conn.SetReadDeadline(time.Now()) //Wakes connections waiting for their next command
+	}
+	s.mut.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mut.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mut.Unlock()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (s *RESPServer) logf(format string, args ...any) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+/*A protocol error, after which the connection can't be trusted to be in step and is closed.*/
+type respProtocolError string
+
+func (e respProtocolError) Error() string {
+	return "Protocol error: " + string(e)
+}
+
+/*One client connection.*/
+type respConn struct {
+	server *RESPServer
+	conn   net.Conn
+	r      *bufio.Reader
+	w      *bufio.Writer
+}
+
+func (s *RESPServer) serveConn(conn net.Conn) {
+	defer func() {
+		s.mut.Lock()
+		delete(s.conns, conn)
+		s.mut.Unlock()
+		conn.Close()
+		s.wg.Done()
+	}()
+	c := &respConn{server: s, conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	for {
+		args, err := c.readCommand()
+		if err != nil {
+			var perr respProtocolError
+			if errors.As(err, &perr) {
+				c.writeError(perr.Error())
+				c.w.Flush()
+			} else if err != io.EOF && !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.ErrUnexpectedEOF) && !isTimeout(err) {
+				s.logf("hyperbloom: RESP connection from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := c.dispatch(args)
+		//Replies to pipelined commands are flushed together
+		if quit || c.r.Buffered() == 0 {
+			if err := c.w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+/*Reads a line, without its \r\n.*/
+func (c *respConn) readLine() ([]byte, error) {
+	line, err := c.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, respProtocolError("line too long")
+	} else if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(line[:len(line)-1], []byte{'\r'}), nil
+}
+
+/*Reads the length following a '*' or '$' prefix, which must be between -1 and max.*/
+func (c *respConn) readLength(prefix byte, max int) (int, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return 0, err
+	}
+	if len(line) == 0 || line[0] != prefix {
+		return 0, respProtocolError(fmt.Sprintf("expected '%c', got '%s'", prefix, line))
+	}
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n < -1 || n > max {
+		return 0, respProtocolError(fmt.Sprintf("invalid length '%s'", line[1:]))
+	}
+	return n, nil
+}
+
+/*Reads a command, sent either as an array of bulk strings (as clients do) or inline as a line of space separated words (as typed into telnet).*/
+func (c *respConn) readCommand() ([]string, error) {
+	b, err := c.r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if b[0] != '*' {
+		var line []byte
+		for {
+			chunk, err := c.r.ReadSlice('\n')
+			line = append(line, chunk...)
+			if len(line) > maxRESPInline {
+				return nil, respProtocolError("too big inline request")
+			} else if err == nil {
+				return strings.Fields(string(line)), nil
+			} else if err != bufio.ErrBufferFull {
+				return nil, err
+			}
+		}
+	}
+	n, err := c.readLength('*', maxRESPArgs)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, min(max(n, 0), 1024))
+	total := 0
+	for i := 0; i < n; i++ {
+		size, err := c.readLength('$', maxRESPBulkLen)
+		if err != nil {
+			return nil, err
+		} else if size < 0 {
+			return nil, respProtocolError("invalid bulk length")
+		} else if total += size; total > maxRESPBulkLen {
+			return nil, respProtocolError("too big request")
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		} else if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, respProtocolError("bulk string not terminated by CRLF")
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func (c *respConn) writeSimple(s string) {
+	c.w.WriteString("+" + s + "\r\n")
+}
+
+func (c *respConn) writeError(msg string) {
+	if !strings.HasPrefix(msg, "ERR ") {
+		msg = "ERR " + msg
+	}
+	c.w.WriteString("-" + strings.NewReplacer("\r", " ", "\n", " ").Replace(msg) + "\r\n")
+}
+
+func (c *respConn) writeInt(n int64) {
+	c.w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func (c *respConn) writeBool(b bool) {
+	if b {
+		c.writeInt(1)
+	} else {
+		c.writeInt(0)
+	}
+}
+
+func (c *respConn) writeBulk(s string) {
+	c.w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func (c *respConn) writeNil() {
+	c.w.WriteString("$-1\r\n")
+}
+
+func (c *respConn) writeArrayLen(n int) {
+	c.w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+func (c *respConn) writeBools(results []bool) {
+	c.writeArrayLen(len(results))
+	for _, b := range results {
+		c.writeBool(b)
+	}
+}
+
+/*Runs a command and writes its reply. Returns true if the connection should be closed.*/
+func (c *respConn) dispatch(args []string) (quit bool) {
+	name := strings.ToLower(args[0])
+	defer func() {
+		if r := recover(); r != nil {
+			c.server.logf("hyperbloom: RESP command %s from %s panicked: %v", name, c.conn.RemoteAddr(), r)
+			c.writeError(fmt.Sprintf("internal error running '%s'", name))
+			quit = false
+		}
+	}()
+	cmd, ok := respCommands[name]
+	if !ok {
+		c.writeError(fmt.Sprintf("unknown command '%s'", args[0]))
+		return false
+	}
+	if len(args) < cmd.minArgs || (cmd.maxArgs >= 0 && len(args) > cmd.maxArgs) {
+		c.writeError(fmt.Sprintf("wrong number of arguments for '%s' command", name))
+		return false
+	}
+	return cmd.run(c, args[1:])
+}
+
+type respCommand struct {
+	minArgs, maxArgs int //Including the command name. No maximum if maxArgs < 0.
+	run              func(c *respConn, args []string) bool
+}
+
+var respCommands map[string]respCommand
+
+func init() {
+	respCommands = map[string]respCommand{
+		"ping":       {1, 2, (*respConn).ping},
+		"echo":       {2, 2, (*respConn).echo},
+		"quit":       {1, 1, (*respConn).quit},
+		"command":    {1, -1, (*respConn).command},
+		"bf.reserve": {4, 6, (*respConn).bfReserve},
+		"bf.add":     {3, 3, func(c *respConn, args []string) bool { return c.bfAdd(args, false) }},
+		"bf.madd":    {3, -1, func(c *respConn, args []string) bool { return c.bfAdd(args, true) }},
+		"bf.exists":  {3, 3, func(c *respConn, args []string) bool { return c.bfExists(args, false) }},
+		"bf.mexists": {3, -1, func(c *respConn, args []string) bool { return c.bfExists(args, true) }},
+		"bf.info":    {2, 3, (*respConn).bfInfo},
+	}
+}
+
+func (c *respConn) ping(args []string) bool {
+	if len(args) == 1 {
+		c.writeBulk(args[0])
+	} else {
+		c.writeSimple("PONG")
+	}
+	return false
+}
+
+func (c *respConn) echo(args []string) bool {
+	c.writeBulk(args[0])
+	return false
+}
+
+func (c *respConn) quit(args []string) bool {
+	c.writeSimple("OK")
+	return true
+}
+
+/*Clients such as redis-cli ask for command documentation when they connect; there is none.*/
+func (c *respConn) command(args []string) bool {
+	c.writeArrayLen(0)
+	return false
+}
+
+func (c *respConn) bfReserve(args []string) bool {
+	name := args[0]
+	p, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		c.writeError("bad error rate")
+		return false
+	} else if !(p > 0 && p < 1) {
+		c.writeError("(0 < error rate range < 1)")
+		return false
+	}
+	n, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		c.writeError("bad capacity")
+		return false
+	} else if n == 0 {
+		c.writeError("(capacity should be larger than 0)")
+		return false
+	}
+	for _, opt := range args[3:] {
+		if strings.EqualFold(opt, "EXPANSION") {
+			c.writeError("EXPANSION is not supported: filters don't scale")
+			return false
+		} else if !strings.EqualFold(opt, "NONSCALING") {
+			c.writeError(fmt.Sprintf("unknown option '%s'", opt))
+			return false
+		}
+	}
+	err = c.server.Store.CreateWithEstimates(name, hyperbloom.KindStriped, n, p)
+	if err == ErrExists {
+		c.writeError("item exists")
+	} else if err != nil {
+		c.writeError(err.Error())
+	} else {
+		c.writeSimple("OK")
+	}
+	return false
+}
+
+/*Handles BF.ADD and BF.MADD (multi), creating the filter if it doesn't exist.*/
+func (c *respConn) bfAdd(args []string, multi bool) bool {
+	name, keys := args[0], args[1:]
+	seen, err := c.server.Store.TestAndInsert(name, keys...)
+	if err == ErrNotFound {
+		n, p := c.server.DefaultCapacity, c.server.DefaultErrorRate
+		if n == 0 {
+			n = 100
+		}
+		if p == 0 {
+			p = 0.01
+		}
+		err = c.server.Store.CreateWithEstimates(name, hyperbloom.KindStriped, n, p)
+		if err == nil || err == ErrExists { //Another client may have created it first
+			seen, err = c.server.Store.TestAndInsert(name, keys...)
+		}
+	}
+	if err != nil {
+		c.writeError(err.Error())
+		return false
+	}
+	for i := range seen {
+		seen[i] = !seen[i]
+	}
+	if multi {
+		c.writeBools(seen)
+	} else {
+		c.writeBool(seen[0])
+	}
+	return false
+}
+
+/*Handles BF.EXISTS and BF.MEXISTS (multi). Items in missing filters are absent.*/
+func (c *respConn) bfExists(args []string, multi bool) bool {
+	name, keys := args[0], args[1:]
+	results, err := c.server.Store.Lookup(name, keys...)
+	if err == ErrNotFound {
+		results, err = make([]bool, len(keys)), nil
+	}
+	if err != nil {
+		c.writeError(err.Error())
+		return false
+	}
+	if multi {
+		c.writeBools(results)
+	} else {
+		c.writeBool(results[0])
+	}
+	return false
+}
+
+/*
+Handles BF.INFO, which replies with every field as name, value pairs, or with the one field asked for.
+The size reported is that of the filter's vector in bytes, and the expansion rate is always nil since filters don't scale.
+*/
+func (c *respConn) bfInfo(args []string) bool {
+	stats, err := c.server.Store.Stats(args[0])
+	if err == ErrNotFound {
+		c.writeError("not found")
+		return false
+	} else if err != nil {
+		c.writeError(err.Error())
+		return false
+	}
+	vectorBytes := stats.Size / 8
+	if stats.Kind == hyperbloom.KindNaive.String() || stats.Kind == hyperbloom.KindNaiveStriped.String() {
+		vectorBytes = stats.Size
+	}
+	fields := []struct {
+		option, name string
+		value        int64
+	}{
+		{"CAPACITY", "Capacity", int64(math.Round(float64(stats.Size) * math.Ln2 / float64(stats.Hashes)))},
+		{"SIZE", "Size", int64(vectorBytes)},
+		{"FILTERS", "Number of filters", 1},
+		{"ITEMS", "Number of items inserted", int64(min(stats.EstimatedCount, math.MaxInt64))},
+		{"EXPANSION", "Expansion rate", -1},
+	}
+	write := func(value int64) {
+		if value < 0 {
+			c.writeNil()
+		} else {
+			c.writeInt(value)
+		}
+	}
+	if len(args) == 2 {
+		for _, f := range fields {
+			if strings.EqualFold(args[1], f.option) {
+				c.writeArrayLen(1)
+				write(f.value)
+				return false
+			}
+		}
+		c.writeError("Invalid information value")
+		return false
+	}
+	c.writeArrayLen(2 * len(fields))
+	for _, f := range fields {
+		c.writeSimple(f.name)
+		write(f.value)
+	}
+	return false
+}
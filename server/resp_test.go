@@ -0,0 +1,301 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*Starts a RESP server for s on a loopback port and returns its address. The server is shut down when the test ends.*/
+func startRESP(t *testing.T, s *Store) (*RESPServer, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	srv := &RESPServer{Store: s, ErrorLog: log.New(io.Discard, "", 0)}
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+	t.Cleanup(func() {
+		srv.Shutdown(context.Background())
+		assert.Equal(t, ErrRESPServerClosed, <-done)
+	})
+	return srv, ln.Addr().String()
+}
+
+/*A minimal RESP2 client.*/
+type respClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialRESP(t *testing.T, addr string) *respClient {
+	conn, err := net.Dial("tcp", addr)
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	return &respClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func encodeCommand(args ...string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return b.Bytes()
+}
+
+func (c *respClient) send(args ...string) {
+	_, err := c.conn.Write(encodeCommand(args...))
+	assert.Nil(c.t, err)
+}
+
+/*Reads a reply: a string for simple strings, an error for errors, an int64, nil, or a []any.*/
+func (c *respClient) read() any {
+	line, err := c.r.ReadString('\n')
+	if !assert.Nil(c.t, err) || !assert.True(c.t, len(line) >= 3) {
+		return nil
+	}
+	line = line[:len(line)-2]
+	switch line[0] {
+	case '+':
+		return line[1:]
+	case '-':
+		return fmt.Errorf("%s", line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		assert.Nil(c.t, err)
+		return n
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		assert.Nil(c.t, err)
+		if n < 0 {
+			return nil
+		}
+		buf := make([]byte, n+2)
+		_, err = io.ReadFull(c.r, buf)
+		assert.Nil(c.t, err)
+		return string(buf[:n])
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		assert.Nil(c.t, err)
+		if n < 0 {
+			return nil
+		}
+		array := make([]any, n)
+		for i := range array {
+			array[i] = c.read()
+		}
+		return array
+	}
+	c.t.Fatalf("bad reply %q", line)
+	return nil
+}
+
+func (c *respClient) do(args ...string) any {
+	c.send(args...)
+	return c.read()
+}
+
+/*Asserts that reply is an error starting with prefix.*/
+func assertRESPError(t *testing.T, prefix string, reply any) {
+	err, ok := reply.(error)
+	if assert.True(t, ok, "%v isn't an error", reply) {
+		assert.Regexp(t, "^"+prefix, err.Error())
+	}
+}
+
+func TestRESPServer(t *testing.T) {
+	_, addr := startRESP(t, NewStore(""))
+	c := dialRESP(t, addr)
+
+	assert.Equal(t, "PONG", c.do("PING"))
+	assert.Equal(t, "hello", c.do("ping", "hello"))
+	assert.Equal(t, "with\r\nnewline", c.do("ECHO", "with\r\nnewline"))
+	assert.Equal(t, []any{}, c.do("COMMAND", "DOCS"))
+
+	assert.Equal(t, "OK", c.do("BF.RESERVE", "users", "0.001", "1000"))
+	assertRESPError(t, "ERR item exists", c.do("BF.RESERVE", "users", "0.01", "100"))
+	assert.Equal(t, int64(1), c.do("BF.ADD", "users", "alice"))
+	assert.Equal(t, int64(0), c.do("BF.ADD", "users", "alice"))
+	assert.Equal(t, []any{int64(1), int64(0), int64(1)}, c.do("BF.MADD", "users", "bob", "alice", "carol"))
+	assert.Equal(t, int64(1), c.do("BF.EXISTS", "users", "bob"))
+	assert.Equal(t, int64(0), c.do("bf.exists", "users", "dave"))
+	assert.Equal(t, []any{int64(1), int64(0), int64(1)}, c.do("BF.MEXISTS", "users", "alice", "dave", "carol"))
+
+	//Capacity, size in bytes and count of the filter, which is sized for 1000 entries
+	info, ok := c.do("BF.INFO", "users").([]any)
+	if assert.True(t, ok) && assert.Len(t, info, 10) {
+		assert.Equal(t, []any{"Capacity", "Size", "Number of filters", "Number of items inserted", "Expansion rate"},
+			[]any{info[0], info[2], info[4], info[6], info[8]})
+		assert.InDelta(t, 1000, info[1], 100)
+		assert.Greater(t, info[3], int64(0))
+		assert.Equal(t, int64(1), info[5])
+		assert.Equal(t, int64(3), info[7])
+		assert.Nil(t, info[9])
+	}
+	assert.Equal(t, []any{int64(3)}, c.do("BF.INFO", "users", "items"))
+	assert.Equal(t, []any{nil}, c.do("BF.INFO", "users", "EXPANSION"))
+	assertRESPError(t, "ERR Invalid information value", c.do("BF.INFO", "users", "colour"))
+	assertRESPError(t, "ERR not found", c.do("BF.INFO", "missing"))
+
+	//Adding to a missing filter creates it, checking a missing filter doesn't
+	assert.Equal(t, int64(0), c.do("BF.EXISTS", "sessions", "s1"))
+	assert.Equal(t, []any{int64(0), int64(0)}, c.do("BF.MEXISTS", "sessions", "s1", "s2"))
+	assertRESPError(t, "ERR not found", c.do("BF.INFO", "sessions"))
+	assert.Equal(t, []any{int64(1), int64(1)}, c.do("BF.MADD", "sessions", "s1", "s2"))
+	capacity, ok := c.do("BF.INFO", "sessions", "CAPACITY").([]any)
+	if assert.True(t, ok) && assert.Len(t, capacity, 1) {
+		assert.InDelta(t, 100, capacity[0], 10)
+	}
+	assert.Equal(t, int64(1), c.do("BF.EXISTS", "sessions", "s1"))
+
+	assert.Equal(t, "OK", c.do("QUIT"))
+	_, err := c.r.ReadByte()
+	assert.Equal(t, io.EOF, err)
+}
+
+/*Without a size limit a huge BF.RESERVE panics allocating the filter, which fails the command but not the connection or the server.*/
+func TestRESPServerRecovers(t *testing.T) {
+	s := NewStore("")
+	s.MaxFilterBytes = 0
+	_, addr := startRESP(t, s)
+	c := dialRESP(t, addr)
+	assertRESPError(t, "ERR internal error running 'bf.reserve'", c.do("BF.RESERVE", "users", "0.01", "100000000000000000"))
+	assert.Equal(t, "PONG", c.do("PING"))
+	assert.Equal(t, "OK", c.do("BF.RESERVE", "users", "0.01", "100"))
+	assert.Equal(t, "PONG", dialRESP(t, addr).do("PING"))
+}
+
+func TestRESPServerErrors(t *testing.T) {
+	s := NewStore("")
+	_, addr := startRESP(t, s)
+	c := dialRESP(t, addr)
+
+	for _, cmd := range [][]string{
+		{"BF.RESERVE", "users", "0.01"},
+		{"BF.RESERVE", "users", "0.01", "100", "NONSCALING", "EXPANSION", "2"},
+		{"BF.ADD", "users"},
+		{"BF.ADD", "users", "alice", "bob"},
+		{"BF.MADD", "users"},
+		{"BF.EXISTS", "users"},
+		{"BF.MEXISTS", "users"},
+		{"BF.INFO"},
+		{"ECHO"},
+	} {
+		assertRESPError(t, "ERR wrong number of arguments", c.do(cmd...))
+	}
+	assertRESPError(t, "ERR unknown command 'SET'", c.do("SET", "a", "b"))
+	assertRESPError(t, "ERR bad error rate", c.do("BF.RESERVE", "users", "low", "100"))
+	assertRESPError(t, `ERR \(0 < error rate range < 1\)`, c.do("BF.RESERVE", "users", "1", "100"))
+	assertRESPError(t, "ERR bad capacity", c.do("BF.RESERVE", "users", "0.01", "-5"))
+	assertRESPError(t, `ERR \(capacity should be larger than 0\)`, c.do("BF.RESERVE", "users", "0.01", "0"))
+	assertRESPError(t, "ERR EXPANSION is not supported", c.do("BF.RESERVE", "users", "0.01", "100", "EXPANSION", "2"))
+	assertRESPError(t, "ERR unknown option", c.do("BF.RESERVE", "users", "0.01", "100", "BUCKETS"))
+	assertRESPError(t, "ERR Invalid filter name", c.do("BF.RESERVE", ".hidden", "0.01", "100"))
+	assertRESPError(t, "ERR Invalid filter name", c.do("BF.ADD", "a/b", "alice"))
+	assertRESPError(t, "ERR Filter is too large", c.do("BF.RESERVE", "users", "0.01", "100000000000000000"))
+	assert.Equal(t, "OK", c.do("BF.RESERVE", "users", "0.01", "100", "nonscaling"))
+	assert.Equal(t, []string{"users"}, s.Names())
+
+	//Protocol errors close the connection
+	for _, raw := range []string{
+		"*2\r\n$4\r\nPING\r\n:1\r\n",
+		"*1\r\n$4\r\nPINGXX",
+		"*1\r\n$-1\r\n",
+		"*x\r\n",
+		"*1\r\n$99999999999\r\n",
+	} {
+		c := dialRESP(t, addr)
+		_, err := c.conn.Write([]byte(raw))
+		assert.Nil(t, err)
+		assertRESPError(t, "ERR Protocol error", c.read())
+		_, err = c.r.ReadByte()
+		assert.Equal(t, io.EOF, err, "%q", raw)
+	}
+}
+
+func TestRESPServerInlineAndPipelined(t *testing.T) {
+	_, addr := startRESP(t, NewStore(""))
+	c := dialRESP(t, addr)
+
+	//Inline commands, as typed into telnet
+	_, err := c.conn.Write([]byte("PING\r\nBF.ADD users alice\r\n\r\nbf.exists users alice\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "PONG", c.read())
+	assert.Equal(t, int64(1), c.read())
+	assert.Equal(t, int64(1), c.read())
+
+	//Many commands in one write, with their replies in order
+	assert.Equal(t, "OK", c.do("BF.RESERVE", "pipelined", "0.001", "10000"))
+	var batch []byte
+	for i := 0; i < 1000; i++ {
+		batch = append(batch, encodeCommand("BF.ADD", "pipelined", strconv.Itoa(i))...)
+		batch = append(batch, encodeCommand("BF.EXISTS", "pipelined", strconv.Itoa(i))...)
+	}
+	_, err = c.conn.Write(batch)
+	assert.Nil(t, err)
+	added := 0
+	for i := 0; i < 1000; i++ {
+		if c.read() == int64(1) {
+			added++
+		}
+		assert.Equal(t, int64(1), c.read())
+	}
+	assert.InDelta(t, 1000, added, 5)
+}
+
+func TestRESPServerConcurrent(t *testing.T) {
+	s := NewStore("")
+	_, addr := startRESP(t, s)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			c := dialRESP(t, addr)
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("%d-%d", w, i)
+				c.do("BF.ADD", "shared", key)
+				assert.Equal(t, int64(1), c.do("BF.EXISTS", "shared", key))
+			}
+		}(w)
+	}
+	wg.Wait()
+	assert.Equal(t, []string{"shared"}, s.Names())
+	stats, err := s.Stats("shared")
+	assert.Nil(t, err)
+	assert.Equal(t, "striped", stats.Kind)
+}
+
+func TestRESPServerShutdown(t *testing.T) {
+	srv, addr := startRESP(t, NewStore(""))
+	idle := dialRESP(t, addr)
+	assert.Equal(t, "PONG", idle.do("PING"))
+
+	//A connection part way through sending a command is closed too
+	busy := dialRESP(t, addr)
+	_, err := busy.conn.Write([]byte("*2\r\n$4\r\nPING\r\n"))
+	assert.Nil(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	assert.Nil(t, srv.Shutdown(context.Background()))
+	_, err = idle.r.ReadByte()
+	assert.Equal(t, io.EOF, err)
+	_, err = busy.r.ReadByte()
+	assert.Equal(t, io.EOF, err)
+	_, err = net.Dial("tcp", addr)
+	assert.NotNil(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	assert.Equal(t, ErrRESPServerClosed, srv.Serve(ln))
+}
@@ -0,0 +1,435 @@
+/*
+Package server hosts named hyperbloom filters for other processes to share. Store holds the filters, NewHandler serves them over HTTP with JSON bodies and RESPServer serves them to Redis clients; cmd/hyperbloomd runs both as a daemon.
+Only the four core variants are hosted: BloomFilter, StripedBloomFilter, NaiveBloomFilter and NaiveStripedBloomFilter.
+*/
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/iamthebot/hyperbloom"
+)
+
+/*Extension of the snapshot files written by Snapshot, in the package's serialization format.*/
+const snapshotExt = ".bloom"
+
+/*Default for Store.MaxFilterBytes.*/
+const DefaultMaxFilterBytes = 1 << 30
+
+var (
+	ErrNotFound = errors.New("No such filter")
+	ErrExists   = errors.New("Filter already exists")
+	ErrNoDir    = errors.New("Server has no snapshot directory")
+	ErrTooLarge = errors.New("Filter is too large")
+)
+
+/*Filter names double as snapshot file names, so they are kept to characters that are safe in both URLs and paths.*/
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]{0,127}$`)
+
+/*The methods shared by the hosted variants.*/
+type hostedFilter interface {
+	hyperbloom.Filter
+	Spec() hyperbloom.Spec
+	PopCount() uint64
+	FillRatio() float64
+	EstimatedCount() uint64
+	EstimatedFalsePositiveRate() float64
+	Reset()
+}
+
+/*Stats describes a hosted filter.*/
+type Stats struct {
+	Name                       string  `json:"name"`
+	Kind                       string  `json:"kind"`
+	Size                       uint64  `json:"size"`
+	Hashes                     int     `json:"hashes"`
+	Shards                     uint64  `json:"shards,omitempty"`
+	Hasher                     string  `json:"hasher"`
+	PopCount                   uint64  `json:"pop_count"`
+	FillRatio                  float64 `json:"fill_ratio"`
+	EstimatedCount             uint64  `json:"estimated_count"`
+	EstimatedFalsePositiveRate float64 `json:"estimated_false_positive_rate"`
+}
+
+/*
+Store is a set of named filters, safe for concurrent use. Inserts and lookups only take the store's lock to find the filter, then the filter's own locks.
+Filters can be saved to and loaded from files named after them in the store's snapshot directory.
+Filters created or loaded in the store are limited to MaxFilterBytes, which should be set before the store is used.
+*/
+type Store struct {
+	MaxFilterBytes uint64 //Largest filter Create, CreateWithEstimates, CreateFrom and Replace (and so Load and LoadAll) accept, in bytes. No limit if 0.
+
+	dir     string //Snapshot directory, "" if snapshots are disabled
+	mut     sync.RWMutex
+	filters map[string]hostedFilter
+}
+
+/*NewStore returns an empty store keeping its snapshots in dir, with a MaxFilterBytes of DefaultMaxFilterBytes. Snapshot and Load return ErrNoDir if dir is "".*/
+func NewStore(dir string) *Store {
+	return &Store{MaxFilterBytes: DefaultMaxFilterBytes, dir: dir, filters: make(map[string]hostedFilter)}
+}
+
+func checkName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("Invalid filter name %q: use up to 128 letters, digits, '_', '-' and '.', not starting with '.'", name)
+	}
+	return nil
+}
+
+func checkKind(kind hyperbloom.Kind) error {
+	switch kind {
+	case hyperbloom.KindBloom, hyperbloom.KindStriped, hyperbloom.KindNaive, hyperbloom.KindNaiveStriped:
+		return nil
+	}
+	return fmt.Errorf("Can't host a %s filter, only bloom, striped, naive and naivestriped filters", kind)
+}
+
+/*
+Returns ErrTooLarge if a filter of the given kind, size and shard count would take more than MaxFilterBytes: a bit per bucket (a byte for the naive kinds), plus a lock per shard.
+*/
+func (s *Store) checkSize(kind hyperbloom.Kind, size, shards uint64) error {
+	if s.MaxFilterBytes == 0 {
+		return nil
+	}
+	n := size / 8
+	if kind == hyperbloom.KindNaive || kind == hyperbloom.KindNaiveStriped {
+		n = size
+	}
+	if shards > s.MaxFilterBytes/16 || n > s.MaxFilterBytes-shards*16 {
+		return fmt.Errorf("%w: a %s filter of size %d takes more than the store's limit of %d bytes", ErrTooLarge, kind, size, s.MaxFilterBytes)
+	}
+	return nil
+}
+
+/*Adds f under name unless the name is taken.*/
+func (s *Store) add(name string, f hyperbloom.Filter) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if _, ok := s.filters[name]; ok {
+		return ErrExists
+	}
+	s.filters[name] = f.(hostedFilter)
+	return nil
+}
+
+/*Create adds an empty filter built by hyperbloom.NewFilter from spec. Returns ErrExists if the name is taken, and ErrTooLarge if the filter would exceed MaxFilterBytes.*/
+func (s *Store) Create(name string, spec hyperbloom.Spec) error {
+	if err := checkName(name); err != nil {
+		return err
+	} else if err := checkKind(spec.Kind); err != nil {
+		return err
+	} else if err := s.checkSize(spec.Kind, spec.Size, spec.Shards); err != nil {
+		return err
+	}
+	f, err := hyperbloom.NewFilter(spec)
+	if err != nil {
+		return err
+	}
+	return s.add(name, f)
+}
+
+/*
+CreateWithEstimates adds an empty filter of the given kind sized for n entries at a false positive rate p (see hyperbloom.NewFilterWithEstimates). Returns ErrExists if the name is taken.
+The size hyperbloom.EstimateParameters picks is checked against MaxFilterBytes before the filter is allocated.
+*/
+func (s *Store) CreateWithEstimates(name string, kind hyperbloom.Kind, n uint64, p float64) error {
+	if err := checkName(name); err != nil {
+		return err
+	} else if err := checkKind(kind); err != nil {
+		return err
+	}
+	size, _, _, err := hyperbloom.EstimateParameters(n, p)
+	if err != nil {
+		return err
+	} else if err := s.checkSize(kind, size, 0); err != nil {
+		return err
+	}
+	f, _, err := hyperbloom.NewFilterWithEstimates(kind, n, p)
+	if err != nil {
+		return err
+	}
+	return s.add(name, f)
+}
+
+/*CreateFrom adds an empty filter described by req (see CreateRequest), with Create or CreateWithEstimates. P defaults to 0.01.*/
+func (s *Store) CreateFrom(name string, req CreateRequest) error {
+	kind, err := hyperbloom.ParseKind(req.Kind)
+	if err != nil {
+		return err
+	}
+	switch {
+	case req.N != 0 && req.Size != 0:
+		return errors.New("Set either n or size, not both")
+	case req.N != 0 && req.Shards != 0:
+		return errors.New("Shards can only be set with size")
+	case req.Size != 0 && req.Hashes < 1:
+		return errors.New("Set hashes (at least 1) with size")
+	case req.N != 0:
+		p := req.P
+		if p == 0 {
+			p = 0.01
+		}
+		return s.CreateWithEstimates(name, kind, req.N, p)
+	case req.Size != 0:
+		return s.Create(name, hyperbloom.Spec{Kind: kind, Size: req.Size, Hashes: req.Hashes, Shards: req.Shards})
+	}
+	return errors.New("Set n (and p) or size and hashes")
+}
+
+/*Delete removes a filter from the store (but not its snapshot).*/
+func (s *Store) Delete(name string) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if _, ok := s.filters[name]; !ok {
+		return ErrNotFound
+	}
+	delete(s.filters, name)
+	return nil
+}
+
+/*Names returns the names of every filter in the store, sorted.*/
+func (s *Store) Names() []string {
+	s.mut.RLock()
+	names := make([]string, 0, len(s.filters))
+	for name := range s.filters {
+		names = append(names, name)
+	}
+	s.mut.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+func (s *Store) get(name string) (hostedFilter, error) {
+	s.mut.RLock()
+	f, ok := s.filters[name]
+	s.mut.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return f, nil
+}
+
+/*Insert inserts keys into the named filter as one batch.*/
+func (s *Store) Insert(name string, keys ...string) error {
+	f, err := s.get(name)
+	if err != nil {
+		return err
+	}
+	return f.InsertBatch(keys)
+}
+
+/*Lookup looks keys up in the named filter as one batch, reporting whether each may be present.*/
+func (s *Store) Lookup(name string, keys ...string) ([]bool, error) {
+	f, err := s.get(name)
+	if err != nil {
+		return nil, err
+	}
+	return f.LookupBatch(keys)
+}
+
+/*TestAndInsert inserts keys into the named filter one at a time, reporting whether each may already have been present (see hyperbloom.Filter).*/
+func (s *Store) TestAndInsert(name string, keys ...string) ([]bool, error) {
+	f, err := s.get(name)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]bool, len(keys))
+	for i, key := range keys {
+		if results[i], err = f.TestAndInsert(key); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+/*Stats describes the named filter.*/
+func (s *Store) Stats(name string) (Stats, error) {
+	f, err := s.get(name)
+	if err != nil {
+		return Stats{}, err
+	}
+	spec := f.Spec()
+	return Stats{
+		Name:                       name,
+		Kind:                       spec.Kind.String(),
+		Size:                       spec.Size,
+		Hashes:                     spec.Hashes,
+		Shards:                     spec.Shards,
+		Hasher:                     fmt.Sprintf("%s(seed=%d)", spec.Hasher.ID(), spec.Hasher.Seed()),
+		PopCount:                   f.PopCount(),
+		FillRatio:                  f.FillRatio(),
+		EstimatedCount:             f.EstimatedCount(),
+		EstimatedFalsePositiveRate: f.EstimatedFalsePositiveRate(),
+	}, nil
+}
+
+/*Spec returns the parameters of the named filter (see hyperbloom.NewFilter).*/
+func (s *Store) Spec(name string) (hyperbloom.Spec, error) {
+	f, err := s.get(name)
+	if err != nil {
+		return hyperbloom.Spec{}, err
+	}
+	return f.Spec(), nil
+}
+
+/*Reset empties the named filter, keeping its parameters.*/
+func (s *Store) Reset(name string) error {
+	f, err := s.get(name)
+	if err != nil {
+		return err
+	}
+	f.Reset()
+	return nil
+}
+
+/*Returns the path of a filter's snapshot.*/
+func (s *Store) snapshotPath(name string) (string, error) {
+	if s.dir == "" {
+		return "", ErrNoDir
+	} else if err := checkName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, name+snapshotExt), nil
+}
+
+/*
+Snapshot saves the named filter to its file in the snapshot directory, replacing any earlier snapshot atomically. Inserts may continue while the filter is written; the striped variants may capture them in some shards but not others.
+Returns the path written.
+*/
+func (s *Store) Snapshot(name string) (string, error) {
+	path, err := s.snapshotPath(name)
+	if err != nil {
+		return "", err
+	}
+	f, err := s.get(name)
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(s.dir, "."+name+".*")
+	if err != nil {
+		return "", err
+	}
+	bw := bufio.NewWriter(tmp)
+	_, err = f.WriteTo(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return path, nil
+}
+
+/*SnapshotAll saves every filter in the store. It carries on past failures and returns the first error.*/
+func (s *Store) SnapshotAll() error {
+	var first error
+	for _, name := range s.Names() {
+		if _, err := s.Snapshot(name); err != nil && first == nil {
+			first = fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return first
+}
+
+/*Export returns the named filter in the package's serialization format.*/
+func (s *Store) Export(name string) ([]byte, error) {
+	f, err := s.get(name)
+	if err != nil {
+		return nil, err
+	}
+	return f.MarshalBinary()
+}
+
+/*Merge merges a serialized filter into the named filter (see hyperbloom.Filter's ReadFrom), which must have the same size, hash count and hasher.*/
+func (s *Store) Merge(name string, data []byte) error {
+	f, err := s.get(name)
+	if err != nil {
+		return err
+	}
+	_, err = f.ReadFrom(bytes.NewReader(data))
+	return err
+}
+
+/*Replace replaces the named filter with a serialized one (or adds it). The filter keeps the variant, parameters and hasher it was serialized with. Returns ErrTooLarge if it would exceed MaxFilterBytes.*/
+func (s *Store) Replace(name string, data []byte) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	spec, err := hyperbloom.ParseSpec(data)
+	if err != nil {
+		return err
+	} else if err := checkKind(spec.Kind); err != nil {
+		return err
+	} else if err := s.checkSize(spec.Kind, spec.Size, spec.Shards); err != nil {
+		return err
+	}
+	f, err := hyperbloom.UnmarshalFilter(data)
+	if err != nil {
+		return err
+	}
+	s.mut.Lock()
+	s.filters[name] = f.(hostedFilter)
+	s.mut.Unlock()
+	return nil
+}
+
+/*Load reads the named filter's snapshot, replacing the filter in the store (or adding it). See Replace.*/
+func (s *Store) Load(name string) error {
+	path, err := s.snapshotPath(name)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	return s.Replace(name, data)
+}
+
+/*LoadAll loads every snapshot in the snapshot directory and returns the names loaded. It carries on past failures and returns the first error.*/
+func (s *Store) LoadAll() ([]string, error) {
+	if s.dir == "" {
+		return nil, ErrNoDir
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var loaded []string
+	var first error
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), snapshotExt)
+		if !ok || entry.IsDir() || checkName(name) != nil {
+			continue
+		}
+		if err := s.Load(name); err != nil {
+			if first == nil {
+				first = fmt.Errorf("%s: %v", name, err)
+			}
+			continue
+		}
+		loaded = append(loaded, name)
+	}
+	return loaded, first
+}
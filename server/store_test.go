@@ -0,0 +1,213 @@
+package server
+
+import (
+	"fmt"
+	"github.com/iamthebot/hyperbloom"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	s := NewStore(t.TempDir())
+	assert.Nil(t, s.Create("users", hyperbloom.Spec{Kind: hyperbloom.KindStriped, Size: 1 << 16, Hashes: 4, Shards: 16}))
+	assert.Nil(t, s.CreateWithEstimates("sessions", hyperbloom.KindNaive, 1000, 0.01))
+	assert.Equal(t, ErrExists, s.Create("users", hyperbloom.Spec{Kind: hyperbloom.KindBloom, Size: 1 << 16, Hashes: 4}))
+	assert.Equal(t, []string{"sessions", "users"}, s.Names())
+
+	assert.Nil(t, s.Insert("users", "alice", "bob"))
+	results, err := s.Lookup("users", "alice", "bob", "carol")
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, true, false}, results)
+	assert.Equal(t, ErrNotFound, s.Insert("groups", "admins"))
+	_, err = s.Lookup("groups", "admins")
+	assert.Equal(t, ErrNotFound, err)
+
+	stats, err := s.Stats("users")
+	assert.Nil(t, err)
+	assert.Equal(t, Stats{Name: "users", Kind: "striped", Size: 1 << 16, Hashes: 4, Shards: 16, Hasher: "xxhash64(seed=0)",
+		PopCount: 8, FillRatio: stats.FillRatio, EstimatedCount: 2, EstimatedFalsePositiveRate: stats.EstimatedFalsePositiveRate}, stats)
+	assert.Equal(t, 8.0/(1<<16), stats.FillRatio)
+	spec, err := s.Spec("users")
+	assert.Nil(t, err)
+	assert.Equal(t, hyperbloom.Spec{Kind: hyperbloom.KindStriped, Size: 1 << 16, Hashes: 4, Shards: 16, Hasher: hyperbloom.DefaultHasher}, spec)
+
+	assert.Nil(t, s.Reset("users"))
+	results, err = s.Lookup("users", "alice", "bob")
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{false, false}, results)
+	assert.Equal(t, ErrNotFound, s.Reset("groups"))
+	_, err = s.Spec("groups")
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.Nil(t, s.Delete("sessions"))
+	assert.Equal(t, ErrNotFound, s.Delete("sessions"))
+	assert.Equal(t, []string{"users"}, s.Names())
+}
+
+func TestStoreRejects(t *testing.T) {
+	s := NewStore("")
+	for _, name := range []string{"", ".hidden", "a/b", "../up", "sp ace", string(make([]byte, 200))} {
+		assert.NotNil(t, s.Create(name, hyperbloom.Spec{Kind: hyperbloom.KindBloom, Size: 1 << 16, Hashes: 4}), "%q", name)
+	}
+	assert.NotNil(t, s.Create("counting", hyperbloom.Spec{Kind: hyperbloom.KindCounting, Size: 1 << 16, Hashes: 4}))
+	assert.NotNil(t, s.CreateWithEstimates("cuckoo", hyperbloom.KindCuckoo, 1000, 0.01))
+	assert.NotNil(t, s.Create("bad", hyperbloom.Spec{Kind: hyperbloom.KindBloom, Size: 1000, Hashes: 4}))
+	assert.Empty(t, s.Names())
+
+	assert.Nil(t, s.Create("ok", hyperbloom.Spec{Kind: hyperbloom.KindBloom, Size: 1 << 16, Hashes: 4}))
+	_, err := s.Snapshot("ok")
+	assert.Equal(t, ErrNoDir, err)
+	assert.Equal(t, ErrNoDir, s.Load("ok"))
+	_, err = s.LoadAll()
+	assert.Equal(t, ErrNoDir, err)
+}
+
+func TestStoreMaxFilterBytes(t *testing.T) {
+	s := NewStore("")
+	s.MaxFilterBytes = 1 << 20
+	for _, spec := range []hyperbloom.Spec{
+		{Kind: hyperbloom.KindBloom, Size: 1 << 45, Hashes: 3},
+		{Kind: hyperbloom.KindBloom, Size: 1 << 24, Hashes: 3},
+		{Kind: hyperbloom.KindNaive, Size: 1 << 21, Hashes: 3},
+		{Kind: hyperbloom.KindStriped, Size: 1 << 23, Hashes: 3, Shards: 1 << 23},
+		{Kind: hyperbloom.KindNaiveStriped, Size: 1 << 20, Hashes: 3, Shards: 1 << 62},
+	} {
+		assert.ErrorIs(t, s.Create("big", spec), ErrTooLarge, "%+v", spec)
+	}
+	assert.ErrorIs(t, s.CreateWithEstimates("big", hyperbloom.KindStriped, 100000000000000000, 0.01), ErrTooLarge)
+	assert.ErrorIs(t, s.CreateFrom("big", CreateRequest{Kind: "naive", N: 1000000, P: 0.01}), ErrTooLarge)
+	assert.Empty(t, s.Names())
+
+	assert.Nil(t, s.Create("bits", hyperbloom.Spec{Kind: hyperbloom.KindBloom, Size: 1 << 23, Hashes: 3}))
+	assert.Nil(t, s.Create("bytes", hyperbloom.Spec{Kind: hyperbloom.KindNaiveStriped, Size: 1 << 19, Hashes: 3, Shards: 64}))
+	assert.Nil(t, s.CreateWithEstimates("estimated", hyperbloom.KindStriped, 100000, 0.01))
+
+	s.MaxFilterBytes = 0
+	assert.Nil(t, s.Create("unlimited", hyperbloom.Spec{Kind: hyperbloom.KindBloom, Size: 1 << 24, Hashes: 3}))
+
+	//Serialized filters are held to the limit too
+	data, err := s.Export("unlimited")
+	assert.Nil(t, err)
+	s.MaxFilterBytes = 1 << 20
+	assert.ErrorIs(t, s.Replace("copy", data), ErrTooLarge)
+	assert.ErrorIs(t, s.Replace("unlimited", data), ErrTooLarge)
+	assert.Equal(t, []string{"bits", "bytes", "estimated", "unlimited"}, s.Names())
+}
+
+func TestStoreSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+	for i, kind := range []hyperbloom.Kind{hyperbloom.KindBloom, hyperbloom.KindStriped, hyperbloom.KindNaive, hyperbloom.KindNaiveStriped} {
+		name := kind.String()
+		assert.Nil(t, s.CreateWithEstimates(name, kind, 1000, 0.01))
+		assert.Nil(t, s.Insert(name, fmt.Sprint(i)))
+		path, err := s.Snapshot(name)
+		assert.Nil(t, err)
+		assert.Equal(t, filepath.Join(dir, name+".bloom"), path)
+	}
+	_, err := s.Snapshot("missing")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, ErrNotFound, s.Load("missing"))
+
+	//Loading replaces the filter with its snapshot
+	assert.Nil(t, s.Insert("bloom", "after"))
+	assert.Nil(t, s.Load("bloom"))
+	results, err := s.Lookup("bloom", "0", "after")
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, false}, results)
+
+	//Other kinds and stray files are skipped or reported
+	cbf, err := hyperbloom.NewCountingBloomFilter(1<<16, 4)
+	assert.Nil(t, err)
+	assert.Nil(t, cbf.Write(filepath.Join(dir, "counting.bloom")))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hello"), 0666))
+
+	fresh := NewStore(dir)
+	loaded, err := fresh.LoadAll()
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"bloom", "naive", "naivestriped", "striped"}, loaded)
+	for i, kind := range []hyperbloom.Kind{hyperbloom.KindBloom, hyperbloom.KindStriped, hyperbloom.KindNaive, hyperbloom.KindNaiveStriped} {
+		stats, err := fresh.Stats(kind.String())
+		assert.Nil(t, err)
+		assert.Equal(t, kind.String(), stats.Kind)
+		results, err := fresh.Lookup(kind.String(), fmt.Sprint(i))
+		assert.Nil(t, err)
+		assert.Equal(t, []bool{true}, results)
+	}
+	assert.Nil(t, fresh.SnapshotAll())
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 6)
+}
+
+func TestStoreExportMergeReplace(t *testing.T) {
+	s := NewStore("")
+	assert.Nil(t, s.CreateFrom("a", CreateRequest{Kind: "bloom", Size: 1 << 16, Hashes: 4}))
+	assert.Nil(t, s.CreateFrom("b", CreateRequest{Kind: "naive", Size: 1 << 16, Hashes: 4}))
+	assert.Nil(t, s.CreateFrom("small", CreateRequest{Kind: "bloom", N: 100}))
+	assert.NotNil(t, s.CreateFrom("c", CreateRequest{Kind: "bloom", N: 100, Size: 1 << 16}))
+	assert.NotNil(t, s.CreateFrom("c", CreateRequest{Kind: "quotient", N: 100}))
+	assert.NotNil(t, s.CreateFrom("c", CreateRequest{Kind: "bloom", Size: 1 << 16}))
+	assert.NotNil(t, s.CreateFrom("c", CreateRequest{Kind: "bloom", Size: 1 << 16, Hashes: -3}))
+	assert.NotNil(t, s.Create("c", hyperbloom.Spec{Kind: hyperbloom.KindBloom, Size: 1 << 16}))
+	assert.Nil(t, s.Insert("a", "alice"))
+	assert.Nil(t, s.Insert("b", "bob"))
+
+	data, err := s.Export("a")
+	assert.Nil(t, err)
+	assert.Nil(t, s.Merge("b", data))
+	results, err := s.Lookup("b", "alice", "bob")
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, true}, results)
+	assert.NotNil(t, s.Merge("small", data))
+	assert.Equal(t, ErrNotFound, s.Merge("missing", data))
+	_, err = s.Export("missing")
+	assert.Equal(t, ErrNotFound, err)
+
+	//Replacing takes the serialized filter's kind, and adds missing filters
+	assert.Nil(t, s.Replace("small", data))
+	assert.Nil(t, s.Replace("copy", data))
+	for _, name := range []string{"small", "copy"} {
+		stats, err := s.Stats(name)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1<<16), stats.Size)
+		results, err := s.Lookup(name, "alice", "bob")
+		assert.Nil(t, err)
+		assert.Equal(t, []bool{true, false}, results)
+	}
+	assert.NotNil(t, s.Replace(".hidden", data))
+	assert.NotNil(t, s.Replace("a", data[:len(data)-1]))
+}
+
+func TestStoreConcurrent(t *testing.T) {
+	s := NewStore(t.TempDir())
+	assert.Nil(t, s.CreateWithEstimates("shared", hyperbloom.KindStriped, 100000, 0.01))
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("%d-%d", w, i)
+				assert.Nil(t, s.Insert("shared", key))
+				results, err := s.Lookup("shared", key)
+				assert.Nil(t, err)
+				assert.True(t, results[0])
+				switch i % 50 {
+				case 0:
+					_, err := s.Snapshot("shared")
+					assert.Nil(t, err)
+				case 25:
+					name := fmt.Sprintf("scratch-%d", w)
+					assert.Nil(t, s.CreateWithEstimates(name, hyperbloom.KindBloom, 1000, 0.01))
+					s.Names()
+					assert.Nil(t, s.Delete(name))
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
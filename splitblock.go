@@ -0,0 +1,552 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+
+	XXHN "github.com/OneOfOne/xxhash"
+)
+
+/*
+Split block Bloom filters follow the Parquet format specification (BloomFilter.md in apache/parquet-format) bit for bit, so that they can be read from and written to Parquet files.
+The bitset is split into 256 bit blocks of eight 32 bit words. An entry's 64 bit xxhash picks a block from its high 32 bits, and its low 32 bits, multiplied by each of the salts, set one bit in each word of the block.
+*/
+const (
+	splitBlockBytes    = 32
+	splitBlockWords    = splitBlockBytes / 4
+	splitBlockMaxBytes = 128 * 1024 * 1024 //Largest filter parquet-mr writes
+)
+
+var splitBlockSalt = [splitBlockWords]uint32{
+	0x47b6137b, 0x44974d91, 0x8824ad5b, 0xa2b7289d,
+	0x705495c7, 0x2df1424b, 0x9efc4947, 0x5c6bfb31,
+}
+
+/*Returns the bit to set in each word of a block for the low 32 bits of an entry's hash.*/
+func splitBlockMask(key uint32) [splitBlockWords]uint32 {
+	var mask [splitBlockWords]uint32
+	for i, salt := range splitBlockSalt {
+		mask[i] = 1 << ((key * salt) >> 27)
+	}
+	return mask
+}
+
+/*ParquetHash returns the hash Parquet Bloom filters use for a value: xxhash64 (seed 0) of its plain encoding. For BYTE_ARRAY and FIXED_LEN_BYTE_ARRAY values that is the bytes themselves, without a length prefix.*/
+func ParquetHash(value []byte) uint64 {
+	return XXHN.Checksum64(value)
+}
+
+/*ParquetHashInt32 returns the Parquet Bloom filter hash of an INT32 value.*/
+func ParquetHashInt32(v int32) uint64 {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], uint32(v))
+	return XXHN.Checksum64(buf[:])
+}
+
+/*ParquetHashInt64 returns the Parquet Bloom filter hash of an INT64 value.*/
+func ParquetHashInt64(v int64) uint64 {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(v))
+	return XXHN.Checksum64(buf[:])
+}
+
+/*ParquetHashFloat32 returns the Parquet Bloom filter hash of a FLOAT value.*/
+func ParquetHashFloat32(v float32) uint64 {
+	return ParquetHashInt32(int32(math.Float32bits(v)))
+}
+
+/*ParquetHashFloat64 returns the Parquet Bloom filter hash of a DOUBLE value.*/
+func ParquetHashFloat64(v float64) uint64 {
+	return ParquetHashInt64(int64(math.Float64bits(v)))
+}
+
+/*
+SplitBlockBloomFilter is the split block Bloom filter used by Parquet for column chunks. It uses central locking via a RWMutex.
+Its hash function and layout are fixed by the Parquet specification, so unlike the other filters it takes no hash count or Hasher. Strings and byte slices are hashed as BYTE_ARRAY values and InsertUint64/LookupUint64 hash INT64 values; for other column types, hash values with the ParquetHash functions and use InsertHash and LookupHash.
+WriteTo, MarshalBinary and Write produce the Bloom filter as stored in a Parquet file (a Thrift BloomFilterHeader followed by the bitset), not the package's own format.
+*/
+type SplitBlockBloomFilter struct {
+	bitset []uint32      //splitBlockWords words per block
+	blocks uint64        //Number of blocks
+	mut    *sync.RWMutex //Centralized mutex
+}
+
+/*
+NewSplitBlockBloomFilter allocates a SplitBlockBloomFilter with a bitset of numBytes bytes.
+numBytes must be a positive multiple of 32 (one block). Parquet writers generally use a power of 2.
+*/
+func NewSplitBlockBloomFilter(numBytes int) (*SplitBlockBloomFilter, error) {
+	if numBytes < splitBlockBytes || numBytes%splitBlockBytes != 0 {
+		return nil, errors.New("Filter size must be a positive multiple of 32 bytes")
+	} else if numBytes > math.MaxInt32 {
+		return nil, errors.New("Filter size can't exceed 2^31-1 bytes")
+	}
+	var bf SplitBlockBloomFilter
+	bf.bitset = make([]uint32, numBytes/4)
+	bf.blocks = uint64(numBytes / splitBlockBytes)
+	bf.mut = &sync.RWMutex{}
+	return &bf, nil
+}
+
+/*
+NewSplitBlockBloomFilterWithEstimates allocates a SplitBlockBloomFilter for n distinct values at a target false positive rate p, sized the way parquet-mr sizes them: rounded up to a power of 2 between 32 bytes and 128MB.
+It also returns the false positive rate expected once n values have been inserted, which may exceed p if the size was capped.
+*/
+func NewSplitBlockBloomFilterWithEstimates(n uint64, p float64) (*SplitBlockBloomFilter, float64, error) {
+	if n == 0 {
+		return nil, 0, errors.New("Expected number of entries must be nonzero")
+	} else if !(p > 0 && p < 1) {
+		return nil, 0, errors.New("False positive rate must be between 0 and 1")
+	}
+	bits := -8 * float64(n) / math.Log(1-math.Pow(p, 1.0/8))
+	numBytes := uint64(splitBlockMaxBytes)
+	if bits/8 < splitBlockMaxBytes {
+		numBytes = max(splitBlockBytes, nextPowerOf2(uint64(math.Ceil(bits/8))))
+	}
+	bf, err := NewSplitBlockBloomFilter(int(numBytes))
+	if err != nil {
+		return nil, 0, err
+	}
+	return bf, splitBlockFalsePositiveRate(numBytes, n), nil
+}
+
+/*
+Returns the expected false positive rate of a split block filter of numBytes bytes holding n distinct values. Each value sets one bit in each of a block's 32 bit words, so a block holding load values answers a false positive with probability (1 - (31/32)^load)^8.
+*/
+func splitBlockFalsePositiveRate(numBytes uint64, n uint64) float64 {
+	return averageOverLoads(float64(n)/float64(numBytes/splitBlockBytes), func(load uint64) float64 {
+		return math.Pow(1-math.Pow(31.0/32, float64(load)), splitBlockWords)
+	})
+}
+
+/*Returns the block selected by a hash: the high 32 bits scaled to the number of blocks ("fastrange").*/
+func (bf SplitBlockBloomFilter) block(hash uint64) []uint32 {
+	start := ((hash >> 32) * bf.blocks) >> 32 * splitBlockWords
+	return bf.bitset[start : start+splitBlockWords : start+splitBlockWords]
+}
+
+func (bf SplitBlockBloomFilter) lookupHashAsync(hash uint64) bool {
+	block := bf.block(hash)
+	for i, m := range splitBlockMask(uint32(hash)) {
+		if block[i]&m == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+/*Sets a hash's bits and reports whether all of them were already set.*/
+func (bf SplitBlockBloomFilter) insertHashAsync(hash uint64) bool {
+	block := bf.block(hash)
+	present := true
+	for i, m := range splitBlockMask(uint32(hash)) {
+		if block[i]&m == 0 {
+			present = false
+			block[i] |= m
+		}
+	}
+	return present
+}
+
+/*LookupHash looks up a value by its hash (see ParquetHash). Takes a reader lock on the filter.*/
+func (bf SplitBlockBloomFilter) LookupHash(hash uint64) (bool, error) {
+	bf.mut.RLock()
+	defer bf.mut.RUnlock()
+	return bf.lookupHashAsync(hash), nil
+}
+
+/*InsertHash inserts a value by its hash (see ParquetHash). Locks the filter.*/
+func (bf SplitBlockBloomFilter) InsertHash(hash uint64) error {
+	bf.mut.Lock()
+	bf.insertHashAsync(hash)
+	bf.mut.Unlock()
+	return nil
+}
+
+/*Looks up a BYTE_ARRAY value in the SplitBlockBloomFilter. Returns true if a match is found, false otherwise. Takes a reader lock on the filter.*/
+func (bf SplitBlockBloomFilter) Lookup(entry string) (bool, error) {
+	return bf.LookupHash(XXHN.ChecksumString64(entry))
+}
+
+/*Looks up a BYTE_ARRAY value in the SplitBlockBloomFilter. Returns true if a match is found, false otherwise. This won't lock the filter.*/
+func (bf SplitBlockBloomFilter) LookupAsync(entry string) (bool, error) {
+	return bf.lookupHashAsync(XXHN.ChecksumString64(entry)), nil
+}
+
+/*LookupBytes is Lookup for a byte slice entry. It gives the same answer as Lookup(string(entry)) without converting or allocating.*/
+func (bf SplitBlockBloomFilter) LookupBytes(entry []byte) (bool, error) {
+	return bf.LookupHash(ParquetHash(entry))
+}
+
+/*LookupUint64 looks up an INT64 value.*/
+func (bf SplitBlockBloomFilter) LookupUint64(entry uint64) (bool, error) {
+	return bf.LookupHash(ParquetHashInt64(int64(entry)))
+}
+
+/*LookupBatch looks up every entry in entries and returns whether each one was found, in the same order. Takes the reader lock once for the whole batch.*/
+func (bf SplitBlockBloomFilter) LookupBatch(entries []string) ([]bool, error) {
+	hashes := make([]uint64, len(entries))
+	for i, entry := range entries {
+		hashes[i] = XXHN.ChecksumString64(entry)
+	}
+	results := make([]bool, len(entries))
+	bf.mut.RLock()
+	for i, hash := range hashes {
+		results[i] = bf.lookupHashAsync(hash)
+	}
+	bf.mut.RUnlock()
+	return results, nil
+}
+
+/*Inserts a BYTE_ARRAY value into the SplitBlockBloomFilter. Locks the filter.*/
+func (bf SplitBlockBloomFilter) Insert(entry string) error {
+	return bf.InsertHash(XXHN.ChecksumString64(entry))
+}
+
+/*Inserts a BYTE_ARRAY value into the SplitBlockBloomFilter. Doesn't lock the filter.*/
+func (bf SplitBlockBloomFilter) InsertAsync(entry string) error {
+	bf.insertHashAsync(XXHN.ChecksumString64(entry))
+	return nil
+}
+
+/*InsertBytes is Insert for a byte slice entry. It is equivalent to Insert(string(entry)) without converting or allocating.*/
+func (bf SplitBlockBloomFilter) InsertBytes(entry []byte) error {
+	return bf.InsertHash(ParquetHash(entry))
+}
+
+/*InsertUint64 inserts an INT64 value.*/
+func (bf SplitBlockBloomFilter) InsertUint64(entry uint64) error {
+	return bf.InsertHash(ParquetHashInt64(int64(entry)))
+}
+
+/*InsertBatch inserts every entry in entries. Takes the write lock once for the whole batch.*/
+func (bf SplitBlockBloomFilter) InsertBatch(entries []string) error {
+	hashes := make([]uint64, len(entries))
+	for i, entry := range entries {
+		hashes[i] = XXHN.ChecksumString64(entry)
+	}
+	bf.mut.Lock()
+	for _, hash := range hashes {
+		bf.insertHashAsync(hash)
+	}
+	bf.mut.Unlock()
+	return nil
+}
+
+/*
+TestAndInsert inserts entry and reports whether it was (probably) already present, as a single operation.
+Holds the write lock for the whole operation: of any number of concurrent TestAndInsert calls for the same entry, exactly one reports it absent.
+*/
+func (bf SplitBlockBloomFilter) TestAndInsert(entry string) (bool, error) {
+	hash := XXHN.ChecksumString64(entry)
+	bf.mut.Lock()
+	defer bf.mut.Unlock()
+	return bf.insertHashAsync(hash), nil
+}
+
+/*NumBytes returns the size of the filter's bitset in bytes.*/
+func (bf SplitBlockBloomFilter) NumBytes() int {
+	return len(bf.bitset) * 4
+}
+
+/*
+The Thrift compact protocol encoding of a BloomFilterHeader (parquet.thrift) with the only algorithm, hash and compression the specification defines: numBytes, then three unions each holding an empty struct in field 1 (BLOCK, XXHASH and UNCOMPRESSED).
+*/
+func splitBlockHeader(numBytes int) []byte {
+	h := []byte{0x15} //Field 1, i32
+	h = binary.AppendUvarint(h, uint64(uint32(int32(numBytes)<<1^int32(numBytes)>>31)))
+	for i := 0; i < 3; i++ {
+		h = append(h, 0x1c, 0x1c, 0x00, 0x00) //Next field, struct: field 1, struct: stop. Stop.
+	}
+	return append(h, 0x00)
+}
+
+/*
+Reads a BloomFilterHeader and returns its numBytes. Unknown fields are skipped, but the algorithm, hash and compression must be the ones the specification defines.
+*/
+func readSplitBlockHeader(r io.ByteReader) (int, error) {
+	numBytes := -1
+	algorithm, hash, compression := -1, -1, -1
+	err := readThriftStruct(r, func(id int16, typ byte) (bool, error) {
+		var union *int
+		switch {
+		case id == 1 && typ == thriftI32:
+			v, err := binary.ReadUvarint(r)
+			if err != nil {
+				return false, err
+			}
+			numBytes = int(int32(uint32(v)>>1) ^ -int32(v&1))
+			return true, nil
+		case id == 2 && typ == thriftStruct:
+			union = &algorithm
+		case id == 3 && typ == thriftStruct:
+			union = &hash
+		case id == 4 && typ == thriftStruct:
+			union = &compression
+		default:
+			return false, nil
+		}
+		return true, readThriftStruct(r, func(id int16, typ byte) (bool, error) {
+			*union = int(id)
+			return false, nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	if numBytes < splitBlockBytes || numBytes%splitBlockBytes != 0 {
+		return 0, fmt.Errorf("Invalid Bloom filter size %d", numBytes)
+	} else if algorithm != 1 || hash != 1 || compression != 1 {
+		return 0, fmt.Errorf("Unsupported Bloom filter (algorithm %d, hash %d, compression %d): only split block, xxhash and uncompressed are supported", algorithm, hash, compression)
+	}
+	return numBytes, nil
+}
+
+/*Thrift compact protocol type codes*/
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI16    = 4
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftDouble = 7
+	thriftBinary = 8
+	thriftList   = 9
+	thriftSet    = 10
+	thriftMap    = 11
+	thriftStruct = 12
+)
+
+/*
+Reads a Thrift compact protocol struct, calling field with the id and type of each field. field either reads the value and returns true, or returns false to have it skipped.
+*/
+func readThriftStruct(r io.ByteReader, field func(id int16, typ byte) (bool, error)) error {
+	var id int16
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		} else if b == 0 {
+			return nil
+		}
+		typ := b & 0x0f
+		if delta := int16(b >> 4); delta != 0 {
+			id += delta
+		} else {
+			v, err := binary.ReadUvarint(r)
+			if err != nil {
+				return err
+			}
+			id = int16(v>>1) ^ -int16(v&1)
+		}
+		read, err := field(id, typ)
+		if err != nil {
+			return err
+		} else if !read {
+			if err := skipThrift(r, typ); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func skipThrift(r io.ByteReader, typ byte) error {
+	switch typ {
+	case thriftTrue, thriftFalse:
+		return nil
+	case thriftByte:
+		_, err := r.ReadByte()
+		return err
+	case thriftI16, thriftI32, thriftI64:
+		_, err := binary.ReadUvarint(r)
+		return err
+	case thriftDouble:
+		return skipBytes(r, 8)
+	case thriftBinary:
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		return skipBytes(r, n)
+	case thriftList, thriftSet:
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		n := uint64(b >> 4)
+		if n == 15 {
+			if n, err = binary.ReadUvarint(r); err != nil {
+				return err
+			}
+		}
+		return skipThriftElements(r, n, b&0x0f)
+	case thriftMap:
+		n, err := binary.ReadUvarint(r)
+		if err != nil || n == 0 {
+			return err
+		}
+		types, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < n; i++ {
+			if err := skipThriftElements(r, 1, types>>4); err != nil {
+				return err
+			}
+			if err := skipThriftElements(r, 1, types&0x0f); err != nil {
+				return err
+			}
+		}
+		return nil
+	case thriftStruct:
+		return readThriftStruct(r, func(int16, byte) (bool, error) { return false, nil })
+	}
+	return fmt.Errorf("Unknown Thrift type %d", typ)
+}
+
+/*Skips n collection elements. Unlike struct fields, boolean elements take a byte each.*/
+func skipThriftElements(r io.ByteReader, n uint64, typ byte) error {
+	for i := uint64(0); i < n; i++ {
+		var err error
+		if typ == thriftTrue || typ == thriftFalse {
+			_, err = r.ReadByte()
+		} else {
+			err = skipThrift(r, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func skipBytes(r io.ByteReader, n uint64) error {
+	for i := uint64(0); i < n; i++ {
+		if _, err := r.ReadByte(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*Reads from an io.Reader a byte at a time, so that nothing past the header is consumed.*/
+type byteReader struct {
+	r   io.Reader
+	buf [1]byte
+}
+
+func (br *byteReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(br.r, br.buf[:]); err != nil {
+		return 0, err
+	}
+	return br.buf[0], nil
+}
+
+/*
+WriteTo writes the filter to w as stored in a Parquet file, a BloomFilterHeader followed by the bitset, and returns the number of bytes written. It implements io.WriterTo.
+Takes a reader lock on the filter while it is written.
+*/
+func (bf SplitBlockBloomFilter) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	if _, err := cw.Write(splitBlockHeader(bf.NumBytes())); err != nil {
+		return cw.n, err
+	}
+	bf.mut.RLock()
+	defer bf.mut.RUnlock()
+	buf := make([]byte, 0, 32*1024)
+	for _, word := range bf.bitset {
+		buf = binary.LittleEndian.AppendUint32(buf, word)
+		if len(buf) == cap(buf) {
+			if _, err := cw.Write(buf); err != nil {
+				return cw.n, err
+			}
+			buf = buf[:0]
+		}
+	}
+	_, err := cw.Write(buf)
+	return cw.n, err
+}
+
+/*
+Reads a Parquet Bloom filter from r, checking its size against numBytes unless it is negative. Returns the bitset.
+The size in the header isn't trusted for allocating: it is checked against what's left of a bytes.Reader, and other readers are read with readPayload.
+*/
+func readSplitBlock(r io.Reader, numBytes int) ([]byte, error) {
+	n, err := readSplitBlockHeader(&byteReader{r: r})
+	if err != nil {
+		return nil, err
+	} else if numBytes >= 0 && n != numBytes {
+		return nil, fmt.Errorf("File filter size: %d bytes. Specified size: %d bytes. Mismatch.", n, numBytes)
+	} else if br, ok := r.(*bytes.Reader); ok && n > br.Len() {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return readPayload(r, uint64(n))
+}
+
+/*
+ReadFrom merges the filter with a Parquet Bloom filter (a BloomFilterHeader followed by the bitset) read from r and returns the number of bytes read. It implements io.ReaderFrom.
+Exactly one filter is consumed from r, and it must have the same size. The filter is read in full before the filter is locked, once, for the merge.
+*/
+func (bf SplitBlockBloomFilter) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+	bitset, err := readSplitBlock(cr, bf.NumBytes())
+	if err != nil {
+		return cr.n, err
+	}
+	bf.merge(bitset)
+	return cr.n, nil
+}
+
+func (bf SplitBlockBloomFilter) merge(bitset []byte) {
+	bf.mut.Lock()
+	for i := range bf.bitset {
+		bf.bitset[i] |= binary.LittleEndian.Uint32(bitset[i*4:])
+	}
+	bf.mut.Unlock()
+}
+
+/*MarshalBinary returns the filter as stored in a Parquet file. It implements encoding.BinaryMarshaler (and so also works with encoding/gob).*/
+func (bf SplitBlockBloomFilter) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := bf.WriteTo(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+/*
+UnmarshalBinary replaces the filter with a Parquet Bloom filter (a BloomFilterHeader followed by the bitset), taking its size from data. It implements encoding.BinaryUnmarshaler.
+It must not be called concurrently with any other method.
+*/
+func (bf *SplitBlockBloomFilter) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	bitset, err := readSplitBlock(r, -1)
+	if err != nil {
+		return err
+	} else if r.Len() != 0 {
+		return errors.New("Trailing data after serialized filter")
+	}
+	fresh, err := NewSplitBlockBloomFilter(len(bitset))
+	if err != nil {
+		return err
+	}
+	fresh.merge(bitset)
+	*bf = *fresh
+	return nil
+}
+
+/*Writes the filter to a file as stored in a Parquet file. See WriteTo.*/
+func (bf SplitBlockBloomFilter) Write(filename string) error {
+	return writeFile(filename, bf.WriteTo)
+}
+
+/*Merges the filter with one loaded from a file. See ReadFrom.*/
+func (bf SplitBlockBloomFilter) Load(filename string) error {
+	return readFile(filename, bf.ReadFrom)
+}
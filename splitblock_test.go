@@ -0,0 +1,201 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestNewSplitBlockBloomFilter(t *testing.T) {
+	for _, numBytes := range []int{0, 16, 33, 1000} {
+		bf, err := NewSplitBlockBloomFilter(numBytes)
+		assert.NotNil(t, err)
+		assert.Nil(t, bf)
+	}
+	bf, err := NewSplitBlockBloomFilter(1024)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(32), bf.blocks)
+	assert.Equal(t, 1024, bf.NumBytes())
+}
+
+/*Sizes follow parquet-mr's BlockSplitBloomFilter.optimalNumOfBits, rounded up to a power of 2.*/
+func TestNewSplitBlockBloomFilterWithEstimates(t *testing.T) {
+	bf, fpRate, err := NewSplitBlockBloomFilterWithEstimates(1000000, 0.01)
+	assert.Nil(t, err)
+	assert.Equal(t, 2097152, bf.NumBytes())
+	assert.True(t, fpRate < 0.01, "fpRate %f", fpRate)
+
+	bf, _, err = NewSplitBlockBloomFilterWithEstimates(1, 0.5)
+	assert.Nil(t, err)
+	assert.Equal(t, 32, bf.NumBytes())
+
+	bf, fpRate, err = NewSplitBlockBloomFilterWithEstimates(1<<40, 0.01)
+	assert.Nil(t, err)
+	assert.Equal(t, splitBlockMaxBytes, bf.NumBytes())
+	assert.True(t, fpRate > 0.01)
+
+	_, _, err = NewSplitBlockBloomFilterWithEstimates(0, 0.01)
+	assert.NotNil(t, err)
+	_, _, err = NewSplitBlockBloomFilterWithEstimates(1000, 1)
+	assert.NotNil(t, err)
+}
+
+/*Test vectors for xxhash64 with seed 0, as used by the specification.*/
+func TestParquetHash(t *testing.T) {
+	assert.Equal(t, uint64(0xEF46DB3751D8E999), ParquetHash(nil))
+	assert.Equal(t, uint64(0x44BC2CF5AD770999), ParquetHash([]byte("abc")))
+
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(1<<40+7))
+	assert.Equal(t, ParquetHash(buf[:]), ParquetHashInt64(1<<40+7))
+	assert.Equal(t, ParquetHash(buf[:4]), ParquetHashInt32(7))
+	assert.Equal(t, ParquetHashInt32(0x3f800000), ParquetHashFloat32(1))
+	assert.Equal(t, ParquetHashInt64(0x3ff0000000000000), ParquetHashFloat64(1))
+}
+
+func TestSplitBlockMask(t *testing.T) {
+	for _, m := range splitBlockMask(0) {
+		assert.Equal(t, uint32(1), m)
+	}
+	assert.Equal(t, [8]uint32{0x100, 0x100, 0x20000, 0x100000, 0x4000, 0x20, 0x80000, 0x800}, splitBlockMask(1))
+	assert.Equal(t, [8]uint32{0x20000000, 0x8000, 0x1000, 0x4000, 0x2000, 0x2000000, 0x1000000, 0x200000}, splitBlockMask(0xdeadbeef))
+}
+
+func TestSplitBlockIndex(t *testing.T) {
+	bf, err := NewSplitBlockBloomFilter(1024)
+	assert.Nil(t, err)
+	assert.Equal(t, &bf.bitset[0], &bf.block(0x00000000ffffffff)[0])
+	assert.Equal(t, &bf.bitset[31*splitBlockWords], &bf.block(0xffffffff00000000)[0])
+	assert.Equal(t, &bf.bitset[16*splitBlockWords], &bf.block(0x8000000000000000)[0])
+}
+
+func TestSplitBlockBloomFilter(t *testing.T) {
+	bf, err := NewSplitBlockBloomFilter(8192)
+	assert.Nil(t, err)
+	for i := 0; i < 500; i++ {
+		assert.Nil(t, bf.Insert("entry"+strconv.Itoa(i)))
+	}
+	for i := 0; i < 500; i++ {
+		found, err := bf.Lookup("entry" + strconv.Itoa(i))
+		assert.Nil(t, err)
+		assert.True(t, found)
+		found, _ = bf.LookupBytes([]byte("entry" + strconv.Itoa(i)))
+		assert.True(t, found)
+		found, _ = bf.LookupHash(ParquetHash([]byte("entry" + strconv.Itoa(i))))
+		assert.True(t, found)
+	}
+	assert.Nil(t, bf.InsertUint64(42))
+	found, _ := bf.LookupHash(ParquetHashInt64(42))
+	assert.True(t, found)
+
+	present, err := bf.TestAndInsert("new")
+	assert.Nil(t, err)
+	assert.False(t, present)
+	present, _ = bf.TestAndInsert("new")
+	assert.True(t, present)
+
+	assert.Nil(t, bf.InsertBatch([]string{"a", "b"}))
+	results, err := bf.LookupBatch([]string{"a", "b"})
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, true}, results)
+}
+
+func TestSplitBlockSerialization(t *testing.T) {
+	assert.Equal(t, []byte{0x15, 0x80, 0x10, 0x1c, 0x1c, 0x00, 0x00, 0x1c, 0x1c, 0x00, 0x00, 0x1c, 0x1c, 0x00, 0x00, 0x00}, splitBlockHeader(1024))
+
+	//A one block filter holds exactly the mask of the inserted hash
+	bf, err := NewSplitBlockBloomFilter(32)
+	assert.Nil(t, err)
+	assert.Nil(t, bf.InsertHash(0xdeadbeef))
+	data, err := bf.MarshalBinary()
+	assert.Nil(t, err)
+	header := splitBlockHeader(32)
+	assert.Equal(t, header, data[:len(header)])
+	mask := splitBlockMask(0xdeadbeef)
+	for i, m := range mask {
+		assert.Equal(t, m, binary.LittleEndian.Uint32(data[len(header)+4*i:]))
+	}
+
+	bf, _ = NewSplitBlockBloomFilter(1024)
+	assert.Nil(t, bf.Insert("foo"))
+	data, err = bf.MarshalBinary()
+	assert.Nil(t, err)
+	var restored SplitBlockBloomFilter
+	assert.Nil(t, restored.UnmarshalBinary(data))
+	assert.Equal(t, bf.bitset, restored.bitset)
+	assert.NotNil(t, restored.UnmarshalBinary(append(data, 0)))
+	assert.NotNil(t, restored.UnmarshalBinary(data[:len(data)-1]))
+
+	other, _ := NewSplitBlockBloomFilter(1024)
+	assert.Nil(t, other.Insert("bar"))
+	n, err := other.ReadFrom(bytes.NewReader(data))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	found, _ := other.Lookup("foo")
+	assert.True(t, found)
+	found, _ = other.Lookup("bar")
+	assert.True(t, found)
+
+	small, _ := NewSplitBlockBloomFilter(512)
+	_, err = small.ReadFrom(bytes.NewReader(data))
+	assert.NotNil(t, err)
+
+	//Unsupported hash (field 2 of the hash union)
+	bad := append([]byte{}, data...)
+	bad[8] = 0x2c
+	assert.NotNil(t, restored.UnmarshalBinary(bad))
+}
+
+/*
+testdata/parquet-bloom.bin is written by testdata/parquet_bloom.py, an implementation of the specification that shares no code with this package, with the values of parquet-testing's bloom_filter.xxhash.bin.
+*/
+func TestSplitBlockParquetFixture(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "parquet-bloom.bin"))
+	assert.Nil(t, err)
+	var bf SplitBlockBloomFilter
+	assert.Nil(t, bf.UnmarshalBinary(data))
+	assert.Equal(t, 1024, bf.NumBytes())
+	for _, value := range []string{"hello", "parquet", "bloom", "filter"} {
+		found, _ := bf.Lookup(value)
+		assert.True(t, found, value)
+	}
+	found, _ := bf.Lookup("absent")
+	assert.False(t, found)
+
+	fresh, _ := NewSplitBlockBloomFilter(1024)
+	assert.Nil(t, fresh.InsertBatch([]string{"hello", "parquet", "bloom", "filter"}))
+	written, err := fresh.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, data, written)
+}
+
+/*Sizes in headers are checked against the data before anything is allocated for them.*/
+func TestSplitBlockTruncated(t *testing.T) {
+	header := splitBlockHeader(1 << 30)
+	var bf SplitBlockBloomFilter
+	assert.Equal(t, io.ErrUnexpectedEOF, bf.UnmarshalBinary(append(header, make([]byte, 64)...)))
+	_, err := readSplitBlock(io.MultiReader(bytes.NewReader(header), bytes.NewReader(make([]byte, 64))), -1)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+/*Fields the reader doesn't know about must be skipped, and known ones may use the long form field id.*/
+func TestSplitBlockHeaderUnknownFields(t *testing.T) {
+	header := []byte{
+		0x15, 0x80, 0x10, //Field 1, i32: 1024
+		0x58, 0x03, 'a', 'b', 'c', //Field 6, binary
+		0x19, 0x25, 0x02, 0x04, //Field 7, list of two i32s
+		0x3c, 0x11, 0x00, //Field 10, struct{field 1: true}
+		0x0c, 0x04, 0x1c, 0x00, 0x00, //Field 2 (long form), BLOCK
+		0x0c, 0x06, 0x1c, 0x00, 0x00, //Field 3 (long form), XXHASH
+		0x0c, 0x08, 0x1c, 0x00, 0x00, //Field 4 (long form), UNCOMPRESSED
+		0x00,
+	}
+	numBytes, err := readSplitBlockHeader(bytes.NewReader(header))
+	assert.Nil(t, err)
+	assert.Equal(t, 1024, numBytes)
+}
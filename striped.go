@@ -1,13 +1,10 @@
 package hyperbloom
 
 import (
-	"bufio"
 	"bytes"
 	"errors"
-	"fmt"
 	"io"
-	"log"
-	"os"
+	"slices"
 	"sync"
 )
 
@@ -19,15 +16,18 @@ type StripedBloomFilter struct {
 	size     uint64        //Size of bitvector. MUST BE A POWER OF 2.
 	shards   uint64        //Number of shards. size must be multiple of shards.
 	hf       int           //Number of hash functions
+	hasher   Hasher        //Produces the base hashes for each entry
 	mutArr   []*sync.Mutex //Mutex for each shard
 	shardLen uint64        //Precomputed number of bits per shard
+	mapping  *mappedFile   //File the bitvector is mapped from, if any
 }
 
-/*NewBloomfilter allocates a StripedBloomFilter with a given size (in bits) and using a certain number of hashes.
+/*
+NewBloomfilter allocates a StripedBloomFilter with a given size (in bits) and using a certain number of hashes.
 Size must be a power of 2 and larger than 64.
 Shards must be a power of 2 (smaller than size) and cannot exceed size/64.
 */
-func NewStripedBloomFilter(size uint64, hf int, shards uint64) (*StripedBloomFilter, error) {
+func NewStripedBloomFilter(size uint64, hf int, shards uint64, opts ...Option) (*StripedBloomFilter, error) {
 	var bf StripedBloomFilter
 	bf.size = size
 	bf.shards = shards
@@ -43,9 +43,12 @@ func NewStripedBloomFilter(size uint64, hf int, shards uint64) (*StripedBloomFil
 		return nil, errors.New("Shards cannot exceed size/64")
 	} else if bf.size%bf.shards != 0 {
 		return nil, errors.New("Size must be a multiple of shards")
+	} else if err := checkHashes(hf); err != nil {
+		return nil, err
 	}
 	bf.bv = make([]uint64, size/64)
 	bf.hf = int(hf)
+	bf.hasher = buildOptions(opts).hasher
 	bf.mutArr = make([]*sync.Mutex, shards)
 
 	for i := 0; i < int(shards); i++ {
@@ -60,6 +63,22 @@ func NewStripedBloomFilter(size uint64, hf int, shards uint64) (*StripedBloomFil
 	return &bf, nil
 }
 
+/*
+NewStripedBloomFilterWithEstimates allocates a StripedBloomFilter sized for n entries at a target false positive rate p (see EstimateParameters). The shard count is picked from GOMAXPROCS.
+It also returns the false positive rate expected once n entries have been inserted.
+*/
+func NewStripedBloomFilterWithEstimates(n uint64, p float64, opts ...Option) (*StripedBloomFilter, float64, error) {
+	size, hf, fpRate, err := EstimateParameters(n, p)
+	if err != nil {
+		return nil, 0, err
+	}
+	bf, err := NewStripedBloomFilter(size, hf, estimateShards(size), opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bf, fpRate, nil
+}
+
 func (bf StripedBloomFilter) setBit(idx uint64) error {
 	if idx > (bf.size - 1) {
 		return errors.New("Index can't be larger than filter size")
@@ -108,13 +127,39 @@ func (bf StripedBloomFilter) getBitAsync(idx uint64) (bool, error) {
 	return exists, nil
 }
 
-/*Looks up an entry in the StripedBloomFilter. Returns true if a match is found, false otherwise. If an error occurs, will also return false.
+/*
+Looks up an entry in the StripedBloomFilter. Returns true if a match is found, false otherwise. If an error occurs, will also return false.
 This perform a reader lock on the filter (writers must wait until all active readers finish).
 */
 func (bf StripedBloomFilter) Lookup(entry string) (bool, error) {
-	hashes := hashEntry([]byte(entry), bf.hf)
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2)
+}
+
+/*
+Looks up an entry in the StripedBloomFilter. Returns true if a match is found, false otherwise. If an error occurs, will also return false.
+This won't lock the filter.
+*/
+func (bf StripedBloomFilter) LookupAsync(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.lookupHashesAsync(h1, h2)
+}
+
+/*LookupBytes is Lookup for a byte slice entry. It gives the same answer as Lookup(string(entry)) without converting or allocating.*/
+func (bf StripedBloomFilter) LookupBytes(entry []byte) (bool, error) {
+	h1, h2 := baseHashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2)
+}
+
+/*LookupUint64 is Lookup for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (bf StripedBloomFilter) LookupUint64(entry uint64) (bool, error) {
+	h1, h2 := uint64Hashes(bf.hasher, entry)
+	return bf.lookupHashes(h1, h2)
+}
+
+func (bf StripedBloomFilter) lookupHashes(h1, h2 uint64) (bool, error) {
 	for i := 0; i < bf.hf; i++ {
-		lookup_idx := hashes[i] & (bf.size - 1)
+		lookup_idx := nthHash(h1, h2, i) & (bf.size - 1)
 		if exists, err := bf.getBit(lookup_idx); !exists {
 			if err != nil {
 				return false, err
@@ -125,13 +170,9 @@ func (bf StripedBloomFilter) Lookup(entry string) (bool, error) {
 	return true, nil
 }
 
-/*Looks up an entry in the StripedBloomFilter. Returns true if a match is found, false otherwise. If an error occurs, will also return false.
-This won't lock the filter.
-*/
-func (bf StripedBloomFilter) LookupAsync(entry string) (bool, error) {
-	hashes := hashEntry([]byte(entry), bf.hf)
+func (bf StripedBloomFilter) lookupHashesAsync(h1, h2 uint64) (bool, error) {
 	for i := 0; i < bf.hf; i++ {
-		lookup_idx := hashes[i] & (bf.size - 1)
+		lookup_idx := nthHash(h1, h2, i) & (bf.size - 1)
 		if exists, err := bf.getBitAsync(lookup_idx); !exists {
 			if err != nil {
 				return false, err
@@ -144,9 +185,31 @@ func (bf StripedBloomFilter) LookupAsync(entry string) (bool, error) {
 
 /*Inserts an entry into the StripedBloomFilter. Locks the filter.*/
 func (bf StripedBloomFilter) Insert(entry string) error {
-	hashes := hashEntry([]byte(entry), bf.hf)
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.insertHashes(h1, h2)
+}
+
+/*Inserts an entry into the StripedBloomFilter. Doesn't lock the filter.*/
+func (bf StripedBloomFilter) InsertAsync(entry string) error {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	return bf.insertHashesAsync(h1, h2)
+}
+
+/*InsertBytes is Insert for a byte slice entry. It is equivalent to Insert(string(entry)) without converting or allocating.*/
+func (bf StripedBloomFilter) InsertBytes(entry []byte) error {
+	h1, h2 := baseHashes(bf.hasher, entry)
+	return bf.insertHashes(h1, h2)
+}
+
+/*InsertUint64 is Insert for an integer entry, which is hashed as its 8 byte little endian encoding without allocating.*/
+func (bf StripedBloomFilter) InsertUint64(entry uint64) error {
+	h1, h2 := uint64Hashes(bf.hasher, entry)
+	return bf.insertHashes(h1, h2)
+}
+
+func (bf StripedBloomFilter) insertHashes(h1, h2 uint64) error {
 	for i := 0; i < bf.hf; i++ {
-		insert_idx := hashes[i] & (bf.size - 1)
+		insert_idx := nthHash(h1, h2, i) & (bf.size - 1)
 		err := bf.setBit(insert_idx)
 		if err != nil {
 			return err
@@ -155,11 +218,9 @@ func (bf StripedBloomFilter) Insert(entry string) error {
 	return nil
 }
 
-/*Inserts an entry into the StripedBloomFilter. Doesn't lock the filter.*/
-func (bf StripedBloomFilter) InsertAsync(entry string) error {
-	hashes := hashEntry([]byte(entry), bf.hf)
+func (bf StripedBloomFilter) insertHashesAsync(h1, h2 uint64) error {
 	for i := 0; i < bf.hf; i++ {
-		insert_idx := hashes[i] & (bf.size - 1)
+		insert_idx := nthHash(h1, h2, i) & (bf.size - 1)
 		err := bf.setBitAsync(insert_idx)
 		if err != nil {
 			return err
@@ -168,52 +229,335 @@ func (bf StripedBloomFilter) InsertAsync(entry string) error {
 	return nil
 }
 
-/*Writes underlying bit vector to a file.*/
-func (bf StripedBloomFilter) Write(filename string) error {
-	/*Writes bit vector to file*/
-	var err error
-	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
-	defer f.Close()
-	if err != nil {
+/*
+TestAndInsert inserts entry and reports whether it was (probably) already present, as a single operation. This replaces a racy Lookup followed by Insert and hashes the entry once.
+Every shard holding one of the entry's buckets is locked (in ascending order, so concurrent calls can't deadlock) for the whole operation. This makes it atomic with respect to other TestAndInsert calls: of any number of concurrent TestAndInsert calls for the same entry, exactly one reports it absent.
+It is not atomic with respect to Insert, InsertBatch or Load, which lock one shard at a time: racing with an Insert of the same entry, both may treat the entry as new.
+*/
+func (bf StripedBloomFilter) TestAndInsert(entry string) (bool, error) {
+	h1, h2 := stringHashes(bf.hasher, entry)
+	indices := make([]uint64, bf.hf)
+	for i := range indices {
+		indices[i] = nthHash(h1, h2, i) & (bf.size - 1)
+	}
+	shards := lockShards(bf.mutArr, indices, bf.shardLen)
+	defer unlockShards(bf.mutArr, shards)
+	present := true
+	for _, idx := range indices {
+		if bf.bv[idx/64]&(1<<(idx&63)) == 0 {
+			present = false
+			bf.bv[idx/64] |= 1 << (idx & 63)
+		}
+	}
+	return present, nil
+}
+
+/*
+InsertBatch inserts every entry in entries. Every entry is hashed first, then each shard touched by the batch is locked once.
+Inserts are not atomic as a whole: concurrent lookups may see some entries of the batch before others.
+*/
+func (bf StripedBloomFilter) InsertBatch(entries []string) error {
+	indices := batchIndices(bf.hasher, entries, bf.hf, bf.size)
+	order, offsets := groupByShard(indices, bf.shardLen, bf.shards)
+	for s := uint64(0); s < bf.shards; s++ {
+		if offsets[s] == offsets[s+1] {
+			continue
+		}
+		bf.mutArr[s].Lock()
+		for _, pos := range order[offsets[s]:offsets[s+1]] {
+			idx := indices[pos]
+			bf.bv[idx/64] |= 1 << (idx & 63)
+		}
+		bf.mutArr[s].Unlock()
+	}
+	return nil
+}
+
+/*
+LookupBatch looks up every entry in entries and returns whether each one was found, in the same order. Every entry is hashed first, then each shard touched by the batch is locked once.
+*/
+func (bf StripedBloomFilter) LookupBatch(entries []string) ([]bool, error) {
+	indices := batchIndices(bf.hasher, entries, bf.hf, bf.size)
+	order, offsets := groupByShard(indices, bf.shardLen, bf.shards)
+	results := allPresent(len(entries))
+	for s := uint64(0); s < bf.shards; s++ {
+		if offsets[s] == offsets[s+1] {
+			continue
+		}
+		bf.mutArr[s].Lock()
+		for _, pos := range order[offsets[s]:offsets[s+1]] {
+			if !results[pos/bf.hf] {
+				continue //Already known to be absent
+			}
+			idx := indices[pos]
+			if bf.bv[idx/64]&(1<<(idx&63)) == 0 {
+				results[pos/bf.hf] = false
+			}
+		}
+		bf.mutArr[s].Unlock()
+	}
+	return results, nil
+}
+
+/*Hasher returns the hasher the filter was built with.*/
+func (bf StripedBloomFilter) Hasher() Hasher {
+	return bf.hasher
+}
+
+/*Spec returns the Spec describing the filter, from which NewFilter builds an empty filter like it.*/
+func (bf StripedBloomFilter) Spec() Spec {
+	return Spec{Kind: KindStriped, Size: bf.size, Hashes: bf.hf, Shards: bf.shards, Hasher: bf.hasher}
+}
+
+/*PopCount returns the number of bits set in the filter. Locks one shard at a time, so concurrent inserts may be counted in some shards but not others.*/
+func (bf StripedBloomFilter) PopCount() uint64 {
+	n := uint64(0)
+	wordsPerShard := bf.shardLen / 64
+	for s := uint64(0); s < bf.shards; s++ {
+		bf.mutArr[s].Lock()
+		n += popCountWords(bf.bv[s*wordsPerShard : (s+1)*wordsPerShard])
+		bf.mutArr[s].Unlock()
+	}
+	return n
+}
+
+/*FillRatio returns the fraction of the filter's bits that are set.*/
+func (bf StripedBloomFilter) FillRatio() float64 {
+	return float64(bf.PopCount()) / float64(bf.size)
+}
+
+/*
+EstimatedCount returns an estimate of the number of distinct entries inserted into the filter, from the number of bits set (Swamidass & Baldi). It returns math.MaxUint64 once every bit is set, as the filter is then saturated.
+*/
+func (bf StripedBloomFilter) EstimatedCount() uint64 {
+	return estimateCount(bf.size, bf.hf, bf.PopCount())
+}
+
+/*EstimatedFalsePositiveRate returns the filter's current false positive rate, estimated from its fill ratio (FillRatio()^hf).*/
+func (bf StripedBloomFilter) EstimatedFalsePositiveRate() float64 {
+	return fillFalsePositiveRate(bf.size, bf.hf, bf.PopCount())
+}
+
+/*
+UnionWith sets every bit that is set in other, so the filter holds every entry of either. other must have the same size, hash count and hasher, but may have a different shard count.
+Works a stripe at a time: each stripe of other is copied under its locks, then the matching stripe of the filter is locked and updated. No two locks are held at once, so concurrent calls can't deadlock.
+*/
+func (bf StripedBloomFilter) UnionWith(other *StripedBloomFilter) error {
+	return bf.combine(other, orWords)
+}
+
+/*
+IntersectWith clears every bit that isn't set in other, so the filter only matches entries that both filters match. other must have the same size, hash count and hasher, but may have a different shard count. Locks as UnionWith does.
+The result has at least the false positive rate of a filter built from the common entries alone, as it keeps bits that other entries set in both filters.
+*/
+func (bf StripedBloomFilter) IntersectWith(other *StripedBloomFilter) error {
+	return bf.combine(other, andWords)
+}
+
+func (bf StripedBloomFilter) combine(other *StripedBloomFilter, op func(dst, src []uint64)) error {
+	if err := checkCombinable(bf.size, bf.hf, bf.hasher, other.size, other.hf, other.hasher); err != nil {
 		return err
 	}
-	var b bytes.Buffer
-	for i := 0; i < int(bf.size); i++ {
-		bin := fmt.Sprintf("%b", bf.bv[i])
-		b.Write([]byte(bin))
-	}
-	bv := b.Bytes()
-	log.Println("Writing bit vector to file...")
-	f.Write(bv)
-	f.Close()
-	log.Printf("Successfully wrote bitvector to file: %s\n", filename)
+	wordsPerShard := bf.shardLen / 64
+	stripe := make([]uint64, wordsPerShard)
+	for s := uint64(0); s < bf.shards; s++ {
+		other.copyStripe(s*wordsPerShard, stripe)
+		bf.mutArr[s].Lock()
+		op(bf.bv[s*wordsPerShard:(s+1)*wordsPerShard], stripe)
+		bf.mutArr[s].Unlock()
+	}
 	return nil
 }
 
-/*Merges current bit vector with one loaded from a file.
-This locks the filter for each nonzero byte being written.
+/*Copies the filter's words from start into dst, locking each shard they span in turn.*/
+func (bf StripedBloomFilter) copyStripe(start uint64, dst []uint64) {
+	wordsPerShard := bf.shardLen / 64
+	end := start + uint64(len(dst))
+	for i := start; i < end; {
+		s := i / wordsPerShard
+		next := min((s+1)*wordsPerShard, end)
+		bf.mutArr[s].Lock()
+		copy(dst[i-start:], bf.bv[i:next])
+		bf.mutArr[s].Unlock()
+		i = next
+	}
+}
+
+/*Union returns a new filter with the same shard count holding every entry of either filter, leaving both unchanged. See UnionWith.*/
+func (bf StripedBloomFilter) Union(other *StripedBloomFilter) (*StripedBloomFilter, error) {
+	if err := checkCombinable(bf.size, bf.hf, bf.hasher, other.size, other.hf, other.hasher); err != nil {
+		return nil, err
+	}
+	fresh := bf.Clone()
+	return fresh, fresh.UnionWith(other)
+}
+
+/*Intersect returns a new filter with the same shard count matching the entries both filters match, leaving both unchanged. See IntersectWith.*/
+func (bf StripedBloomFilter) Intersect(other *StripedBloomFilter) (*StripedBloomFilter, error) {
+	if err := checkCombinable(bf.size, bf.hf, bf.hasher, other.size, other.hf, other.hasher); err != nil {
+		return nil, err
+	}
+	fresh := bf.Clone()
+	return fresh, fresh.IntersectWith(other)
+}
+
+/*
+EstimatedIntersectionCount estimates the number of entries inserted into both filters, from the bits set in each and in their union. other must have the same size, hash count and hasher. Locks as UnionWith does.
+It returns math.MaxUint64 if the union of the filters is saturated.
 */
-func (bf StripedBloomFilter) Load(filename string) error {
-	/*Loads bit vector from file*/
-	f, err := os.Open(filename)
-	defer f.Close()
+func (bf StripedBloomFilter) EstimatedIntersectionCount(other *StripedBloomFilter) (uint64, error) {
+	if err := checkCombinable(bf.size, bf.hf, bf.hasher, other.size, other.hf, other.hasher); err != nil {
+		return 0, err
+	}
+	popA, popB, popU := uint64(0), uint64(0), uint64(0)
+	wordsPerShard := bf.shardLen / 64
+	stripe := make([]uint64, wordsPerShard)
+	for s := uint64(0); s < bf.shards; s++ {
+		other.copyStripe(s*wordsPerShard, stripe)
+		bf.mutArr[s].Lock()
+		a, b, u := unionPopCountWords(bf.bv[s*wordsPerShard:(s+1)*wordsPerShard], stripe)
+		bf.mutArr[s].Unlock()
+		popA, popB, popU = popA+a, popB+b, popU+u
+	}
+	return estimateIntersection(bf.size, bf.hf, popA, popB, popU), nil
+}
+
+/*Reset clears every bit in the filter, leaving it empty with the same parameters. Locks one shard at a time.*/
+func (bf StripedBloomFilter) Reset() {
+	wordsPerShard := bf.shardLen / 64
+	for s := uint64(0); s < bf.shards; s++ {
+		bf.mutArr[s].Lock()
+		clear(bf.bv[s*wordsPerShard : (s+1)*wordsPerShard])
+		bf.mutArr[s].Unlock()
+	}
+}
+
+/*Clone returns a deep copy of the filter, with its own bit vector and mutexes. Locks one shard at a time while it is copied.*/
+func (bf StripedBloomFilter) Clone() *StripedBloomFilter {
+	clone := bf
+	clone.mapping = nil
+	clone.bv = make([]uint64, len(bf.bv))
+	bf.copyStripe(0, clone.bv)
+	clone.mutArr = make([]*sync.Mutex, bf.shards)
+	for i := range clone.mutArr {
+		clone.mutArr[i] = &sync.Mutex{}
+	}
+	return &clone
+}
+
+/*
+Equal reports whether other has the same size, hash count, hasher and bits as the filter. The shard counts may differ. Compares a stripe at a time, locking as UnionWith does.
+*/
+func (bf StripedBloomFilter) Equal(other *StripedBloomFilter) bool {
+	if checkCombinable(bf.size, bf.hf, bf.hasher, other.size, other.hf, other.hasher) != nil {
+		return false
+	}
+	wordsPerShard := bf.shardLen / 64
+	stripe := make([]uint64, wordsPerShard)
+	for s := uint64(0); s < bf.shards; s++ {
+		other.copyStripe(s*wordsPerShard, stripe)
+		bf.mutArr[s].Lock()
+		equal := slices.Equal(bf.bv[s*wordsPerShard:(s+1)*wordsPerShard], stripe)
+		bf.mutArr[s].Unlock()
+		if !equal {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+WriteTo writes the filter to w in the package's binary format (see serialization.go) and returns the number of bytes written. It implements io.WriterTo.
+Locks one shard at a time while it is written, so concurrent inserts may be captured in some shards but not others.
+*/
+func (bf StripedBloomFilter) WriteTo(w io.Writer) (int64, error) {
+	h := newFileHeader(KindStriped, bf.size, bf.hf, bf.shards, bf.hasher)
+	return writeFilter(w, h, func(w io.Writer) error {
+		wordsPerShard := bf.shardLen / 64
+		for s := uint64(0); s < bf.shards; s++ {
+			bf.mutArr[s].Lock()
+			err := writeWords(w, bf.bv[s*wordsPerShard:(s+1)*wordsPerShard])
+			bf.mutArr[s].Unlock()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+/*
+ReadFrom merges the filter with a serialized one read from r and returns the number of bytes read. It implements io.ReaderFrom.
+Exactly one serialized filter is consumed from r. It may have been written by any variant but must have the same size, hash count and hasher.
+The filter is read and verified in full before the merge, which locks each shard once.
+*/
+func (bf StripedBloomFilter) ReadFrom(r io.Reader) (int64, error) {
+	h, payload, n, err := readFilter(r, func(h fileHeader) error {
+		return h.checkCompatible(KindStriped, bf.size, bf.hf, bf.hasher)
+	})
 	if err != nil {
-		return err
+		return n, err
+	}
+	bf.merge(h, payload)
+	return n, nil
+}
+
+func (bf StripedBloomFilter) merge(h fileHeader, payload []byte) {
+	words := payloadWords(h, payload)
+	wordsPerShard := bf.shardLen / 64
+	for s := uint64(0); s < bf.shards; s++ {
+		bf.mutArr[s].Lock()
+		for i := s * wordsPerShard; i < (s+1)*wordsPerShard; i++ {
+			bf.bv[i] |= words[i]
+		}
+		bf.mutArr[s].Unlock()
+	}
+}
+
+/*MarshalBinary returns the filter in the package's binary format. It implements encoding.BinaryMarshaler (and so also works with encoding/gob).*/
+func (bf StripedBloomFilter) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := bf.WriteTo(&b); err != nil {
+		return nil, err
 	}
+	return b.Bytes(), nil
+}
 
-	buf_rdr := bufio.NewReader(f)
-	bv, err := buf_rdr.ReadBytes('\n')
-	if err != nil && err != io.EOF {
+/*
+UnmarshalBinary replaces the filter with a serialized one, taking its size, hash count and hasher from data. It implements encoding.BinaryUnmarshaler.
+The filter's current hasher is kept if it matches the serialized one (which is the only way to unmarshal a filter using a keyed hasher). It must not be called concurrently with any other method. Memory mapped filters can't be unmarshalled into (merge into them with ReadFrom).
+*/
+func (bf *StripedBloomFilter) UnmarshalBinary(data []byte) error {
+	if bf.mapping != nil {
+		return errUnmarshalMapped
+	}
+	h, payload, err := unmarshalFilter(data, KindStriped)
+	if err != nil {
 		return err
 	}
-	log.Println("Bytes read successfully")
-	bf.size = uint64(len(bv) - 1)
-	bf.bv = make([]uint64, bf.size-1)
-	for i := 0; i < len(bv); i++ {
-		if bv[i] > 0 {
-			bf.setBit(uint64(i))
-		}
+	hasher, err := h.resolveHasher(bf.hasher)
+	if err != nil {
+		return err
+	}
+	shards := h.shards
+	if !h.kind.striped() {
+		shards = estimateShards(h.size)
 	}
-	fmt.Printf("Loaded bitvector from file: %s\n", filename)
+	fresh, err := NewStripedBloomFilter(h.size, h.hf, shards, WithHasher(hasher))
+	if err != nil {
+		return err
+	}
+	fresh.merge(h, payload)
+	*bf = *fresh
 	return nil
 }
+
+/*Writes the filter to a file. See WriteTo.*/
+func (bf StripedBloomFilter) Write(filename string) error {
+	return writeFile(filename, bf.WriteTo)
+}
+
+/*Merges the filter with one loaded from a file. See ReadFrom.*/
+func (bf StripedBloomFilter) Load(filename string) error {
+	return readFile(filename, bf.ReadFrom)
+}
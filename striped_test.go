@@ -79,3 +79,18 @@ func TestStripedBloomFilter(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, false, fake4Exists)
 }
+
+func TestNewStripedBloomFilterWithEstimates(t *testing.T) {
+	bf, fpRate, err := NewStripedBloomFilterWithEstimates(1000000, 0.01)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1<<24), bf.size)
+	assert.Equal(t, 12, bf.hf)
+	assert.Equal(t, estimateShards(bf.size), bf.shards)
+	assert.Equal(t, bf.size/bf.shards, bf.shardLen)
+	assert.True(t, fpRate <= 0.01)
+
+	//Small filters cap the shard count at size/64
+	bf, _, err = NewStripedBloomFilterWithEstimates(10, 0.1)
+	assert.Nil(t, err)
+	assert.True(t, bf.shards <= bf.size/64)
+}
@@ -0,0 +1,112 @@
+#!/usr/bin/env python3
+"""
+Writes parquet-bloom.bin, a Parquet Bloom filter holding the BYTE_ARRAY values
+"hello", "parquet", "bloom" and "filter" (the values of the bloom_filter.xxhash.bin
+fixture in apache/parquet-testing), in a 1024 byte bitset.
+
+It follows BloomFilter.md in apache/parquet-format and the Thrift compact protocol
+directly, and shares no code with the Go implementation, so that
+TestSplitBlockParquetFixture checks the package against the specification rather
+than against itself.
+"""
+
+import struct
+import sys
+
+MASK64 = (1 << 64) - 1
+P1 = 0x9E3779B185EBCA87
+P2 = 0xC2B2AE3D27D4EB4F
+P3 = 0x165667B19E3779F9
+P4 = 0x85EBCA77C2B2AE63
+P5 = 0x27D4EB2F165667C5
+
+
+def rotl(x, r):
+    return ((x << r) | (x >> (64 - r))) & MASK64
+
+
+def xxh64_round(acc, lane):
+    acc = (acc + lane * P2) & MASK64
+    return (rotl(acc, 31) * P1) & MASK64
+
+
+def xxh64_merge(acc, val):
+    acc ^= xxh64_round(0, val)
+    return (acc * P1 + P4) & MASK64
+
+
+def xxh64(data, seed=0):
+    n = len(data)
+    i = 0
+    if n >= 32:
+        v = [(seed + P1 + P2) & MASK64, (seed + P2) & MASK64, seed, (seed - P1) & MASK64]
+        while i + 32 <= n:
+            for j in range(4):
+                v[j] = xxh64_round(v[j], struct.unpack_from("<Q", data, i + 8 * j)[0])
+            i += 32
+        h = (rotl(v[0], 1) + rotl(v[1], 7) + rotl(v[2], 12) + rotl(v[3], 18)) & MASK64
+        for lane in v:
+            h = xxh64_merge(h, lane)
+    else:
+        h = (seed + P5) & MASK64
+    h = (h + n) & MASK64
+    while i + 8 <= n:
+        h ^= xxh64_round(0, struct.unpack_from("<Q", data, i)[0])
+        h = (rotl(h, 27) * P1 + P4) & MASK64
+        i += 8
+    if i + 4 <= n:
+        h ^= (struct.unpack_from("<I", data, i)[0] * P1) & MASK64
+        h = (rotl(h, 23) * P2 + P3) & MASK64
+        i += 4
+    while i < n:
+        h ^= (data[i] * P5) & MASK64
+        h = (rotl(h, 11) * P1) & MASK64
+        i += 1
+    h ^= h >> 33
+    h = (h * P2) & MASK64
+    h ^= h >> 29
+    h = (h * P3) & MASK64
+    return h ^ (h >> 32)
+
+
+SALT = [0x47B6137B, 0x44974D91, 0x8824AD5B, 0xA2B7289D, 0x705495C7, 0x2DF1424B, 0x9EFC4947, 0x5C6BFB31]
+
+
+def insert(words, h):
+    blocks = len(words) // 8
+    block = ((h >> 32) * blocks) >> 32
+    key = h & 0xFFFFFFFF
+    for i in range(8):
+        y = (key * SALT[i]) & 0xFFFFFFFF
+        words[block * 8 + i] |= 1 << (y >> 27)
+
+
+def header(num_bytes):
+    """BloomFilterHeader{1: numBytes, 2: BLOCK, 3: XXHASH, 4: UNCOMPRESSED} in the compact protocol."""
+    out = bytearray([0x15])  # field 1, i32
+    z = ((num_bytes << 1) ^ (num_bytes >> 31)) & 0xFFFFFFFF
+    while z >= 0x80:
+        out.append(z & 0x7F | 0x80)
+        z >>= 7
+    out.append(z)
+    for _ in range(3):
+        out += bytes([0x1C, 0x1C, 0x00, 0x00])  # next field, struct: {field 1, struct: {}}
+    out.append(0x00)
+    return bytes(out)
+
+
+def main():
+    num_bytes = 1024
+    words = [0] * (num_bytes // 4)
+    for value in [b"hello", b"parquet", b"bloom", b"filter"]:
+        insert(words, xxh64(value))
+    out = header(num_bytes) + struct.pack("<%dI" % len(words), *words)
+    path = sys.argv[1] if len(sys.argv) > 1 else "parquet-bloom.bin"
+    with open(path, "wb") as f:
+        f.write(out)
+
+
+if __name__ == "__main__":
+    assert xxh64(b"") == 0xEF46DB3751D8E999
+    assert xxh64(b"abc") == 0x44BC2CF5AD770999
+    main()
@@ -0,0 +1,251 @@
+package hyperbloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func staticKeys(prefix string, n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%s-%d", prefix, i)
+	}
+	return keys
+}
+
+/*
+Checks a static filter built from 100000 keys: no false negatives, a false positive rate close to 1/256, at most maxBits bits per key, and the same answers from every lookup method.
+*/
+func checkStaticFilter(t *testing.T, q Querier, size int, maxBits float64) {
+	members := staticKeys("member", 100000)
+	for _, m := range members {
+		exists, err := q.Lookup(m)
+		assert.Nil(t, err)
+		if !exists {
+			t.Fatalf("%s not found", m)
+		}
+	}
+	results, err := q.LookupBatch(members[:100])
+	assert.Nil(t, err)
+	for _, r := range results {
+		assert.True(t, r)
+	}
+	exists, _ := q.LookupBytes([]byte(members[7]))
+	assert.True(t, exists)
+	exists, _ = q.LookupAsync(members[7])
+	assert.True(t, exists)
+
+	const probes = 400000
+	fp := 0
+	for _, p := range staticKeys("probe", probes) {
+		if exists, _ := q.Lookup(p); exists {
+			fp++
+		}
+	}
+	measured := float64(fp) / probes
+	assert.InDelta(t, 1.0/256, measured, 0.15/256, "measured %f", measured)
+	assert.True(t, float64(size*8)/float64(len(members)) < maxBits, "%f bits per key", float64(size*8)/float64(len(members)))
+}
+
+func TestXorFilter(t *testing.T) {
+	xf, err := BuildXorFilter(staticKeys("member", 100000))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(100000), xf.Count())
+	checkStaticFilter(t, xf, len(xf.fingerprints), 10)
+}
+
+func TestXorFilterSmall(t *testing.T) {
+	//Duplicates are ignored, and integer keys are their little endian encoding
+	xf, err := BuildXorFilter([]string{"foo", "bar", "foo", "\x2a\x00\x00\x00\x00\x00\x00\x00"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), xf.Count())
+	for _, k := range []string{"foo", "bar"} {
+		exists, err := xf.Lookup(k)
+		assert.Nil(t, err)
+		assert.True(t, exists)
+	}
+	exists, err := xf.LookupUint64(42)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+
+	xf, err = BuildXorFilter(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), xf.Count())
+	_, err = xf.Lookup("foo")
+	assert.Nil(t, err)
+}
+
+/*The same keys, in any order, must always build the same filter.*/
+func TestStaticDeterministic(t *testing.T) {
+	keys := staticKeys("member", 1000)
+	reversed := make([]string, len(keys))
+	for i, k := range keys {
+		reversed[len(keys)-1-i] = k
+	}
+	for _, build := range []func([]string, ...Option) (Querier, error){buildXor, buildBinaryFuse} {
+		a, err := build(keys)
+		assert.Nil(t, err)
+		b, err := build(reversed)
+		assert.Nil(t, err)
+		assert.Equal(t, a, b)
+	}
+}
+
+func buildXor(keys []string, opts ...Option) (Querier, error) {
+	return BuildXorFilter(keys, opts...)
+}
+
+func buildBinaryFuse(keys []string, opts ...Option) (Querier, error) {
+	return BuildBinaryFuseFilter(keys, opts...)
+}
+
+/*The serialized filters in testdata must be reproduced exactly, and read back into working filters.*/
+func TestStaticGoldenFiles(t *testing.T) {
+	xf, err := BuildXorFilter(goldenEntries)
+	assert.Nil(t, err)
+	bff, err := BuildBinaryFuseFilter(goldenEntries)
+	assert.Nil(t, err)
+	for name, f := range map[string]interface {
+		MarshalBinary() ([]byte, error)
+	}{"xor": xf, "binaryfuse": bff} {
+		got, err := f.MarshalBinary()
+		assert.Nil(t, err)
+		golden := filepath.Join("testdata", name+".golden")
+		if *update {
+			assert.Nil(t, os.WriteFile(golden, got, 0666))
+		}
+		want, err := os.ReadFile(golden)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got, name)
+	}
+
+	var loadedXor XorFilter
+	assert.Nil(t, loadedXor.Load(filepath.Join("testdata", "xor.golden")))
+	var loadedFuse BinaryFuseFilter
+	assert.Nil(t, loadedFuse.Load(filepath.Join("testdata", "binaryfuse.golden")))
+	for _, e := range goldenEntries {
+		exists, _ := loadedXor.Lookup(e)
+		assert.True(t, exists)
+		exists, _ = loadedFuse.Lookup(e)
+		assert.True(t, exists)
+	}
+	assert.Equal(t, *xf, loadedXor)
+	assert.Equal(t, *bff, loadedFuse)
+
+	//Static filters only load into their own kind
+	assert.NotNil(t, loadedXor.Load(filepath.Join("testdata", "binaryfuse.golden")))
+	assert.NotNil(t, loadedFuse.Load(filepath.Join("testdata", "xor.golden")))
+	bf, err := NewBloomFilter(1024, 3)
+	assert.Nil(t, err)
+	assert.NotNil(t, bf.Load(filepath.Join("testdata", "xor.golden")))
+	assert.NotNil(t, loadedXor.Load(filepath.Join("testdata", "bloom.golden")))
+}
+
+func TestStaticSerialization(t *testing.T) {
+	h := NewMurmur3Hasher(9)
+	xf, err := BuildXorFilter(staticKeys("member", 500), WithHasher(h))
+	assert.Nil(t, err)
+	var stream bytes.Buffer
+	n, err := xf.WriteTo(&stream)
+	assert.Nil(t, err)
+	data := bytes.Clone(stream.Bytes())
+	var restored XorFilter
+	m, err := restored.ReadFrom(&stream)
+	assert.Nil(t, err)
+	assert.Equal(t, n, m)
+	assert.Equal(t, h, restored.Hasher())
+	exists, _ := restored.Lookup("member-499")
+	assert.True(t, exists)
+
+	//Trailing data and inconsistent layouts are rejected
+	assert.NotNil(t, restored.UnmarshalBinary(append(bytes.Clone(data), 0)))
+	bad := bytes.Clone(data)
+	bad[headerLen+16]++
+	assert.NotNil(t, restored.UnmarshalBinary(bad))
+
+	assert.Nil(t, restored.UnmarshalBinary(data))
+	exists, _ = restored.Lookup("member-0")
+	assert.True(t, exists)
+}
+
+func TestStaticRejectsHugeSizes(t *testing.T) {
+	xf, err := BuildXorFilter(staticKeys("member", 100))
+	assert.Nil(t, err)
+	bff, err := BuildBinaryFuseFilter(staticKeys("member", 100))
+	assert.Nil(t, err)
+	for _, f := range []interface {
+		MarshalBinary() ([]byte, error)
+		ReadFrom(io.Reader) (int64, error)
+		UnmarshalBinary([]byte) error
+	}{xf, bff} {
+		data, err := f.MarshalBinary()
+		assert.Nil(t, err)
+		//A size whose payload length wraps around gets a complete, checksummed filter
+		for _, size := range []uint64{1 << 62, 1 << 33, math.MaxUint64 - 15} {
+			b := bytes.Clone(data[:headerLen])
+			binary.LittleEndian.PutUint64(b[16:], size)
+			binary.LittleEndian.PutUint64(b[40:], staticParamsLen+size)
+			if staticParamsLen+size < size {
+				b = append(b, make([]byte, staticParamsLen+size)...)
+				b = binary.LittleEndian.AppendUint32(b, crc32.Checksum(b, crcTable))
+			}
+			_, err := f.ReadFrom(bytes.NewReader(b))
+			assert.NotNil(t, err, "size %d", size)
+			assert.NotNil(t, f.UnmarshalBinary(b), "size %d", size)
+		}
+		exists, _ := f.(Querier).Lookup("member-0")
+		assert.True(t, exists)
+	}
+}
+
+func TestNewFilterRejectsStatic(t *testing.T) {
+	for _, kind := range []Kind{KindXor, KindBinaryFuse} {
+		f, err := NewFilter(Spec{Kind: kind, Size: 1024, Hashes: 3})
+		assert.NotNil(t, err)
+		assert.True(t, f == nil)
+		f, _, err = NewFilterWithEstimates(kind, 1000, 0.01)
+		assert.NotNil(t, err)
+		assert.True(t, f == nil)
+	}
+}
+
+func TestStaticConcurrentLookups(t *testing.T) {
+	keys := staticKeys("member", 10000)
+	bff, err := BuildBinaryFuseFilter(keys)
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, k := range keys {
+				if exists, _ := bff.Lookup(k); !exists {
+					t.Errorf("%s not found", k)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkBuildStatic(b *testing.B) {
+	keys := staticKeys("member", 1000000)
+	for name, build := range map[string]func([]string, ...Option) (Querier, error){"xor": buildXor, "binaryfuse": buildBinaryFuse} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := build(keys); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}